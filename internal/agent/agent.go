@@ -2,16 +2,24 @@ package agent
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
+	"github.com/yourusername/hbf-agent/internal/audit"
 	"github.com/yourusername/hbf-agent/internal/config"
 	"github.com/yourusername/hbf-agent/internal/firewall"
 	"github.com/yourusername/hbf-agent/internal/health"
+	"github.com/yourusername/hbf-agent/internal/logging"
 	"github.com/yourusername/hbf-agent/internal/metrics"
+	"github.com/yourusername/hbf-agent/internal/secrets"
 	"github.com/yourusername/hbf-agent/internal/servicemesh"
+	"github.com/yourusername/hbf-agent/internal/tracing"
 	"github.com/yourusername/hbf-agent/internal/api"
+	"golang.org/x/sync/errgroup"
 )
 
 // Agent represents the main HBF agent
@@ -24,10 +32,18 @@ type Agent struct {
 	healthCheck *health.Checker
 	metrics     *metrics.Manager
 	apiServer   *api.Server
+	grpcServer  *api.GRPCServer
+	healthSrv   *healthServer
+	election    *servicemesh.LeaderElection
+	reaper      *criticalReaper
+	svcChecks   *serviceChecks
+	
+	shutdownTracing func(context.Context) error
+	audit           *audit.Logger
 	
 	mu          sync.RWMutex
 	running     bool
-	stopChan    chan struct{}
+	cancel      context.CancelFunc // cancels the context of the components
 }
 
 // New creates a new agent instance
@@ -37,14 +53,41 @@ func New(cfg *config.Config, log *logrus.Logger) (*Agent, error) {
 	}
 	
 	if log == nil {
-		log = logrus.New()
+		configured, err := logging.New(cfg.Log)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure logging: %w", err)
+		}
+		log = configured
 	}
+	logging.WithFields(log, logrus.Fields{
+		"node_id":    cfg.Agent.NodeID,
+		"datacenter": cfg.Agent.Datacenter,
+	})
 	
 	agent := &Agent{
-		config:   cfg,
-		log:      log,
-		stopChan: make(chan struct{}),
+		config: cfg,
+		log:    log,
+	}
+	
+	// Resolve token references before anything reads the tokens; an
+	// unresolvable reference fails closed
+	if err := secrets.NewResolver(cfg.Security.Vault).ResolveConfig(context.Background(), cfg); err != nil {
+		return nil, fmt.Errorf("failed to resolve secrets: %w", err)
+	}
+	
+	// Initialize tracing; a no-op unless an OTLP endpoint is configured
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Monitoring, cfg.Agent.NodeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up tracing: %w", err)
 	}
+	agent.shutdownTracing = shutdownTracing
+	
+	// Initialize audit log; nil when disabled
+	auditLog, err := audit.New(cfg.Security.Audit, log)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	agent.audit = auditLog
 	
 	// Initialize firewall manager
 	fwManager, err := firewall.NewManager(cfg.Firewall, log)
@@ -52,6 +95,7 @@ func New(cfg *config.Config, log *logrus.Logger) (*Agent, error) {
 		return nil, fmt.Errorf("failed to create firewall manager: %w", err)
 	}
 	agent.firewall = fwManager
+	fwManager.SetAuditLog(auditLog)
 	
 	// Initialize service mesh manager if enabled
 	if cfg.ServiceMesh.Enabled {
@@ -60,27 +104,105 @@ func New(cfg *config.Config, log *logrus.Logger) (*Agent, error) {
 			return nil, fmt.Errorf("failed to create service mesh manager: %w", err)
 		}
 		agent.serviceMesh = smManager
+		smManager.SetAuditLog(auditLog)
+		agent.reaper = newCriticalReaper(smManager, log)
 	}
 	
 	// Initialize health checker
-	healthChecker := health.NewChecker(log)
+	healthChecker := health.NewChecker(cfg.Health, log)
 	agent.healthCheck = healthChecker
 	
+	// Derive health checks from the HealthCheck of registered services
+	if agent.serviceMesh != nil {
+		agent.svcChecks = newServiceChecks(agent.serviceMesh, healthChecker, agent.reaper, log)
+	}
+	
 	// Initialize metrics manager
 	metricsManager := metrics.NewManager(cfg.Monitoring, log)
 	agent.metrics = metricsManager
 	
+	if agent.serviceMesh != nil {
+		agent.serviceMesh.SetMetrics(metricsManager)
+	}
+	healthChecker.SetMetrics(metricsManager)
+	fwManager.SetMetrics(metricsManager)
+	
 	// Initialize API server
 	apiServer, err := api.NewServer(cfg, agent.firewall, agent.serviceMesh, log)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create API server: %w", err)
 	}
 	agent.apiServer = apiServer
+	apiServer.SetHealthChecker(healthChecker)
+	apiServer.SetMetrics(metricsManager)
+	apiServer.SetMetricsHandler(metricsManager.Handler())
+	apiServer.SetConfigSource(agent.Config)
+	apiServer.SetStateStore(agent)
+	
+	apiServer.RegisterComponent("firewall", agent.firewall)
+	if agent.serviceMesh != nil {
+		apiServer.RegisterComponent("service_mesh", agent.serviceMesh)
+	}
+	apiServer.RegisterComponent("health", agent.healthCheck)
+	apiServer.RegisterComponent("metrics", agent.metrics)
+	
+	// Initialize the health probe server
+	agent.healthSrv = newHealthServer(cfg.Monitoring, agent.IsRunning, log)
+	agent.healthSrv.register("firewall", agent.firewall)
+	if agent.serviceMesh != nil {
+		agent.healthSrv.register("service_mesh", agent.serviceMesh)
+	}
+	agent.healthSrv.register("health", agent.healthCheck)
+	agent.healthSrv.register("metrics", agent.metrics)
+	
+	// Initialize gRPC API server if enabled
+	if cfg.Agent.GRPCPort != 0 {
+		grpcServer, err := api.NewGRPCServer(cfg, agent.firewall, agent.serviceMesh, log)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gRPC server: %w", err)
+		}
+		agent.grpcServer = grpcServer
+	}
+	
+	// Initialize leader election if enabled
+	if cfg.Agent.LeaderElection.Enabled && agent.serviceMesh != nil {
+		election, err := servicemesh.NewLeaderElection(cfg.Agent.LeaderElection,
+			agent.serviceMesh.Discovery(), cfg.Agent.NodeID, log)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create leader election: %w", err)
+		}
+		agent.election = election
+		
+		election.OnChange(metricsManager.SetLeader)
+		agent.serviceMesh.SetLeaderCheck(election.IsLeader)
+		apiServer.SetLeaderCheck(election.IsLeader)
+		if agent.grpcServer != nil {
+			agent.grpcServer.SetLeaderCheck(election.IsLeader)
+		}
+	} else {
+		metricsManager.SetLeader(true)
+	}
 	
 	return agent, nil
 }
 
-// Start starts the agent and all its components
+// component is an agent component with a start/stop lifecycle
+type component struct {
+	name  string
+	start func(ctx context.Context) error
+	stop  func() error
+}
+
+// Start starts the agent and all its components.
+//
+// The firewall is started first, followed by the service mesh, health
+// checker and metrics manager in parallel. If any component fails to start,
+// the components that already started are stopped in reverse order before
+// the error is returned, so a failed Start never leaves a half-initialized
+// agent behind.
+//
+// The components run with a context derived from ctx, and Start blocks
+// until ctx is cancelled or Stop is called.
 func (a *Agent) Start(ctx context.Context) error {
 	a.mu.Lock()
 	if a.running {
@@ -88,35 +210,64 @@ func (a *Agent) Start(ctx context.Context) error {
 		return fmt.Errorf("agent is already running")
 	}
 	a.running = true
+	ctx, cancel := context.WithCancel(ctx)
+	a.cancel = cancel
 	a.mu.Unlock()
 	
 	a.log.Info("Starting agent components...")
 	
-	// Start firewall manager
-	if err := a.firewall.Start(ctx); err != nil {
-		return fmt.Errorf("failed to start firewall manager: %w", err)
+	// Serve the health probes first so that liveness is reported while
+	// the components start
+	if err := a.healthSrv.start(); err != nil {
+		cancel()
+		a.mu.Lock()
+		a.running = false
+		a.mu.Unlock()
+		return fmt.Errorf("failed to start health server: %w", err)
+	}
+	
+	stages := [][]component{
+		{
+			{name: "firewall manager", start: a.firewall.Start, stop: a.firewall.Stop},
+		},
+		{
+			{name: "health checker", start: a.healthCheck.Start, stop: a.healthCheck.Stop},
+			{name: "metrics manager", start: a.metrics.Start, stop: a.metrics.Stop},
+		},
 	}
-	a.log.Info("Firewall manager started")
 	
 	// Start service mesh manager if enabled
 	if a.serviceMesh != nil {
-		if err := a.serviceMesh.Start(ctx); err != nil {
-			return fmt.Errorf("failed to start service mesh manager: %w", err)
-		}
-		a.log.Info("Service mesh manager started")
+		stages[1] = append(stages[1], component{
+			name:  "service mesh manager",
+			start: a.serviceMesh.Start,
+			stop:  a.serviceMesh.Stop,
+		})
+	}
+	
+	// Campaign for leadership once discovery is available
+	if a.election != nil {
+		stages = append(stages, []component{
+			{name: "leader election", start: a.election.Start, stop: a.election.Stop},
+		})
 	}
 	
-	// Start health checker
-	if err := a.healthCheck.Start(ctx); err != nil {
-		return fmt.Errorf("failed to start health checker: %w", err)
+	var started []component
+	for _, stage := range stages {
+		if err := a.startStage(ctx, stage, &started); err != nil {
+			a.rollback(started)
+			a.healthSrv.stop()
+			cancel()
+			a.mu.Lock()
+			a.running = false
+			a.mu.Unlock()
+			return err
+		}
 	}
-	a.log.Info("Health checker started")
 	
-	// Start metrics manager
-	if err := a.metrics.Start(ctx); err != nil {
-		return fmt.Errorf("failed to start metrics manager: %w", err)
+	if a.svcChecks != nil {
+		a.svcChecks.start(ctx)
 	}
-	a.log.Info("Metrics manager started")
 	
 	// Start API server
 	go func() {
@@ -126,12 +277,59 @@ func (a *Agent) Start(ctx context.Context) error {
 	}()
 	a.log.Info("API server started")
 	
-	// Wait for context cancellation
+	if a.grpcServer != nil {
+		go func() {
+			if err := a.grpcServer.Start(); err != nil {
+				a.log.Errorf("gRPC server error: %v", err)
+			}
+		}()
+		a.log.Info("gRPC server started")
+	}
+	
+	// Wait for context cancellation or Stop
 	<-ctx.Done()
 	
 	return nil
 }
 
+// startStage starts independent components concurrently and waits for all
+// of them. Components that started successfully are appended to started even
+// when another component in the stage fails, so that they can be rolled back.
+func (a *Agent) startStage(ctx context.Context, stage []component, started *[]component) error {
+	var (
+		g  errgroup.Group
+		mu sync.Mutex
+	)
+	
+	for _, c := range stage {
+		c := c
+		g.Go(func() error {
+			if err := c.start(ctx); err != nil {
+				return fmt.Errorf("failed to start %s: %w", c.name, err)
+			}
+			a.log.Infof("Started %s", c.name)
+			
+			mu.Lock()
+			*started = append(*started, c)
+			mu.Unlock()
+			return nil
+		})
+	}
+	
+	return g.Wait()
+}
+
+// rollback stops started components in reverse start order
+func (a *Agent) rollback(started []component) {
+	a.log.Warnf("Agent startup failed, stopping %d started components", len(started))
+	
+	for i := len(started) - 1; i >= 0; i-- {
+		if err := started[i].stop(); err != nil {
+			a.log.Errorf("Failed to stop %s during rollback: %v", started[i].name, err)
+		}
+	}
+}
+
 // Stop stops the agent and all its components
 func (a *Agent) Stop() error {
 	a.mu.Lock()
@@ -140,17 +338,43 @@ func (a *Agent) Stop() error {
 		return fmt.Errorf("agent is not running")
 	}
 	a.running = false
+	cancel := a.cancel
 	a.mu.Unlock()
 	
 	a.log.Info("Stopping agent components...")
 	
 	var errors []error
 	
+	// Resign leadership before tearing anything down
+	if a.election != nil {
+		if err := a.election.Stop(); err != nil {
+			errors = append(errors, fmt.Errorf("failed to stop leader election: %w", err))
+		}
+	}
+	
+	if a.svcChecks != nil {
+		a.svcChecks.stop()
+	}
+	
+	if a.reaper != nil {
+		a.reaper.stop()
+	}
+	
 	// Stop API server
 	if err := a.apiServer.Stop(); err != nil {
 		errors = append(errors, fmt.Errorf("failed to stop API server: %w", err))
 	}
 	
+	if a.grpcServer != nil {
+		if err := a.grpcServer.Stop(); err != nil {
+			errors = append(errors, fmt.Errorf("failed to stop gRPC server: %w", err))
+		}
+	}
+	
+	if err := a.healthSrv.stop(); err != nil {
+		errors = append(errors, err)
+	}
+	
 	// Stop metrics manager
 	if err := a.metrics.Stop(); err != nil {
 		errors = append(errors, fmt.Errorf("failed to stop metrics manager: %w", err))
@@ -173,7 +397,20 @@ func (a *Agent) Stop() error {
 		errors = append(errors, fmt.Errorf("failed to stop firewall manager: %w", err))
 	}
 	
-	close(a.stopChan)
+	// Flush buffered spans
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := a.shutdownTracing(ctx); err != nil {
+		errors = append(errors, fmt.Errorf("failed to shut down tracing: %w", err))
+	}
+	
+	if err := a.audit.Close(); err != nil {
+		errors = append(errors, fmt.Errorf("failed to close audit log: %w", err))
+	}
+	
+	// Release Start, which waits for the context; the components have
+	// stopped their loops already
+	cancel()
 	
 	if len(errors) > 0 {
 		return fmt.Errorf("errors during shutdown: %v", errors)
@@ -182,6 +419,78 @@ func (a *Agent) Stop() error {
 	return nil
 }
 
+// ErrRestartRequired is returned by Reload when the new configuration
+// changes settings that cannot be applied to a running agent
+var ErrRestartRequired = errors.New("restart required")
+
+// Reload applies a new configuration to the running agent without
+// restarting it. Firewall rules are added and removed, the load balancing
+// strategy and resilience settings are switched, metrics are toggled and
+// logging is reconfigured, all while the data plane keeps serving.
+//
+// If the new configuration changes settings that can only take effect on
+// restart, such as the firewall backend, listener addresses or API
+// authentication, nothing is applied and an error wrapping
+// ErrRestartRequired lists them.
+func (a *Agent) Reload(newCfg *config.Config) error {
+	if newCfg == nil {
+		return fmt.Errorf("config is required")
+	}
+	
+	if err := newCfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	
+	if err := secrets.NewResolver(newCfg.Security.Vault).ResolveConfig(context.Background(), newCfg); err != nil {
+		return fmt.Errorf("failed to resolve secrets: %w", err)
+	}
+	
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	
+	if changed := newCfg.RestartRequired(a.config); len(changed) > 0 {
+		return fmt.Errorf("%w: %s changed", ErrRestartRequired, strings.Join(changed, ", "))
+	}
+	
+	a.log.Info("Reloading agent configuration...")
+	
+	var errs []error
+	
+	if err := a.firewall.Reload(newCfg.Firewall); err != nil {
+		errs = append(errs, fmt.Errorf("failed to reload firewall manager: %w", err))
+	}
+	
+	if a.serviceMesh != nil {
+		if err := a.serviceMesh.Reload(newCfg.ServiceMesh); err != nil {
+			errs = append(errs, fmt.Errorf("failed to reload service mesh manager: %w", err))
+		}
+	}
+	
+	if err := a.metrics.Reload(newCfg.Monitoring); err != nil {
+		errs = append(errs, fmt.Errorf("failed to reload metrics manager: %w", err))
+	}
+	
+	if err := a.healthSrv.reload(newCfg.Monitoring); err != nil {
+		errs = append(errs, fmt.Errorf("failed to reload health server: %w", err))
+	}
+	
+	if newCfg.Log != a.config.Log {
+		if err := logging.Configure(a.log, newCfg.Log); err != nil {
+			errs = append(errs, fmt.Errorf("failed to reconfigure logging: %w", err))
+		}
+	}
+	
+	// Keep the previous configuration unless every component took the new
+	// one, so that later reloads and diffs compare against what was applied
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	
+	a.config = newCfg
+	a.log.Info("Agent configuration reloaded")
+	return nil
+}
+
 // IsRunning returns whether the agent is currently running
 func (a *Agent) IsRunning() bool {
 	a.mu.RLock()
@@ -189,6 +498,55 @@ func (a *Agent) IsRunning() bool {
 	return a.running
 }
 
+// IsLeader reports whether this agent is the cluster leader. Without leader
+// election every agent considers itself the leader.
+func (a *Agent) IsLeader() bool {
+	if a.election == nil {
+		return true
+	}
+	return a.election.IsLeader()
+}
+
+// OnLeadershipChange registers a callback invoked with the new role whenever
+// this agent gains or loses leadership. It is never called when leader
+// election is disabled. Callbacks must not block.
+func (a *Agent) OnLeadershipChange(fn func(leader bool)) {
+	if a.election != nil {
+		a.election.OnChange(fn)
+	}
+}
+
+// LinkServiceCheck ties a health check to a registered service. When the
+// service's HealthCheck sets DeregisterCriticalServiceAfter and the check
+// stays critical for that long, the service is deregistered; recovering in
+// time cancels the deregistration.
+func (a *Agent) LinkServiceCheck(serviceID, checkID string) error {
+	if a.serviceMesh == nil {
+		return fmt.Errorf("service mesh is not enabled")
+	}
+	
+	service, err := a.serviceMesh.GetService(serviceID)
+	if err != nil {
+		return err
+	}
+	
+	if service.HealthCheck == nil || service.HealthCheck.DeregisterCriticalServiceAfter <= 0 {
+		return nil
+	}
+	after := service.HealthCheck.DeregisterCriticalServiceAfter
+	
+	return a.healthCheck.SetCallback(checkID, func(status health.CheckStatus) {
+		a.reaper.observe(serviceID, after, status)
+	})
+}
+
+// Config returns the configuration currently in effect
+func (a *Agent) Config() *config.Config {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.config
+}
+
 // GetFirewallManager returns the firewall manager
 func (a *Agent) GetFirewallManager() *firewall.Manager {
 	return a.firewall
@@ -0,0 +1,187 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourusername/hbf-agent/internal/config"
+)
+
+// healthComponent is an agent component that can report its health
+type healthComponent interface {
+	Healthy() error
+}
+
+// healthServer serves unauthenticated liveness and readiness probes on
+// monitoring.health_port, independent of the API server and its mTLS and
+// auth settings. GET <health_path> reports liveness from the agent running
+// state; GET <health_path>/ready reports readiness from component health.
+type healthServer struct {
+	config     config.MonitoringConfig
+	log        *logrus.Logger
+	live       func() bool
+	components map[string]healthComponent
+	server     *http.Server
+	mu         sync.Mutex
+	running    bool
+}
+
+func newHealthServer(cfg config.MonitoringConfig, live func() bool, log *logrus.Logger) *healthServer {
+	return &healthServer{
+		config:     cfg,
+		log:        log,
+		live:       live,
+		components: make(map[string]healthComponent),
+	}
+}
+
+// register adds a component to the readiness check. Components must be
+// registered before start is called.
+func (h *healthServer) register(name string, component healthComponent) {
+	h.components[name] = component
+}
+
+// start binds the health listener when monitoring is enabled
+func (h *healthServer) start() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	
+	h.running = true
+	if !h.config.Enabled {
+		return nil
+	}
+	return h.serveLocked()
+}
+
+// stop shuts the health listener down
+func (h *healthServer) stop() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	
+	h.running = false
+	return h.closeLocked()
+}
+
+// reload applies a new monitoring configuration, restarting the listener
+// when the health endpoint is enabled, disabled or moved
+func (h *healthServer) reload(cfg config.MonitoringConfig) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	
+	restart := cfg.Enabled != h.config.Enabled ||
+		cfg.HealthPort != h.config.HealthPort ||
+		cfg.HealthPath != h.config.HealthPath
+	h.config = cfg
+	
+	if !h.running || !restart {
+		return nil
+	}
+	
+	if err := h.closeLocked(); err != nil {
+		return err
+	}
+	if !cfg.Enabled {
+		return nil
+	}
+	return h.serveLocked()
+}
+
+// serveLocked binds the health listener and serves it in the background.
+// Callers must hold h.mu.
+func (h *healthServer) serveLocked() error {
+	mux := http.NewServeMux()
+	mux.HandleFunc(h.config.HealthPath, h.handleLive)
+	mux.HandleFunc(h.config.HealthPath+"/ready", h.handleReady)
+	
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", h.config.HealthPort),
+		Handler: mux,
+	}
+	
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", server.Addr, err)
+	}
+	
+	h.server = server
+	h.log.Infof("Health server listening on %s%s", server.Addr, h.config.HealthPath)
+	
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			h.log.Errorf("Health server error: %v", err)
+		}
+	}()
+	
+	return nil
+}
+
+// closeLocked shuts the health server down. Callers must hold h.mu.
+func (h *healthServer) closeLocked() error {
+	if h.server == nil {
+		return nil
+	}
+	
+	server := h.server
+	h.server = nil
+	if err := server.Close(); err != nil {
+		return fmt.Errorf("failed to stop health server: %w", err)
+	}
+	
+	return nil
+}
+
+// handleLive reports whether the agent is running. Components are not
+// checked, so a failing dependency doesn't get the agent restarted.
+func (h *healthServer) handleLive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	
+	if !h.live() {
+		h.writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "stopped"})
+		return
+	}
+	h.writeJSON(w, http.StatusOK, map[string]string{"status": "alive"})
+}
+
+// handleReady reports whether all components are healthy
+func (h *healthServer) handleReady(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	
+	ready := h.live()
+	components := make(map[string]string, len(h.components))
+	for name, component := range h.components {
+		if err := component.Healthy(); err != nil {
+			ready = false
+			components[name] = err.Error()
+			continue
+		}
+		components[name] = "healthy"
+	}
+	
+	status, code := "ready", http.StatusOK
+	if !ready {
+		status, code = "not_ready", http.StatusServiceUnavailable
+	}
+	h.writeJSON(w, code, map[string]interface{}{
+		"status":     status,
+		"components": components,
+	})
+}
+
+func (h *healthServer) writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		h.log.Errorf("Failed to encode JSON response: %v", err)
+	}
+}
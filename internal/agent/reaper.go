@@ -0,0 +1,85 @@
+package agent
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourusername/hbf-agent/internal/health"
+	"github.com/yourusername/hbf-agent/internal/servicemesh"
+)
+
+// criticalReaper deregisters services whose health check stays critical for
+// longer than their DeregisterCriticalServiceAfter
+type criticalReaper struct {
+	mesh   *servicemesh.Manager
+	log    *logrus.Logger
+	timers map[string]*time.Timer
+	mu     sync.Mutex
+}
+
+func newCriticalReaper(mesh *servicemesh.Manager, log *logrus.Logger) *criticalReaper {
+	return &criticalReaper{
+		mesh:   mesh,
+		log:    log,
+		timers: make(map[string]*time.Timer),
+	}
+}
+
+// observe handles a check result for a service. A critical result starts the
+// deregistration timer if it isn't already running; any other result
+// cancels it.
+func (r *criticalReaper) observe(serviceID string, after time.Duration, status health.CheckStatus) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	
+	timer, pending := r.timers[serviceID]
+	
+	if status != health.StatusCritical {
+		if pending {
+			timer.Stop()
+			delete(r.timers, serviceID)
+			r.log.Infof("Service %s recovered, cancelled critical deregistration", serviceID)
+		}
+		return
+	}
+	
+	if pending {
+		return
+	}
+	
+	r.log.Warnf("Service %s is critical, deregistering in %s unless it recovers", serviceID, after)
+	r.timers[serviceID] = time.AfterFunc(after, func() {
+		r.mu.Lock()
+		delete(r.timers, serviceID)
+		r.mu.Unlock()
+		
+		if err := r.mesh.DeregisterService(serviceID); err != nil {
+			r.log.Errorf("Failed to deregister critical service %s: %v", serviceID, err)
+			return
+		}
+		r.log.Warnf("Deregistered service %s after being critical for %s", serviceID, after)
+	})
+}
+
+// forget cancels the pending deregistration of a service that went away
+func (r *criticalReaper) forget(serviceID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	
+	if timer, pending := r.timers[serviceID]; pending {
+		timer.Stop()
+		delete(r.timers, serviceID)
+	}
+}
+
+// stop cancels all pending deregistrations
+func (r *criticalReaper) stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	
+	for serviceID, timer := range r.timers {
+		timer.Stop()
+		delete(r.timers, serviceID)
+	}
+}
@@ -0,0 +1,228 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourusername/hbf-agent/internal/health"
+	"github.com/yourusername/hbf-agent/internal/servicemesh"
+)
+
+// serviceCheckResync is how often the service checks are reconciled with
+// the registered services, covering service events dropped while the sync
+// was busy
+const serviceCheckResync = time.Minute
+
+// serviceCheckPrefix starts the IDs of the checks derived from services
+const serviceCheckPrefix = "service:"
+
+// serviceCheckSpec is what a check derived from a service's HealthCheck
+// probes; a change re-creates the check
+type serviceCheckSpec struct {
+	checkType string
+	target    string
+	interval  time.Duration
+	timeout   time.Duration
+}
+
+// serviceChecks keeps a health check for every locally registered service
+// that has a HealthCheck. Check results update the service status and feed
+// the critical reaper; the check is removed when the service deregisters.
+type serviceChecks struct {
+	mesh    *servicemesh.Manager
+	checker *health.Checker
+	reaper  *criticalReaper
+	log     *logrus.Logger
+	
+	mu     sync.Mutex
+	specs  map[string]serviceCheckSpec // by service ID
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newServiceChecks(mesh *servicemesh.Manager, checker *health.Checker, reaper *criticalReaper, log *logrus.Logger) *serviceChecks {
+	return &serviceChecks{
+		mesh:    mesh,
+		checker: checker,
+		reaper:  reaper,
+		log:     log,
+		specs:   make(map[string]serviceCheckSpec),
+	}
+}
+
+// start creates the checks of the services registered so far and follows
+// registrations until ctx is cancelled or stop is called
+func (s *serviceChecks) start(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	events := s.mesh.Subscribe()
+	
+	s.mu.Lock()
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	done := s.done
+	s.mu.Unlock()
+	
+	s.reconcile()
+	go s.run(ctx, events, done)
+}
+
+// stop stops following registrations. The checks are left to the checker,
+// which is stopped with the agent.
+func (s *serviceChecks) stop() {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.cancel = nil
+	s.mu.Unlock()
+	
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+func (s *serviceChecks) run(ctx context.Context, events <-chan servicemesh.Event, done chan struct{}) {
+	defer close(done)
+	defer s.mesh.Unsubscribe(events)
+	
+	ticker := time.NewTicker(serviceCheckResync)
+	defer ticker.Stop()
+	
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.Type == servicemesh.EventRegistered || event.Type == servicemesh.EventDeregistered {
+				s.reconcile()
+			}
+		case <-ticker.C:
+			s.reconcile()
+		}
+	}
+}
+
+// reconcile adds the checks of registered services that lack one, replaces
+// checks whose HealthCheck changed and removes the checks of services gone
+func (s *serviceChecks) reconcile() {
+	services := make(map[string]*servicemesh.Service)
+	wanted := make(map[string]serviceCheckSpec)
+	for _, service := range s.mesh.ListServices() {
+		if spec, ok := s.specOf(service); ok {
+			services[service.ID] = service
+			wanted[service.ID] = spec
+		}
+	}
+	
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	for serviceID, spec := range s.specs {
+		if want, ok := wanted[serviceID]; !ok || want != spec {
+			s.removeLocked(serviceID)
+		}
+	}
+	
+	for serviceID, spec := range wanted {
+		if _, exists := s.specs[serviceID]; !exists {
+			s.addLocked(services[serviceID], spec)
+		}
+	}
+}
+
+// addLocked creates the check of a service. Callers must hold s.mu.
+func (s *serviceChecks) addLocked(service *servicemesh.Service, spec serviceCheckSpec) {
+	check := &health.Check{
+		ID:       serviceCheckPrefix + service.ID,
+		Type:     spec.checkType,
+		Target:   spec.target,
+		Interval: spec.interval,
+		Timeout:  spec.timeout,
+	}
+	if err := s.checker.AddCheck(check); err != nil {
+		if errors.Is(err, health.ErrCheckExists) {
+			s.log.Warnf("Check %s already exists, not deriving one from service %s", check.ID, service.ID)
+		} else {
+			s.log.Errorf("Failed to add health check of service %s: %v", service.ID, err)
+		}
+		return
+	}
+	
+	serviceID := service.ID
+	after := service.HealthCheck.DeregisterCriticalServiceAfter
+	if err := s.checker.SetCallback(check.ID, func(status health.CheckStatus) {
+		s.observe(serviceID, after, status)
+	}); err != nil {
+		s.log.Errorf("Failed to link health check of service %s: %v", serviceID, err)
+	}
+	
+	s.specs[serviceID] = spec
+	s.log.Infof("Added %s health check of service %s on %s", spec.checkType, serviceID, spec.target)
+}
+
+// removeLocked removes the check of a service. Callers must hold s.mu.
+func (s *serviceChecks) removeLocked(serviceID string) {
+	delete(s.specs, serviceID)
+	if s.reaper != nil {
+		s.reaper.forget(serviceID)
+	}
+	
+	if err := s.checker.RemoveCheck(serviceCheckPrefix + serviceID); err != nil && !errors.Is(err, health.ErrCheckNotFound) {
+		s.log.Errorf("Failed to remove health check of service %s: %v", serviceID, err)
+	}
+}
+
+// observe applies a check result to its service
+func (s *serviceChecks) observe(serviceID string, after time.Duration, status health.CheckStatus) {
+	err := s.mesh.UpdateServiceStatus(serviceID, serviceStatus(status))
+	if errors.Is(err, servicemesh.ErrServiceNotFound) {
+		return
+	}
+	if err != nil {
+		s.log.Errorf("Failed to update status of service %s: %v", serviceID, err)
+	}
+	
+	if s.reaper != nil && after > 0 {
+		s.reaper.observe(serviceID, after, status)
+	}
+}
+
+// serviceStatus maps a check status to the status of its service
+func serviceStatus(status health.CheckStatus) servicemesh.ServiceStatus {
+	switch status {
+	case health.StatusPassing:
+		return servicemesh.StatusHealthy
+	case health.StatusWarning:
+		return servicemesh.StatusWarning
+	default:
+		return servicemesh.StatusUnhealthy
+	}
+}
+
+// specOf derives the check of a service from its HealthCheck, see
+// servicemesh.Service.CheckTarget
+func (s *serviceChecks) specOf(service *servicemesh.Service) (serviceCheckSpec, bool) {
+	hc := service.HealthCheck
+	if hc == nil || hc.Type == "" {
+		return serviceCheckSpec{}, false
+	}
+	
+	target, ok := service.CheckTarget()
+	if !ok {
+		s.log.Debugf("Service %s has a %s health check, which cannot be derived", service.ID, hc.Type)
+		return serviceCheckSpec{}, false
+	}
+	
+	return serviceCheckSpec{
+		checkType: hc.Type,
+		target:    target,
+		interval:  hc.Interval,
+		timeout:   hc.Timeout,
+	}, true
+}
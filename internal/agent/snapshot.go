@@ -0,0 +1,231 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/yourusername/hbf-agent/internal/firewall"
+	"github.com/yourusername/hbf-agent/internal/servicemesh"
+	"github.com/yourusername/hbf-agent/internal/snapshot"
+)
+
+// Snapshot returns the state of the agent: the configuration with secrets
+// redacted, the firewall rules added at runtime, the registered services
+// and the health checks
+func (a *Agent) Snapshot() (*snapshot.StateSnapshot, error) {
+	cfg := a.Config()
+	dump, err := cfg.Dump("json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to dump config: %w", err)
+	}
+	
+	snap := &snapshot.StateSnapshot{
+		Version:       snapshot.Version,
+		CreatedAt:     time.Now().UTC(),
+		NodeID:        cfg.Agent.NodeID,
+		Config:        dump,
+		FirewallRules: a.firewall.RuntimeRules(),
+		Services:      []*servicemesh.Service{},
+		HealthChecks:  a.healthCheck.ListChecks(),
+	}
+	if a.serviceMesh != nil {
+		snap.Services = a.serviceMesh.ListServices()
+	}
+	
+	sort.Slice(snap.Services, func(i, j int) bool {
+		if snap.Services[i].Name != snap.Services[j].Name {
+			return snap.Services[i].Name < snap.Services[j].Name
+		}
+		return snap.Services[i].ID < snap.Services[j].ID
+	})
+	sort.Slice(snap.HealthChecks, func(i, j int) bool { return snap.HealthChecks[i].ID < snap.HealthChecks[j].ID })
+	
+	return snap, nil
+}
+
+// Restore re-applies the firewall rules and services of a snapshot. Rules
+// and services that are already present, and rules that have expired, are
+// skipped. The restore is all or nothing: if any item is invalid nothing is
+// applied, and if an item fails to apply the items applied before it are
+// removed again. The result reports the outcome of every item.
+func (a *Agent) Restore(ctx context.Context, snap *snapshot.StateSnapshot) (*snapshot.RestoreResult, error) {
+	if err := snap.CheckVersion(); err != nil {
+		return nil, err
+	}
+	
+	result := &snapshot.RestoreResult{Items: make([]snapshot.ItemResult, 0, len(snap.FirewallRules)+len(snap.Services))}
+	rules, ruleItems := a.planRules(snap.FirewallRules, result)
+	services, serviceItems := a.planServices(snap.Services, result)
+	
+	for _, item := range result.Items {
+		if item.Status == snapshot.StatusInvalid {
+			abort(result, append(ruleItems, serviceItems...))
+			return result, snapshot.ErrInvalidSnapshot
+		}
+	}
+	
+	ids, err := a.firewall.AddRulesAtomicContext(ctx, rules)
+	if err != nil {
+		// The firewall has removed what the batch applied
+		var batchErr *firewall.BatchError
+		if errors.As(err, &batchErr) {
+			for i, ruleErr := range batchErr.Errors {
+				result.Items[ruleItems[i]].Status = snapshot.StatusFailed
+				result.Items[ruleItems[i]].Error = ruleErr.Error()
+			}
+		} else {
+			for _, i := range ruleItems {
+				result.Items[i].Status = snapshot.StatusFailed
+				result.Items[i].Error = err.Error()
+			}
+		}
+		abort(result, append(ruleItems, serviceItems...))
+		return result, fmt.Errorf("failed to restore firewall rules: %w", err)
+	}
+	for i, id := range ids {
+		result.Items[ruleItems[i]].ID = id
+		result.Items[ruleItems[i]].Status = snapshot.StatusApplied
+	}
+	
+	for i, service := range services {
+		item := &result.Items[serviceItems[i]]
+		if err := a.serviceMesh.RegisterServiceContext(ctx, service); err != nil {
+			item.Status = snapshot.StatusFailed
+			item.Error = err.Error()
+			a.rollbackRestore(ctx, result, ids, services[:i], serviceItems[:i])
+			abort(result, serviceItems[i+1:])
+			return result, fmt.Errorf("failed to restore service %s: %w", service.Name, err)
+		}
+		item.ID = service.ID
+		item.Status = snapshot.StatusApplied
+	}
+	
+	result.Applied = true
+	a.log.Infof("Restored %d firewall rules and %d services from the snapshot of %s", len(rules), len(services), snap.NodeID)
+	return result, nil
+}
+
+// planRules records a result for every rule of a snapshot and returns the
+// rules to add, with the indexes of their results
+func (a *Agent) planRules(snapRules []*firewall.Rule, result *snapshot.RestoreResult) ([]*firewall.Rule, []int) {
+	now := time.Now()
+	var rules []*firewall.Rule
+	var items []int
+	
+	for _, rule := range snapRules {
+		item := snapshot.ItemResult{Kind: snapshot.KindRule}
+		switch {
+		case rule == nil:
+			item.Status, item.Error = snapshot.StatusInvalid, "empty rule"
+		case rule.ID != "" && a.hasRule(rule.ID):
+			item.ID, item.Status, item.Error = rule.ID, snapshot.StatusSkipped, "rule already exists"
+		case !rule.ExpiresAt.IsZero() && !rule.ExpiresAt.After(now):
+			item.ID, item.Status, item.Error = rule.ID, snapshot.StatusSkipped, "rule has expired"
+		default:
+			item.ID = rule.ID
+			if err := a.firewall.ValidateRule(rule); err != nil {
+				item.Status, item.Error = snapshot.StatusInvalid, err.Error()
+				break
+			}
+			restored := *rule
+			rules = append(rules, &restored)
+			items = append(items, len(result.Items))
+		}
+		result.Items = append(result.Items, item)
+	}
+	return rules, items
+}
+
+// hasRule reports whether a rule with the ID exists
+func (a *Agent) hasRule(ruleID string) bool {
+	_, err := a.firewall.GetRule(ruleID)
+	return err == nil
+}
+
+// planServices records a result for every service of a snapshot and
+// returns the services to register, with the indexes of their results
+func (a *Agent) planServices(snapServices []*servicemesh.Service, result *snapshot.RestoreResult) ([]*servicemesh.Service, []int) {
+	var registered []*servicemesh.Service
+	if a.serviceMesh != nil {
+		registered = a.serviceMesh.ListServices()
+	}
+	
+	var services []*servicemesh.Service
+	var items []int
+	for _, service := range snapServices {
+		item := snapshot.ItemResult{Kind: snapshot.KindService}
+		switch {
+		case service == nil:
+			item.Status, item.Error = snapshot.StatusInvalid, "empty service"
+		case a.serviceMesh == nil:
+			item.ID, item.Status, item.Error = service.ID, snapshot.StatusInvalid, "service mesh not enabled"
+		case isRegistered(registered, service):
+			item.ID, item.Status, item.Error = service.ID, snapshot.StatusSkipped, "service already registered"
+		default:
+			item.ID = service.ID
+			if err := service.Validate(); err != nil {
+				item.Status, item.Error = snapshot.StatusInvalid, err.Error()
+				break
+			}
+			restored := *service
+			restored.Status = servicemesh.StatusUnknown
+			restored.Stale = false
+			services = append(services, &restored)
+			items = append(items, len(result.Items))
+		}
+		result.Items = append(result.Items, item)
+	}
+	return services, items
+}
+
+// isRegistered reports whether a service of a snapshot is registered: by ID,
+// or without an ID by name, address and port
+func isRegistered(registered []*servicemesh.Service, service *servicemesh.Service) bool {
+	for _, existing := range registered {
+		if service.ID != "" && existing.ID == service.ID {
+			return true
+		}
+		if service.ID == "" && existing.Name == service.Name && existing.Address == service.Address && existing.Port == service.Port {
+			return true
+		}
+	}
+	return false
+}
+
+// rollbackRestore removes the rules and services a failed restore applied
+func (a *Agent) rollbackRestore(ctx context.Context, result *snapshot.RestoreResult, ruleIDs []string, services []*servicemesh.Service, serviceItems []int) {
+	for i, service := range services {
+		if err := a.serviceMesh.DeregisterServiceContext(ctx, service.ID); err != nil {
+			a.log.Errorf("Failed to roll back restored service %s: %v", service.ID, err)
+			continue
+		}
+		result.Items[serviceItems[i]].Status = snapshot.StatusRolledBack
+	}
+	
+	rolledBack := make(map[string]bool, len(ruleIDs))
+	for _, id := range ruleIDs {
+		if err := a.firewall.DeleteRuleContext(ctx, id); err != nil {
+			a.log.Errorf("Failed to roll back restored rule %s: %v", id, err)
+			continue
+		}
+		rolledBack[id] = true
+	}
+	for i, item := range result.Items {
+		if item.Kind == snapshot.KindRule && item.Status == snapshot.StatusApplied && rolledBack[item.ID] {
+			result.Items[i].Status = snapshot.StatusRolledBack
+		}
+	}
+}
+
+// abort marks the items of a restore that were neither applied nor failed
+// as not applied
+func abort(result *snapshot.RestoreResult, items []int) {
+	for _, i := range items {
+		if result.Items[i].Status == "" {
+			result.Items[i].Status = snapshot.StatusNotApplied
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourusername/hbf-agent/internal/audit"
+	"github.com/yourusername/hbf-agent/internal/config"
+	"github.com/yourusername/hbf-agent/internal/logging"
+	"github.com/yourusername/hbf-agent/internal/secrets"
+)
+
+// requireAuth checks the request credentials when security.auth is enabled
+// and rejects the request if they are missing or wrong. Tokens are sent as
+// "Authorization: Bearer"; mtls authorizes the verified client certificate.
+func (s *Server) requireAuth(w http.ResponseWriter, r *http.Request) bool {
+	auth := s.config.Security.Auth
+	if !auth.Enabled || s.rbac != nil {
+		// With roles, rbacMiddleware has already authorized the route
+		return true
+	}
+	
+	switch auth.Type {
+	case "token":
+	case "mtls":
+		_, ok := s.authorizeClientCert(w, r)
+		return ok
+	default:
+		writeError(w, http.StatusForbidden, codeForbidden, "Authentication type "+auth.Type+" is not supported by the API")
+		return false
+	}
+	
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, codeUnauthenticated, "Missing bearer token")
+		return false
+	}
+	
+	if containsToken(auth.Tokens, token) {
+		return true
+	}
+	
+	writeError(w, http.StatusUnauthorized, codeUnauthenticated, "Invalid token")
+	return false
+}
+
+// callerMiddleware records the caller identity and source address in the
+// request context for the audit log
+func (s *Server) callerMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		caller := audit.Caller{Identity: "anonymous", SourceIP: r.RemoteAddr}
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			caller.SourceIP = host
+		}
+		if identities := clientIdentities(r.TLS); len(identities) > 0 {
+			caller.Identity = identities[0]
+		}
+		
+		next.ServeHTTP(w, r.WithContext(audit.WithCaller(r.Context(), caller)))
+	})
+}
+
+// mtlsAuthMiddleware restricts mutating requests to the client certificate
+// identities in security.auth.allowed_identities when auth type is mtls, and
+// logs the identity behind every mutating call. It is replaced by
+// rbacMiddleware when roles are defined.
+func (s *Server) mtlsAuthMiddleware(next http.Handler) http.Handler {
+	auth := s.config.Security.Auth
+	if !auth.Enabled || auth.Type != "mtls" || s.rbac != nil {
+		return next
+	}
+	
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		
+		identity, ok := s.authorizeClientCert(w, r)
+		if !ok {
+			return
+		}
+		
+		logging.Entry(r.Context(), s.log).WithField("identity", identity).
+			Infof("Authorized %s %s", r.Method, r.URL.Path)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authorizeClientCert checks the verified client certificate against the
+// allow-list and returns the matching identity. The request is rejected
+// with 403 if there is no verified certificate or no SAN is allowed.
+func (s *Server) authorizeClientCert(w http.ResponseWriter, r *http.Request) (string, bool) {
+	identities := clientIdentities(r.TLS)
+	if len(identities) == 0 {
+		writeError(w, http.StatusForbidden, codeForbidden, "A verified client certificate is required")
+		return "", false
+	}
+	
+	if identity, ok := allowedIdentity(identities, s.config.Security.Auth.AllowedIdentities); ok {
+		return identity, true
+	}
+	
+	logging.Entry(r.Context(), s.log).Warnf("Rejected %s %s from unauthorized client %s",
+		r.Method, r.URL.Path, strings.Join(identities, ", "))
+	writeError(w, http.StatusForbidden, codeForbidden, "Client certificate identity is not authorized")
+	return "", false
+}
+
+// clientIdentities returns the SANs of the verified client certificate: URI
+// SANs such as SPIFFE IDs first, then DNS names and email addresses
+func clientIdentities(state *tls.ConnectionState) []string {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	
+	leaf := state.VerifiedChains[0][0]
+	identities := make([]string, 0, len(leaf.URIs)+len(leaf.DNSNames)+len(leaf.EmailAddresses))
+	for _, uri := range leaf.URIs {
+		identities = append(identities, uri.String())
+	}
+	identities = append(identities, leaf.DNSNames...)
+	identities = append(identities, leaf.EmailAddresses...)
+	
+	return identities
+}
+
+// allowedIdentity returns the first identity that is in the allow-list
+func allowedIdentity(identities, allowed []string) (string, bool) {
+	for _, identity := range identities {
+		for _, a := range allowed {
+			if identity == a {
+				return identity, true
+			}
+		}
+	}
+	return "", false
+}
+
+// serverTLSConfig builds the API server TLS configuration for mTLS: clients
+// must present a certificate signed by the configured CA. The files may be
+// secret references; one that cannot be resolved fails the server start.
+// Rotated certificates are picked up live (see certLoader), with onLoad,
+// if set, receiving their expiry.
+func serverTLSConfig(security config.SecurityConfig, onLoad certExpiryFunc, log *logrus.Logger) (*tls.Config, error) {
+	base := &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		MinVersion: tls.VersionTLS12,
+	}
+	
+	certs, err := newCertLoader(secrets.NewResolver(security.Vault), security.MTLS,
+		security.Vault.RefreshInterval, base, onLoad, log)
+	if err != nil {
+		return nil, err
+	}
+	return certs.TLSConfig(), nil
+}
@@ -0,0 +1,254 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourusername/hbf-agent/internal/config"
+	"github.com/yourusername/hbf-agent/internal/secrets"
+)
+
+// certCheckInterval is how often handshakes check the certificate files
+// for changes
+const certCheckInterval = 10 * time.Second
+
+// Names of the certificates in the expiry metric
+const (
+	certServer   = "server"
+	certClientCA = "client_ca"
+)
+
+// certExpiryFunc receives the expiry of a certificate whenever it is loaded
+type certExpiryFunc func(name string, notAfter time.Time)
+
+// fileStamp identifies a version of a file on disk
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// certLoader serves the server key pair and the client CA pool to TLS
+// handshakes and reloads them when they rotate, so that certificates
+// renewed by e.g. cert-manager are used without a restart. Files on disk are
+// checked for changes at most every certCheckInterval; secret references
+// (e.g. Vault) are resolved again every refresh interval. If a reload fails
+// the current certificates are kept and the failure logged.
+type certLoader struct {
+	resolver *secrets.Resolver
+	mtls     config.MTLSConfig
+	refresh  time.Duration
+	base     *tls.Config
+	onLoad   certExpiryFunc
+	log      *logrus.Logger
+	
+	mu        sync.Mutex
+	current   *tls.Config
+	caPEM     []byte
+	stamps    map[string]fileStamp
+	checkedAt time.Time
+	loadedAt  time.Time
+}
+
+// newCertLoader loads the key pair and CA pool, failing if either cannot be
+// resolved. base holds the remaining TLS settings; onLoad may be nil.
+func newCertLoader(resolver *secrets.Resolver, mtls config.MTLSConfig, refresh time.Duration, base *tls.Config, onLoad certExpiryFunc, log *logrus.Logger) (*certLoader, error) {
+	l := &certLoader{
+		resolver: resolver,
+		mtls:     mtls,
+		base:     base,
+		onLoad:   onLoad,
+		log:      log,
+	}
+	for _, ref := range l.files() {
+		if secrets.IsRef(ref) {
+			l.refresh = refresh
+		}
+	}
+	
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// files returns the configured certificate, key and CA settings
+func (l *certLoader) files() []string {
+	return []string{l.mtls.CertFile, l.mtls.KeyFile, l.mtls.CAFile}
+}
+
+// load resolves the key pair and CA pool and installs them. Callers must
+// hold l.mu or have exclusive access to l.
+func (l *certLoader) load() error {
+	ctx := context.Background()
+	stamps := l.stat()
+	
+	certPEM, err := l.resolver.Resolve(ctx, l.mtls.CertFile)
+	if err != nil {
+		return fmt.Errorf("failed to load server certificate: %w", err)
+	}
+	keyPEM, err := l.resolver.Resolve(ctx, l.mtls.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load server key: %w", err)
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return fmt.Errorf("failed to load server certificate: %w", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("failed to parse server certificate: %w", err)
+	}
+	
+	caPEM, err := l.resolver.Resolve(ctx, l.mtls.CAFile)
+	if err != nil {
+		return fmt.Errorf("failed to read CA file: %w", err)
+	}
+	pool, caExpiry, err := parseCAPool(caPEM)
+	if err != nil {
+		return fmt.Errorf("%w in CA file %s", err, l.mtls.CAFile)
+	}
+	
+	previous := l.current
+	l.current = l.base.Clone()
+	l.current.Certificates = []tls.Certificate{cert}
+	l.current.ClientCAs = pool
+	l.stamps = stamps
+	l.loadedAt = time.Now()
+	
+	if previous != nil {
+		if !bytes.Equal(cert.Certificate[0], previous.Certificates[0].Certificate[0]) {
+			l.log.Infof("Reloaded server certificate %s, expires %s", l.mtls.CertFile, leaf.NotAfter.Format(time.RFC3339))
+		}
+		if !bytes.Equal(caPEM, l.caPEM) {
+			l.log.Infof("Reloaded client CA pool %s", l.mtls.CAFile)
+		}
+	}
+	l.caPEM = caPEM
+	
+	if l.onLoad != nil {
+		l.onLoad(certServer, leaf.NotAfter)
+		l.onLoad(certClientCA, caExpiry)
+	}
+	return nil
+}
+
+// parseCAPool builds a pool from PEM certificates and returns the earliest
+// expiry among them
+func parseCAPool(caPEM []byte) (*x509.CertPool, time.Time, error) {
+	pool := x509.NewCertPool()
+	var expiry time.Time
+	
+	for rest := caPEM; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		// Like AppendCertsFromPEM, skip certificates that do not parse
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			continue
+		}
+		
+		pool.AddCert(cert)
+		if expiry.IsZero() || cert.NotAfter.Before(expiry) {
+			expiry = cert.NotAfter
+		}
+	}
+	
+	if expiry.IsZero() {
+		return nil, time.Time{}, fmt.Errorf("no certificates found")
+	}
+	return pool, expiry, nil
+}
+
+// stat returns the stamps of the certificate files on disk. Secret
+// references are skipped; they are refreshed by time instead.
+func (l *certLoader) stat() map[string]fileStamp {
+	stamps := make(map[string]fileStamp)
+	for _, file := range l.files() {
+		if secrets.IsRef(file) {
+			continue
+		}
+		if info, err := os.Stat(file); err == nil {
+			stamps[file] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		}
+	}
+	return stamps
+}
+
+// changed reports whether a certificate file differs from the loaded one
+func (l *certLoader) changed() bool {
+	stamps := l.stat()
+	if len(stamps) != len(l.stamps) {
+		return true
+	}
+	for file, stamp := range stamps {
+		if l.stamps[file] != stamp {
+			return true
+		}
+	}
+	return false
+}
+
+// config returns the TLS configuration for a handshake, reloading the
+// certificates first if they have rotated
+func (l *certLoader) config() *tls.Config {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	
+	now := time.Now()
+	if now.Sub(l.checkedAt) < certCheckInterval {
+		return l.current
+	}
+	l.checkedAt = now
+	
+	due := l.refresh > 0 && now.Sub(l.loadedAt) >= l.refresh
+	if !due && !l.changed() {
+		return l.current
+	}
+	
+	if err := l.load(); err != nil {
+		// Files are often written one at a time, so a pair that does not
+		// match yet is retried on the next check
+		l.log.Warnf("Failed to reload TLS certificates, keeping the current ones: %v", err)
+		if due {
+			l.loadedAt = now
+		}
+	}
+	return l.current
+}
+
+// GetConfigForClient serves the current certificates to a handshake
+func (l *certLoader) GetConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	return l.config(), nil
+}
+
+// GetCertificate returns the current key pair
+func (l *certLoader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return &l.config().Certificates[0], nil
+}
+
+// TLSConfig returns the configuration to serve with. Handshakes get the
+// current certificates through GetConfigForClient.
+func (l *certLoader) TLSConfig() *tls.Config {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	
+	cfg := l.base.Clone()
+	cfg.ClientCAs = l.current.ClientCAs
+	cfg.GetCertificate = l.GetCertificate
+	cfg.GetConfigForClient = l.GetConfigForClient
+	return cfg
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// compressMiddleware compresses responses with gzip or deflate, as
+// negotiated through Accept-Encoding. Responses are buffered until they
+// reach the minimum size, so small responses are sent as-is. Responses that
+// already carry a Content-Encoding and the event stream are never
+// compressed.
+func (s *Server) compressMiddleware(next http.Handler) http.Handler {
+	cfg := s.config.Agent.Compression
+	if !cfg.Enabled {
+		return next
+	}
+	
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.URL.Path == "/api/v1/events" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		
+		cw := &compressWriter{
+			ResponseWriter: w,
+			encoding:       encoding,
+			minSize:        cfg.MinSize,
+		}
+		defer cw.close()
+		
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// negotiateEncoding picks gzip or deflate from an Accept-Encoding header,
+// preferring gzip. Encodings with q=0 are treated as refused.
+func negotiateEncoding(header string) string {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		refused := false
+		for _, param := range fields[1:] {
+			param = strings.ReplaceAll(param, " ", "")
+			if param == "q=0" || strings.HasPrefix(param, "q=0.0") && strings.Trim(param[4:], "0") == "" {
+				refused = true
+			}
+		}
+		accepted[name] = !refused
+	}
+	
+	switch {
+	case accepted["gzip"]:
+		return "gzip"
+	case accepted["deflate"]:
+		return "deflate"
+	default:
+		return ""
+	}
+}
+
+// compressWriter buffers the start of a response to decide whether to
+// compress it
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+	status   int
+	buf      []byte
+	encoder  io.WriteCloser
+	decided  bool
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if !cw.decided && cw.status == 0 {
+		cw.status = status
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.decided {
+		if cw.encoder != nil {
+			return cw.encoder.Write(p)
+		}
+		return cw.ResponseWriter.Write(p)
+	}
+	
+	cw.buf = append(cw.buf, p...)
+	if len(cw.buf) >= cw.minSize {
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	
+	return len(p), nil
+}
+
+// Flush sends buffered data to the client
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.decide(len(cw.buf) >= cw.minSize)
+	}
+	
+	if flusher, ok := cw.encoder.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// decide writes the headers, compressing the rest of the response if
+// compress is set and the response is eligible, then writes the buffer
+func (cw *compressWriter) decide(compress bool) error {
+	cw.decided = true
+	
+	header := cw.Header()
+	contentType := header.Get("Content-Type")
+	if compress && header.Get("Content-Encoding") == "" && !strings.HasPrefix(contentType, "text/event-stream") {
+		header.Set("Content-Encoding", cw.encoding)
+		header.Del("Content-Length")
+		
+		if cw.encoding == "gzip" {
+			cw.encoder = gzip.NewWriter(cw.ResponseWriter)
+		} else {
+			encoder, err := flate.NewWriter(cw.ResponseWriter, flate.DefaultCompression)
+			if err != nil {
+				return err
+			}
+			cw.encoder = encoder
+		}
+	}
+	
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+	
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	
+	_, err := cw.Write(buf)
+	return err
+}
+
+// close sends any buffered response uncompressed and finishes the encoder
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		cw.decide(false)
+	}
+	
+	if cw.encoder != nil {
+		cw.encoder.Close()
+	}
+}
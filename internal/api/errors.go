@@ -0,0 +1,93 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/yourusername/hbf-agent/internal/config"
+	"github.com/yourusername/hbf-agent/internal/firewall"
+	"github.com/yourusername/hbf-agent/internal/health"
+	"github.com/yourusername/hbf-agent/internal/logging"
+	"github.com/yourusername/hbf-agent/internal/servicemesh"
+)
+
+// Stable error codes of the API error envelope. Clients should branch on
+// these rather than on messages.
+const (
+	codeBadRequest       = "bad_request"
+	codeValidation       = "validation_failed"
+	codeNotFound         = "not_found"
+	codeAlreadyExists    = "already_exists"
+	codeMethodNotAllowed = "method_not_allowed"
+	codeUnauthenticated  = "unauthenticated"
+	codeForbidden        = "forbidden"
+	codeNotLeader        = "not_leader"
+	codeUnavailable      = "unavailable"
+	codeInternal         = "internal"
+)
+
+// errorBody is the JSON error envelope:
+//
+//	{"error": {"code": "not_found", "message": "...", "request_id": "..."}}
+type errorBody struct {
+	Error errorDetail `json:"error"`
+}
+
+type errorDetail struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+	// Problems lists every problem of a request failing validation
+	Problems  []string `json:"problems,omitempty"`
+}
+
+// writeError writes a JSON error envelope. The request ID is taken from the
+// X-Request-ID response header set by requestIDMiddleware.
+func writeError(w http.ResponseWriter, status int, code, msg string) {
+	writeErrorDetail(w, status, errorDetail{Code: code, Message: msg})
+}
+
+// writeValidationError writes the error envelope of a failed request, with
+// the problems of the *config.ValidationError err wraps, if any
+func writeValidationError(w http.ResponseWriter, status int, msg string, err error) {
+	detail := errorDetail{Code: errorCode(err, codeInternal), Message: msg}
+	var validationErr *config.ValidationError
+	if errors.As(err, &validationErr) {
+		detail.Problems = validationErr.Problems
+	}
+	writeErrorDetail(w, status, detail)
+}
+
+// writeErrorDetail writes a JSON error envelope
+func writeErrorDetail(w http.ResponseWriter, status int, detail errorDetail) {
+	detail.RequestID = w.Header().Get(logging.RequestIDHeader)
+	body := errorBody{Error: detail}
+	
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// errorCode maps manager errors to stable error codes, falling back to
+// fallback for errors without one
+func errorCode(err error, fallback string) string {
+	switch {
+	case errors.Is(err, firewall.ErrRuleNotFound),
+		errors.Is(err, firewall.ErrGroupNotFound),
+		errors.Is(err, servicemesh.ErrServiceNotFound),
+		errors.Is(err, health.ErrCheckNotFound):
+		return codeNotFound
+	case errors.Is(err, firewall.ErrRuleExists),
+		errors.Is(err, health.ErrCheckExists),
+		errors.Is(err, servicemesh.ErrAlreadyRegistered):
+		return codeAlreadyExists
+	case errors.Is(err, firewall.ErrInvalidRule),
+		errors.Is(err, servicemesh.ErrInvalidService),
+		errors.Is(err, health.ErrInvalidCheck):
+		return codeValidation
+	default:
+		return fallback
+	}
+}
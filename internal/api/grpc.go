@@ -0,0 +1,434 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourusername/hbf-agent/internal/api/pb"
+	"github.com/yourusername/hbf-agent/internal/audit"
+	"github.com/yourusername/hbf-agent/internal/config"
+	"github.com/yourusername/hbf-agent/internal/firewall"
+	"github.com/yourusername/hbf-agent/internal/servicemesh"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+//go:generate protoc --go_out=pb --go_opt=paths=source_relative --go-grpc_out=pb --go-grpc_opt=paths=source_relative -I pb pb/hbf.proto
+
+// GRPCServer serves the gRPC API on agent.grpc_port. It mirrors the service
+// and firewall rule REST endpoints and shares the same managers.
+type GRPCServer struct {
+	config      *config.Config
+	log         *logrus.Logger
+	firewall    *firewall.Manager
+	serviceMesh *servicemesh.Manager
+	isLeader    func() bool
+	server      *grpc.Server
+	rbac        *rbac
+	ipFilter    *ipFilter
+}
+
+// NewGRPCServer creates a new gRPC API server. When security.mtls is enabled
+// the server uses the same certificates as the REST API and requires
+// client certificates.
+func NewGRPCServer(cfg *config.Config, fw *firewall.Manager, sm *servicemesh.Manager, log *logrus.Logger) (*GRPCServer, error) {
+	filter, err := newIPFilter(cfg.API)
+	if err != nil {
+		return nil, err
+	}
+	
+	s := &GRPCServer{
+		config:      cfg,
+		log:         log,
+		firewall:    fw,
+		serviceMesh: sm,
+		rbac:        newRBAC(cfg.Security),
+		ipFilter:    filter,
+	}
+	
+	opts := []grpc.ServerOption{grpc.ChainUnaryInterceptor(s.ipFilterInterceptor, s.callerInterceptor, s.mtlsAuthInterceptor, s.rbacInterceptor)}
+	if cfg.Security.MTLS.Enabled {
+		tlsConfig, err := serverTLSConfig(cfg.Security, nil, log)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	
+	s.server = grpc.NewServer(opts...)
+	pb.RegisterServiceMeshServer(s.server, &grpcServiceMesh{s: s})
+	pb.RegisterFirewallServer(s.server, &grpcFirewall{s: s})
+	
+	return s, nil
+}
+
+// SetLeaderCheck restricts writes to cluster-wide state to the leader.
+// Non-leaders keep serving reads.
+func (s *GRPCServer) SetLeaderCheck(isLeader func() bool) {
+	s.isLeader = isLeader
+}
+
+// Start starts the gRPC server and blocks until it stops
+func (s *GRPCServer) Start() error {
+	addr := fmt.Sprintf("%s:%d", s.config.Agent.BindAddr, s.config.Agent.GRPCPort)
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	
+	if s.config.Security.MTLS.Enabled {
+		s.log.Infof("gRPC server listening on %s (mTLS)", addr)
+	} else {
+		s.log.Infof("gRPC server listening on %s", addr)
+	}
+	return s.server.Serve(lis)
+}
+
+// Stop stops the gRPC server, letting in-flight calls finish
+func (s *GRPCServer) Stop() error {
+	s.server.GracefulStop()
+	return nil
+}
+
+// requireLeader rejects the call if this agent is not the leader
+func (s *GRPCServer) requireLeader() error {
+	if s.isLeader != nil && !s.isLeader() {
+		return status.Error(codes.Unavailable, "This agent is not the cluster leader")
+	}
+	return nil
+}
+
+// callerInterceptor records the caller identity and source address in the
+// call context for the audit log
+func (s *GRPCServer) callerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	caller := audit.Caller{Identity: "anonymous"}
+	if p, ok := peer.FromContext(ctx); ok {
+		caller.SourceIP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(caller.SourceIP); err == nil {
+			caller.SourceIP = host
+		}
+		if identities := peerIdentities(p); len(identities) > 0 {
+			caller.Identity = identities[0]
+		}
+	}
+	
+	return handler(audit.WithCaller(ctx, caller), req)
+}
+
+// mtlsAuthInterceptor restricts mutating calls to the client certificate
+// identities in security.auth.allowed_identities when auth type is mtls. It
+// is replaced by rbacInterceptor when roles are defined.
+func (s *GRPCServer) mtlsAuthInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	auth := s.config.Security.Auth
+	if !auth.Enabled || auth.Type != "mtls" || s.rbac != nil || !mutatingMethod(info.FullMethod) {
+		return handler(ctx, req)
+	}
+	
+	var identities []string
+	if p, ok := peer.FromContext(ctx); ok {
+		identities = peerIdentities(p)
+	}
+	if len(identities) == 0 {
+		return nil, status.Error(codes.PermissionDenied, "A verified client certificate is required")
+	}
+	
+	identity, ok := allowedIdentity(identities, auth.AllowedIdentities)
+	if !ok {
+		s.log.Warnf("Rejected %s from unauthorized client %s", info.FullMethod, strings.Join(identities, ", "))
+		return nil, status.Error(codes.PermissionDenied, "Client certificate identity is not authorized")
+	}
+	
+	s.log.WithField("identity", identity).Infof("Authorized %s", info.FullMethod)
+	return handler(ctx, req)
+}
+
+// rbacInterceptor enforces security.roles. Tokens are read from the
+// "authorization" metadata as "Bearer <token>".
+func (s *GRPCServer) rbacInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if s.rbac == nil {
+		return handler(ctx, req)
+	}
+	
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			token = bearerToken(values[0])
+		}
+	}
+	var identities []string
+	if p, ok := peer.FromContext(ctx); ok {
+		identities = peerIdentities(p)
+	}
+	
+	permission := methodPermission(info.FullMethod)
+	if err := s.rbac.authorize(token, identities, permission); err != nil {
+		s.log.Warnf("Denied %s: %v", info.FullMethod, err)
+		if errors.Is(err, errMissingToken) || errors.Is(err, errInvalidToken) {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	return handler(ctx, req)
+}
+
+// methodPermission returns the permission required by a full gRPC method
+// name, e.g. /hbf.v1.Firewall/AddRule needs firewall:write
+func methodPermission(fullMethod string) string {
+	resource := "services"
+	if strings.HasPrefix(fullMethod, "/"+pb.Firewall_ServiceDesc.ServiceName+"/") {
+		resource = "firewall"
+	}
+	if mutatingMethod(fullMethod) {
+		return resource + ":write"
+	}
+	return resource + ":read"
+}
+
+// mutatingMethod reports whether a full gRPC method name changes state
+func mutatingMethod(fullMethod string) bool {
+	method := fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+	return !strings.HasPrefix(method, "List") && !strings.HasPrefix(method, "Get")
+}
+
+// peerIdentities returns the SANs of the peer's verified client certificate
+func peerIdentities(p *peer.Peer) []string {
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return nil
+	}
+	return clientIdentities(&tlsInfo.State)
+}
+
+// grpcServiceMesh implements pb.ServiceMeshServer
+type grpcServiceMesh struct {
+	pb.UnimplementedServiceMeshServer
+	s *GRPCServer
+}
+
+func (g *grpcServiceMesh) manager() (*servicemesh.Manager, error) {
+	if g.s.serviceMesh == nil {
+		return nil, status.Error(codes.Unavailable, "Service mesh not enabled")
+	}
+	return g.s.serviceMesh, nil
+}
+
+func (g *grpcServiceMesh) ListServices(ctx context.Context, req *pb.ListServicesRequest) (*pb.ListServicesResponse, error) {
+	sm, err := g.manager()
+	if err != nil {
+		return nil, err
+	}
+	if req.Limit < 0 || req.Offset < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit and offset must not be negative")
+	}
+	
+	services, total := sm.ListServicesFiltered(servicemesh.ServiceFilter{
+		Status: servicemesh.ServiceStatus(req.Status),
+		Tag:    req.Tag,
+		Limit:  int(req.Limit),
+		Offset: int(req.Offset),
+	})
+	
+	resp := &pb.ListServicesResponse{
+		Services: make([]*pb.Service, 0, len(services)),
+		Total:    int32(total),
+	}
+	for _, service := range services {
+		resp.Services = append(resp.Services, toPBService(service))
+	}
+	return resp, nil
+}
+
+func (g *grpcServiceMesh) GetService(ctx context.Context, req *pb.GetServiceRequest) (*pb.Service, error) {
+	sm, err := g.manager()
+	if err != nil {
+		return nil, err
+	}
+	
+	service, err := sm.GetService(req.Id)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return toPBService(service), nil
+}
+
+func (g *grpcServiceMesh) RegisterService(ctx context.Context, req *pb.RegisterServiceRequest) (*pb.Service, error) {
+	sm, err := g.manager()
+	if err != nil {
+		return nil, err
+	}
+	if err := g.s.requireLeader(); err != nil {
+		return nil, err
+	}
+	if req.Service == nil {
+		return nil, status.Error(codes.InvalidArgument, "service is required")
+	}
+	
+	service := fromPBService(req.Service)
+	if err := sm.RegisterServiceContext(ctx, service); err != nil {
+		code := codes.Internal
+		switch {
+		case errors.Is(err, servicemesh.ErrAlreadyRegistered):
+			code = codes.AlreadyExists
+		case errors.Is(err, servicemesh.ErrInvalidService):
+			code = codes.InvalidArgument
+		}
+		return nil, status.Errorf(code, "Failed to register service: %v", err)
+	}
+	return toPBService(service), nil
+}
+
+func (g *grpcServiceMesh) DeregisterService(ctx context.Context, req *pb.DeregisterServiceRequest) (*pb.DeregisterServiceResponse, error) {
+	sm, err := g.manager()
+	if err != nil {
+		return nil, err
+	}
+	if err := g.s.requireLeader(); err != nil {
+		return nil, err
+	}
+	
+	if err := sm.DeregisterServiceContext(ctx, req.Id); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &pb.DeregisterServiceResponse{}, nil
+}
+
+// grpcFirewall implements pb.FirewallServer
+type grpcFirewall struct {
+	pb.UnimplementedFirewallServer
+	s *GRPCServer
+}
+
+func (g *grpcFirewall) ListRules(ctx context.Context, req *pb.ListRulesRequest) (*pb.ListRulesResponse, error) {
+	if req.Limit < 0 || req.Offset < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit and offset must not be negative")
+	}
+	
+	rules, total := g.s.firewall.ListRulesFiltered(firewall.RuleFilter{
+		Chain:  req.Chain,
+		Action: req.Action,
+		Limit:  int(req.Limit),
+		Offset: int(req.Offset),
+	})
+	
+	resp := &pb.ListRulesResponse{
+		Rules: make([]*pb.Rule, 0, len(rules)),
+		Total: int32(total),
+	}
+	for _, rule := range rules {
+		resp.Rules = append(resp.Rules, toPBRule(rule))
+	}
+	return resp, nil
+}
+
+func (g *grpcFirewall) GetRule(ctx context.Context, req *pb.GetRuleRequest) (*pb.Rule, error) {
+	rule, err := g.s.firewall.GetRule(req.Id)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return toPBRule(rule), nil
+}
+
+func (g *grpcFirewall) AddRule(ctx context.Context, req *pb.AddRuleRequest) (*pb.Rule, error) {
+	if req.Rule == nil {
+		return nil, status.Error(codes.InvalidArgument, "rule is required")
+	}
+	
+	rule := fromPBRule(req.Rule)
+	if err := g.s.firewall.AddRuleContext(ctx, rule); err != nil {
+		code := codes.Internal
+		if errors.Is(err, firewall.ErrInvalidRule) {
+			code = codes.InvalidArgument
+		}
+		return nil, status.Errorf(code, "Failed to add rule: %v", err)
+	}
+	return toPBRule(rule), nil
+}
+
+func (g *grpcFirewall) DeleteRule(ctx context.Context, req *pb.DeleteRuleRequest) (*pb.DeleteRuleResponse, error) {
+	if err := g.s.firewall.DeleteRuleContext(ctx, req.Id); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &pb.DeleteRuleResponse{}, nil
+}
+
+// Conversions between the manager types and their protobuf messages
+
+func toPBService(service *servicemesh.Service) *pb.Service {
+	return &pb.Service{
+		Id:           service.ID,
+		Name:         service.Name,
+		Address:      service.Address,
+		Port:         int32(service.Port),
+		Tags:         service.Tags,
+		Meta:         service.Meta,
+		Status:       string(service.Status),
+		RegisteredAt: timestamppb.New(service.RegisteredAt),
+		LastSeen:     timestamppb.New(service.LastSeen),
+	}
+}
+
+// fromPBService converts a registration request. Status and timestamps are
+// set by the manager.
+func fromPBService(service *pb.Service) *servicemesh.Service {
+	return &servicemesh.Service{
+		ID:      service.Id,
+		Name:    service.Name,
+		Address: service.Address,
+		Port:    int(service.Port),
+		Tags:    service.Tags,
+		Meta:    service.Meta,
+	}
+}
+
+func toPBRule(rule *firewall.Rule) *pb.Rule {
+	return &pb.Rule{
+		Id:         rule.ID,
+		Chain:      rule.Chain,
+		Protocol:   rule.Protocol,
+		Source:     rule.Source,
+		Dest:       rule.Dest,
+		Sport:      rule.SPort,
+		Dport:      rule.DPort,
+		Action:     rule.Action,
+		Comment:    rule.Comment,
+		LogPrefix:  rule.LogPrefix,
+		RateLimit:  rule.RateLimit,
+		RateBurst:  int32(rule.RateBurst),
+		PerSource:  rule.PerSource,
+		RejectWith: rule.RejectWith,
+		Priority:   int32(rule.Priority),
+		CreatedAt:  timestamppb.New(rule.CreatedAt),
+	}
+}
+
+// fromPBRule converts an add request. The creation time is set by the
+// manager.
+func fromPBRule(rule *pb.Rule) *firewall.Rule {
+	return &firewall.Rule{
+		ID:         rule.Id,
+		Chain:      rule.Chain,
+		Protocol:   rule.Protocol,
+		Source:     rule.Source,
+		Dest:       rule.Dest,
+		SPort:      rule.Sport,
+		DPort:      rule.Dport,
+		Action:     rule.Action,
+		Comment:    rule.Comment,
+		LogPrefix:  rule.LogPrefix,
+		RateLimit:  rule.RateLimit,
+		RateBurst:  int(rule.RateBurst),
+		PerSource:  rule.PerSource,
+		RejectWith: rule.RejectWith,
+		Priority:   int(rule.Priority),
+		Enabled:    true,
+	}
+}
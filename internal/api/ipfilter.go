@@ -0,0 +1,136 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/yourusername/hbf-agent/internal/config"
+	"github.com/yourusername/hbf-agent/internal/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// ipFilter enforces api.allow_cidrs and api.deny_cidrs on the client address
+type ipFilter struct {
+	allow   []*net.IPNet
+	deny    []*net.IPNet
+	trusted []*net.IPNet
+}
+
+// newIPFilter returns nil when neither list is configured
+func newIPFilter(cfg config.APIConfig) (*ipFilter, error) {
+	if len(cfg.AllowCIDRs) == 0 && len(cfg.DenyCIDRs) == 0 {
+		return nil, nil
+	}
+	
+	allow, err := config.ParseNetworks(cfg.AllowCIDRs)
+	if err != nil {
+		return nil, err
+	}
+	deny, err := config.ParseNetworks(cfg.DenyCIDRs)
+	if err != nil {
+		return nil, err
+	}
+	trusted, err := config.ParseNetworks(cfg.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	
+	return &ipFilter{allow: allow, deny: deny, trusted: trusted}, nil
+}
+
+// allowed reports whether a client address may use the API. Deny entries
+// win over allow entries.
+func (f *ipFilter) allowed(ip net.IP) bool {
+	if ip == nil || containsIP(f.deny, ip) {
+		return false
+	}
+	return len(f.allow) == 0 || containsIP(f.allow, ip)
+}
+
+// clientIP returns the address of the client behind a request. The
+// X-Forwarded-For header is only used when the peer is a trusted proxy; it
+// is walked from the right, skipping trusted proxies, so that clients cannot
+// spoof their address by sending the header themselves.
+func (f *ipFilter) clientIP(r *http.Request) net.IP {
+	ip := remoteIP(r.RemoteAddr)
+	if ip == nil || !containsIP(f.trusted, ip) {
+		return ip
+	}
+	
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		
+		ip = net.ParseIP(hop)
+		if ip == nil {
+			return nil
+		}
+		if !containsIP(f.trusted, ip) {
+			return ip
+		}
+	}
+	return ip
+}
+
+// remoteIP parses the host part of a host:port address
+func remoteIP(addr string) net.IP {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return net.ParseIP(addr)
+}
+
+// containsIP reports whether any of the networks contains ip
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ipFilterMiddleware rejects clients outside the allowed networks before
+// authentication runs
+func (s *Server) ipFilterMiddleware(next http.Handler) http.Handler {
+	if s.ipFilter == nil {
+		return next
+	}
+	
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := s.ipFilter.clientIP(r)
+		if !s.ipFilter.allowed(ip) {
+			logging.Entry(r.Context(), s.log).Warnf("Denied %s %s from %s: address not allowed", r.Method, r.URL.Path, r.RemoteAddr)
+			writeError(w, http.StatusForbidden, codeForbidden, "Client address not allowed")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ipFilterInterceptor rejects calls from peers outside the allowed networks.
+// gRPC calls are not proxied, so only the peer address is considered.
+func (s *GRPCServer) ipFilterInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if s.ipFilter == nil {
+		return handler(ctx, req)
+	}
+	
+	var ip net.IP
+	if p, ok := peer.FromContext(ctx); ok {
+		ip = remoteIP(p.Addr.String())
+	}
+	if !s.ipFilter.allowed(ip) {
+		s.log.Warnf("Denied %s from %v: address not allowed", info.FullMethod, ip)
+		return nil, status.Error(codes.PermissionDenied, "Client address not allowed")
+	}
+	
+	return handler(ctx, req)
+}
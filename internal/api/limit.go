@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http"
+)
+
+// concurrencyExempt are the paths outside api.max_concurrent_requests: the
+// liveness probe must answer under load, so that an overloaded agent is not
+// restarted, and event streams have their own agent.max_event_streams cap
+var concurrencyExempt = map[string]bool{
+	"/api/v1/live":   true,
+	"/api/v1/events": true,
+}
+
+// concurrencyMiddleware rejects requests with 503 while
+// api.max_concurrent_requests are being served, so that a burst of clients
+// cannot exhaust the agent
+func (s *Server) concurrencyMiddleware(next http.Handler) http.Handler {
+	limit := s.config.API.MaxConcurrentRequests
+	if limit <= 0 {
+		return next
+	}
+	
+	slots := make(chan struct{}, limit)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if concurrencyExempt[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		
+		select {
+		case slots <- struct{}{}:
+			defer func() { <-slots }()
+			next.ServeHTTP(w, r)
+		default:
+			w.Header().Set("Retry-After", "1")
+			writeError(w, http.StatusServiceUnavailable, codeUnavailable, "Too many concurrent requests")
+		}
+	})
+}
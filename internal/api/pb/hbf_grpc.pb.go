@@ -0,0 +1,437 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v4.25.1
+// source: hbf.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	ServiceMesh_ListServices_FullMethodName      = "/hbf.v1.ServiceMesh/ListServices"
+	ServiceMesh_GetService_FullMethodName        = "/hbf.v1.ServiceMesh/GetService"
+	ServiceMesh_RegisterService_FullMethodName   = "/hbf.v1.ServiceMesh/RegisterService"
+	ServiceMesh_DeregisterService_FullMethodName = "/hbf.v1.ServiceMesh/DeregisterService"
+)
+
+// ServiceMeshClient is the client API for ServiceMesh service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ServiceMeshClient interface {
+	// ListServices lists registered services, optionally filtered and paged
+	ListServices(ctx context.Context, in *ListServicesRequest, opts ...grpc.CallOption) (*ListServicesResponse, error)
+	// GetService returns a registered service by ID
+	GetService(ctx context.Context, in *GetServiceRequest, opts ...grpc.CallOption) (*Service, error)
+	// RegisterService registers a service instance
+	RegisterService(ctx context.Context, in *RegisterServiceRequest, opts ...grpc.CallOption) (*Service, error)
+	// DeregisterService deregisters a service instance by ID
+	DeregisterService(ctx context.Context, in *DeregisterServiceRequest, opts ...grpc.CallOption) (*DeregisterServiceResponse, error)
+}
+
+type serviceMeshClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewServiceMeshClient(cc grpc.ClientConnInterface) ServiceMeshClient {
+	return &serviceMeshClient{cc}
+}
+
+func (c *serviceMeshClient) ListServices(ctx context.Context, in *ListServicesRequest, opts ...grpc.CallOption) (*ListServicesResponse, error) {
+	out := new(ListServicesResponse)
+	err := c.cc.Invoke(ctx, ServiceMesh_ListServices_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *serviceMeshClient) GetService(ctx context.Context, in *GetServiceRequest, opts ...grpc.CallOption) (*Service, error) {
+	out := new(Service)
+	err := c.cc.Invoke(ctx, ServiceMesh_GetService_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *serviceMeshClient) RegisterService(ctx context.Context, in *RegisterServiceRequest, opts ...grpc.CallOption) (*Service, error) {
+	out := new(Service)
+	err := c.cc.Invoke(ctx, ServiceMesh_RegisterService_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *serviceMeshClient) DeregisterService(ctx context.Context, in *DeregisterServiceRequest, opts ...grpc.CallOption) (*DeregisterServiceResponse, error) {
+	out := new(DeregisterServiceResponse)
+	err := c.cc.Invoke(ctx, ServiceMesh_DeregisterService_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ServiceMeshServer is the server API for ServiceMesh service.
+// All implementations must embed UnimplementedServiceMeshServer
+// for forward compatibility
+type ServiceMeshServer interface {
+	// ListServices lists registered services, optionally filtered and paged
+	ListServices(context.Context, *ListServicesRequest) (*ListServicesResponse, error)
+	// GetService returns a registered service by ID
+	GetService(context.Context, *GetServiceRequest) (*Service, error)
+	// RegisterService registers a service instance
+	RegisterService(context.Context, *RegisterServiceRequest) (*Service, error)
+	// DeregisterService deregisters a service instance by ID
+	DeregisterService(context.Context, *DeregisterServiceRequest) (*DeregisterServiceResponse, error)
+	mustEmbedUnimplementedServiceMeshServer()
+}
+
+// UnimplementedServiceMeshServer must be embedded to have forward compatible implementations.
+type UnimplementedServiceMeshServer struct {
+}
+
+func (UnimplementedServiceMeshServer) ListServices(context.Context, *ListServicesRequest) (*ListServicesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListServices not implemented")
+}
+func (UnimplementedServiceMeshServer) GetService(context.Context, *GetServiceRequest) (*Service, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetService not implemented")
+}
+func (UnimplementedServiceMeshServer) RegisterService(context.Context, *RegisterServiceRequest) (*Service, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RegisterService not implemented")
+}
+func (UnimplementedServiceMeshServer) DeregisterService(context.Context, *DeregisterServiceRequest) (*DeregisterServiceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeregisterService not implemented")
+}
+func (UnimplementedServiceMeshServer) mustEmbedUnimplementedServiceMeshServer() {}
+
+// UnsafeServiceMeshServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ServiceMeshServer will
+// result in compilation errors.
+type UnsafeServiceMeshServer interface {
+	mustEmbedUnimplementedServiceMeshServer()
+}
+
+func RegisterServiceMeshServer(s grpc.ServiceRegistrar, srv ServiceMeshServer) {
+	s.RegisterService(&ServiceMesh_ServiceDesc, srv)
+}
+
+func _ServiceMesh_ListServices_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListServicesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ServiceMeshServer).ListServices(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ServiceMesh_ListServices_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ServiceMeshServer).ListServices(ctx, req.(*ListServicesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ServiceMesh_GetService_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetServiceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ServiceMeshServer).GetService(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ServiceMesh_GetService_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ServiceMeshServer).GetService(ctx, req.(*GetServiceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ServiceMesh_RegisterService_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterServiceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ServiceMeshServer).RegisterService(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ServiceMesh_RegisterService_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ServiceMeshServer).RegisterService(ctx, req.(*RegisterServiceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ServiceMesh_DeregisterService_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeregisterServiceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ServiceMeshServer).DeregisterService(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ServiceMesh_DeregisterService_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ServiceMeshServer).DeregisterService(ctx, req.(*DeregisterServiceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ServiceMesh_ServiceDesc is the grpc.ServiceDesc for ServiceMesh service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ServiceMesh_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "hbf.v1.ServiceMesh",
+	HandlerType: (*ServiceMeshServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListServices",
+			Handler:    _ServiceMesh_ListServices_Handler,
+		},
+		{
+			MethodName: "GetService",
+			Handler:    _ServiceMesh_GetService_Handler,
+		},
+		{
+			MethodName: "RegisterService",
+			Handler:    _ServiceMesh_RegisterService_Handler,
+		},
+		{
+			MethodName: "DeregisterService",
+			Handler:    _ServiceMesh_DeregisterService_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "hbf.proto",
+}
+
+const (
+	Firewall_ListRules_FullMethodName  = "/hbf.v1.Firewall/ListRules"
+	Firewall_GetRule_FullMethodName    = "/hbf.v1.Firewall/GetRule"
+	Firewall_AddRule_FullMethodName    = "/hbf.v1.Firewall/AddRule"
+	Firewall_DeleteRule_FullMethodName = "/hbf.v1.Firewall/DeleteRule"
+)
+
+// FirewallClient is the client API for Firewall service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type FirewallClient interface {
+	// ListRules lists firewall rules, optionally filtered and paged
+	ListRules(ctx context.Context, in *ListRulesRequest, opts ...grpc.CallOption) (*ListRulesResponse, error)
+	// GetRule returns a firewall rule by ID
+	GetRule(ctx context.Context, in *GetRuleRequest, opts ...grpc.CallOption) (*Rule, error)
+	// AddRule adds a firewall rule
+	AddRule(ctx context.Context, in *AddRuleRequest, opts ...grpc.CallOption) (*Rule, error)
+	// DeleteRule removes a firewall rule by ID
+	DeleteRule(ctx context.Context, in *DeleteRuleRequest, opts ...grpc.CallOption) (*DeleteRuleResponse, error)
+}
+
+type firewallClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewFirewallClient(cc grpc.ClientConnInterface) FirewallClient {
+	return &firewallClient{cc}
+}
+
+func (c *firewallClient) ListRules(ctx context.Context, in *ListRulesRequest, opts ...grpc.CallOption) (*ListRulesResponse, error) {
+	out := new(ListRulesResponse)
+	err := c.cc.Invoke(ctx, Firewall_ListRules_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *firewallClient) GetRule(ctx context.Context, in *GetRuleRequest, opts ...grpc.CallOption) (*Rule, error) {
+	out := new(Rule)
+	err := c.cc.Invoke(ctx, Firewall_GetRule_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *firewallClient) AddRule(ctx context.Context, in *AddRuleRequest, opts ...grpc.CallOption) (*Rule, error) {
+	out := new(Rule)
+	err := c.cc.Invoke(ctx, Firewall_AddRule_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *firewallClient) DeleteRule(ctx context.Context, in *DeleteRuleRequest, opts ...grpc.CallOption) (*DeleteRuleResponse, error) {
+	out := new(DeleteRuleResponse)
+	err := c.cc.Invoke(ctx, Firewall_DeleteRule_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// FirewallServer is the server API for Firewall service.
+// All implementations must embed UnimplementedFirewallServer
+// for forward compatibility
+type FirewallServer interface {
+	// ListRules lists firewall rules, optionally filtered and paged
+	ListRules(context.Context, *ListRulesRequest) (*ListRulesResponse, error)
+	// GetRule returns a firewall rule by ID
+	GetRule(context.Context, *GetRuleRequest) (*Rule, error)
+	// AddRule adds a firewall rule
+	AddRule(context.Context, *AddRuleRequest) (*Rule, error)
+	// DeleteRule removes a firewall rule by ID
+	DeleteRule(context.Context, *DeleteRuleRequest) (*DeleteRuleResponse, error)
+	mustEmbedUnimplementedFirewallServer()
+}
+
+// UnimplementedFirewallServer must be embedded to have forward compatible implementations.
+type UnimplementedFirewallServer struct {
+}
+
+func (UnimplementedFirewallServer) ListRules(context.Context, *ListRulesRequest) (*ListRulesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRules not implemented")
+}
+func (UnimplementedFirewallServer) GetRule(context.Context, *GetRuleRequest) (*Rule, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRule not implemented")
+}
+func (UnimplementedFirewallServer) AddRule(context.Context, *AddRuleRequest) (*Rule, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddRule not implemented")
+}
+func (UnimplementedFirewallServer) DeleteRule(context.Context, *DeleteRuleRequest) (*DeleteRuleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteRule not implemented")
+}
+func (UnimplementedFirewallServer) mustEmbedUnimplementedFirewallServer() {}
+
+// UnsafeFirewallServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to FirewallServer will
+// result in compilation errors.
+type UnsafeFirewallServer interface {
+	mustEmbedUnimplementedFirewallServer()
+}
+
+func RegisterFirewallServer(s grpc.ServiceRegistrar, srv FirewallServer) {
+	s.RegisterService(&Firewall_ServiceDesc, srv)
+}
+
+func _Firewall_ListRules_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRulesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FirewallServer).ListRules(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Firewall_ListRules_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FirewallServer).ListRules(ctx, req.(*ListRulesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Firewall_GetRule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRuleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FirewallServer).GetRule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Firewall_GetRule_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FirewallServer).GetRule(ctx, req.(*GetRuleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Firewall_AddRule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddRuleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FirewallServer).AddRule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Firewall_AddRule_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FirewallServer).AddRule(ctx, req.(*AddRuleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Firewall_DeleteRule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRuleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FirewallServer).DeleteRule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Firewall_DeleteRule_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FirewallServer).DeleteRule(ctx, req.(*DeleteRuleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Firewall_ServiceDesc is the grpc.ServiceDesc for Firewall service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Firewall_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "hbf.v1.Firewall",
+	HandlerType: (*FirewallServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListRules",
+			Handler:    _Firewall_ListRules_Handler,
+		},
+		{
+			MethodName: "GetRule",
+			Handler:    _Firewall_GetRule_Handler,
+		},
+		{
+			MethodName: "AddRule",
+			Handler:    _Firewall_AddRule_Handler,
+		},
+		{
+			MethodName: "DeleteRule",
+			Handler:    _Firewall_DeleteRule_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "hbf.proto",
+}
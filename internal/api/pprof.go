@@ -0,0 +1,33 @@
+package api
+
+import (
+	"net/http"
+	"net/http/pprof"
+)
+
+// pprofPrefix is where the profiles are served when
+// monitoring.pprof_enabled is set
+const pprofPrefix = "/api/v1/debug/pprof/"
+
+// pprofHandler serves the net/http/pprof profiles under pprofPrefix, e.g.
+// goroutine, heap and profile (CPU, ?seconds= below api.write_timeout).
+// Profiles expose internals, so they require the same credentials as the
+// rest of the API, and the debug:read permission when roles are defined.
+func (s *Server) pprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	
+	// pprof.Index resolves profile names below /debug/pprof/
+	profiles := http.StripPrefix("/api/v1", mux)
+	
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.requireAuth(w, r) {
+			return
+		}
+		profiles.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,186 @@
+package api
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/yourusername/hbf-agent/internal/config"
+	"github.com/yourusername/hbf-agent/internal/logging"
+)
+
+var (
+	errMissingToken      = errors.New("missing bearer token")
+	errInvalidToken      = errors.New("invalid token")
+	errMissingClientCert = errors.New("a verified client certificate is required")
+	errNoRole            = errors.New("caller has no role")
+)
+
+// missingPermissionError is returned when none of the caller's roles grant
+// the permission
+type missingPermissionError struct {
+	permission string
+}
+
+func (e *missingPermissionError) Error() string {
+	return "missing permission " + e.permission
+}
+
+// rbac enforces security.roles. Callers are identified by their bearer
+// token or, with mtls auth, by their client certificate identities.
+type rbac struct {
+	authType string
+	tokens   []string
+	roles    []config.RoleConfig
+}
+
+// newRBAC returns nil when auth is disabled or no roles are defined
+func newRBAC(security config.SecurityConfig) *rbac {
+	if !security.Auth.Enabled || len(security.Roles) == 0 {
+		return nil
+	}
+	return &rbac{
+		authType: security.Auth.Type,
+		tokens:   security.Auth.Tokens,
+		roles:    security.Roles,
+	}
+}
+
+// authorize checks that the caller holds permission. Callers without a role
+// are denied.
+func (p *rbac) authorize(token string, identities []string, permission string) error {
+	var roles []config.RoleConfig
+	switch p.authType {
+	case "mtls":
+		if len(identities) == 0 {
+			return errMissingClientCert
+		}
+		for _, role := range p.roles {
+			if _, ok := allowedIdentity(identities, role.Identities); ok {
+				roles = append(roles, role)
+			}
+		}
+	default:
+		if token == "" {
+			return errMissingToken
+		}
+		for _, role := range p.roles {
+			if containsToken(role.Tokens, token) {
+				roles = append(roles, role)
+			}
+		}
+		if len(roles) == 0 && !containsToken(p.tokens, token) {
+			return errInvalidToken
+		}
+	}
+	
+	if len(roles) == 0 {
+		return errNoRole
+	}
+	
+	for _, role := range roles {
+		for _, granted := range role.Permissions {
+			if permissionGrants(granted, permission) {
+				return nil
+			}
+		}
+	}
+	return &missingPermissionError{permission: permission}
+}
+
+// containsToken compares tokens in constant time
+func containsToken(tokens []string, token string) bool {
+	for _, valid := range tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(valid)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// bearerToken extracts the token from an Authorization header value
+func bearerToken(header string) string {
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok {
+		return ""
+	}
+	return token
+}
+
+// permissionGrants reports whether a granted permission, which may use "*"
+// for the resource or verb, covers the needed one
+func permissionGrants(granted, needed string) bool {
+	grantedResource, grantedVerb, _ := strings.Cut(granted, ":")
+	neededResource, neededVerb, _ := strings.Cut(needed, ":")
+	
+	return (grantedResource == "*" || grantedResource == neededResource) &&
+		(grantedVerb == "*" || grantedVerb == neededVerb)
+}
+
+// routePermission returns the permission required by a request, or "" for
+// the health probes and unknown paths
+func routePermission(r *http.Request) string {
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1/")
+	// ttl check updates live under the health path but change checks
+	if strings.HasPrefix(path, "health/checks/") {
+		path = strings.TrimPrefix(path, "health/")
+	}
+	
+	// restoring applies a snapshot
+	if path == "restore" {
+		path = "snapshot"
+	}
+	// select/<name> is an alias of services/select
+	if strings.HasPrefix(path, "select/") {
+		path = "services/select"
+	}
+	
+	resource, _, _ := strings.Cut(path, "/")
+	if !config.PermissionResources[resource] {
+		return ""
+	}
+	// validating a rule or previewing a config changes nothing
+	if path == "firewall/rules/validate" || path == "config/diff" {
+		return resource + ":read"
+	}
+	
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return resource + ":read"
+	default:
+		return resource + ":write"
+	}
+}
+
+// rbacMiddleware enforces security.roles on every route
+func (s *Server) rbacMiddleware(next http.Handler) http.Handler {
+	if s.rbac == nil {
+		return next
+	}
+	
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		permission := routePermission(r)
+		if permission == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		
+		err := s.rbac.authorize(bearerToken(r.Header.Get("Authorization")), clientIdentities(r.TLS), permission)
+		if err == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		
+		logging.Entry(r.Context(), s.log).Warnf("Denied %s %s: %v", r.Method, r.URL.Path, err)
+		switch {
+		case errors.Is(err, errMissingToken):
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, codeUnauthenticated, err.Error())
+		case errors.Is(err, errInvalidToken):
+			writeError(w, http.StatusUnauthorized, codeUnauthenticated, err.Error())
+		default:
+			writeError(w, http.StatusForbidden, codeForbidden, err.Error())
+		}
+	})
+}
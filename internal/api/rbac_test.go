@@ -0,0 +1,77 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourusername/hbf-agent/internal/config"
+)
+
+func TestRoutePermission(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		want   string
+	}{
+		{http.MethodGet, "/api/v1/services/select", "services:read"},
+		{http.MethodGet, "/api/v1/select/web", "services:read"},
+		{http.MethodPost, "/api/v1/select/web", "services:write"},
+		{http.MethodPost, "/api/v1/restore", "snapshot:write"},
+		{http.MethodPut, "/api/v1/health/checks/web/pass", "checks:write"},
+		{http.MethodGet, "/api/v1/live", ""},
+	}
+	
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		if got := routePermission(r); got != tt.want {
+			t.Errorf("routePermission(%s %s) = %q, want %q", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRBACProtectsSelectAlias(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	
+	s := &Server{
+		log: log,
+		rbac: newRBAC(config.SecurityConfig{
+			Auth: config.AuthConfig{Enabled: true, Type: "token"},
+			Roles: []config.RoleConfig{
+				{Name: "reader", Permissions: []string{"services:read"}, Tokens: []string{"read-token"}},
+				{Name: "firewall", Permissions: []string{"firewall:*"}, Tokens: []string{"firewall-token"}},
+			},
+		}),
+	}
+	handler := s.rbacMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"unauthenticated", "", http.StatusUnauthorized},
+		{"invalid token", "wrong", http.StatusUnauthorized},
+		{"missing permission", "firewall-token", http.StatusForbidden},
+		{"services:read", "read-token", http.StatusOK},
+	}
+	
+	for _, path := range []string{"/api/v1/select/web", "/api/v1/services/select?name=web"} {
+		for _, tt := range tests {
+			r := httptest.NewRequest(http.MethodGet, path, nil)
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("%s: GET %s = %d, want %d", tt.name, path, w.Code, tt.want)
+			}
+		}
+	}
+}
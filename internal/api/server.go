@@ -1,57 +1,223 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/yourusername/hbf-agent/internal/config"
 	"github.com/yourusername/hbf-agent/internal/firewall"
+	"github.com/yourusername/hbf-agent/internal/health"
+	"github.com/yourusername/hbf-agent/internal/logging"
+	"github.com/yourusername/hbf-agent/internal/secrets"
+	"github.com/yourusername/hbf-agent/internal/snapshot"
+	"github.com/yourusername/hbf-agent/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 	"github.com/yourusername/hbf-agent/internal/servicemesh"
 )
 
+// Component is an agent component that can report its health
+type Component interface {
+	Healthy() error
+}
+
 // Server represents the API server
 type Server struct {
 	config      *config.Config
 	log         *logrus.Logger
 	firewall    *firewall.Manager
 	serviceMesh *servicemesh.Manager
+	healthCheck *health.Checker
 	server      *http.Server
+	streams     int32
+	components  map[string]Component
+	isLeader    func() bool
+	metrics     MetricsRecorder
+	current     func() *config.Config
+	state       StateStore
+	rbac        *rbac
+	ipFilter    *ipFilter
+	promHandler http.Handler
+}
+
+// StateStore exports and restores the state of the agent. It is satisfied
+// by agent.Agent.
+type StateStore interface {
+	Snapshot() (*snapshot.StateSnapshot, error)
+	Restore(ctx context.Context, snap *snapshot.StateSnapshot) (*snapshot.RestoreResult, error)
+}
+
+// MetricsRecorder records API server metrics. It is satisfied by
+// metrics.Manager.
+type MetricsRecorder interface {
+	RecordAPIRequest(method, path, status string, duration float64)
+	AddAPIRequestsInFlight(method, path string, delta float64)
+	SetCertExpiry(name string, notAfter time.Time)
 }
 
 // NewServer creates a new API server
 func NewServer(cfg *config.Config, fw *firewall.Manager, sm *servicemesh.Manager, log *logrus.Logger) (*Server, error) {
+	filter, err := newIPFilter(cfg.API)
+	if err != nil {
+		return nil, err
+	}
+	
 	return &Server{
 		config:      cfg,
 		log:         log,
 		firewall:    fw,
 		serviceMesh: sm,
+		components:  make(map[string]Component),
+		rbac:        newRBAC(cfg.Security),
+		ipFilter:    filter,
 	}, nil
 }
 
+// SetHealthChecker enables the health check endpoints
+func (s *Server) SetHealthChecker(checker *health.Checker) {
+	s.healthCheck = checker
+}
+
+// SetLeaderCheck restricts writes to cluster-wide state to the leader.
+// Non-leaders keep serving reads.
+func (s *Server) SetLeaderCheck(isLeader func() bool) {
+	s.isLeader = isLeader
+}
+
+// requireLeader rejects the request if this agent is not the leader
+func (s *Server) requireLeader(w http.ResponseWriter) bool {
+	if s.isLeader != nil && !s.isLeader() {
+		writeError(w, http.StatusServiceUnavailable, codeNotLeader, "This agent is not the cluster leader")
+		return false
+	}
+	return true
+}
+
+// SetMetrics sets the recorder used for API request metrics. It must be
+// called before Start.
+func (s *Server) SetMetrics(metrics MetricsRecorder) {
+	s.metrics = metrics
+}
+
+// SetMetricsHandler mounts the Prometheus exposition handler on
+// /api/v1/metrics
+func (s *Server) SetMetricsHandler(handler http.Handler) {
+	s.promHandler = handler
+}
+
+// SetConfigSource sets the function returning the configuration currently
+// in effect, which may change on reload
+func (s *Server) SetConfigSource(current func() *config.Config) {
+	s.current = current
+}
+
+// SetStateStore enables the snapshot and restore endpoints
+func (s *Server) SetStateStore(state StateStore) {
+	s.state = state
+}
+
+// RegisterComponent adds a component to the health and readiness checks.
+// Components must be registered before Start is called.
+func (s *Server) RegisterComponent(name string, component Component) {
+	s.components[name] = component
+}
+
 // Start starts the API server
 func (s *Server) Start() error {
 	mux := http.NewServeMux()
 	
-	// Health endpoint
+	// Health endpoints
 	mux.HandleFunc("/api/v1/health", s.handleHealth)
+	mux.HandleFunc("/api/v1/live", s.handleLive)
+	mux.HandleFunc("/api/v1/ready", s.handleReady)
 	
 	// Service endpoints
 	mux.HandleFunc("/api/v1/services", s.handleServices)
 	mux.HandleFunc("/api/v1/services/", s.handleServiceByID)
+	mux.HandleFunc("/api/v1/services/select", s.handleSelectService)
+	mux.HandleFunc("/api/v1/select/", s.handleSelectService)
+	
+	// Health check endpoints
+	mux.HandleFunc("/api/v1/checks", s.handleChecks)
+	mux.HandleFunc("/api/v1/checks/", s.handleCheckByID)
+	mux.HandleFunc("/api/v1/health/checks/", s.handleCheckStatus)
 	
 	// Firewall endpoints
 	mux.HandleFunc("/api/v1/firewall/rules", s.handleFirewallRules)
 	mux.HandleFunc("/api/v1/firewall/rules/", s.handleFirewallRuleByID)
+	mux.HandleFunc("/api/v1/firewall/rules/batch", s.handleFirewallRulesBatch)
+	mux.HandleFunc("/api/v1/firewall/rules/validate", s.handleFirewallRuleValidate)
+	mux.HandleFunc("/api/v1/firewall/conflicts", s.handleFirewallConflicts)
+	mux.HandleFunc("/api/v1/firewall/drops", s.handleFirewallDrops)
+	mux.HandleFunc("/api/v1/firewall/groups/", s.handleFirewallGroup)
+	mux.HandleFunc("/api/v1/firewall/flush", s.handleFirewallFlush)
+	mux.HandleFunc("/api/v1/firewall/reload", s.handleFirewallReload)
+	
+	// Configuration endpoint
+	mux.HandleFunc("/api/v1/config", s.handleConfig)
+	mux.HandleFunc("/api/v1/config/diff", s.handleConfigDiff)
+	
+	// Snapshot endpoints
+	mux.HandleFunc("/api/v1/snapshot", s.handleSnapshot)
+	mux.HandleFunc("/api/v1/restore", s.handleRestore)
 	
-	// Metrics endpoint (redirects to metrics server)
+	// Event stream endpoint
+	mux.HandleFunc("/api/v1/events", s.handleEvents)
+	
+	// Metrics endpoint, serving the same registry as the metrics server
 	mux.HandleFunc("/api/v1/metrics", s.handleMetrics)
 	
+	// Profiling endpoints, off unless enabled
+	if s.config.Monitoring.PprofEnabled {
+		mux.Handle(pprofPrefix, s.pprofHandler())
+		s.log.Warnf("Profiling endpoints enabled on %s", pprofPrefix)
+	}
+	
+	// Middleware, innermost first
+	handler := s.compressMiddleware(mux)
+	handler = s.metricsMiddleware(mux, handler)
+	handler = s.mtlsAuthMiddleware(handler)
+	handler = s.rbacMiddleware(handler)
+	handler = s.callerMiddleware(handler)
+	handler = s.concurrencyMiddleware(handler)
+	handler = s.ipFilterMiddleware(handler)
+	handler = s.loggingMiddleware(handler)
+	handler = s.requestIDMiddleware(handler)
+	handler = s.tracingMiddleware(handler)
+	
 	s.server = &http.Server{
-		Addr:    fmt.Sprintf("%s:%d", s.config.Agent.BindAddr, s.config.Agent.APIPort),
-		Handler: s.loggingMiddleware(mux),
+		Addr:         fmt.Sprintf("%s:%d", s.config.Agent.BindAddr, s.config.Agent.APIPort),
+		Handler:      handler,
+		ReadTimeout:  s.config.API.ReadTimeout,
+		WriteTimeout: s.config.API.WriteTimeout,
+		IdleTimeout:  s.config.API.IdleTimeout,
+	}
+	
+	if s.config.Security.MTLS.Enabled {
+		var onLoad certExpiryFunc
+		if s.metrics != nil {
+			onLoad = s.metrics.SetCertExpiry
+		}
+		tlsConfig, err := serverTLSConfig(s.config.Security, onLoad, s.log)
+		if err != nil {
+			return err
+		}
+		s.server.TLSConfig = tlsConfig
+		
+		s.log.Infof("API server listening on %s (mTLS)", s.server.Addr)
+		return s.server.ListenAndServeTLS("", "")
 	}
 	
 	s.log.Infof("API server listening on %s", s.server.Addr)
@@ -70,28 +236,201 @@ func (s *Server) Stop() error {
 
 func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		s.log.Infof("%s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+		logging.Entry(r.Context(), s.log).Infof("%s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
 		next.ServeHTTP(w, r)
 	})
 }
 
+// tracingMiddleware starts a server span for each request, continuing the
+// trace from an incoming traceparent header if present
+func (s *Server) tracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracing.Start(ctx, r.Method+" "+r.URL.Path,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.method", r.Method),
+				attribute.String("http.target", r.URL.Path),
+				attribute.String("net.peer.addr", r.RemoteAddr),
+			),
+		)
+		defer span.End()
+		
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requestIDMiddleware attaches a request ID to the request context and the
+// response. A well-formed incoming X-Request-ID is reused so that callers can
+// correlate their own logs; otherwise a new ID is generated.
+func (s *Server) requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(logging.RequestIDHeader)
+		if !validRequestID(id) {
+			id = logging.NewRequestID()
+		}
+		
+		w.Header().Set(logging.RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+	})
+}
+
+// metricsMiddleware records request counts, durations and in-flight
+// requests. Requests are labeled with the mux pattern that matched them
+// rather than the raw path, so IDs in the path do not create new series.
+func (s *Server) metricsMiddleware(mux *http.ServeMux, next http.Handler) http.Handler {
+	if s.metrics == nil {
+		return next
+	}
+	
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, path := mux.Handler(r)
+		if path == "" {
+			path = "unmatched"
+		}
+		
+		s.metrics.AddAPIRequestsInFlight(r.Method, path, 1)
+		defer s.metrics.AddAPIRequestsInFlight(r.Method, path, -1)
+		
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(rec, r)
+		
+		s.metrics.RecordAPIRequest(r.Method, path, strconv.Itoa(rec.status), time.Since(start).Seconds())
+	})
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(p []byte) (int, error) {
+	rec.wroteHeader = true
+	return rec.ResponseWriter.Write(p)
+}
+
+// Flush keeps the event stream working through the recorder
+func (rec *statusRecorder) Flush() {
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the connection
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// validRequestID accepts short IDs made of characters that are safe to log
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	
+	return true
+}
+
 // Handlers
 
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
 		return
 	}
 	
+	healthy, components := s.componentStatus()
+	
+	status := "healthy"
+	code := http.StatusOK
+	if !healthy {
+		status = "unhealthy"
+		code = http.StatusServiceUnavailable
+	}
+	
 	response := map[string]interface{}{
-		"status": "healthy",
+		"status": status,
 		"agent": map[string]string{
 			"node_id":    s.config.Agent.NodeID,
 			"datacenter": s.config.Agent.Datacenter,
 		},
+		"components": components,
+	}
+	if s.firewall != nil {
+		response["firewall_mode"] = s.firewall.Mode()
+	}
+	if s.serviceMesh != nil {
+		response["discovery"] = s.serviceMesh.DiscoveryStatus()
+	}
+	
+	s.writeJSON(w, code, response)
+}
+
+// handleLive reports whether the agent process is alive. It does not check
+// components, so a failing dependency doesn't get the agent restarted.
+func (s *Server) handleLive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	
+	s.writeJSON(w, http.StatusOK, map[string]string{"status": "alive"})
+}
+
+// handleReady reports whether all components are healthy
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	
+	healthy, components := s.componentStatus()
+	if !healthy {
+		s.writeJSON(w, http.StatusServiceUnavailable, map[string]interface{}{
+			"status":     "not_ready",
+			"components": components,
+		})
+		return
+	}
+	
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":     "ready",
+		"components": components,
+	})
+}
+
+// componentStatus checks every registered component and returns whether all
+// are healthy along with a per-component breakdown
+func (s *Server) componentStatus() (bool, map[string]string) {
+	healthy := true
+	status := make(map[string]string, len(s.components))
+	
+	for name, component := range s.components {
+		if err := component.Healthy(); err != nil {
+			healthy = false
+			status[name] = err.Error()
+			continue
+		}
+		status[name] = "healthy"
 	}
 	
-	s.writeJSON(w, http.StatusOK, response)
+	return healthy, status
 }
 
 func (s *Server) handleServices(w http.ResponseWriter, r *http.Request) {
@@ -99,148 +438,1103 @@ func (s *Server) handleServices(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		s.listServices(w, r)
 	case http.MethodPost:
+		if !s.requireLeader(w) {
+			return
+		}
 		s.registerService(w, r)
+	case http.MethodDelete:
+		if !s.requireLeader(w) {
+			return
+		}
+		s.deregisterServicesByName(w, r)
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
 	}
 }
 
 func (s *Server) listServices(w http.ResponseWriter, r *http.Request) {
 	if s.serviceMesh == nil {
-		http.Error(w, "Service mesh not enabled", http.StatusServiceUnavailable)
+		writeError(w, http.StatusServiceUnavailable, codeUnavailable, "Service mesh not enabled")
+		return
+	}
+	
+	limit, offset, err := pageParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
 		return
 	}
 	
-	services := s.serviceMesh.ListServices()
-	s.writeJSON(w, http.StatusOK, services)
+	query := r.URL.Query()
+	services, total := s.serviceMesh.ListServicesFiltered(servicemesh.ServiceFilter{
+		Status: servicemesh.ServiceStatus(query.Get("status")),
+		Tag:    query.Get("tag"),
+		Limit:  limit,
+		Offset: offset,
+	})
+	
+	s.writeJSON(w, http.StatusOK, newPage(services, len(services), total, offset))
 }
 
 func (s *Server) registerService(w http.ResponseWriter, r *http.Request) {
 	if s.serviceMesh == nil {
-		http.Error(w, "Service mesh not enabled", http.StatusServiceUnavailable)
+		writeError(w, http.StatusServiceUnavailable, codeUnavailable, "Service mesh not enabled")
 		return
 	}
 	
 	var service servicemesh.Service
 	if err := json.NewDecoder(r.Body).Decode(&service); err != nil {
-		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeBadRequest, fmt.Sprintf("Invalid request body: %v", err))
 		return
 	}
 	
-	if err := s.serviceMesh.RegisterService(&service); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to register service: %v", err), http.StatusInternalServerError)
+	if err := s.serviceMesh.RegisterServiceContext(r.Context(), &service); err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, servicemesh.ErrAlreadyRegistered):
+			status = http.StatusConflict
+		case errors.Is(err, servicemesh.ErrInvalidService):
+			status = http.StatusBadRequest
+		}
+		writeValidationError(w, status, fmt.Sprintf("Failed to register service: %v", err), err)
 		return
 	}
 	
 	s.writeJSON(w, http.StatusCreated, service)
 }
 
+// deregisterServicesByName removes every instance of the service named by
+// ?name=
+func (s *Server) deregisterServicesByName(w http.ResponseWriter, r *http.Request) {
+	if s.serviceMesh == nil {
+		writeError(w, http.StatusServiceUnavailable, codeUnavailable, "Service mesh not enabled")
+		return
+	}
+	
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "name is required")
+		return
+	}
+	
+	removed, err := s.serviceMesh.DeregisterServiceByNameContext(r.Context(), name)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, errorCode(err, codeInternal), fmt.Sprintf("Deregistered %d instance(s), but some failed: %v", removed, err))
+		return
+	}
+	
+	if removed == 0 {
+		writeError(w, http.StatusNotFound, codeNotFound, fmt.Sprintf("No instances of service %s", name))
+		return
+	}
+	
+	s.writeJSON(w, http.StatusOK, map[string]int{"deregistered": removed})
+}
+
 func (s *Server) handleServiceByID(w http.ResponseWriter, r *http.Request) {
 	if s.serviceMesh == nil {
-		http.Error(w, "Service mesh not enabled", http.StatusServiceUnavailable)
+		writeError(w, http.StatusServiceUnavailable, codeUnavailable, "Service mesh not enabled")
 		return
 	}
 	
 	// Extract service ID from path
 	parts := strings.Split(r.URL.Path, "/")
 	if len(parts) < 5 {
-		http.Error(w, "Invalid service ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid service ID")
 		return
 	}
 	serviceID := parts[4]
 	
+	if len(parts) > 5 {
+		switch {
+		case len(parts) == 6 && parts[5] == "maintenance":
+			s.setServiceMaintenance(w, r, serviceID)
+		case len(parts) == 6 && parts[5] == "probe":
+			s.probeService(w, r, serviceID)
+		default:
+			http.NotFound(w, r)
+		}
+		return
+	}
+	
 	switch r.Method {
 	case http.MethodGet:
 		service, err := s.serviceMesh.GetService(serviceID)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			writeError(w, http.StatusNotFound, codeNotFound, err.Error())
 			return
 		}
 		s.writeJSON(w, http.StatusOK, service)
 		
 	case http.MethodDelete:
-		if err := s.serviceMesh.DeregisterService(serviceID); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		if !s.requireLeader(w) {
+			return
+		}
+		if err := s.serviceMesh.DeregisterServiceContext(r.Context(), serviceID); err != nil {
+			writeError(w, http.StatusInternalServerError, errorCode(err, codeInternal), err.Error())
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
 		
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
 	}
 }
 
-func (s *Server) handleFirewallRules(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodGet:
-		s.listFirewallRules(w, r)
-	case http.MethodPost:
-		s.addFirewallRule(w, r)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// probeService handles GET /api/v1/services/{id}/probe: it probes the
+// instance once from this agent, with its HealthCheck type or else a TCP
+// connect to its address and port, and returns the result and latency.
+// Instances not registered here are looked up in the discovery of the
+// service named by ?service=. Check state is left untouched.
+func (s *Server) probeService(w http.ResponseWriter, r *http.Request, serviceID string) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if s.healthCheck == nil {
+		writeError(w, http.StatusServiceUnavailable, codeUnavailable, "Health checker not enabled")
+		return
+	}
+	
+	service, err := s.serviceMesh.GetService(serviceID)
+	if errors.Is(err, servicemesh.ErrServiceNotFound) && r.URL.Query().Get("service") != "" {
+		service, err = s.discoveredInstance(r.Context(), r.URL.Query().Get("service"), serviceID)
+	}
+	if err != nil {
+		writeError(w, http.StatusNotFound, errorCode(err, codeNotFound), err.Error())
+		return
 	}
+	
+	check := &health.Check{
+		Type:   "tcp",
+		Target: net.JoinHostPort(service.Address, strconv.Itoa(service.Port)),
+	}
+	if target, ok := service.CheckTarget(); ok {
+		check.Type = service.HealthCheck.Type
+		check.Target = target
+		check.Timeout = service.HealthCheck.Timeout
+	}
+	
+	result, err := s.healthCheck.Probe(check)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errorCode(err, codeBadRequest), err.Error())
+		return
+	}
+	
+	s.writeJSON(w, http.StatusOK, struct {
+		ServiceID   string `json:"service_id"`
+		ServiceName string `json:"service_name"`
+		*health.ProbeResult
+	}{service.ID, service.Name, result})
 }
 
-func (s *Server) listFirewallRules(w http.ResponseWriter, r *http.Request) {
-	rules := s.firewall.ListRules()
-	s.writeJSON(w, http.StatusOK, rules)
+// discoveredInstance finds an instance of a service in its discovery
+func (s *Server) discoveredInstance(ctx context.Context, serviceName, serviceID string) (*servicemesh.Service, error) {
+	instances, err := s.serviceMesh.DiscoverServiceContext(ctx, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	for _, instance := range instances {
+		if instance.ID == serviceID {
+			return instance, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no instance %s of service %s", servicemesh.ErrServiceNotFound, serviceID, serviceName)
 }
 
-func (s *Server) addFirewallRule(w http.ResponseWriter, r *http.Request) {
-	var rule firewall.Rule
-	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
-		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+// setServiceMaintenance handles PUT /api/v1/services/{id}/maintenance with
+// {"enabled": true|false}. The flag defaults to true.
+func (s *Server) setServiceMaintenance(w http.ResponseWriter, r *http.Request, serviceID string) {
+	if r.Method != http.MethodPut {
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
 		return
 	}
+	if !s.requireLeader(w) {
+		return
+	}
+	
+	update := struct {
+		Enabled bool `json:"enabled"`
+	}{Enabled: true}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			writeError(w, http.StatusBadRequest, codeBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+			return
+		}
+	}
 	
-	if err := s.firewall.AddRule(&rule); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to add rule: %v", err), http.StatusInternalServerError)
+	if err := s.serviceMesh.SetMaintenance(serviceID, update.Enabled); err != nil {
+		writeError(w, http.StatusNotFound, errorCode(err, codeInternal), err.Error())
 		return
 	}
 	
-	s.writeJSON(w, http.StatusCreated, rule)
+	service, err := s.serviceMesh.GetService(serviceID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, codeNotFound, err.Error())
+		return
+	}
+	s.writeJSON(w, http.StatusOK, service)
 }
 
-func (s *Server) handleFirewallRuleByID(w http.ResponseWriter, r *http.Request) {
-	// Extract rule ID from path
+// handleSelectService selects an instance of ?name= with the configured
+// load balancing, restricted to instances with every ?tag= and every
+// ?meta=key:value
+func (s *Server) handleSelectService(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	
+	if s.serviceMesh == nil {
+		writeError(w, http.StatusServiceUnavailable, codeUnavailable, "Service mesh not enabled")
+		return
+	}
+	
+	query := r.URL.Query()
+	name := query.Get("name")
+	if strings.HasPrefix(r.URL.Path, "/api/v1/select/") {
+		name = strings.TrimPrefix(r.URL.Path, "/api/v1/select/")
+	}
+	if name == "" {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "name is required")
+		return
+	}
+	
+	var meta map[string]string
+	for _, pair := range query["meta"] {
+		key, value, ok := strings.Cut(pair, ":")
+		if !ok || key == "" {
+			writeError(w, http.StatusBadRequest, codeBadRequest, fmt.Sprintf("Invalid meta filter %q: expected key:value", pair))
+			return
+		}
+		if meta == nil {
+			meta = make(map[string]string)
+		}
+		meta[key] = value
+	}
+	
+	if query.Get("explain") == "true" {
+		explanation, err := s.serviceMesh.ExplainSelection(r.Context(), name, query["tag"], meta)
+		if err != nil {
+			writeError(w, http.StatusServiceUnavailable, codeUnavailable, err.Error())
+			return
+		}
+		s.writeJSON(w, http.StatusOK, explanation)
+		return
+	}
+	
+	service, err := s.serviceMesh.SelectServiceFilteredContext(r.Context(), name, query["tag"], meta)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, codeUnavailable, err.Error())
+		return
+	}
+	
+	s.writeJSON(w, http.StatusOK, service)
+}
+
+func (s *Server) handleChecks(w http.ResponseWriter, r *http.Request) {
+	if s.healthCheck == nil {
+		writeError(w, http.StatusServiceUnavailable, codeUnavailable, "Health checker not enabled")
+		return
+	}
+	
+	switch r.Method {
+	case http.MethodGet:
+		s.writeJSON(w, http.StatusOK, s.healthCheck.ListChecks())
+	case http.MethodPost:
+		var check health.Check
+		if err := json.NewDecoder(r.Body).Decode(&check); err != nil {
+			writeError(w, http.StatusBadRequest, codeBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+			return
+		}
+		
+		if err := s.healthCheck.AddCheck(&check); err != nil {
+			writeError(w, http.StatusBadRequest, errorCode(err, codeBadRequest), fmt.Sprintf("Failed to add check: %v", err))
+			return
+		}
+		
+		s.writeJSON(w, http.StatusCreated, check)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+	}
+}
+
+func (s *Server) handleCheckByID(w http.ResponseWriter, r *http.Request) {
+	if s.healthCheck == nil {
+		writeError(w, http.StatusServiceUnavailable, codeUnavailable, "Health checker not enabled")
+		return
+	}
+	
+	// Extract check ID from path
 	parts := strings.Split(r.URL.Path, "/")
-	if len(parts) < 6 {
-		http.Error(w, "Invalid rule ID", http.StatusBadRequest)
+	if len(parts) < 5 || parts[4] == "" {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid check ID")
 		return
 	}
-	ruleID := parts[5]
+	checkID := parts[4]
 	
 	switch r.Method {
 	case http.MethodGet:
-		rule, err := s.firewall.GetRule(ruleID)
+		check, err := s.healthCheck.GetCheck(checkID)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			writeError(w, http.StatusNotFound, codeNotFound, err.Error())
 			return
 		}
-		s.writeJSON(w, http.StatusOK, rule)
+		s.writeJSON(w, http.StatusOK, check)
 		
 	case http.MethodDelete:
-		if err := s.firewall.DeleteRule(ruleID); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		if err := s.healthCheck.RemoveCheck(checkID); err != nil {
+			writeError(w, http.StatusNotFound, codeNotFound, err.Error())
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
 		
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
 	}
 }
 
-func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
-	response := map[string]interface{}{
-		"message": "Metrics available at /metrics endpoint",
-		"port":    s.config.Monitoring.MetricsPort,
-		"path":    s.config.Monitoring.MetricsPath,
+// handleCheckStatus records a heartbeat of a ttl check:
+// PUT /api/v1/health/checks/{id}/status with {"status": "...", "output": "..."}.
+// The status defaults to passing.
+func (s *Server) handleCheckStatus(w http.ResponseWriter, r *http.Request) {
+	if s.healthCheck == nil {
+		writeError(w, http.StatusServiceUnavailable, codeUnavailable, "Health checker not enabled")
+		return
+	}
+	
+	checkID, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/v1/health/checks/"), "/")
+	if checkID == "" || (rest != "status" && rest != "history") {
+		http.NotFound(w, r)
+		return
+	}
+	
+	if rest == "history" {
+		s.handleCheckHistory(w, r, checkID)
+		return
+	}
+	
+	if r.Method != http.MethodPut {
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	
+	var update struct {
+		Status health.CheckStatus `json:"status"`
+		Output string             `json:"output"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			writeError(w, http.StatusBadRequest, codeBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+			return
+		}
+	}
+	if update.Status == "" {
+		update.Status = health.StatusPassing
+	}
+	
+	check, err := s.healthCheck.GetCheck(checkID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, codeNotFound, err.Error())
+		return
+	}
+	
+	if err := s.healthCheck.UpdateTTL(checkID, update.Status, update.Output); err != nil {
+		writeError(w, http.StatusBadRequest, errorCode(err, codeBadRequest), err.Error())
+		return
 	}
-	s.writeJSON(w, http.StatusOK, response)
+	
+	s.writeJSON(w, http.StatusOK, check)
 }
 
-// Helper methods
+// handleCheckHistory serves the latest results of a check
+func (s *Server) handleCheckHistory(w http.ResponseWriter, r *http.Request, checkID string) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	
+	limit, _, err := pageParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	
+	history, err := s.healthCheck.History(checkID, limit)
+	if err != nil {
+		writeError(w, http.StatusNotFound, errorCode(err, codeNotFound), err.Error())
+		return
+	}
+	s.writeJSON(w, http.StatusOK, history)
+}
+
+func (s *Server) handleFirewallRules(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.listFirewallRules(w, r)
+	case http.MethodPost:
+		s.addFirewallRule(w, r)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+	}
+}
+
+func (s *Server) listFirewallRules(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := pageParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	
+	query := r.URL.Query()
+	rules, total := s.firewall.ListRulesFiltered(firewall.RuleFilter{
+		Chain:  query.Get("chain"),
+		Action: query.Get("action"),
+		Group:  query.Get("group"),
+		Limit:  limit,
+		Offset: offset,
+	})
+	
+	s.writeJSON(w, http.StatusOK, newPage(rules, len(rules), total, offset))
+}
+
+// ruleRequest is a rule in a request body, with an optional "ttl" duration
+// such as "1h" after which the rule is removed. Rules are enabled unless
+// "Enabled" is false.
+type ruleRequest struct {
+	firewall.Rule
+	TTLString string `json:"ttl"`
+	Enabled   *bool  `json:"Enabled"`
+}
+
+// rulePatch is the body of PATCH /api/v1/firewall/rules/{id}
+type rulePatch struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// ruleView is a rule in a response, with the time left before it expires
+type ruleView struct {
+	*firewall.Rule
+	Remaining string `json:"remaining,omitempty"`
+}
+
+func newRuleView(rule *firewall.Rule) ruleView {
+	view := ruleView{Rule: rule}
+	if !rule.ExpiresAt.IsZero() {
+		view.Remaining = rule.Remaining().Round(time.Second).String()
+	}
+	return view
+}
+
+// decodeRuleRequest reads a rule from the request body, writing a 400
+// response if it cannot be decoded
+func decodeRuleRequest(w http.ResponseWriter, r *http.Request) (*firewall.Rule, bool) {
+	var req ruleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return nil, false
+	}
+	
+	rule := req.Rule
+	rule.Enabled = req.Enabled == nil || *req.Enabled
+	if req.TTLString != "" {
+		ttl, err := time.ParseDuration(req.TTLString)
+		if err != nil || ttl <= 0 {
+			writeError(w, http.StatusBadRequest, codeBadRequest, fmt.Sprintf("Invalid ttl %q: expected a positive duration such as 1h", req.TTLString))
+			return nil, false
+		}
+		rule.TTL = ttl
+	}
+	
+	return &rule, true
+}
+
+func (s *Server) addFirewallRule(w http.ResponseWriter, r *http.Request) {
+	rule, ok := decodeRuleRequest(w, r)
+	if !ok {
+		return
+	}
+	
+	if err := s.firewall.AddRuleContext(r.Context(), rule); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, firewall.ErrInvalidRule) {
+			status = http.StatusBadRequest
+		}
+		writeError(w, status, errorCode(err, codeInternal), fmt.Sprintf("Failed to add rule: %v", err))
+		return
+	}
+	
+	s.writeJSON(w, http.StatusCreated, newRuleView(rule))
+}
+
+// handleFirewallRuleValidate checks a rule without applying it. Invalid
+// rules are reported with all their problems.
+func (s *Server) handleFirewallRuleValidate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	
+	rule, ok := decodeRuleRequest(w, r)
+	if !ok {
+		return
+	}
+	
+	var problems []string
+	if err := s.firewall.ValidateRule(rule); err != nil {
+		var validationErr *config.ValidationError
+		if !errors.As(err, &validationErr) {
+			writeError(w, http.StatusInternalServerError, codeInternal, err.Error())
+			return
+		}
+		problems = validationErr.Problems
+	}
+	
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"valid":    len(problems) == 0,
+		"problems": problems,
+	})
+}
+
+// handleFirewallConflicts lists the rules that can never match because an
+// earlier rule of their chain covers them
+func (s *Server) handleFirewallConflicts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"conflicts": s.firewall.DetectConflicts(),
+	})
+}
+
+func (s *Server) handleFirewallDrops(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	
+	limit, _, err := pageParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	
+	drops, err := s.firewall.Drops(limit)
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, codeUnavailable, err.Error())
+		return
+	}
+	
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"drops": drops,
+	})
+}
+
+func (s *Server) handleFirewallRulesBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	
+	var rules []*firewall.Rule
+	if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	
+	atomic := r.URL.Query().Get("atomic") == "true"
+	
+	var ids []string
+	var err error
+	if atomic {
+		ids, err = s.firewall.AddRulesAtomicContext(r.Context(), rules)
+	} else {
+		ids, err = s.firewall.AddRulesContext(r.Context(), rules)
+	}
+	
+	response := map[string]interface{}{
+		"ids": ids,
+	}
+	
+	if err == nil {
+		s.writeJSON(w, http.StatusCreated, response)
+		return
+	}
+	
+	batchErr, ok := err.(*firewall.BatchError)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errorCode(err, codeInternal), fmt.Sprintf("Failed to add rules: %v", err))
+		return
+	}
+	
+	errors := make(map[string]string, len(batchErr.Errors))
+	for i, ruleErr := range batchErr.Errors {
+		errors[fmt.Sprintf("%d", i)] = ruleErr.Error()
+	}
+	response["errors"] = errors
+	
+	status := http.StatusMultiStatus
+	if atomic {
+		status = http.StatusUnprocessableEntity
+	}
+	s.writeJSON(w, status, response)
+}
+
+func (s *Server) handleFirewallRuleByID(w http.ResponseWriter, r *http.Request) {
+	// Extract rule ID from path
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) < 6 {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid rule ID")
+		return
+	}
+	ruleID := parts[5]
+	
+	switch r.Method {
+	case http.MethodGet:
+		rule, err := s.firewall.GetRule(ruleID)
+		if err != nil {
+			writeError(w, http.StatusNotFound, codeNotFound, err.Error())
+			return
+		}
+		s.writeJSON(w, http.StatusOK, newRuleView(rule))
+		
+	case http.MethodPatch:
+		var patch rulePatch
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			writeError(w, http.StatusBadRequest, codeBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+			return
+		}
+		if patch.Enabled == nil {
+			writeError(w, http.StatusBadRequest, codeBadRequest, "enabled is required")
+			return
+		}
+		
+		rule, err := s.firewall.SetRuleEnabled(r.Context(), ruleID, *patch.Enabled)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, firewall.ErrRuleNotFound) {
+				status = http.StatusNotFound
+			}
+			writeError(w, status, errorCode(err, codeInternal), err.Error())
+			return
+		}
+		s.writeJSON(w, http.StatusOK, newRuleView(rule))
+		
+	case http.MethodDelete:
+		if err := s.firewall.DeleteRuleContext(r.Context(), ruleID); err != nil {
+			writeError(w, http.StatusInternalServerError, errorCode(err, codeInternal), err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		
+	default:
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// handleFirewallGroup lists (GET) or deletes (DELETE) the rules of a group
+func (s *Server) handleFirewallGroup(w http.ResponseWriter, r *http.Request) {
+	group := strings.TrimPrefix(r.URL.Path, "/api/v1/firewall/groups/")
+	if group == "" || strings.Contains(group, "/") {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "Invalid group name")
+		return
+	}
+	
+	switch r.Method {
+	case http.MethodGet:
+		rules := s.firewall.ListRulesByGroup(group)
+		if len(rules) == 0 {
+			writeError(w, http.StatusNotFound, codeNotFound, fmt.Sprintf("%v: %s", firewall.ErrGroupNotFound, group))
+			return
+		}
+		views := make([]ruleView, 0, len(rules))
+		for _, rule := range rules {
+			views = append(views, newRuleView(rule))
+		}
+		s.writeJSON(w, http.StatusOK, map[string]interface{}{"group": group, "rules": views})
+		
+	case http.MethodDelete:
+		deleted, err := s.firewall.DeleteRulesByGroupContext(r.Context(), group)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, firewall.ErrGroupNotFound) {
+				status = http.StatusNotFound
+			}
+			writeError(w, status, errorCode(err, codeInternal), err.Error())
+			return
+		}
+		s.writeJSON(w, http.StatusOK, map[string]interface{}{"group": group, "deleted": deleted})
+		
+	default:
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// handleFirewallFlush removes all managed rules and restores the default
+// policies
+func (s *Server) handleFirewallFlush(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	
+	if !s.requireAuth(w, r) {
+		return
+	}
+	
+	if err := s.firewall.FlushContext(r.Context()); err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, err.Error())
+		return
+	}
+	
+	s.writeJSON(w, http.StatusOK, map[string]int{"rules": len(s.firewall.ListRules())})
+}
+
+// handleFirewallReload flushes all managed rules and reapplies the rules
+// from the configuration
+func (s *Server) handleFirewallReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	
+	if !s.requireAuth(w, r) {
+		return
+	}
+	
+	count, err := s.firewall.ReloadRulesContext(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, err.Error())
+		return
+	}
+	
+	s.writeJSON(w, http.StatusOK, map[string]int{"rules": count})
+}
+
+// handleConfig returns the effective configuration with secrets redacted
+// (?format=json or yaml)
+func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	
+	if !s.requireAuth(w, r) {
+		return
+	}
+	
+	cfg := s.config
+	if s.current != nil {
+		cfg = s.current()
+	}
+	
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	
+	data, err := cfg.Dump(format)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+	} else {
+		w.Header().Set("Content-Type", "application/yaml")
+	}
+	w.Write(data)
+}
+
+// maxConfigBodySize limits the candidate configuration of a config diff
+const maxConfigBodySize = 1 << 20
+
+// maxSnapshotBodySize limits the snapshot of a restore
+const maxSnapshotBodySize = 16 << 20
+
+// handleSnapshot returns the state of the agent: the redacted
+// configuration, runtime firewall rules, registered services and health
+// checks
+func (s *Server) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	
+	if !s.requireAuth(w, r) {
+		return
+	}
+	
+	if s.state == nil {
+		writeError(w, http.StatusServiceUnavailable, codeUnavailable, "Snapshots not enabled")
+		return
+	}
+	
+	snap, err := s.state.Snapshot()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, codeInternal, err.Error())
+		return
+	}
+	
+	s.writeJSON(w, http.StatusOK, snap)
+}
+
+// handleRestore re-applies the rules and services of a snapshot, all or
+// nothing, and reports the outcome of each item
+func (s *Server) handleRestore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	
+	if !s.requireAuth(w, r) {
+		return
+	}
+	
+	if s.state == nil {
+		writeError(w, http.StatusServiceUnavailable, codeUnavailable, "Snapshots not enabled")
+		return
+	}
+	
+	if !s.requireLeader(w) {
+		return
+	}
+	
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSnapshotBodySize))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	
+	snap, err := snapshot.Decode(data)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	
+	result, err := s.state.Restore(r.Context(), snap)
+	switch {
+	case err == nil:
+		s.writeJSON(w, http.StatusOK, result)
+	case result == nil:
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+	case errors.Is(err, snapshot.ErrInvalidSnapshot):
+		s.writeJSON(w, http.StatusUnprocessableEntity, result)
+	default:
+		logging.Entry(r.Context(), s.log).Errorf("Restore failed: %v", err)
+		s.writeJSON(w, http.StatusInternalServerError, result)
+	}
+}
+
+// handleConfigDiff previews a configuration change: it parses the candidate
+// configuration in the body (JSON, or YAML with a YAML Content-Type or
+// ?format=yaml) and returns what reloading it would change, without
+// applying anything
+func (s *Server) handleConfigDiff(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	
+	if !s.requireAuth(w, r) {
+		return
+	}
+	
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+		if strings.Contains(r.Header.Get("Content-Type"), "yaml") {
+			format = "yaml"
+		}
+	}
+	if format != "json" && format != "yaml" {
+		writeError(w, http.StatusBadRequest, codeBadRequest, fmt.Sprintf("unsupported config format: %s", format))
+		return
+	}
+	
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxConfigBodySize))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+		return
+	}
+	
+	candidate, err := config.Parse(data, format)
+	if err != nil {
+		code := codeBadRequest
+		var validationErr *config.ValidationError
+		if errors.As(err, &validationErr) {
+			code = codeValidation
+		}
+		writeError(w, http.StatusBadRequest, code, err.Error())
+		return
+	}
+	
+	// Compare tokens as the agent would see them after a reload
+	if err := secrets.NewResolver(candidate.Security.Vault).ResolveConfig(r.Context(), candidate); err != nil {
+		writeError(w, http.StatusBadRequest, codeValidation, fmt.Sprintf("failed to resolve secrets: %v", err))
+		return
+	}
+	
+	current := s.config
+	if s.current != nil {
+		current = s.current()
+	}
+	
+	changes := candidate.Diff(current)
+	if changes == nil {
+		changes = []config.Change{}
+	}
+	restart := candidate.RestartRequired(current)
+	if restart == nil {
+		restart = []string{}
+	}
+	
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"changes":          changes,
+		"restart_required": restart,
+	})
+}
+
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, codeInternal, "Streaming not supported")
+		return
+	}
+	
+	if atomic.AddInt32(&s.streams, 1) > int32(s.config.Agent.MaxEventStreams) {
+		atomic.AddInt32(&s.streams, -1)
+		writeError(w, http.StatusServiceUnavailable, codeUnavailable, "Too many event streams")
+		return
+	}
+	defer atomic.AddInt32(&s.streams, -1)
+	
+	// Streams last as long as the client listens, past api.write_timeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		s.log.Debugf("Failed to clear the write deadline of an event stream: %v", err)
+	}
+	
+	firewallEvents := s.firewall.Subscribe()
+	defer s.firewall.Unsubscribe(firewallEvents)
+	
+	// A nil channel never receives, so the select below works without a mesh
+	var serviceEvents <-chan servicemesh.Event
+	if s.serviceMesh != nil {
+		serviceEvents = s.serviceMesh.Subscribe()
+		defer s.serviceMesh.Unsubscribe(serviceEvents)
+	}
+	
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	
+	heartbeat := time.NewTicker(eventHeartbeatInterval)
+	defer heartbeat.Stop()
+	
+	for {
+		var err error
+		
+		select {
+		case <-r.Context().Done():
+			return
+		case event := <-firewallEvents:
+			err = s.writeEvent(w, "firewall", event)
+		case event := <-serviceEvents:
+			err = s.writeEvent(w, "service", event)
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(w, ": heartbeat\n\n")
+		}
+		
+		if err != nil {
+			s.log.Debugf("Event stream to %s closed: %v", r.RemoteAddr, err)
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// handleMetrics serves the Prometheus exposition, so that a single port
+// can expose both the API and metrics
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "Method not allowed")
+		return
+	}
+	
+	if s.promHandler == nil {
+		writeError(w, http.StatusServiceUnavailable, codeUnavailable, "Metrics not enabled")
+		return
+	}
+	
+	if !s.requireAuth(w, r) {
+		return
+	}
+	
+	s.promHandler.ServeHTTP(w, r)
+}
+
+// Helper methods
+
+// eventHeartbeatInterval keeps idle event streams alive through proxies
+const eventHeartbeatInterval = 15 * time.Second
+
+// writeEvent writes a single Server-Sent Event with a JSON payload
+func (s *Server) writeEvent(w http.ResponseWriter, eventType string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", eventType, payload)
+	return err
+}
+
+// page is the response wrapper for paginated list endpoints
+type page struct {
+	Items      interface{} `json:"items"`
+	Total      int         `json:"total"`
+	NextOffset *int        `json:"next_offset"` // null on the last page
+}
+
+// newPage wraps count items starting at offset out of total matches
+func newPage(items interface{}, count, total, offset int) page {
+	p := page{Items: items, Total: total}
+	if next := offset + count; next < total {
+		p.NextOffset = &next
+	}
+	return p
+}
+
+// pageParams parses the limit and offset query parameters. Both are
+// optional; a missing limit returns all remaining items.
+func pageParams(r *http.Request) (limit, offset int, err error) {
+	query := r.URL.Query()
+	
+	if v := query.Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			return 0, 0, fmt.Errorf("invalid limit: %s", v)
+		}
+	}
+	
+	if v := query.Get("offset"); v != "" {
+		offset, err = strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("invalid offset: %s", v)
+		}
+	}
+	
+	return limit, offset, nil
+}
 
 func (s *Server) writeJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
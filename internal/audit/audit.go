@@ -0,0 +1,130 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourusername/hbf-agent/internal/config"
+	"github.com/yourusername/hbf-agent/internal/logging"
+)
+
+// Audited actions
+const (
+	ActionRuleAdd           = "firewall.rule.add"
+	ActionRuleDelete        = "firewall.rule.delete"
+	ActionRuleEnable        = "firewall.rule.enable"
+	ActionRuleDisable       = "firewall.rule.disable"
+	ActionRulesFlush        = "firewall.rules.flush"
+	ActionRulesReload       = "firewall.rules.reload"
+	ActionServiceRegister   = "service.register"
+	ActionServiceDeregister = "service.deregister"
+)
+
+// SystemIdentity is recorded for changes made by the agent itself, such as
+// rules loaded from the configuration
+const SystemIdentity = "system"
+
+// Record is one audit log line
+type Record struct {
+	Time      time.Time   `json:"time"`
+	Action    string      `json:"action"`
+	Identity  string      `json:"identity"`
+	SourceIP  string      `json:"source_ip,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+	Object    interface{} `json:"object,omitempty"`
+}
+
+// Caller identifies who made a request
+type Caller struct {
+	Identity string
+	SourceIP string
+}
+
+type callerKey struct{}
+
+// WithCaller returns a copy of ctx carrying the caller of a request
+func WithCaller(ctx context.Context, caller Caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFrom returns the caller stored in ctx, if any
+func CallerFrom(ctx context.Context) (Caller, bool) {
+	caller, ok := ctx.Value(callerKey{}).(Caller)
+	return caller, ok
+}
+
+// Logger appends audit records to a file as JSON lines, independently of
+// the agent log level. A nil *Logger discards records, so components can
+// record unconditionally.
+type Logger struct {
+	file *os.File
+	enc  *json.Encoder
+	log  *logrus.Logger
+	mu   sync.Mutex
+}
+
+// New opens the audit log. It returns nil if auditing is disabled.
+func New(cfg config.AuditConfig, log *logrus.Logger) (*Logger, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	
+	if err := os.MkdirAll(filepath.Dir(cfg.File), 0750); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	
+	file, err := os.OpenFile(cfg.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	
+	return &Logger{
+		file: file,
+		enc:  json.NewEncoder(file),
+		log:  log,
+	}, nil
+}
+
+// Record appends a record for action on object, taking the caller and
+// request ID from ctx. Changes without a caller are attributed to the agent.
+func (l *Logger) Record(ctx context.Context, action string, object interface{}) {
+	if l == nil {
+		return
+	}
+	
+	record := Record{
+		Time:      time.Now().UTC(),
+		Action:    action,
+		Identity:  SystemIdentity,
+		RequestID: logging.RequestID(ctx),
+		Object:    object,
+	}
+	if caller, ok := CallerFrom(ctx); ok {
+		record.Identity = caller.Identity
+		record.SourceIP = caller.SourceIP
+	}
+	
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	
+	if err := l.enc.Encode(record); err != nil {
+		l.log.Errorf("Failed to write audit record for %s: %v", action, err)
+	}
+}
+
+// Close closes the audit log file
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+	
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
@@ -1,7 +1,13 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
@@ -10,10 +16,12 @@ import (
 // Config represents the complete agent configuration
 type Config struct {
 	Agent       AgentConfig       `mapstructure:"agent"`
+	API         APIConfig         `mapstructure:"api"`
 	Firewall    FirewallConfig    `mapstructure:"firewall"`
 	ServiceMesh ServiceMeshConfig `mapstructure:"service_mesh"`
 	Security    SecurityConfig    `mapstructure:"security"`
 	Monitoring  MonitoringConfig  `mapstructure:"monitoring"`
+	Health      HealthConfig      `mapstructure:"health"`
 	Log         LogConfig         `mapstructure:"log"`
 }
 
@@ -24,27 +32,100 @@ type AgentConfig struct {
 	Region     string `mapstructure:"region"`
 	BindAddr   string `mapstructure:"bind_addr"`
 	APIPort    int    `mapstructure:"api_port"`
+	GRPCPort   int    `mapstructure:"grpc_port"` // 0 disables the gRPC API
+	// MaxEventStreams caps concurrent /api/v1/events streams
+	MaxEventStreams int                  `mapstructure:"max_event_streams"`
+	LeaderElection  LeaderElectionConfig `mapstructure:"leader_election"`
+	Compression     CompressionConfig    `mapstructure:"compression"`
+}
+
+// APIConfig restricts which networks may reach the management API and
+// bounds the resources its clients may use. Network entries are CIDRs or
+// single addresses.
+type APIConfig struct {
+	// AllowCIDRs limits clients to these networks; empty allows all
+	AllowCIDRs []string `mapstructure:"allow_cidrs"`
+	// DenyCIDRs rejects clients in these networks, even if they are allowed
+	DenyCIDRs []string `mapstructure:"deny_cidrs"`
+	// TrustedProxies are the networks whose X-Forwarded-For header is used
+	// to find the client address; without them the header is ignored
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+	// MaxConcurrentRequests caps the requests served at once; requests
+	// beyond it are rejected with 503. 0 disables the limit.
+	MaxConcurrentRequests int `mapstructure:"max_concurrent_requests"`
+	// Timeouts of client connections; 0 disables a timeout. Event streams
+	// are exempt from the write timeout.
+	ReadTimeout  time.Duration `mapstructure:"read_timeout"`
+	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+	IdleTimeout  time.Duration `mapstructure:"idle_timeout"`
+}
+
+// CompressionConfig contains API response compression configuration
+type CompressionConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	MinSize int  `mapstructure:"min_size"` // bytes; smaller responses are sent as-is
+}
+
+// LeaderElectionConfig contains leader election configuration
+type LeaderElectionConfig struct {
+	Enabled bool          `mapstructure:"enabled"`
+	Key     string        `mapstructure:"key"`
+	TTL     time.Duration `mapstructure:"ttl"`
 }
 
 // FirewallConfig contains firewall configuration
 type FirewallConfig struct {
-	Backend       string        `mapstructure:"backend"` // iptables or nftables
+	Backend       string        `mapstructure:"backend"` // iptables, nftables or memory
+	Mode          string        `mapstructure:"mode"`    // enforce, observe or auto
 	DefaultPolicy string        `mapstructure:"default_policy"`
+	// ChainPolicies overrides DefaultPolicy for the INPUT, FORWARD or OUTPUT
+	// chain, e.g. {OUTPUT: allow} on a host denying inbound traffic
+	ChainPolicies map[string]string `mapstructure:"chain_policies"`
 	EnableIPv6    bool          `mapstructure:"enable_ipv6"`
+	// AllowICMPv6ND accepts ICMPv6 neighbor discovery in the INPUT and
+	// OUTPUT chains whose policy denies traffic, when IPv6 is enabled, so
+	// that the host keeps its IPv6 neighbors and routes
+	AllowICMPv6ND bool          `mapstructure:"allow_icmpv6_nd"`
 	SyncInterval  time.Duration `mapstructure:"sync_interval"`
 	Rules         []FirewallRule `mapstructure:"rules"`
+	// RulesDir holds additional rule files (*.yaml, *.yml, *.json), each with
+	// a top-level "rules" list, merged with Rules
+	RulesDir      string        `mapstructure:"rules_dir"`
+	WatchRulesDir bool          `mapstructure:"watch_rules_dir"` // reload rules when files change
+	DropLog       DropLogConfig `mapstructure:"drop_log"`
+}
+
+// DropLogConfig controls the capture of packets logged by rules with a
+// log_prefix from the kernel log
+type DropLogConfig struct {
+	Enabled    bool `mapstructure:"enabled"`
+	BufferSize int  `mapstructure:"buffer_size"` // packets kept, newest first
 }
 
 // FirewallRule represents a firewall rule
 type FirewallRule struct {
-	Chain    string `mapstructure:"chain"`
-	Protocol string `mapstructure:"protocol"`
-	Source   string `mapstructure:"source"`
-	Dest     string `mapstructure:"dest"`
-	SPort    string `mapstructure:"sport"`
-	DPort    string `mapstructure:"dport"`
-	Action   string `mapstructure:"action"`
-	Comment  string `mapstructure:"comment"`
+	Table      string `mapstructure:"table"` // filter (default), raw or mangle
+	Chain      string `mapstructure:"chain"`
+	Protocol   string `mapstructure:"protocol"`
+	Source     string `mapstructure:"source"`
+	Dest       string `mapstructure:"dest"`
+	SPort      string `mapstructure:"sport"`
+	DPort      string `mapstructure:"dport"`
+	Action     string `mapstructure:"action"`
+	Comment    string `mapstructure:"comment"`
+	LogPrefix  string `mapstructure:"log_prefix"`
+	RateLimit  string `mapstructure:"rate_limit"` // e.g. 10/second
+	RateBurst  int    `mapstructure:"rate_burst"`
+	PerSource  bool   `mapstructure:"rate_limit_per_source"`
+	RejectWith string `mapstructure:"reject_with"` // only for REJECT
+	Priority   int    `mapstructure:"priority"`    // lower comes first in the chain
+	ICMPType   string `mapstructure:"icmp_type"`   // only for icmp and icmpv6
+	Group      string `mapstructure:"group"`       // e.g. the application owning the rule
+	Family     string `mapstructure:"family"`      // ipv4, ipv6 or both; implied by addresses when empty
+	Mark       string `mapstructure:"mark"`        // match the fwmark, value or value/mask
+	SetMark    string `mapstructure:"set_mark"`    // fwmark set by SETMARK, value or value/mask
+	// Enabled: false keeps the rule configured but out of the kernel
+	Enabled *bool `mapstructure:"enabled"`
 }
 
 // ServiceMeshConfig contains service mesh configuration
@@ -56,6 +137,24 @@ type ServiceMeshConfig struct {
 	Discovery   DiscoveryConfig   `mapstructure:"discovery"`
 	LoadBalance LoadBalanceConfig `mapstructure:"load_balance"`
 	CircuitBreaker CircuitBreakerConfig `mapstructure:"circuit_breaker"`
+	OutlierDetection OutlierDetectionConfig `mapstructure:"outlier_detection"`
+	Retry       RetryConfig       `mapstructure:"retry"`
+	// ReadyTimeout is how long selections wait for the initial discovery
+	// sync; 0 fails immediately
+	ReadyTimeout time.Duration `mapstructure:"ready_timeout"`
+	// DrainTimeout is how long a deregistered instance keeps its in-flight
+	// connections before removal; 0 removes it immediately
+	DrainTimeout time.Duration `mapstructure:"drain_timeout"`
+	// RequestTimeout bounds how long a proxied connection may use its
+	// upstream; a service's "timeout" meta overrides it. 0 disables it.
+	RequestTimeout time.Duration `mapstructure:"request_timeout"`
+	// IdempotentRegistration makes repeated registrations of an instance
+	// (same ID, or same name, address and port) update it in place instead
+	// of failing or adding a duplicate
+	IdempotentRegistration bool `mapstructure:"idempotent_registration"`
+	// StateFile persists the locally registered services across restarts;
+	// empty disables persistence
+	StateFile string `mapstructure:"state_file"`
 }
 
 // DiscoveryConfig contains service discovery configuration
@@ -64,11 +163,81 @@ type DiscoveryConfig struct {
 	Address  string        `mapstructure:"address"`
 	Timeout  time.Duration `mapstructure:"timeout"`
 	Interval time.Duration `mapstructure:"interval"`
+	// MaxBackoff caps the sync interval while the backend is failing
+	MaxBackoff time.Duration `mapstructure:"max_backoff"`
+	// KeepaliveInterval replaces Interval while changes are learned from
+	// backend watches
+	KeepaliveInterval time.Duration `mapstructure:"keepalive_interval"`
+	// Datacenter to query; defaults to agent.datacenter
+	Datacenter string `mapstructure:"datacenter"`
+	// CacheMaxAge is how long the last successful discovery of a service is
+	// served while the backend fails; 0 disables the cache
+	CacheMaxAge time.Duration `mapstructure:"cache_max_age"`
+	// Retries is how often a backend call failing with a transient error
+	// (e.g. a timeout) is retried, backing off exponentially from
+	// RetryBackoff; each attempt is bounded by Timeout
+	Retries      int           `mapstructure:"retries"`
+	RetryBackoff time.Duration `mapstructure:"retry_backoff"`
+	// SyncConcurrency is how many services a sync re-registers at once
+	SyncConcurrency int `mapstructure:"sync_concurrency"`
+	// Backends are additional backends whose instances are merged into
+	// discoveries. Registrations only go to Backend, the primary.
+	Backends []DiscoveryBackendConfig `mapstructure:"backends"`
+}
+
+// DiscoveryBackendConfig is an additional discovery backend
+type DiscoveryBackendConfig struct {
+	Backend string `mapstructure:"backend"`
+	Address string `mapstructure:"address"`
+	// Datacenter to query; defaults to the primary's datacenter
+	Datacenter string `mapstructure:"datacenter"`
+}
+
+// BackendConfig returns the settings of the additional backend i: its own
+// backend, address and datacenter with the timeouts and intervals of the
+// primary
+func (c DiscoveryConfig) BackendConfig(i int) DiscoveryConfig {
+	backend := c.Backends[i]
+	
+	cfg := c
+	cfg.Backend = backend.Backend
+	cfg.Address = backend.Address
+	if backend.Datacenter != "" {
+		cfg.Datacenter = backend.Datacenter
+	}
+	cfg.Backends = nil
+	return cfg
 }
 
 // LoadBalanceConfig contains load balancing configuration
 type LoadBalanceConfig struct {
-	Strategy string `mapstructure:"strategy"` // round_robin, least_conn, random, weighted
+	Strategy string `mapstructure:"strategy"` // round_robin, least_conn, random, weighted, smooth_weighted_round_robin, p2c, least_time, topology
+	Locality string `mapstructure:"locality"` // prefer_local, local_only, any
+	// AffinityTTL is how long an unused sticky session assignment is kept
+	AffinityTTL time.Duration `mapstructure:"affinity_ttl"`
+	// Services overrides the strategy per service name
+	Services map[string]string `mapstructure:"services"`
+	// EWMADecay is the weight of the previous latency average when
+	// least_time folds in a new observation
+	EWMADecay float64 `mapstructure:"ewma_decay"`
+	// Zone is the local zone of the topology strategy, matched against the
+	// "zone" meta of instances or, without it, their "datacenter" meta.
+	// Defaults to the discovery datacenter.
+	Zone string `mapstructure:"zone"`
+	// LocalWeight is the fraction of requests the topology strategy sends to
+	// the local zone while it has healthy instances
+	LocalWeight float64 `mapstructure:"local_weight"`
+	// WarningPolicy decides when instances whose checks warn receive
+	// traffic: exclude (never), fallback (only while no instance is
+	// healthy) or weighted (always, at WarningWeight of a healthy instance)
+	WarningPolicy string `mapstructure:"warning_policy"`
+	// WarningWeight is the share of traffic a warning instance receives
+	// relative to a healthy one under the weighted policy
+	WarningWeight float64 `mapstructure:"warning_weight"`
+	// Splits divides the selections of a service between instances by tag,
+	// e.g. {web: {canary: 5, stable: 95}} for a progressive rollout. Weights
+	// are relative, usually percentages.
+	Splits map[string]map[string]int `mapstructure:"splits"`
 }
 
 // CircuitBreakerConfig contains circuit breaker configuration
@@ -79,18 +248,67 @@ type CircuitBreakerConfig struct {
 	HalfOpenRequests  int           `mapstructure:"half_open_requests"`
 }
 
+// RetryConfig contains retry configuration for CallWithRetry
+type RetryConfig struct {
+	MaxAttempts int           `mapstructure:"max_attempts"`
+	BaseBackoff time.Duration `mapstructure:"base_backoff"`
+	MaxBackoff  time.Duration `mapstructure:"max_backoff"`
+}
+
+// OutlierDetectionConfig contains passive outlier detection configuration
+type OutlierDetectionConfig struct {
+	Enabled             bool          `mapstructure:"enabled"`
+	ConsecutiveFailures int           `mapstructure:"consecutive_failures"`
+	EjectionTime        time.Duration `mapstructure:"ejection_time"`
+}
+
 // SecurityConfig contains security configuration
 type SecurityConfig struct {
 	MTLS       MTLSConfig       `mapstructure:"mtls"`
 	Auth       AuthConfig       `mapstructure:"auth"`
 	RateLimit  RateLimitConfig  `mapstructure:"rate_limit"`
+	Audit      AuditConfig      `mapstructure:"audit"`
+	Vault      VaultConfig      `mapstructure:"vault"`
+	// Roles grant API permissions to tokens and client certificate
+	// identities. When set and auth is enabled, callers without a role are
+	// denied.
+	Roles []RoleConfig `mapstructure:"roles"`
 }
 
-// MTLSConfig contains mTLS configuration
+// RoleConfig binds API permissions to tokens and client certificate
+// identities. Permissions have the form <resource>:<verb>, e.g.
+// firewall:read or services:write; either part may be "*".
+type RoleConfig struct {
+	Name        string   `mapstructure:"name"`
+	Permissions []string `mapstructure:"permissions"`
+	Tokens      []string `mapstructure:"tokens" secret:"true"`
+	Identities  []string `mapstructure:"identities"`
+}
+
+// AuditConfig contains audit log configuration
+type AuditConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	File    string `mapstructure:"file"` // JSON lines, appended
+}
+
+// VaultConfig configures the HashiCorp Vault provider of vault:// secret
+// references in security.mtls and the auth and role tokens
+type VaultConfig struct {
+	Address   string        `mapstructure:"address"`             // empty uses VAULT_ADDR
+	Token     string        `mapstructure:"token" secret:"true"` // empty uses VAULT_TOKEN
+	Namespace string        `mapstructure:"namespace"`
+	Timeout   time.Duration `mapstructure:"timeout"`
+	// RefreshInterval is how often certificates read from Vault are read
+	// again to pick up rotations
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"`
+}
+
+// MTLSConfig contains mTLS configuration. The files may be secret
+// references such as vault://secret/data/hbf-agent#cert.
 type MTLSConfig struct {
 	Enabled  bool   `mapstructure:"enabled"`
 	CertFile string `mapstructure:"cert_file"`
-	KeyFile  string `mapstructure:"key_file"`
+	KeyFile  string `mapstructure:"key_file" secret:"true"`
 	CAFile   string `mapstructure:"ca_file"`
 }
 
@@ -98,7 +316,10 @@ type MTLSConfig struct {
 type AuthConfig struct {
 	Enabled bool     `mapstructure:"enabled"`
 	Type    string   `mapstructure:"type"` // token, jwt, mtls
-	Tokens  []string `mapstructure:"tokens"`
+	Tokens  []string `mapstructure:"tokens" secret:"true"`
+	// AllowedIdentities lists the client certificate SANs (SPIFFE IDs, DNS
+	// names, emails) allowed to call mutating endpoints with mtls auth
+	AllowedIdentities []string `mapstructure:"allowed_identities"`
 }
 
 // RateLimitConfig contains rate limiting configuration
@@ -115,6 +336,18 @@ type MonitoringConfig struct {
 	MetricsPath    string `mapstructure:"metrics_path"`
 	HealthPort     int    `mapstructure:"health_port"`
 	HealthPath     string `mapstructure:"health_path"`
+	OTLPEndpoint   string `mapstructure:"otlp_endpoint"` // empty disables tracing
+	OTLPInsecure   bool   `mapstructure:"otlp_insecure"`
+	// PprofEnabled serves runtime profiles on /api/v1/debug/pprof/
+	PprofEnabled   bool   `mapstructure:"pprof_enabled"`
+}
+
+// HealthConfig contains health check scheduling configuration
+type HealthConfig struct {
+	MaxConcurrentChecks int `mapstructure:"max_concurrent_checks"` // 0 = unlimited
+	// LogSummaryInterval is how often repeated passing results are logged
+	// as a summary instead of one by one; 0 logs each result
+	LogSummaryInterval time.Duration `mapstructure:"log_summary_interval"`
 }
 
 // LogConfig contains logging configuration
@@ -126,15 +359,40 @@ type LogConfig struct {
 
 // Load loads the configuration from viper
 func Load() (*Config, error) {
-	cfg := &Config{}
-	
 	// Set defaults
-	setDefaults()
+	setDefaults(viper.GetViper())
 	
-	if err := viper.Unmarshal(cfg); err != nil {
+	return load(viper.GetViper())
+}
+
+// Parse parses a configuration document in the given format ("yaml" or
+// "json"), applying the same defaults and validation as Load, e.g. to
+// check a candidate configuration before deploying it
+func Parse(data []byte, format string) (*Config, error) {
+	v := viper.New()
+	setDefaults(v)
+	v.SetConfigType(format)
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	
+	return load(v)
+}
+
+// load unmarshals and validates the configuration held by v
+func load(v *viper.Viper) (*Config, error) {
+	cfg := &Config{}
+	if err := v.Unmarshal(cfg); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 	
+	if cfg.ServiceMesh.Discovery.Datacenter == "" {
+		cfg.ServiceMesh.Discovery.Datacenter = cfg.Agent.Datacenter
+	}
+	if cfg.ServiceMesh.LoadBalance.Zone == "" {
+		cfg.ServiceMesh.LoadBalance.Zone = cfg.ServiceMesh.Discovery.Datacenter
+	}
+	
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
@@ -143,70 +401,197 @@ func Load() (*Config, error) {
 }
 
 // setDefaults sets default configuration values
-func setDefaults() {
+func setDefaults(v *viper.Viper) {
 	// Agent defaults
-	viper.SetDefault("agent.node_id", "node-01")
-	viper.SetDefault("agent.datacenter", "dc1")
-	viper.SetDefault("agent.region", "default")
-	viper.SetDefault("agent.bind_addr", "0.0.0.0")
-	viper.SetDefault("agent.api_port", 9090)
+	v.SetDefault("agent.node_id", "node-01")
+	v.SetDefault("agent.datacenter", "dc1")
+	v.SetDefault("agent.region", "default")
+	v.SetDefault("agent.bind_addr", "0.0.0.0")
+	v.SetDefault("agent.api_port", 9090)
+	v.SetDefault("agent.grpc_port", 0)
+	v.SetDefault("agent.max_event_streams", 16)
+	v.SetDefault("agent.leader_election.enabled", false)
+	v.SetDefault("agent.leader_election.key", "hbf-agent/leader")
+	v.SetDefault("agent.leader_election.ttl", "15s")
+	v.SetDefault("agent.compression.enabled", true)
+	v.SetDefault("agent.compression.min_size", 1024)
+	v.SetDefault("api.max_concurrent_requests", 256)
+	v.SetDefault("api.read_timeout", "30s")
+	v.SetDefault("api.write_timeout", "60s")
+	v.SetDefault("api.idle_timeout", "120s")
 	
 	// Firewall defaults
-	viper.SetDefault("firewall.backend", "iptables")
-	viper.SetDefault("firewall.default_policy", "deny")
-	viper.SetDefault("firewall.enable_ipv6", true)
-	viper.SetDefault("firewall.sync_interval", "30s")
+	v.SetDefault("firewall.backend", "iptables")
+	v.SetDefault("firewall.default_policy", "deny")
+	v.SetDefault("firewall.mode", "enforce")
+	v.SetDefault("firewall.enable_ipv6", true)
+	v.SetDefault("firewall.allow_icmpv6_nd", true)
+	v.SetDefault("firewall.sync_interval", "30s")
+	v.SetDefault("firewall.watch_rules_dir", false)
+	v.SetDefault("firewall.drop_log.enabled", false)
+	v.SetDefault("firewall.drop_log.buffer_size", 256)
 	
 	// Service mesh defaults
-	viper.SetDefault("service_mesh.enabled", true)
-	viper.SetDefault("service_mesh.bind_address", "0.0.0.0")
-	viper.SetDefault("service_mesh.proxy_port", 8080)
-	viper.SetDefault("service_mesh.admin_port", 8081)
-	viper.SetDefault("service_mesh.discovery.backend", "consul")
-	viper.SetDefault("service_mesh.discovery.address", "localhost:8500")
-	viper.SetDefault("service_mesh.discovery.timeout", "5s")
-	viper.SetDefault("service_mesh.discovery.interval", "10s")
-	viper.SetDefault("service_mesh.load_balance.strategy", "round_robin")
-	viper.SetDefault("service_mesh.circuit_breaker.enabled", true)
-	viper.SetDefault("service_mesh.circuit_breaker.threshold", 5)
-	viper.SetDefault("service_mesh.circuit_breaker.timeout", "30s")
-	viper.SetDefault("service_mesh.circuit_breaker.half_open_requests", 3)
+	v.SetDefault("service_mesh.enabled", true)
+	v.SetDefault("service_mesh.bind_address", "0.0.0.0")
+	v.SetDefault("service_mesh.proxy_port", 8080)
+	v.SetDefault("service_mesh.admin_port", 8081)
+	v.SetDefault("service_mesh.ready_timeout", "5s")
+	v.SetDefault("service_mesh.drain_timeout", "30s")
+	v.SetDefault("service_mesh.idempotent_registration", true)
+	v.SetDefault("service_mesh.discovery.backend", "consul")
+	v.SetDefault("service_mesh.discovery.address", "localhost:8500")
+	v.SetDefault("service_mesh.discovery.timeout", "5s")
+	v.SetDefault("service_mesh.discovery.interval", "10s")
+	v.SetDefault("service_mesh.discovery.max_backoff", "5m")
+	v.SetDefault("service_mesh.discovery.keepalive_interval", "1m")
+	v.SetDefault("service_mesh.discovery.cache_max_age", "5m")
+	v.SetDefault("service_mesh.discovery.retries", 2)
+	v.SetDefault("service_mesh.discovery.retry_backoff", "200ms")
+	v.SetDefault("service_mesh.discovery.sync_concurrency", 8)
+	v.SetDefault("service_mesh.load_balance.strategy", "round_robin")
+	v.SetDefault("service_mesh.load_balance.locality", "prefer_local")
+	v.SetDefault("service_mesh.load_balance.affinity_ttl", "10m")
+	v.SetDefault("service_mesh.load_balance.ewma_decay", 0.8)
+	v.SetDefault("service_mesh.load_balance.local_weight", 0.9)
+	v.SetDefault("service_mesh.load_balance.warning_policy", "fallback")
+	v.SetDefault("service_mesh.load_balance.warning_weight", 0.25)
+	v.SetDefault("service_mesh.circuit_breaker.enabled", true)
+	v.SetDefault("service_mesh.circuit_breaker.threshold", 5)
+	v.SetDefault("service_mesh.circuit_breaker.timeout", "30s")
+	v.SetDefault("service_mesh.circuit_breaker.half_open_requests", 3)
+	v.SetDefault("service_mesh.retry.max_attempts", 3)
+	v.SetDefault("service_mesh.retry.base_backoff", "100ms")
+	v.SetDefault("service_mesh.retry.max_backoff", "2s")
+	v.SetDefault("service_mesh.outlier_detection.enabled", true)
+	v.SetDefault("service_mesh.outlier_detection.consecutive_failures", 5)
+	v.SetDefault("service_mesh.outlier_detection.ejection_time", "30s")
 	
 	// Security defaults
-	viper.SetDefault("security.mtls.enabled", false)
-	viper.SetDefault("security.auth.enabled", false)
-	viper.SetDefault("security.rate_limit.enabled", true)
-	viper.SetDefault("security.rate_limit.rps", 1000)
-	viper.SetDefault("security.rate_limit.burst", 2000)
+	v.SetDefault("security.mtls.enabled", false)
+	v.SetDefault("security.auth.enabled", false)
+	v.SetDefault("security.rate_limit.enabled", true)
+	v.SetDefault("security.rate_limit.rps", 1000)
+	v.SetDefault("security.rate_limit.burst", 2000)
+	v.SetDefault("security.audit.enabled", false)
+	v.SetDefault("security.audit.file", "/var/log/hbf-agent/audit.log")
+	v.SetDefault("security.vault.timeout", "10s")
+	v.SetDefault("security.vault.refresh_interval", "5m")
 	
 	// Monitoring defaults
-	viper.SetDefault("monitoring.enabled", true)
-	viper.SetDefault("monitoring.metrics_port", 9091)
-	viper.SetDefault("monitoring.metrics_path", "/metrics")
-	viper.SetDefault("monitoring.health_port", 9092)
-	viper.SetDefault("monitoring.health_path", "/health")
+	v.SetDefault("monitoring.enabled", true)
+	v.SetDefault("monitoring.metrics_port", 9091)
+	v.SetDefault("monitoring.metrics_path", "/metrics")
+	v.SetDefault("monitoring.health_port", 9092)
+	v.SetDefault("monitoring.health_path", "/health")
+	v.SetDefault("monitoring.pprof_enabled", false)
+	
+	// Health check defaults
+	v.SetDefault("health.max_concurrent_checks", 32)
+	v.SetDefault("health.log_summary_interval", "1m")
 	
 	// Log defaults
-	viper.SetDefault("log.level", "info")
-	viper.SetDefault("log.format", "text")
-	viper.SetDefault("log.output", "stdout")
+	v.SetDefault("log.level", "info")
+	v.SetDefault("log.format", "text")
+	v.SetDefault("log.output", "stdout")
 }
 
-// Validate validates the configuration
+// ValidationError lists every problem found by Config.Validate
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Problems) == 1 {
+		return e.Problems[0]
+	}
+	return fmt.Sprintf("%d problems: %s", len(e.Problems), strings.Join(e.Problems, "; "))
+}
+
+// addf records a problem
+func (e *ValidationError) addf(format string, args ...interface{}) {
+	e.Problems = append(e.Problems, fmt.Sprintf(format, args...))
+}
+
+// Validate validates the configuration. All problems are reported together
+// in a *ValidationError.
 func (c *Config) Validate() error {
+	errs := &ValidationError{}
+	
 	if c.Agent.NodeID == "" {
-		return fmt.Errorf("agent.node_id is required")
+		errs.addf("agent.node_id is required")
 	}
 	
-	if c.Firewall.Backend != "iptables" && c.Firewall.Backend != "nftables" {
-		return fmt.Errorf("firewall.backend must be 'iptables' or 'nftables'")
+	c.validatePorts(errs)
+	
+	if _, err := ParseNetworks(c.API.AllowCIDRs); err != nil {
+		errs.addf("api.allow_cidrs: %v", err)
+	}
+	if _, err := ParseNetworks(c.API.DenyCIDRs); err != nil {
+		errs.addf("api.deny_cidrs: %v", err)
+	}
+	if _, err := ParseNetworks(c.API.TrustedProxies); err != nil {
+		errs.addf("api.trusted_proxies: %v", err)
+	}
+	if c.API.MaxConcurrentRequests < 0 {
+		errs.addf("api.max_concurrent_requests must not be negative")
+	}
+	if c.API.ReadTimeout < 0 || c.API.WriteTimeout < 0 || c.API.IdleTimeout < 0 {
+		errs.addf("api.read_timeout, api.write_timeout and api.idle_timeout must not be negative")
 	}
 	
-	if c.ServiceMesh.Enabled {
-		if c.ServiceMesh.Discovery.Backend == "" {
-			return fmt.Errorf("service_mesh.discovery.backend is required when service mesh is enabled")
+	switch c.Firewall.Backend {
+	case "iptables", "nftables", "memory":
+	default:
+		errs.addf("firewall.backend must be 'iptables', 'nftables' or 'memory'")
+	}
+	
+	switch c.Firewall.Mode {
+	case "", "enforce", "observe", "auto":
+	default:
+		errs.addf("firewall.mode must be 'enforce', 'observe' or 'auto'")
+	}
+	
+	if c.Firewall.SyncInterval <= 0 {
+		errs.addf("firewall.sync_interval must be positive")
+	}
+	if c.Firewall.DropLog.Enabled && c.Firewall.DropLog.BufferSize <= 0 {
+		errs.addf("firewall.drop_log.buffer_size must be positive")
+	}
+	
+	if _, err := PolicyTarget(c.Firewall.DefaultPolicy); err != nil {
+		errs.addf("firewall.default_policy: %v", err)
+	}
+	policyChains := make([]string, 0, len(c.Firewall.ChainPolicies))
+	for chain := range c.Firewall.ChainPolicies {
+		policyChains = append(policyChains, chain)
+	}
+	sort.Strings(policyChains)
+	for _, chain := range policyChains {
+		policy := c.Firewall.ChainPolicies[chain]
+		if !isPolicyChain(chain) {
+			errs.addf("firewall.chain_policies: invalid chain %s (must be %s)", chain, strings.Join(PolicyChains, ", "))
 		}
-		
+		if _, err := PolicyTarget(policy); err != nil {
+			errs.addf("firewall.chain_policies.%s: %v", chain, err)
+		}
+	}
+	
+	for i, rule := range c.Firewall.Rules {
+		name := fmt.Sprintf("firewall.rules[%d]", i)
+		validateFirewallRule(name, rule, errs)
+		if family, err := RuleFamily(rule.Family, rule.Protocol, rule.RejectWith, rule.Source, rule.Dest); err == nil {
+			if err := FamilyEnabled(rule.Family, family, c.Firewall.EnableIPv6); err != nil {
+				errs.addf("%s: %v", name, err)
+			}
+		}
+	}
+	
+	if c.Firewall.WatchRulesDir && c.Firewall.RulesDir == "" {
+		errs.addf("firewall.watch_rules_dir requires firewall.rules_dir")
+	}
+	
+	if c.ServiceMesh.Enabled {
 		validBackends := map[string]bool{
 			"consul": true,
 			"etcd":   true,
@@ -214,16 +599,860 @@ func (c *Config) Validate() error {
 			"static": true,
 		}
 		
-		if !validBackends[c.ServiceMesh.Discovery.Backend] {
-			return fmt.Errorf("invalid service_mesh.discovery.backend: %s", c.ServiceMesh.Discovery.Backend)
+		if c.ServiceMesh.Discovery.Backend == "" {
+			errs.addf("service_mesh.discovery.backend is required when service mesh is enabled")
+		} else if !validBackends[c.ServiceMesh.Discovery.Backend] {
+			errs.addf("invalid service_mesh.discovery.backend: %s", c.ServiceMesh.Discovery.Backend)
+		}
+		for i, backend := range c.ServiceMesh.Discovery.Backends {
+			if !validBackends[backend.Backend] {
+				errs.addf("invalid service_mesh.discovery.backends[%d].backend: %s", i, backend.Backend)
+			}
+		}
+		
+		if c.ServiceMesh.ReadyTimeout < 0 {
+			errs.addf("service_mesh.ready_timeout must not be negative")
+		}
+		if c.ServiceMesh.DrainTimeout < 0 {
+			errs.addf("service_mesh.drain_timeout must not be negative")
+		}
+		if c.ServiceMesh.RequestTimeout < 0 {
+			errs.addf("service_mesh.request_timeout must not be negative")
+		}
+		
+		if c.ServiceMesh.Discovery.Timeout <= 0 {
+			errs.addf("service_mesh.discovery.timeout must be positive")
+		}
+		if c.ServiceMesh.Discovery.Interval <= 0 {
+			errs.addf("service_mesh.discovery.interval must be positive")
+		}
+		if c.ServiceMesh.Discovery.MaxBackoff < 0 {
+			errs.addf("service_mesh.discovery.max_backoff must not be negative")
+		}
+		if c.ServiceMesh.Discovery.KeepaliveInterval < 0 {
+			errs.addf("service_mesh.discovery.keepalive_interval must not be negative")
+		}
+		if c.ServiceMesh.Discovery.CacheMaxAge < 0 {
+			errs.addf("service_mesh.discovery.cache_max_age must not be negative")
+		}
+		if c.ServiceMesh.Discovery.Retries < 0 {
+			errs.addf("service_mesh.discovery.retries must not be negative")
+		}
+		if c.ServiceMesh.Discovery.RetryBackoff < 0 {
+			errs.addf("service_mesh.discovery.retry_backoff must not be negative")
+		}
+		if c.ServiceMesh.Discovery.SyncConcurrency <= 0 {
+			errs.addf("service_mesh.discovery.sync_concurrency must be positive")
+		}
+		
+		if err := ValidateStrategy(c.ServiceMesh.LoadBalance.Strategy); err != nil {
+			errs.addf("service_mesh.load_balance.strategy: %v", err)
+		}
+		for name, strategy := range c.ServiceMesh.LoadBalance.Services {
+			if err := ValidateStrategy(strategy); err != nil {
+				errs.addf("service_mesh.load_balance.services.%s: %v", name, err)
+			}
+		}
+		for name, split := range c.ServiceMesh.LoadBalance.Splits {
+			if err := ValidateSplit(split); err != nil {
+				errs.addf("service_mesh.load_balance.splits.%s: %v", name, err)
+			}
+		}
+		
+		switch c.ServiceMesh.LoadBalance.Locality {
+		case "prefer_local", "local_only", "any":
+		default:
+			errs.addf("invalid service_mesh.load_balance.locality: %s", c.ServiceMesh.LoadBalance.Locality)
+		}
+		
+		if c.ServiceMesh.LoadBalance.AffinityTTL <= 0 {
+			errs.addf("service_mesh.load_balance.affinity_ttl must be positive")
+		}
+		if d := c.ServiceMesh.LoadBalance.EWMADecay; d < 0 || d >= 1 {
+			errs.addf("service_mesh.load_balance.ewma_decay must be at least 0 and less than 1")
+		}
+		if w := c.ServiceMesh.LoadBalance.LocalWeight; w < 0 || w > 1 {
+			errs.addf("service_mesh.load_balance.local_weight must be between 0 and 1")
+		}
+		switch c.ServiceMesh.LoadBalance.WarningPolicy {
+		case "exclude", "fallback", "weighted":
+		default:
+			errs.addf("invalid service_mesh.load_balance.warning_policy: %s", c.ServiceMesh.LoadBalance.WarningPolicy)
+		}
+		if w := c.ServiceMesh.LoadBalance.WarningWeight; w < 0 || w > 1 {
+			errs.addf("service_mesh.load_balance.warning_weight must be between 0 and 1")
+		}
+		
+		// The topology strategy spills traffic across zones itself, which a
+		// locality filter would prevent
+		if lb := c.ServiceMesh.LoadBalance; lb.Locality != "any" {
+			if lb.Strategy == "topology" {
+				errs.addf("service_mesh.load_balance.strategy topology requires locality any")
+			}
+			for name, strategy := range lb.Services {
+				if strategy == "topology" {
+					errs.addf("service_mesh.load_balance.services.%s: topology requires locality any", name)
+				}
+			}
+		}
+		
+		if cb := c.ServiceMesh.CircuitBreaker; cb.Enabled {
+			if cb.Threshold < 1 {
+				errs.addf("service_mesh.circuit_breaker.threshold must be at least 1")
+			}
+			if cb.Timeout <= 0 {
+				errs.addf("service_mesh.circuit_breaker.timeout must be positive")
+			}
+			if cb.HalfOpenRequests < 1 {
+				errs.addf("service_mesh.circuit_breaker.half_open_requests must be at least 1")
+			}
+		}
+		
+		if retry := c.ServiceMesh.Retry; retry.MaxAttempts < 1 {
+			errs.addf("service_mesh.retry.max_attempts must be at least 1")
+		} else if retry.BaseBackoff < 0 || retry.MaxBackoff < retry.BaseBackoff {
+			errs.addf("service_mesh.retry backoffs must satisfy 0 <= base_backoff <= max_backoff")
+		}
+		
+		if od := c.ServiceMesh.OutlierDetection; od.Enabled {
+			if od.ConsecutiveFailures < 1 {
+				errs.addf("service_mesh.outlier_detection.consecutive_failures must be at least 1")
+			}
+			if od.EjectionTime <= 0 {
+				errs.addf("service_mesh.outlier_detection.ejection_time must be positive")
+			}
 		}
 	}
 	
+	if c.Agent.Compression.MinSize < 0 {
+		errs.addf("agent.compression.min_size must not be negative")
+	}
+	
+	if le := c.Agent.LeaderElection; le.Enabled {
+		if !c.ServiceMesh.Enabled || c.ServiceMesh.Discovery.Backend != "consul" {
+			errs.addf("agent.leader_election requires the consul discovery backend")
+		}
+		if le.Key == "" {
+			errs.addf("agent.leader_election.key is required")
+		}
+		// Consul rejects session TTLs below 10s
+		if le.TTL < 10*time.Second {
+			errs.addf("agent.leader_election.ttl must be at least 10s")
+		}
+	}
+	
+	switch c.Log.Format {
+	case "", "text", "json":
+	default:
+		errs.addf("log.format must be 'text' or 'json'")
+	}
+	
+	if c.Health.MaxConcurrentChecks < 0 {
+		errs.addf("health.max_concurrent_checks must not be negative")
+	}
+	if c.Health.LogSummaryInterval < 0 {
+		errs.addf("health.log_summary_interval must not be negative")
+	}
+	
 	if c.Security.MTLS.Enabled {
 		if c.Security.MTLS.CertFile == "" || c.Security.MTLS.KeyFile == "" || c.Security.MTLS.CAFile == "" {
-			return fmt.Errorf("mTLS requires cert_file, key_file, and ca_file")
+			errs.addf("mTLS requires cert_file, key_file, and ca_file")
+		}
+	}
+	
+	if auth := c.Security.Auth; auth.Enabled && auth.Type == "mtls" {
+		if !c.Security.MTLS.Enabled {
+			errs.addf("security.auth type mtls requires security.mtls to be enabled")
+		}
+		if len(auth.AllowedIdentities) == 0 && len(c.Security.Roles) == 0 {
+			errs.addf("security.auth.allowed_identities or security.roles is required for mtls auth")
+		}
+	}
+	
+	roles := make(map[string]bool, len(c.Security.Roles))
+	for i, role := range c.Security.Roles {
+		if role.Name == "" {
+			errs.addf("security.roles[%d].name is required", i)
+		} else if roles[role.Name] {
+			errs.addf("security.roles: duplicate role %s", role.Name)
+		}
+		roles[role.Name] = true
+		
+		for _, permission := range role.Permissions {
+			if err := ValidatePermission(permission); err != nil {
+				errs.addf("security.roles[%d]: %v", i, err)
+			}
+		}
+	}
+	
+	if c.Security.Vault.Timeout < 0 {
+		errs.addf("security.vault.timeout must not be negative")
+	}
+	if c.Security.Vault.RefreshInterval < 0 {
+		errs.addf("security.vault.refresh_interval must not be negative")
+	}
+	
+	if c.Security.Audit.Enabled && c.Security.Audit.File == "" {
+		errs.addf("security.audit.file is required when auditing is enabled")
+	}
+	
+	if rl := c.Security.RateLimit; rl.Enabled {
+		if rl.RPS < 1 {
+			errs.addf("security.rate_limit.rps must be at least 1")
+		}
+		if rl.Burst < rl.RPS {
+			errs.addf("security.rate_limit.burst must be at least rps")
+		}
+	}
+	
+	if len(errs.Problems) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ValidateSplit validates a traffic split: tag values with non-negative
+// weights, at least one of them positive
+func ValidateSplit(split map[string]int) error {
+	if len(split) == 0 {
+		return fmt.Errorf("split must have at least one bucket")
+	}
+	
+	total := 0
+	for tag, weight := range split {
+		if tag == "" {
+			return fmt.Errorf("split bucket tag must not be empty")
+		}
+		if weight < 0 {
+			return fmt.Errorf("weight of split bucket %s must not be negative", tag)
+		}
+		total += weight
+	}
+	if total == 0 {
+		return fmt.Errorf("split must have a bucket with a positive weight")
+	}
+	return nil
+}
+
+// PolicyChains are the filter chains whose default policy the agent sets
+var PolicyChains = []string{"INPUT", "FORWARD", "OUTPUT"}
+
+// isPolicyChain reports whether chain, in any case, is one of PolicyChains
+func isPolicyChain(chain string) bool {
+	for _, c := range PolicyChains {
+		if strings.EqualFold(chain, c) {
+			return true
+		}
+	}
+	return false
+}
+
+// PolicyTarget maps a default policy, allow or deny (or the targets ACCEPT
+// and DROP), to its chain policy target
+func PolicyTarget(policy string) (string, error) {
+	switch strings.ToLower(policy) {
+	case "allow", "accept":
+		return "ACCEPT", nil
+	case "deny", "drop":
+		return "DROP", nil
+	default:
+		return "", fmt.Errorf("invalid policy %q (must be allow or deny)", policy)
+	}
+}
+
+// ChainPolicy returns the policy target of a filter chain: its entry in
+// ChainPolicies, else DefaultPolicy. Chain names are matched in any case,
+// as configuration keys are read lowercased.
+func (c FirewallConfig) ChainPolicy(chain string) string {
+	policy := c.DefaultPolicy
+	for name, p := range c.ChainPolicies {
+		if strings.EqualFold(name, chain) {
+			policy = p
+			break
+		}
+	}
+	
+	target, err := PolicyTarget(policy)
+	if err != nil {
+		return "ACCEPT"
+	}
+	return target
+}
+
+// validateFirewallRule checks the fields of a configured rule, reporting
+// problems under name
+func validateFirewallRule(name string, rule FirewallRule, errs *ValidationError) {
+	if rule.Chain == "" {
+		errs.addf("%s: chain is required", name)
+	}
+	if err := ValidateProtocol(rule.Protocol); err != nil {
+		errs.addf("%s: %v", name, err)
+	}
+	if err := ValidateAction(rule.Action); err != nil {
+		errs.addf("%s: %v", name, err)
+	}
+	if err := ValidateTable(rule.Table, rule.Chain, rule.Action); err != nil {
+		errs.addf("%s: %v", name, err)
+	}
+	if rule.Source != "" {
+		if err := ValidateAddress(rule.Source); err != nil {
+			errs.addf("%s: source: %v", name, err)
+		}
+	}
+	if rule.Dest != "" {
+		if err := ValidateAddress(rule.Dest); err != nil {
+			errs.addf("%s: dest: %v", name, err)
+		}
+	}
+	if rule.SPort != "" || rule.DPort != "" {
+		if !PortProtocols[rule.Protocol] {
+			errs.addf("%s: ports require protocol tcp, udp or sctp", name)
+		}
+		if rule.SPort != "" {
+			if err := ValidatePort(rule.SPort); err != nil {
+				errs.addf("%s: sport: %v", name, err)
+			}
+		}
+		if rule.DPort != "" {
+			if err := ValidatePort(rule.DPort); err != nil {
+				errs.addf("%s: dport: %v", name, err)
+			}
+		}
+	}
+	if rule.ICMPType != "" {
+		if _, _, err := ParseICMPType(rule.ICMPType, rule.Protocol); err != nil {
+			errs.addf("%s: %v", name, err)
+		}
+	}
+	if rule.Group != "" {
+		if err := ValidateGroup(rule.Group); err != nil {
+			errs.addf("%s: %v", name, err)
+		}
+	}
+	if _, err := RuleFamily(rule.Family, rule.Protocol, rule.RejectWith, rule.Source, rule.Dest); err != nil {
+		errs.addf("%s: %v", name, err)
+	}
+	if rule.RateLimit != "" {
+		if err := ValidateRateLimit(rule.RateLimit); err != nil {
+			errs.addf("%s: %v", name, err)
 		}
 	}
+	if rule.RateBurst < 0 {
+		errs.addf("%s: rate_burst must not be negative", name)
+	}
+	if rule.RejectWith != "" {
+		if rule.Action != "REJECT" {
+			errs.addf("%s: reject_with requires action REJECT", name)
+		} else if err := ValidateRejectWith(rule.RejectWith, rule.Protocol); err != nil {
+			errs.addf("%s: %v", name, err)
+		}
+	}
+	if rule.Mark != "" {
+		if err := ValidateMark(rule.Mark); err != nil {
+			errs.addf("%s: mark: %v", name, err)
+		}
+	}
+	if err := ValidateSetMark(rule.SetMark, rule.Action); err != nil {
+		errs.addf("%s: set_mark: %v", name, err)
+	}
+}
+
+// validatePorts checks that every listening port is in range and that no
+// two listeners share a port. The gRPC, proxy and admin ports may be 0 to
+// disable them.
+func (c *Config) validatePorts(errs *ValidationError) {
+	type port struct {
+		name     string
+		value    int
+		optional bool
+	}
 	
+	ports := []port{
+		{"agent.api_port", c.Agent.APIPort, false},
+		{"agent.grpc_port", c.Agent.GRPCPort, true},
+	}
+	if c.Monitoring.Enabled {
+		ports = append(ports,
+			port{"monitoring.metrics_port", c.Monitoring.MetricsPort, false},
+			port{"monitoring.health_port", c.Monitoring.HealthPort, false},
+		)
+	}
+	if c.ServiceMesh.Enabled {
+		ports = append(ports,
+			port{"service_mesh.proxy_port", c.ServiceMesh.ProxyPort, true},
+			port{"service_mesh.admin_port", c.ServiceMesh.AdminPort, true},
+		)
+	}
+	
+	used := make(map[int]string)
+	for _, p := range ports {
+		if p.optional && p.value == 0 {
+			continue
+		}
+		if p.value < 1 || p.value > 65535 {
+			errs.addf("%s must be between 1 and 65535, got %d", p.name, p.value)
+			continue
+		}
+		if other, ok := used[p.value]; ok {
+			errs.addf("%s conflicts with %s (port %d)", p.name, other, p.value)
+			continue
+		}
+		used[p.value] = p.name
+	}
+}
+
+// rateLimitPattern matches iptables/nftables rate expressions such as 10/second
+var rateLimitPattern = regexp.MustCompile(`^[1-9][0-9]*/(second|minute|hour|day)$`)
+
+// ValidateRateLimit validates a packet rate limit expression
+func ValidateRateLimit(rate string) error {
+	if !rateLimitPattern.MatchString(rate) {
+		return fmt.Errorf("invalid rate limit %q: expected <count>/<second|minute|hour|day>", rate)
+	}
+	return nil
+}
+
+// groupPattern matches rule group names, which appear in API paths
+var groupPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,62}$`)
+
+// ValidateGroup validates a firewall rule group name
+func ValidateGroup(group string) error {
+	if !groupPattern.MatchString(group) {
+		return fmt.Errorf("invalid group %q: expected up to 63 letters, digits, '.', '_' or '-', starting with a letter or digit", group)
+	}
+	return nil
+}
+
+// ParseNetworks parses a list of CIDRs and single addresses, the latter
+// becoming host networks
+func ParseNetworks(entries []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(entries))
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address %q", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", entry)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// PermissionResources lists the API resources that role permissions apply to
+var PermissionResources = map[string]bool{
+	"services": true,
+	"checks":   true,
+	"firewall": true,
+	"config":   true,
+	"events":   true,
+	"metrics":  true,
+	"debug":    true,
+	"snapshot": true,
+}
+
+// ValidatePermission validates a role permission such as firewall:read
+func ValidatePermission(permission string) error {
+	resource, verb, ok := strings.Cut(permission, ":")
+	if !ok || (resource != "*" && !PermissionResources[resource]) {
+		return fmt.Errorf("invalid permission %q: expected <resource>:<verb>", permission)
+	}
+	switch verb {
+	case "read", "write", "*":
+		return nil
+	default:
+		return fmt.Errorf("invalid permission %q: verb must be read, write or *", permission)
+	}
+}
+
+// Strategies lists the load balancing strategies
+var Strategies = map[string]bool{
+	"round_robin":                 true,
+	"least_conn":                  true,
+	"random":                      true,
+	"weighted":                    true,
+	"smooth_weighted_round_robin": true,
+	"p2c":                         true,
+	"least_time":                  true,
+	"topology":                    true,
+}
+
+// ValidateStrategy validates a load balancing strategy name
+func ValidateStrategy(strategy string) error {
+	if !Strategies[strategy] {
+		return fmt.Errorf("invalid load balancing strategy: %s", strategy)
+	}
+	return nil
+}
+
+// RejectTypes lists the reject types accepted by the REJECT action
+var RejectTypes = map[string]bool{
+	"icmp-net-unreachable":   true,
+	"icmp-host-unreachable":  true,
+	"icmp-port-unreachable":  true,
+	"icmp-proto-unreachable": true,
+	"icmp-net-prohibited":    true,
+	"icmp-host-prohibited":   true,
+	"icmp-admin-prohibited":  true,
+	"icmp6-no-route":         true,
+	"icmp6-adm-prohibited":   true,
+	"icmp6-addr-unreachable": true,
+	"icmp6-port-unreachable": true,
+	"tcp-reset":              true,
+}
+
+// ValidateRejectWith validates a REJECT reject type for the given protocol
+func ValidateRejectWith(rejectWith, protocol string) error {
+	if !RejectTypes[rejectWith] {
+		return fmt.Errorf("invalid reject type: %s", rejectWith)
+	}
+	if rejectWith == "tcp-reset" && protocol != "tcp" {
+		return fmt.Errorf("reject type tcp-reset requires protocol tcp")
+	}
+	return nil
+}
+
+// Protocols lists the protocols accepted in firewall rules. An empty
+// protocol matches all.
+var Protocols = map[string]bool{
+	"tcp":    true,
+	"udp":    true,
+	"icmp":   true,
+	"icmpv6": true,
+	"sctp":   true,
+	"all":    true,
+}
+
+// PortProtocols lists the protocols whose rules may match ports
+var PortProtocols = map[string]bool{
+	"tcp":  true,
+	"udp":  true,
+	"sctp": true,
+}
+
+// ICMPTypes maps the ICMP type names accepted in firewall rules to their
+// numbers. The names are understood by iptables and nftables alike.
+var ICMPTypes = map[string]int{
+	"echo-reply":              0,
+	"destination-unreachable": 3,
+	"source-quench":           4,
+	"redirect":                5,
+	"echo-request":            8,
+	"router-advertisement":    9,
+	"router-solicitation":     10,
+	"time-exceeded":           11,
+	"parameter-problem":       12,
+	"timestamp-request":       13,
+	"timestamp-reply":         14,
+	"address-mask-request":    17,
+	"address-mask-reply":      18,
+}
+
+// ICMPv6Types maps the ICMPv6 type names accepted in firewall rules to
+// their numbers
+var ICMPv6Types = map[string]int{
+	"destination-unreachable": 1,
+	"packet-too-big":          2,
+	"time-exceeded":           3,
+	"parameter-problem":       4,
+	"echo-request":            128,
+	"echo-reply":              129,
+	"router-solicitation":     133,
+	"router-advertisement":    134,
+	"neighbor-solicitation":   135,
+	"neighbor-advertisement":  136,
+	"redirect":                137,
+}
+
+// ICMPv6NDTypes are the ICMPv6 neighbor discovery types an IPv6 host needs
+// to resolve its neighbors and find its routers (RFC 4861)
+var ICMPv6NDTypes = []string{
+	"router-solicitation",
+	"router-advertisement",
+	"neighbor-solicitation",
+	"neighbor-advertisement",
+}
+
+// ParseICMPType parses the ICMP type of a rule for protocol icmp or icmpv6:
+// a type name, or a type number with an optional /code. It returns the type
+// number and the code, -1 when any code matches.
+func ParseICMPType(icmpType, protocol string) (int, int, error) {
+	names := ICMPTypes
+	switch protocol {
+	case "icmp":
+	case "icmpv6":
+		names = ICMPv6Types
+	default:
+		return 0, 0, fmt.Errorf("icmp type requires protocol icmp or icmpv6")
+	}
+	
+	if number, ok := names[icmpType]; ok {
+		return number, -1, nil
+	}
+	
+	other, otherNames := "icmpv6", ICMPv6Types
+	if protocol == "icmpv6" {
+		other, otherNames = "icmp", ICMPTypes
+	}
+	if _, ok := otherNames[icmpType]; ok {
+		return 0, 0, fmt.Errorf("invalid %s type %q: it is an %s type", protocol, icmpType, other)
+	}
+	
+	invalid := fmt.Errorf("invalid %s type %q: expected a type name or 0-255 with an optional /code", protocol, icmpType)
+	typePart, codePart, hasCode := strings.Cut(icmpType, "/")
+	number, err := strconv.Atoi(typePart)
+	if err != nil || number < 0 || number > 255 {
+		return 0, 0, invalid
+	}
+	if !hasCode {
+		return number, -1, nil
+	}
+	code, err := strconv.Atoi(codePart)
+	if err != nil || code < 0 || code > 255 {
+		return 0, 0, invalid
+	}
+	return number, code, nil
+}
+
+// ValidateProtocol validates a firewall rule protocol
+func ValidateProtocol(protocol string) error {
+	if protocol != "" && !Protocols[protocol] {
+		return fmt.Errorf("unknown protocol: %s", protocol)
+	}
+	return nil
+}
+
+// Actions lists the firewall rule actions
+var Actions = map[string]bool{
+	"ACCEPT": true,
+	"DROP":   true,
+	"REJECT": true,
+	"LOG":    true,
+	"RETURN": true,
+	// NOTRACK exempts packets from connection tracking; raw table only
+	"NOTRACK": true,
+	// SETMARK sets the fwmark of packets, e.g. for policy routing; mangle
+	// table only
+	"SETMARK": true,
+}
+
+// ValidateAction validates a firewall rule action
+func ValidateAction(action string) error {
+	if action == "" {
+		return fmt.Errorf("action is required")
+	}
+	if !Actions[action] {
+		return fmt.Errorf("unknown action: %s", action)
+	}
+	return nil
+}
+
+// Tables lists the iptables tables rules can be placed in, with their
+// chains. The raw table is evaluated before connection tracking, so it
+// only has the PREROUTING and OUTPUT chains; the mangle table alters
+// packets, e.g. their marks, at every hook. An empty chain list allows any
+// chain, including user-defined ones.
+var Tables = map[string][]string{
+	"filter": nil,
+	"raw":    {"PREROUTING", "OUTPUT"},
+	"mangle": {"PREROUTING", "INPUT", "FORWARD", "OUTPUT", "POSTROUTING"},
+}
+
+// ValidateTable validates the table of a firewall rule (empty for filter)
+// against its chain and action. NOTRACK is only valid in the raw table,
+// SETMARK only in the mangle table, and
+// REJECT is not valid there.
+func ValidateTable(table, chain, action string) error {
+	if table == "" {
+		table = "filter"
+	}
+	chains, ok := Tables[table]
+	if !ok {
+		return fmt.Errorf("unknown table: %s", table)
+	}
+	
+	if len(chains) > 0 && chain != "" {
+		valid := false
+		for _, c := range chains {
+			valid = valid || c == chain
+		}
+		if !valid {
+			return fmt.Errorf("chain %s is not valid in the %s table, expected one of %s", chain, table, strings.Join(chains, ", "))
+		}
+	}
+	
+	switch {
+	case action == "NOTRACK" && table != "raw":
+		return fmt.Errorf("action NOTRACK requires table raw")
+	case action == "SETMARK" && table != "mangle":
+		return fmt.Errorf("action SETMARK requires table mangle")
+	case action == "REJECT" && table != "filter":
+		return fmt.Errorf("action REJECT is not valid in the %s table", table)
+	}
+	return nil
+}
+
+// ParseMark parses a fwmark, a value or value/mask given in decimal or as
+// 0x-prefixed hex. Without a mask all 32 bits are used.
+func ParseMark(mark string) (value, mask uint32, err error) {
+	valueStr, maskStr, masked := strings.Cut(mark, "/")
+	parsed, err := strconv.ParseUint(valueStr, 0, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid mark %q: expected a decimal or 0x hex value, optionally with a /mask", mark)
+	}
+	value, mask = uint32(parsed), 0xffffffff
+	
+	if masked {
+		parsed, err = strconv.ParseUint(maskStr, 0, 32)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid mask of mark %q: expected a decimal or 0x hex value", mark)
+		}
+		mask = uint32(parsed)
+	}
+	return value, mask, nil
+}
+
+// ValidateMark validates a fwmark match or SETMARK value
+func ValidateMark(mark string) error {
+	_, _, err := ParseMark(mark)
+	return err
+}
+
+// ValidateSetMark validates the mark set by a rule: required for SETMARK
+// and only valid with it
+func ValidateSetMark(setMark, action string) error {
+	switch {
+	case action == "SETMARK" && setMark == "":
+		return fmt.Errorf("action SETMARK requires a mark to set")
+	case action != "SETMARK" && setMark != "":
+		return fmt.Errorf("a mark to set requires action SETMARK")
+	case setMark != "":
+		return ValidateMark(setMark)
+	}
+	return nil
+}
+
+// ValidateAddress validates a firewall rule source or destination, a single
+// address or a CIDR
+func ValidateAddress(addr string) error {
+	if strings.Contains(addr, "/") {
+		if _, _, err := net.ParseCIDR(addr); err != nil {
+			return fmt.Errorf("invalid CIDR: %s", addr)
+		}
+		return nil
+	}
+	if net.ParseIP(addr) == nil {
+		return fmt.Errorf("invalid address: %s", addr)
+	}
+	return nil
+}
+
+// Address families of firewall rules
+const (
+	FamilyIPv4 = "ipv4"
+	FamilyIPv6 = "ipv6"
+	FamilyBoth = "both"
+)
+
+// AddressFamily returns the family of a valid address or CIDR
+func AddressFamily(addr string) string {
+	if strings.Contains(addr, ":") {
+		return FamilyIPv6
+	}
+	return FamilyIPv4
+}
+
+// RuleFamily returns the address family a rule applies to. The family is
+// implied by the rule's addresses, an icmp or icmpv6 protocol and an icmp
+// reject type; these must agree with each other and with family, when
+// set. A rule that implies no family applies to both unless family says
+// otherwise.
+func RuleFamily(family, protocol, rejectWith, source, dest string) (string, error) {
+	switch family {
+	case "", FamilyIPv4, FamilyIPv6, FamilyBoth:
+	default:
+		return "", fmt.Errorf("invalid family: %s (must be ipv4, ipv6 or both)", family)
+	}
+	
+	type hint struct{ what, family string }
+	var hints []hint
+	if source != "" && ValidateAddress(source) == nil {
+		hints = append(hints, hint{"source " + source, AddressFamily(source)})
+	}
+	if dest != "" && ValidateAddress(dest) == nil {
+		hints = append(hints, hint{"dest " + dest, AddressFamily(dest)})
+	}
+	switch protocol {
+	case "icmp":
+		hints = append(hints, hint{"protocol icmp", FamilyIPv4})
+	case "icmpv6":
+		hints = append(hints, hint{"protocol icmpv6", FamilyIPv6})
+	}
+	switch {
+	case strings.HasPrefix(rejectWith, "icmp6-"):
+		hints = append(hints, hint{"reject type " + rejectWith, FamilyIPv6})
+	case strings.HasPrefix(rejectWith, "icmp-"):
+		hints = append(hints, hint{"reject type " + rejectWith, FamilyIPv4})
+	}
+	
+	for i, h := range hints {
+		if i > 0 && h.family != hints[0].family {
+			return "", fmt.Errorf("%s is %s but %s is %s", hints[0].what, hints[0].family, h.what, h.family)
+		}
+		if family != "" && family != h.family {
+			return "", fmt.Errorf("family %s conflicts with %s, which is %s", family, h.what, h.family)
+		}
+	}
+	
+	switch {
+	case len(hints) > 0:
+		return hints[0].family, nil
+	case family != "":
+		return family, nil
+	default:
+		return FamilyBoth, nil
+	}
+}
+
+// FamilyEnabled checks that a rule of family, whose effective family as
+// returned by RuleFamily is effective, can be applied. With IPv6 disabled,
+// rules implying no family are applied to IPv4 only, but rules that are
+// IPv6 or explicitly both are rejected.
+func FamilyEnabled(family, effective string, enableIPv6 bool) error {
+	if enableIPv6 {
+		return nil
+	}
+	if effective == FamilyIPv6 || family == FamilyBoth {
+		return fmt.Errorf("rule applies to IPv6 but firewall.enable_ipv6 is off")
+	}
+	return nil
+}
+
+// ValidatePort validates a firewall rule port, a single port or a
+// first:last range
+func ValidatePort(port string) error {
+	first, last, isRange := strings.Cut(port, ":")
+	low, err := strconv.Atoi(first)
+	if err != nil || low < 1 || low > 65535 {
+		return fmt.Errorf("invalid port %q: expected 1-65535 or a first:last range", port)
+	}
+	if !isRange {
+		return nil
+	}
+	high, err := strconv.Atoi(last)
+	if err != nil || high < low || high > 65535 {
+		return fmt.Errorf("invalid port %q: expected 1-65535 or a first:last range", port)
+	}
 	return nil
 }
@@ -0,0 +1,220 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kinds of configuration changes
+const (
+	ChangeAdded    = "added"
+	ChangeRemoved  = "removed"
+	ChangeModified = "changed"
+)
+
+// Change is a difference between two configurations. Path uses the keys of
+// the configuration file, e.g. "service_mesh.load_balance.strategy". Rules
+// are compared as a set: each added or removed rule is one change of
+// "firewall.rules". Secret values are redacted.
+type Change struct {
+	Path            string      `json:"path"`
+	Kind            string      `json:"kind"`
+	Old             interface{} `json:"old,omitempty"`
+	New             interface{} `json:"new,omitempty"`
+	RestartRequired bool        `json:"restart_required"`
+}
+
+// restartKeys are the settings that cannot be changed on a running agent.
+// A change of a key or of anything below it requires a restart.
+var restartKeys = []string{
+	"agent.node_id",
+	"agent.datacenter",
+	"agent.bind_addr",
+	"agent.api_port",
+	"agent.grpc_port",
+	"agent.compression",
+	"agent.leader_election",
+	"api",
+	"firewall.backend",
+	"firewall.mode",
+	"firewall.watch_rules_dir",
+	"firewall.rules_dir", // only while watch_rules_dir is enabled
+	"firewall.enable_ipv6",
+	"firewall.drop_log",
+	"service_mesh.enabled",
+	"service_mesh.bind_address",
+	"service_mesh.proxy_port",
+	"service_mesh.admin_port",
+	"service_mesh.discovery",
+	"service_mesh.state_file",
+	"health.max_concurrent_checks",
+	"health.log_summary_interval",
+	"monitoring.otlp_endpoint",
+	"monitoring.otlp_insecure",
+	"monitoring.pprof_enabled",
+	"security.mtls",
+	"security.auth", // read by the API servers on startup
+	"security.audit",
+	"security.vault",
+	"security.roles",
+}
+
+// Diff returns what changes when the agent moves from old to c, ordered by
+// path
+func (c *Config) Diff(old *Config) []Change {
+	var changes []Change
+	diffValues("", reflect.ValueOf(*old), reflect.ValueOf(*c), false, &changes)
+	
+	for i := range changes {
+		key, ok := restartKey(changes[i].Path)
+		if key == "firewall.rules_dir" {
+			ok = c.Firewall.WatchRulesDir
+		}
+		changes[i].RestartRequired = ok
+	}
+	
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// RestartRequired returns the settings that differ between old and c and
+// cannot be changed on a running agent
+func (c *Config) RestartRequired(old *Config) []string {
+	changed := make(map[string]bool)
+	for _, change := range c.Diff(old) {
+		if change.RestartRequired {
+			key, _ := restartKey(change.Path)
+			changed[key] = true
+		}
+	}
+	
+	var keys []string
+	for _, key := range restartKeys {
+		if changed[key] {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// restartKey returns the restart key covering a path
+func restartKey(path string) (string, bool) {
+	for _, key := range restartKeys {
+		if path == key || strings.HasPrefix(path, key+".") || strings.HasPrefix(path, key+"[") {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// diffValues appends the differences between two values of the same type
+func diffValues(path string, oldV, newV reflect.Value, secret bool, changes *[]Change) {
+	if _, ok := oldV.Interface().(time.Duration); ok {
+		diffLeaf(path, oldV, newV, secret, changes)
+		return
+	}
+	
+	switch oldV.Kind() {
+	case reflect.Struct:
+		t := oldV.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			key := field.Tag.Get("mapstructure")
+			if key == "" || key == "-" {
+				continue
+			}
+			if path != "" {
+				key = path + "." + key
+			}
+			diffValues(key, oldV.Field(i), newV.Field(i), field.Tag.Get("secret") == "true", changes)
+		}
+	case reflect.Slice:
+		if path == "firewall.rules" {
+			diffRules(path, oldV, newV, changes)
+			return
+		}
+		diffLeaf(path, oldV, newV, secret, changes)
+	case reflect.Map:
+		diffMaps(path, oldV, newV, secret, changes)
+	default:
+		diffLeaf(path, oldV, newV, secret, changes)
+	}
+}
+
+// diffLeaf compares two values as a whole
+func diffLeaf(path string, oldV, newV reflect.Value, secret bool, changes *[]Change) {
+	if reflect.DeepEqual(oldV.Interface(), newV.Interface()) {
+		return
+	}
+	
+	*changes = append(*changes, Change{
+		Path: path,
+		Kind: ChangeModified,
+		Old:  diffValue(oldV, secret),
+		New:  diffValue(newV, secret),
+	})
+}
+
+// diffMaps compares maps key by key
+func diffMaps(path string, oldV, newV reflect.Value, secret bool, changes *[]Change) {
+	keys := make(map[string]reflect.Value)
+	for _, v := range []reflect.Value{oldV, newV} {
+		iter := v.MapRange()
+		for iter.Next() {
+			keys[fmt.Sprint(iter.Key().Interface())] = iter.Key()
+		}
+	}
+	
+	for name, key := range keys {
+		oldItem, newItem := oldV.MapIndex(key), newV.MapIndex(key)
+		itemPath := path + "." + name
+		switch {
+		case !oldItem.IsValid():
+			*changes = append(*changes, Change{Path: itemPath, Kind: ChangeAdded, New: diffValue(newItem, secret)})
+		case !newItem.IsValid():
+			*changes = append(*changes, Change{Path: itemPath, Kind: ChangeRemoved, Old: diffValue(oldItem, secret)})
+		default:
+			diffLeaf(itemPath, oldItem, newItem, secret, changes)
+		}
+	}
+}
+
+// diffRules compares rule lists as sets, so that moving a rule within the
+// list is not reported
+func diffRules(path string, oldV, newV reflect.Value, changes *[]Change) {
+	matched := make([]bool, newV.Len())
+	
+	for i := 0; i < oldV.Len(); i++ {
+		found := false
+		for j := 0; j < newV.Len(); j++ {
+			if !matched[j] && reflect.DeepEqual(oldV.Index(i).Interface(), newV.Index(j).Interface()) {
+				matched[j] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			*changes = append(*changes, Change{Path: path, Kind: ChangeRemoved, Old: toMap(oldV.Index(i))})
+		}
+	}
+	
+	for j := 0; j < newV.Len(); j++ {
+		if !matched[j] {
+			*changes = append(*changes, Change{Path: path, Kind: ChangeAdded, New: toMap(newV.Index(j))})
+		}
+	}
+}
+
+// diffValue renders a value for a change, redacting secrets
+func diffValue(v reflect.Value, secret bool) interface{} {
+	if secret {
+		if v.IsZero() {
+			return nil
+		}
+		return redacted
+	}
+	return toMap(v)
+}
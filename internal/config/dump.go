@@ -0,0 +1,89 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces the value of fields tagged secret:"true" in dumps
+const redacted = "REDACTED"
+
+// Dump renders the configuration as "yaml" or "json" using the same keys as
+// the configuration file. Secrets are redacted.
+func (c *Config) Dump(format string) ([]byte, error) {
+	m := toMap(reflect.ValueOf(*c))
+	
+	switch format {
+	case "", "yaml":
+		return yaml.Marshal(m)
+	case "json":
+		return json.MarshalIndent(m, "", "  ")
+	default:
+		return nil, fmt.Errorf("unsupported config format: %s", format)
+	}
+}
+
+// String renders the configuration as YAML with secrets redacted
+func (c *Config) String() string {
+	data, err := c.Dump("yaml")
+	if err != nil {
+		return fmt.Sprintf("<config: %v>", err)
+	}
+	return string(data)
+}
+
+// toMap converts a config value into maps and slices keyed by the
+// mapstructure tags, so dumps read like the configuration file
+func toMap(v reflect.Value) interface{} {
+	if d, ok := v.Interface().(time.Duration); ok {
+		return d.String()
+	}
+	
+	switch v.Kind() {
+	case reflect.Struct:
+		m := make(map[string]interface{}, v.NumField())
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			field := t.Field(i)
+			key := field.Tag.Get("mapstructure")
+			if key == "" || key == "-" {
+				continue
+			}
+			if field.Tag.Get("secret") == "true" {
+				if !v.Field(i).IsZero() {
+					m[key] = redacted
+				}
+				continue
+			}
+			m[key] = toMap(v.Field(i))
+		}
+		return m
+	case reflect.Slice:
+		if v.IsNil() {
+			return []interface{}{}
+		}
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			items[i] = toMap(v.Index(i))
+		}
+		return items
+	case reflect.Map:
+		m := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m[fmt.Sprint(iter.Key().Interface())] = toMap(iter.Value())
+		}
+		return m
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return toMap(v.Elem())
+	default:
+		return v.Interface()
+	}
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// RulesFile is a firewall rule file from firewall.rules_dir
+type RulesFile struct {
+	Path  string
+	Rules []FirewallRule
+}
+
+// IsRulesFile reports whether a file name has a rule file extension
+func IsRulesFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	default:
+		return false
+	}
+}
+
+// LoadRulesDir reads every rule file in dir, ordered by file name. Each file
+// holds a top-level "rules" list in the same format as firewall.rules.
+// Invalid rules in any file are reported together in a *ValidationError.
+func LoadRulesDir(dir string) ([]RulesFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules directory: %w", err)
+	}
+	
+	var files []RulesFile
+	errs := &ValidationError{}
+	
+	for _, entry := range entries {
+		if entry.IsDir() || !IsRulesFile(entry.Name()) {
+			continue
+		}
+		
+		path := filepath.Join(dir, entry.Name())
+		v := viper.New()
+		v.SetConfigFile(path)
+		if err := v.ReadInConfig(); err != nil {
+			errs.addf("%s: %v", path, err)
+			continue
+		}
+		
+		var file struct {
+			Rules []FirewallRule `mapstructure:"rules"`
+		}
+		if err := v.Unmarshal(&file); err != nil {
+			errs.addf("%s: %v", path, err)
+			continue
+		}
+		
+		for i, rule := range file.Rules {
+			validateFirewallRule(fmt.Sprintf("%s: rules[%d]", path, i), rule, errs)
+		}
+		files = append(files, RulesFile{Path: path, Rules: file.Rules})
+	}
+	
+	if len(errs.Problems) > 0 {
+		return nil, errs
+	}
+	
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
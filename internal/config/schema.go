@@ -0,0 +1,282 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// schemaDraft is the JSON Schema dialect of Schema
+const schemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// durationPattern matches the durations accepted by time.ParseDuration
+const durationPattern = `^-?([0-9]+(\.[0-9]*)?(ns|us|µs|ms|s|m|h))+$|^0$`
+
+// Schema returns a JSON Schema of the configuration file, derived from the
+// mapstructure tags of Config. Objects reject unknown keys, so that typos
+// are reported instead of silently ignored.
+func Schema() map[string]interface{} {
+	schema := schemaOf(reflect.TypeOf(Config{}))
+	schema["$schema"] = schemaDraft
+	schema["title"] = "hbf-agent configuration"
+	return schema
+}
+
+// schemaOf returns the schema of a configuration type
+func schemaOf(t reflect.Type) map[string]interface{} {
+	if t == reflect.TypeOf(time.Duration(0)) {
+		return map[string]interface{}{
+			"type":    []string{"string", "integer"},
+			"pattern": durationPattern,
+		}
+	}
+	
+	switch t.Kind() {
+	case reflect.Struct:
+		properties := make(map[string]interface{}, t.NumField())
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			key := field.Tag.Get("mapstructure")
+			if key == "" || key == "-" {
+				continue
+			}
+			properties[key] = schemaOf(field.Type)
+		}
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem())}
+	case reflect.Ptr:
+		return schemaOf(t.Elem())
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	default:
+		return map[string]interface{}{"type": "string"}
+	}
+}
+
+// ValidateFile checks a YAML or JSON configuration file against Schema
+// before it is unmarshaled, reporting unknown keys (with the closest known
+// key, e.g. sync_interval for sync_intervel) and values of the wrong type,
+// each with its line. All problems are reported together in a
+// *ValidationError. Values are only checked for their type; Validate checks
+// the rest once the file loads.
+func ValidateFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+	
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse config: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	
+	errs := &ValidationError{}
+	validateNode("", doc.Content[0], Schema(), errs)
+	if len(errs.Problems) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// validateNode checks a YAML node against a schema
+func validateNode(path string, node *yaml.Node, schema map[string]interface{}, errs *ValidationError) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return
+	}
+	
+	switch schema["type"] {
+	case "object":
+		if node.Kind != yaml.MappingNode {
+			errs.addf("line %d: %s must be a mapping, got %s", node.Line, schemaPath(path), nodeKind(node))
+			return
+		}
+		validateMapping(path, node, schema, errs)
+	case "array":
+		items := schema["items"].(map[string]interface{})
+		switch node.Kind {
+		case yaml.SequenceNode:
+			for i, item := range node.Content {
+				validateNode(fmt.Sprintf("%s[%d]", path, i), item, items, errs)
+			}
+		case yaml.ScalarNode:
+			// A single value is decoded as a list of one
+			if items["type"] == "object" {
+				errs.addf("line %d: %s must be a list, got %s", node.Line, schemaPath(path), nodeKind(node))
+			}
+		default:
+			errs.addf("line %d: %s must be a list, got %s", node.Line, schemaPath(path), nodeKind(node))
+		}
+	default:
+		if node.Kind != yaml.ScalarNode {
+			errs.addf("line %d: %s must be a single value, got %s", node.Line, schemaPath(path), nodeKind(node))
+			return
+		}
+		if !scalarMatches(node.Value, schema) {
+			errs.addf("line %d: %s must be %s, got %q", node.Line, schemaPath(path), schemaTypeName(schema), node.Value)
+		}
+	}
+}
+
+// validateMapping checks the keys and values of a mapping node. Keys are
+// matched case-insensitively, as viper does.
+func validateMapping(path string, node *yaml.Node, schema map[string]interface{}, errs *ValidationError) {
+	properties, _ := schema["properties"].(map[string]interface{})
+	additional, _ := schema["additionalProperties"].(map[string]interface{})
+	
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], node.Content[i+1]
+		if keyNode.Tag == "!!merge" {
+			validateNode(path, valueNode, schema, errs)
+			continue
+		}
+		
+		key := strings.ToLower(keyNode.Value)
+		itemPath := key
+		if path != "" {
+			itemPath = path + "." + key
+		}
+		
+		if additional != nil {
+			validateNode(itemPath, valueNode, additional, errs)
+			continue
+		}
+		
+		property, ok := properties[key].(map[string]interface{})
+		if !ok {
+			msg := fmt.Sprintf("line %d: unknown key %s", keyNode.Line, itemPath)
+			if suggestion := closestKey(key, properties); suggestion != "" {
+				msg += fmt.Sprintf(" (did you mean %s?)", suggestion)
+			}
+			errs.addf("%s", msg)
+			continue
+		}
+		validateNode(itemPath, valueNode, property, errs)
+	}
+}
+
+// scalarMatches reports whether a scalar decodes into a value of the schema
+// type. Like viper, numbers and booleans may be quoted.
+func scalarMatches(value string, schema map[string]interface{}) bool {
+	if _, ok := schema["pattern"]; ok {
+		if _, err := time.ParseDuration(value); err == nil {
+			return true
+		}
+		_, err := strconv.ParseInt(value, 10, 64)
+		return err == nil
+	}
+	
+	switch schema["type"] {
+	case "boolean":
+		_, err := strconv.ParseBool(value)
+		return err == nil
+	case "integer":
+		_, err := strconv.ParseInt(value, 0, 64)
+		return err == nil
+	case "number":
+		_, err := strconv.ParseFloat(value, 64)
+		return err == nil
+	default:
+		return true
+	}
+}
+
+// schemaTypeName describes the type of a scalar schema for messages
+func schemaTypeName(schema map[string]interface{}) string {
+	if _, ok := schema["pattern"]; ok {
+		return "a duration such as 30s"
+	}
+	switch schema["type"] {
+	case "boolean":
+		return "true or false"
+	case "integer":
+		return "an integer"
+	case "number":
+		return "a number"
+	default:
+		return "a string"
+	}
+}
+
+// nodeKind describes the kind of a YAML node for messages
+func nodeKind(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a list"
+	default:
+		return fmt.Sprintf("%q", node.Value)
+	}
+}
+
+// schemaPath names the document root in messages
+func schemaPath(path string) string {
+	if path == "" {
+		return "the configuration"
+	}
+	return path
+}
+
+// closestKey returns the known key nearest to an unknown one, if it is
+// close enough to be a typo
+func closestKey(key string, properties map[string]interface{}) string {
+	known := make([]string, 0, len(properties))
+	for name := range properties {
+		known = append(known, name)
+	}
+	sort.Strings(known)
+	
+	best, bestDistance := "", len(key)/3+1
+	for _, name := range known {
+		if d := editDistance(key, name); d <= bestDistance && (best == "" || d < editDistance(key, best)) {
+			best, bestDistance = name, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
@@ -0,0 +1,68 @@
+package events
+
+import (
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// bufferSize is the number of events buffered per subscriber before events
+// are dropped
+const bufferSize = 64
+
+// Bus fans events out to subscribers without ever blocking the publisher;
+// events for subscribers with a full buffer are dropped with a warning
+type Bus[T any] struct {
+	name        string
+	log         *logrus.Logger
+	subscribers map[chan T]struct{}
+	mu          sync.RWMutex
+}
+
+// NewBus creates a new event bus. The name identifies the bus in logs.
+func NewBus[T any](name string, log *logrus.Logger) *Bus[T] {
+	return &Bus[T]{
+		name:        name,
+		log:         log,
+		subscribers: make(map[chan T]struct{}),
+	}
+}
+
+// Subscribe returns a channel receiving published events
+func (b *Bus[T]) Subscribe() <-chan T {
+	ch := make(chan T, bufferSize)
+	
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+	
+	return ch
+}
+
+// Unsubscribe stops delivery to a channel returned by Subscribe and closes it
+func (b *Bus[T]) Unsubscribe(ch <-chan T) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	
+	for sub := range b.subscribers {
+		if sub == ch {
+			delete(b.subscribers, sub)
+			close(sub)
+			return
+		}
+	}
+}
+
+// Publish delivers an event to all subscribers
+func (b *Bus[T]) Publish(event T) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			b.log.Warnf("Dropping %s event: subscriber is not keeping up", b.name)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package firewall
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourusername/hbf-agent/internal/audit"
+	"github.com/yourusername/hbf-agent/internal/logging"
+	"github.com/yourusername/hbf-agent/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// BatchBackend is implemented by backends that can apply many rules in a
+// single operation
+type BatchBackend interface {
+	AddRules(rules []*Rule) error
+}
+
+// BatchError reports the rules of a batch that failed, keyed by their index
+// in the request
+type BatchError struct {
+	Errors map[int]error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("%d of the rules in the batch failed", len(e.Errors))
+}
+
+// AddRules adds a batch of firewall rules under a single lock. It returns the
+// assigned IDs in request order, with an empty ID for rules that failed.
+// Failed rules are reported in a *BatchError; successful ones are kept.
+func (m *Manager) AddRules(rules []*Rule) ([]string, error) {
+	return m.addRules(context.Background(), rules, false)
+}
+
+// AddRulesContext is AddRules, logging the request ID from ctx
+func (m *Manager) AddRulesContext(ctx context.Context, rules []*Rule) ([]string, error) {
+	return m.addRules(ctx, rules, false)
+}
+
+// AddRulesAtomic adds a batch of firewall rules, applying either all of them
+// or none. Rules already applied are rolled back if any rule fails.
+func (m *Manager) AddRulesAtomic(rules []*Rule) ([]string, error) {
+	return m.addRules(context.Background(), rules, true)
+}
+
+// AddRulesAtomicContext is AddRulesAtomic, logging the request ID from ctx
+func (m *Manager) AddRulesAtomicContext(ctx context.Context, rules []*Rule) ([]string, error) {
+	return m.addRules(ctx, rules, true)
+}
+
+// addRules adds a batch of rules, preferring the backend batch path
+func (m *Manager) addRules(ctx context.Context, rules []*Rule, atomic bool) ([]string, error) {
+	ctx, span := tracing.Start(ctx, "firewall.AddRules", trace.WithAttributes(
+		attribute.Int("firewall.rules", len(rules)),
+		attribute.Bool("firewall.atomic", atomic),
+	))
+	defer span.End()
+	
+	ids, err := m.applyBatch(ctx, rules, atomic)
+	return ids, tracing.Fail(span, err)
+}
+
+// applyBatch applies a batch of rules under the manager lock
+func (m *Manager) applyBatch(ctx context.Context, rules []*Rule, atomic bool) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	ids := make([]string, len(rules))
+	batchErr := &BatchError{Errors: make(map[int]error)}
+	
+	for i, rule := range rules {
+		if err := m.validateRuleLocked(rule); err != nil {
+			batchErr.Errors[i] = fmt.Errorf("%w: %v", ErrInvalidRule, err)
+		}
+	}
+	
+	if atomic && len(batchErr.Errors) > 0 {
+		return ids, batchErr
+	}
+	
+	if batch, ok := m.backend.(BatchBackend); ok && len(batchErr.Errors) == 0 {
+		now := time.Now()
+		for _, rule := range rules {
+			if rule.ID == "" {
+				rule.ID = m.generateRuleID()
+			}
+			rule.CreatedAt = now
+			setExpiry(rule, now)
+		}
+		
+		enabled := make([]*Rule, 0, len(rules))
+		for _, rule := range rules {
+			if rule.Enabled {
+				enabled = append(enabled, rule)
+			}
+		}
+		
+		err := batch.AddRules(enabled)
+		if err == nil {
+			chains := make(map[tableChain]bool)
+			for i, rule := range rules {
+				m.rules[rule.ID] = rule
+				ids[i] = rule.ID
+				chains[chainOf(rule)] = true
+				m.audit.Record(ctx, audit.ActionRuleAdd, rule)
+				m.publish(EventRuleAdded, rule)
+				m.recordAddLocked(rule)
+			}
+			logging.Entry(ctx, m.log).Infof("Added %d firewall rules in batch", len(rules))
+			
+			// The batch appends; move the rules to their priority positions
+			if ordered, ok := m.backend.(OrderedBackend); ok {
+				for chain := range chains {
+					if err := m.reorderChainLocked(ordered, chain); err != nil {
+						logging.Entry(ctx, m.log).Errorf("Failed to order chain %s: %v", chain, err)
+					}
+				}
+			}
+			return ids, nil
+		}
+		
+		// The backend has put back what it applied; every rule of the
+		// batch failed with it
+		if atomic {
+			for i, rule := range rules {
+				if rule.Enabled {
+					batchErr.Errors[i] = fmt.Errorf("failed to add rules: %w", err)
+				}
+			}
+			return ids, batchErr
+		}
+		logging.Entry(ctx, m.log).Warnf("Batch apply failed, falling back to per-rule apply: %v", err)
+	}
+	
+	for i, rule := range rules {
+		if _, failed := batchErr.Errors[i]; failed {
+			continue
+		}
+		
+		if err := m.addRuleLocked(ctx, rule); err != nil {
+			batchErr.Errors[i] = err
+			if atomic {
+				m.rollbackLocked(rules[:i])
+				return make([]string, len(rules)), batchErr
+			}
+			continue
+		}
+		ids[i] = rule.ID
+	}
+	
+	if len(batchErr.Errors) > 0 {
+		return ids, batchErr
+	}
+	
+	return ids, nil
+}
+
+// rollbackLocked removes rules applied earlier in a failed atomic batch.
+// Callers must hold m.mu.
+func (m *Manager) rollbackLocked(rules []*Rule) {
+	for _, rule := range rules {
+		if !rule.Enabled {
+			delete(m.rules, rule.ID)
+			m.publish(EventRuleDeleted, rule)
+			continue
+		}
+		if err := m.backend.DeleteRule(rule); err != nil {
+			m.log.Errorf("Failed to roll back rule %s: %v", rule.ID, err)
+		}
+		delete(m.rules, rule.ID)
+		m.publish(EventRuleDeleted, rule)
+		if m.metrics != nil {
+			m.metrics.RecordFirewallRuleDelete()
+			m.metrics.SetFirewallRulesTotal(float64(len(m.rules)))
+		}
+	}
+}
+
+// AddRules adds rules in one iptables-restore transaction per family, with
+// ip6tables-restore for IPv6. iptables-restore commits table by table, so
+// the tables are saved first and, if a transaction fails, every table
+// changed so far is restored: either all rules are applied or none.
+func (b *IPTablesBackend) AddRules(rules []*Rule) error {
+	var committed []savedTables
+	for _, h := range b.allHandles() {
+		var familyRules []*Rule
+		var tables []string
+		seen := make(map[string]bool)
+		for _, rule := range rules {
+			if !rule.appliesTo(h.family) {
+				continue
+			}
+			familyRules = append(familyRules, rule)
+			if !seen[rule.table()] {
+				seen[rule.table()] = true
+				tables = append(tables, rule.table())
+			}
+		}
+		if len(familyRules) == 0 {
+			continue
+		}
+		
+		saved, err := saveTables(h, tables)
+		if err != nil {
+			return errors.Join(err, restoreSaved(committed))
+		}
+		committed = append(committed, saved)
+		if err := b.restoreRules(h, familyRules); err != nil {
+			return errors.Join(err, restoreSaved(committed))
+		}
+	}
+	return nil
+}
+
+// restoreRules adds the rules of one family with its iptables-restore
+func (b *IPTablesBackend) restoreRules(h familyHandle, rules []*Rule) error {
+	var tables []string
+	lines := make(map[string][]string)
+	
+	for _, rule := range rules {
+		table := rule.table()
+		if _, seen := lines[table]; !seen {
+			tables = append(tables, table)
+			lines[table] = nil
+		}
+		
+		for _, spec := range b.kernelSpecs(rule) {
+			exists, err := h.ipt.Exists(table, rule.Chain, spec...)
+			if err != nil {
+				return fmt.Errorf("failed to check iptables rule: %w", err)
+			}
+			if exists {
+				continue
+			}
+			lines[table] = append(lines[table], fmt.Sprintf("-A %s %s", rule.Chain, restoreArgs(spec)))
+		}
+	}
+	
+	return restore(h, tables, lines)
+}
+
+// restore feeds the lines of each table to the iptables-restore of a
+// family, keeping the rules it does not touch. flags are passed on, e.g.
+// --test to check the input without committing it.
+func restore(h familyHandle, tables []string, lines map[string][]string, flags ...string) error {
+	var buf bytes.Buffer
+	for _, table := range tables {
+		fmt.Fprintf(&buf, "*%s\n", table)
+		for _, line := range lines[table] {
+			buf.WriteString(line + "\n")
+		}
+		buf.WriteString("COMMIT\n")
+	}
+	
+	cmd := exec.Command(h.restoreCommand(), append([]string{"--noflush"}, flags...)...)
+	cmd.Stdin = &buf
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", h.restoreCommand(), err, strings.TrimSpace(string(out)))
+	}
+	
+	return nil
+}
+
+// savedTables are tables of a family as iptables-save printed them
+type savedTables struct {
+	h    familyHandle
+	dump []byte
+}
+
+// saveTables saves the tables of a family with its iptables-save
+func saveTables(h familyHandle, tables []string) (savedTables, error) {
+	saved := savedTables{h: h}
+	for _, table := range tables {
+		out, err := exec.Command(h.saveCommand(), "-t", table).Output()
+		if err != nil {
+			return savedTables{}, fmt.Errorf("%s failed: %w", h.saveCommand(), err)
+		}
+		saved.dump = append(saved.dump, out...)
+	}
+	return saved, nil
+}
+
+// restore replaces the saved tables with their saved contents
+func (s savedTables) restore() error {
+	cmd := exec.Command(s.h.restoreCommand())
+	cmd.Stdin = bytes.NewReader(s.dump)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", s.h.restoreCommand(), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// restoreSaved puts back the tables saved before a failed transaction
+func restoreSaved(saved []savedTables) error {
+	var errs []error
+	for _, s := range saved {
+		if err := s.restore(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to restore the %s tables: %w", s.h.family, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// restoreArgs joins a rule specification for iptables-restore input,
+// quoting arguments that contain whitespace
+func restoreArgs(spec []string) string {
+	args := make([]string, len(spec))
+	for i, arg := range spec {
+		if arg == "" || strings.ContainsAny(arg, " \t\"") {
+			arg = strconv.Quote(arg)
+		}
+		args[i] = arg
+	}
+	return strings.Join(args, " ")
+}
@@ -0,0 +1,205 @@
+package firewall
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/yourusername/hbf-agent/internal/config"
+)
+
+// Kinds of rule conflicts
+const (
+	// ConflictShadowed is a rule that never matches because an earlier rule
+	// with a different action matches all of its packets
+	ConflictShadowed = "shadowed"
+	// ConflictRedundant is a rule that never matches because an earlier rule
+	// with the same action matches all of its packets
+	ConflictRedundant = "redundant"
+)
+
+// terminalActions end the evaluation of a packet in its chain. LOG,
+// NOTRACK and SETMARK continue with the next rule, so they shadow nothing.
+var terminalActions = map[string]bool{
+	"ACCEPT":     true,
+	"DROP":       true,
+	ActionReject: true,
+}
+
+// Conflict is a rule that can never match, because an earlier rule of its
+// chain matches every packet it would
+type Conflict struct {
+	Kind      string `json:"kind"`
+	Chain     string `json:"chain"`
+	RuleID    string `json:"rule_id"`
+	CoveredBy string `json:"covered_by"`
+	Message   string `json:"message"`
+}
+
+// DetectConflicts analyzes each chain in priority order and returns the
+// rules shadowed or made redundant by an earlier rule. Rate-limited rules
+// never cover others, since packets over the limit fall through.
+func (m *Manager) DetectConflicts() []Conflict {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	chains := make(map[tableChain]bool)
+	for _, rule := range m.rules {
+		chains[chainOf(rule)] = true
+	}
+	
+	conflicts := []Conflict{}
+	for _, chain := range sortedChains(chains) {
+		rules := m.chainRulesLocked(chain)
+		for i, rule := range rules {
+			if conflict, ok := findConflict(chain, rule, rules[:i]); ok {
+				conflicts = append(conflicts, conflict)
+			}
+		}
+	}
+	return conflicts
+}
+
+// conflictsOfLocked returns the conflicts rule has with the other rules of
+// its chain, as the covered or the covering rule. Callers must hold m.mu.
+func (m *Manager) conflictsOfLocked(rule *Rule) []Conflict {
+	chain := chainOf(rule)
+	rules := m.chainRulesLocked(chain)
+	
+	var conflicts []Conflict
+	for i, other := range rules {
+		conflict, ok := findConflict(chain, other, rules[:i])
+		if ok && (other.ID == rule.ID || conflict.CoveredBy == rule.ID) {
+			conflicts = append(conflicts, conflict)
+		}
+	}
+	return conflicts
+}
+
+// findConflict returns the first of the preceding rules that covers rule
+func findConflict(chain tableChain, rule *Rule, preceding []*Rule) (Conflict, bool) {
+	for _, earlier := range preceding {
+		if !covers(earlier, rule) {
+			continue
+		}
+		
+		conflict := Conflict{
+			Kind:      ConflictShadowed,
+			Chain:     chain.String(),
+			RuleID:    rule.ID,
+			CoveredBy: earlier.ID,
+		}
+		if earlier.Action == rule.Action {
+			conflict.Kind = ConflictRedundant
+			conflict.Message = fmt.Sprintf("rule %s is redundant, earlier rule %s already matches its packets with %s", rule.ID, earlier.ID, earlier.Action)
+		} else {
+			conflict.Message = fmt.Sprintf("rule %s (%s) is shadowed by earlier rule %s (%s)", rule.ID, rule.Action, earlier.ID, earlier.Action)
+		}
+		return conflict, true
+	}
+	return Conflict{}, false
+}
+
+// covers reports whether every packet matching b also matches a and ends
+// its evaluation there
+func covers(a, b *Rule) bool {
+	if !terminalActions[a.Action] || a.RateLimit != "" {
+		return false
+	}
+	
+	return coversFamily(a.family(), b.family()) &&
+		coversProtocol(a.Protocol, b.Protocol) &&
+		coversAddress(a.Source, b.Source) &&
+		coversAddress(a.Dest, b.Dest) &&
+		coversPort(a.SPort, b.SPort) &&
+		coversPort(a.DPort, b.DPort) &&
+		(a.ICMPType == "" || a.ICMPType == b.ICMPType) &&
+		(a.Mark == "" || a.Mark == b.Mark)
+}
+
+// coversFamily reports whether a rule of family a matches all packets of
+// family b
+func coversFamily(a, b string) bool {
+	return a == config.FamilyBoth || a == b
+}
+
+func coversProtocol(a, b string) bool {
+	if a == "" || a == "all" {
+		return true
+	}
+	return a == b
+}
+
+// coversAddress reports whether address or network a contains b
+func coversAddress(a, b string) bool {
+	if a == "" {
+		return true
+	}
+	if b == "" {
+		return false
+	}
+	
+	outer, innerNet := parseNetwork(a), parseNetwork(b)
+	if outer == nil || innerNet == nil {
+		return a == b
+	}
+	
+	outerOnes, outerBits := outer.Mask.Size()
+	innerOnes, innerBits := innerNet.Mask.Size()
+	return outerBits == innerBits && outerOnes <= innerOnes && outer.Contains(innerNet.IP)
+}
+
+// parseNetwork parses a CIDR or a single address as a network
+func parseNetwork(addr string) *net.IPNet {
+	if strings.Contains(addr, "/") {
+		_, network, err := net.ParseCIDR(addr)
+		if err != nil {
+			return nil
+		}
+		return network
+	}
+	
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return nil
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return &net.IPNet{IP: v4, Mask: net.CIDRMask(32, 32)}
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+}
+
+// coversPort reports whether port or range a contains b
+func coversPort(a, b string) bool {
+	if a == "" {
+		return true
+	}
+	if b == "" {
+		return false
+	}
+	
+	aLow, aHigh, okA := portRange(a)
+	bLow, bHigh, okB := portRange(b)
+	if !okA || !okB {
+		return a == b
+	}
+	return aLow <= bLow && bHigh <= aHigh
+}
+
+// portRange parses a port or first:last range
+func portRange(port string) (int, int, bool) {
+	first, last, isRange := strings.Cut(port, ":")
+	low, err := strconv.Atoi(first)
+	if err != nil {
+		return 0, 0, false
+	}
+	if !isRange {
+		return low, low, true
+	}
+	high, err := strconv.Atoi(last)
+	if err != nil {
+		return 0, 0, false
+	}
+	return low, high, true
+}
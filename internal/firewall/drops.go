@@ -0,0 +1,235 @@
+package firewall
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// kmsgPath is the kernel log device the LOG target and the nftables log
+// statement write to
+const kmsgPath = "/dev/kmsg"
+
+// ErrDropLogDisabled is returned by Drops unless firewall.drop_log is enabled
+var ErrDropLogDisabled = errors.New("drop log is not enabled")
+
+// Drop is a packet logged by a rule with a LogPrefix, read from the kernel
+// log
+type Drop struct {
+	Timestamp time.Time `json:"timestamp"`
+	Prefix    string    `json:"prefix"`
+	// RuleID is the first rule in chain order with the packet's prefix
+	// whose match covers the packet, empty if none does
+	RuleID   string `json:"rule_id,omitempty"`
+	Action   string `json:"action,omitempty"`
+	In       string `json:"in,omitempty"`
+	Out      string `json:"out,omitempty"`
+	Protocol string `json:"protocol"`
+	Source   string `json:"source"`
+	Dest     string `json:"dest"`
+	SPort    int    `json:"sport,omitempty"`
+	DPort    int    `json:"dport,omitempty"`
+}
+
+// dropRing keeps the latest logged packets, overwriting the oldest once full
+type dropRing struct {
+	mu    sync.Mutex
+	drops []Drop
+	next  int
+	count int
+}
+
+func newDropRing(size int) *dropRing {
+	return &dropRing{drops: make([]Drop, size)}
+}
+
+// add records a packet
+func (r *dropRing) add(drop Drop) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	
+	r.drops[r.next] = drop
+	r.next = (r.next + 1) % len(r.drops)
+	if r.count < len(r.drops) {
+		r.count++
+	}
+}
+
+// latest returns up to limit packets, newest first; limit 0 returns all
+func (r *dropRing) latest(limit int) []Drop {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	
+	n := r.count
+	if limit > 0 && limit < n {
+		n = limit
+	}
+	drops := make([]Drop, n)
+	for i := range drops {
+		drops[i] = r.drops[(r.next-1-i+len(r.drops))%len(r.drops)]
+	}
+	return drops
+}
+
+// Drops returns up to limit packets recently logged by rules with a
+// LogPrefix, newest first; limit 0 returns all that are kept
+func (m *Manager) Drops(limit int) ([]Drop, error) {
+	if m.drops == nil {
+		return nil, ErrDropLogDisabled
+	}
+	return m.drops.latest(limit), nil
+}
+
+// watchDrops reads the packets logged by managed rules from the kernel log
+// into the drop ring until ctx is cancelled. Records from before the start
+// are skipped.
+func (m *Manager) watchDrops(ctx context.Context) error {
+	kmsg, err := os.Open(kmsgPath)
+	if err != nil {
+		return fmt.Errorf("failed to open kernel log: %w", err)
+	}
+	if _, err := kmsg.Seek(0, io.SeekEnd); err != nil {
+		kmsg.Close()
+		return fmt.Errorf("failed to seek kernel log: %w", err)
+	}
+	
+	m.loops.Add(2)
+	go func() {
+		defer m.loops.Done()
+		// Closing the device ends the blocked read below
+		<-ctx.Done()
+		kmsg.Close()
+	}()
+	go func() {
+		defer m.loops.Done()
+		m.readDrops(ctx, kmsg)
+	}()
+	
+	return nil
+}
+
+// readDrops parses kernel log records until the log is closed. Each read
+// of /dev/kmsg returns one record.
+func (m *Manager) readDrops(ctx context.Context, kmsg io.Reader) {
+	buf := make([]byte, 8192)
+	for {
+		n, err := kmsg.Read(buf)
+		// Records overwritten before they were read fail with EPIPE; the
+		// next read continues with the oldest record still kept
+		if errors.Is(err, syscall.EPIPE) {
+			continue
+		}
+		if err != nil {
+			if ctx.Err() == nil && !errors.Is(err, os.ErrClosed) {
+				m.log.Errorf("Stopped reading dropped packets from the kernel log: %v", err)
+			}
+			return
+		}
+		
+		if drop, ok := parseKernelLogRecord(string(buf[:n])); ok && m.attributeDrop(&drop) {
+			m.drops.add(drop)
+		}
+	}
+}
+
+// attributeDrop sets the rule that logged the packet: of the managed rules
+// with the packet's log prefix, the first in chain order that matches it.
+// It returns false for packets no managed rule logs.
+func (m *Manager) attributeDrop(drop *Drop) bool {
+	packet := &Rule{
+		Protocol: drop.Protocol,
+		Source:   drop.Source,
+		Dest:     drop.Dest,
+	}
+	if drop.SPort > 0 {
+		packet.SPort = strconv.Itoa(drop.SPort)
+	}
+	if drop.DPort > 0 {
+		packet.DPort = strconv.Itoa(drop.DPort)
+	}
+	
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	prefixed := false
+	var matched []*Rule
+	for _, rule := range m.rules {
+		if rule.LogPrefix == "" || strings.TrimSpace(rule.LogPrefix) != drop.Prefix {
+			continue
+		}
+		prefixed = true
+		if coversProtocol(rule.Protocol, packet.Protocol) &&
+			coversAddress(rule.Source, packet.Source) &&
+			coversAddress(rule.Dest, packet.Dest) &&
+			coversPort(rule.SPort, packet.SPort) &&
+			coversPort(rule.DPort, packet.DPort) {
+			matched = append(matched, rule)
+		}
+	}
+	
+	if len(matched) > 0 {
+		sort.Slice(matched, func(i, j int) bool { return ruleLess(matched[i], matched[j]) })
+		drop.RuleID = matched[0].ID
+		drop.Action = matched[0].Action
+	}
+	return prefixed
+}
+
+// parseKernelLogRecord parses a /dev/kmsg record, "pri,seq,usec,flags;msg"
+// followed by continuation lines, whose message was written by the LOG
+// target or the nftables log statement:
+//
+//	PREFIX IN=eth0 OUT= MAC=... SRC=10.0.0.1 DST=10.0.0.2 ... PROTO=TCP SPT=4321 DPT=23 ...
+func parseKernelLogRecord(record string) (Drop, bool) {
+	_, msg, ok := strings.Cut(record, ";")
+	if !ok {
+		return Drop{}, false
+	}
+	msg, _, _ = strings.Cut(msg, "\n")
+	
+	start := strings.Index(msg, "IN=")
+	if start < 0 {
+		return Drop{}, false
+	}
+	
+	drop := Drop{
+		Timestamp: time.Now(),
+		Prefix:    strings.TrimSpace(msg[:start]),
+	}
+	for _, field := range strings.Fields(msg[start:]) {
+		key, value, _ := strings.Cut(field, "=")
+		switch key {
+		case "IN":
+			drop.In = value
+		case "OUT":
+			drop.Out = value
+		case "SRC":
+			drop.Source = value
+		case "DST":
+			drop.Dest = value
+		case "PROTO":
+			drop.Protocol = strings.ToLower(value)
+		case "SPT":
+			drop.SPort, _ = strconv.Atoi(value)
+		case "DPT":
+			drop.DPort, _ = strconv.Atoi(value)
+		}
+	}
+	
+	if drop.Prefix == "" || drop.Source == "" || drop.Dest == "" {
+		return Drop{}, false
+	}
+	// ICMPv6 is logged as PROTO=ICMPv6
+	if drop.Protocol == "icmpv6" || drop.Protocol == "ipv6-icmp" {
+		drop.Protocol = "icmpv6"
+	}
+	return drop, true
+}
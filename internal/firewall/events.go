@@ -0,0 +1,47 @@
+package firewall
+
+import (
+	"time"
+)
+
+// EventType represents the kind of a firewall event
+type EventType string
+
+const (
+	EventRuleAdded    EventType = "rule_added"
+	EventRuleDeleted  EventType = "rule_deleted"
+	EventRuleEnabled  EventType = "rule_enabled"
+	EventRuleDisabled EventType = "rule_disabled"
+	EventRulesFlushed EventType = "rules_flushed"
+)
+
+// Event describes a change to the managed rule set
+type Event struct {
+	Type      EventType `json:"type"`
+	Rule      *Rule     `json:"rule,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// publish publishes an event carrying a snapshot of the rule, if any
+func (m *Manager) publish(eventType EventType, rule *Rule) {
+	event := Event{
+		Type:      eventType,
+		Timestamp: time.Now(),
+	}
+	if rule != nil {
+		snapshot := *rule
+		event.Rule = &snapshot
+	}
+	m.events.Publish(event)
+}
+
+// Subscribe returns a channel receiving firewall events. Events are dropped
+// for subscribers that fall behind. Call Unsubscribe when done.
+func (m *Manager) Subscribe() <-chan Event {
+	return m.events.Subscribe()
+}
+
+// Unsubscribe stops delivery to a channel returned by Subscribe and closes it
+func (m *Manager) Unsubscribe(ch <-chan Event) {
+	m.events.Unsubscribe(ch)
+}
@@ -0,0 +1,106 @@
+package firewall
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	// maxExpiryWait bounds how long the expiry loop sleeps when no rule
+	// expires
+	maxExpiryWait = time.Hour
+	// expiryRetryDelay is how long the expiry loop waits before retrying
+	// rules whose deletion failed
+	expiryRetryDelay = 5 * time.Second
+)
+
+// setExpiry derives ExpiresAt from TTL for a rule added at now. An explicit
+// ExpiresAt is kept.
+func setExpiry(rule *Rule, now time.Time) {
+	if rule.TTL > 0 && rule.ExpiresAt.IsZero() {
+		rule.ExpiresAt = now.Add(rule.TTL)
+	}
+}
+
+// Remaining returns how long the rule has left before it expires, or 0 if it
+// never expires
+func (r *Rule) Remaining() time.Duration {
+	if r.ExpiresAt.IsZero() {
+		return 0
+	}
+	return max(time.Until(r.ExpiresAt), 0)
+}
+
+// wakeExpiry makes the expiry loop recompute the next expiry, e.g. after a
+// rule with an earlier expiry was added
+func (m *Manager) wakeExpiry() {
+	select {
+	case m.expiryWake <- struct{}{}:
+	default:
+	}
+}
+
+// expiryLoop deletes rules when they expire. It sleeps until the earliest
+// expiry and is woken when rules are added.
+func (m *Manager) expiryLoop(ctx context.Context) {
+	failed := false
+	for {
+		wait := m.untilNextExpiry()
+		if failed {
+			wait = max(wait, expiryRetryDelay)
+		}
+		
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-m.expiryWake:
+			timer.Stop()
+			continue
+		case <-timer.C:
+		}
+		
+		err := m.expireRules()
+		if err != nil {
+			m.log.Errorf("Failed to remove expired firewall rules: %v", err)
+		}
+		failed = err != nil
+	}
+}
+
+// untilNextExpiry returns the time until the earliest rule expiry
+func (m *Manager) untilNextExpiry() time.Duration {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	wait := maxExpiryWait
+	for _, rule := range m.rules {
+		if !rule.ExpiresAt.IsZero() {
+			wait = min(wait, time.Until(rule.ExpiresAt))
+		}
+	}
+	return max(wait, 0)
+}
+
+// expireRules deletes every rule whose expiry has passed from the backend
+// and the rule set
+func (m *Manager) expireRules() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	now := time.Now()
+	var errs []error
+	for _, rule := range m.rules {
+		if rule.ExpiresAt.IsZero() || now.Before(rule.ExpiresAt) {
+			continue
+		}
+		
+		m.log.Infof("Firewall rule %s expired", rule.ID)
+		if err := m.deleteRuleLocked(context.Background(), rule); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
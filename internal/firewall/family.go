@@ -0,0 +1,146 @@
+package firewall
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/coreos/go-iptables/iptables"
+	"github.com/yourusername/hbf-agent/internal/config"
+)
+
+// icmpv6NDPriority places the neighbor discovery rules before every other
+// rule, so that no rule can block them
+const icmpv6NDPriority = math.MinInt32
+
+// icmpv6NDRules returns the rules accepting ICMPv6 neighbor discovery in the
+// INPUT and OUTPUT chains whose policy drops traffic, if
+// firewall.allow_icmpv6_nd and firewall.enable_ipv6 are set. Without them a
+// default-deny IPv6 host loses its neighbors and routes and falls off the
+// network.
+func icmpv6NDRules(cfg config.FirewallConfig) []*Rule {
+	if !cfg.AllowICMPv6ND || !cfg.EnableIPv6 {
+		return nil
+	}
+	
+	var rules []*Rule
+	for _, chain := range []string{"INPUT", "OUTPUT"} {
+		if cfg.ChainPolicy(chain) != "DROP" {
+			continue
+		}
+		for _, icmpType := range config.ICMPv6NDTypes {
+			rules = append(rules, &Rule{
+				Chain:    chain,
+				Protocol: "icmpv6",
+				ICMPType: icmpType,
+				Action:   "ACCEPT",
+				Comment:  "ICMPv6 neighbor discovery",
+				Priority: icmpv6NDPriority,
+				Enabled:  true,
+				Family:   config.FamilyIPv6,
+			})
+		}
+	}
+	return rules
+}
+
+// family returns the address family the rule applies to: the one its
+// addresses, protocol or reject type imply, else its Family, else
+// config.FamilyBoth. Rules are validated before they are applied, so the
+// family is never contradictory here.
+func (r *Rule) family() string {
+	family, err := config.RuleFamily(r.Family, r.Protocol, r.RejectWith, r.Source, r.Dest)
+	if err != nil {
+		return r.Family
+	}
+	return family
+}
+
+// appliesTo reports whether the rule is applied to the tables of family,
+// config.FamilyIPv4 or config.FamilyIPv6
+func (r *Rule) appliesTo(family string) bool {
+	ruleFamily := r.family()
+	return ruleFamily == config.FamilyBoth || ruleFamily == family
+}
+
+// ValidateRule checks a rule like Rule.Validate and also that its address
+// family is enabled by firewall.enable_ipv6
+func (m *Manager) ValidateRule(rule *Rule) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	return m.validateRuleLocked(rule)
+}
+
+// validateRuleLocked implements ValidateRule. Callers must hold m.mu.
+func (m *Manager) validateRuleLocked(rule *Rule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	if err := config.FamilyEnabled(rule.Family, rule.family(), m.config.EnableIPv6); err != nil {
+		return &config.ValidationError{Problems: []string{err.Error()}}
+	}
+	return nil
+}
+
+// familyHandle is the iptables or ip6tables handle of an address family
+type familyHandle struct {
+	family string
+	ipt    *iptables.IPTables
+}
+
+// restoreCommand returns the iptables-restore command of the handle's family
+func (h familyHandle) restoreCommand() string {
+	if h.family == config.FamilyIPv6 {
+		return "ip6tables-restore"
+	}
+	return "iptables-restore"
+}
+
+// saveCommand returns the iptables-save command of the handle's family
+func (h familyHandle) saveCommand() string {
+	if h.family == config.FamilyIPv6 {
+		return "ip6tables-save"
+	}
+	return "iptables-save"
+}
+
+// allHandles returns the handles of the enabled families
+func (b *IPTablesBackend) allHandles() []familyHandle {
+	handles := []familyHandle{{config.FamilyIPv4, b.ipt}}
+	if b.ip6t != nil {
+		handles = append(handles, familyHandle{config.FamilyIPv6, b.ip6t})
+	}
+	return handles
+}
+
+// handles returns the handles of the families a rule is applied to
+func (b *IPTablesBackend) handles(rule *Rule) []familyHandle {
+	var handles []familyHandle
+	for _, h := range b.allHandles() {
+		if rule.appliesTo(h.family) {
+			handles = append(handles, h)
+		}
+	}
+	return handles
+}
+
+// newFamilyHandle creates the iptables handle of a protocol, probing the
+// filter table so that a missing CAP_NET_ADMIN is reported now rather than
+// on first use
+func newFamilyHandle(proto iptables.Protocol) (*iptables.IPTables, error) {
+	ipt, err := iptables.NewWithProtocol(proto)
+	if err != nil {
+		if isPermissionError(err) {
+			return nil, fmt.Errorf("%w: %v", ErrInsufficientPrivileges, err)
+		}
+		return nil, err
+	}
+	
+	if _, err := ipt.ListChains("filter"); err != nil {
+		if isPermissionError(err) {
+			return nil, fmt.Errorf("%w: %v", ErrInsufficientPrivileges, err)
+		}
+		return nil, err
+	}
+	return ipt, nil
+}
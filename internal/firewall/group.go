@@ -0,0 +1,88 @@
+package firewall
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/yourusername/hbf-agent/internal/logging"
+	"github.com/yourusername/hbf-agent/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ListRulesByGroup returns the rules of a group in priority order
+func (m *Manager) ListRulesByGroup(group string) []*Rule {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	return m.groupRulesLocked(group)
+}
+
+// DeleteRulesByGroup deletes every rule of a group
+func (m *Manager) DeleteRulesByGroup(group string) (int, error) {
+	return m.DeleteRulesByGroupContext(context.Background(), group)
+}
+
+// DeleteRulesByGroupContext deletes every rule of a group, logging the
+// request ID from ctx, and returns how many were deleted. The deletion is
+// atomic: if a rule cannot be removed from the backend, the rules of the
+// group removed so far are applied again and the group is left intact.
+func (m *Manager) DeleteRulesByGroupContext(ctx context.Context, group string) (int, error) {
+	ctx, span := tracing.Start(ctx, "firewall.DeleteRulesByGroup",
+		trace.WithAttributes(attribute.String("firewall.group", group)))
+	defer span.End()
+	
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	rules := m.groupRulesLocked(group)
+	if len(rules) == 0 {
+		return 0, tracing.Fail(span, fmt.Errorf("%w: %s", ErrGroupNotFound, group))
+	}
+	
+	for i, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+		if err := m.backend.DeleteRule(rule); err != nil {
+			m.restoreRulesLocked(rules[:i])
+			return 0, tracing.Fail(span, fmt.Errorf("failed to delete rule %s of group %s: %w", rule.ID, group, err))
+		}
+	}
+	
+	for _, rule := range rules {
+		m.forgetRuleLocked(ctx, rule)
+	}
+	logging.Entry(ctx, m.log).Infof("Deleted %d firewall rules of group %s", len(rules), group)
+	
+	span.SetAttributes(attribute.Int("firewall.rules", len(rules)))
+	return len(rules), nil
+}
+
+// groupRulesLocked returns the rules of a group in priority order. Callers
+// must hold m.mu.
+func (m *Manager) groupRulesLocked(group string) []*Rule {
+	rules := make([]*Rule, 0)
+	for _, rule := range m.rules {
+		if rule.Group == group {
+			rules = append(rules, rule)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool { return ruleLess(rules[i], rules[j]) })
+	return rules
+}
+
+// restoreRulesLocked applies again rules removed from the backend by a
+// failed group deletion. Rules are given in priority order, so each is
+// placed after those restored before it. Callers must hold m.mu.
+func (m *Manager) restoreRulesLocked(rules []*Rule) {
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+		if err := m.applyRuleLocked(rule); err != nil {
+			m.log.Errorf("Failed to restore rule %s: %v", rule.ID, err)
+		}
+	}
+}
@@ -2,30 +2,75 @@ package firewall
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/coreos/go-iptables/iptables"
 	"github.com/sirupsen/logrus"
+	"github.com/yourusername/hbf-agent/internal/audit"
 	"github.com/yourusername/hbf-agent/internal/config"
+	"github.com/yourusername/hbf-agent/internal/events"
+	"github.com/yourusername/hbf-agent/internal/idgen"
+	"github.com/yourusername/hbf-agent/internal/logging"
+	"github.com/yourusername/hbf-agent/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// ErrRuleNotFound is returned for operations on unknown rules
+	ErrRuleNotFound = errors.New("rule not found")
+	// ErrRuleExists is returned when adding a rule whose ID is taken
+	ErrRuleExists = errors.New("rule already exists")
+	// ErrInvalidRule wraps rule validation failures
+	ErrInvalidRule = errors.New("invalid rule")
+	// ErrGroupNotFound is returned for operations on groups without rules
+	ErrGroupNotFound = errors.New("rule group not found")
+	// ErrListUnsupported is returned by the ListRules of a backend that
+	// cannot read its rules back; sync then leaves the backend alone
+	ErrListUnsupported = errors.New("listing rules not supported")
 )
 
 // Manager manages firewall rules
 type Manager struct {
-	config    config.FirewallConfig
-	log       *logrus.Logger
-	backend   Backend
-	rules     map[string]*Rule
-	mu        sync.RWMutex
-	stopChan  chan struct{}
-	running   bool
+	config     config.FirewallConfig
+	log        *logrus.Logger
+	backend    Backend
+	mode       string // ModeEnforce or ModeObserve
+	newID      idgen.Generator
+	rules      map[string]*Rule
+	fromConfig map[string]bool // IDs of rules loaded from config
+	events     *events.Bus[Event]
+	audit      *audit.Logger
+	metrics    MetricsRecorder
+	expiryWake chan struct{} // wakes the expiry loop when rules are added
+	drops      *dropRing      // packets logged by rules, nil unless drop_log is enabled
+	mu         sync.RWMutex
+	cancel     context.CancelFunc // cancels the context of the loops
+	loops      sync.WaitGroup     // the loops started by Start
+	running    bool
+	syncErr    error
+}
+
+// MetricsRecorder records firewall metrics. It is satisfied by
+// metrics.Manager.
+type MetricsRecorder interface {
+	RecordFirewallRuleAdd()
+	RecordFirewallRuleDelete()
+	SetFirewallRulesTotal(count float64)
 }
 
 // Backend represents a firewall backend (iptables or nftables)
 type Backend interface {
 	AddRule(rule *Rule) error
 	DeleteRule(rule *Rule) error
+	// ListRules returns the rules in the kernel, or ErrListUnsupported
 	ListRules() ([]*Rule, error)
 	Flush() error
 	SetDefaultPolicy(chain, policy string) error
@@ -33,46 +78,151 @@ type Backend interface {
 
 // Rule represents a firewall rule
 type Rule struct {
-	ID        string
-	Chain     string
-	Protocol  string
-	Source    string
-	Dest      string
-	SPort     string
-	DPort     string
-	Action    string
-	Comment   string
-	CreatedAt time.Time
+	ID         string
+	Table      string // iptables table: filter (default), raw or mangle
+	Chain      string
+	Protocol   string
+	Source     string
+	Dest       string
+	SPort      string
+	DPort      string
+	Action     string
+	Comment    string
+	LogPrefix  string // when set, a LOG rule is emitted before the action
+	RateLimit  string // e.g. 10/second
+	RateBurst  int
+	PerSource  bool   // apply the rate limit per source address
+	RejectWith string // reject type for REJECT, e.g. tcp-reset
+	// ICMPType matches an icmp or icmpv6 type by name or number, with an
+	// optional /code, e.g. echo-request or 3/4
+	ICMPType   string
+	// Mark matches packets by fwmark, a value or value/mask in decimal or
+	// 0x hex, e.g. 0x100/0xff00
+	Mark       string
+	// SetMark is the fwmark set by ActionSetMark, a value or value/mask;
+	// bits outside the mask are kept
+	SetMark    string
+	Priority   int    // lower priorities come first in the chain
+	CreatedAt  time.Time
+	// TTL makes the rule expire this long after it is added
+	TTL        time.Duration
+	// ExpiresAt is when the rule is removed; zero means never
+	ExpiresAt  time.Time
+	// Enabled rules are applied to the backend. Disabled rules keep their ID
+	// and priority but are left out of the kernel until enabled again; see
+	// Manager.SetRuleEnabled.
+	Enabled    bool
+	// Group tags the rule, e.g. with the application owning it, so that
+	// its rules can be listed and deleted together
+	Group      string
+	// Family restricts the rule to config.FamilyIPv4 or config.FamilyIPv6,
+	// or applies it to config.FamilyBoth. When empty, the family is implied
+	// by the rule's addresses and protocol, and is both if they imply none.
+	Family     string
+}
+
+const (
+	// ActionLog is the action for rules that only log matching packets
+	ActionLog = "LOG"
+	// ActionReject is the action for rules that reject matching packets
+	ActionReject = "REJECT"
+	// ActionNotrack exempts matching packets from connection tracking, e.g.
+	// DNS or NTP traffic on busy nodes. It is only valid in TableRaw.
+	ActionNotrack = "NOTRACK"
+	// ActionSetMark sets the fwmark of matching packets to SetMark, e.g. to
+	// select a policy routing table. It is only valid in TableMangle.
+	ActionSetMark = "SETMARK"
+)
+
+const (
+	// TableFilter is the default table of rules
+	TableFilter = "filter"
+	// TableRaw is evaluated before connection tracking, in the PREROUTING
+	// and OUTPUT chains
+	TableRaw = "raw"
+	// TableMangle alters packets, e.g. their fwmark
+	TableMangle = "mangle"
+)
+
+// logsBeforeAction reports whether a companion LOG rule must precede the rule
+func (r *Rule) logsBeforeAction() bool {
+	return r.LogPrefix != "" && r.Action != ActionLog
+}
+
+// table returns the table of the rule, TableFilter unless set
+func (r *Rule) table() string {
+	if r.Table == "" {
+		return TableFilter
+	}
+	return r.Table
 }
 
 // NewManager creates a new firewall manager
 func NewManager(cfg config.FirewallConfig, log *logrus.Logger) (*Manager, error) {
 	var backend Backend
 	var err error
+	mode := ModeEnforce
 	
-	switch cfg.Backend {
-	case "iptables":
-		backend, err = NewIPTablesBackend(log)
-	case "nftables":
-		backend, err = NewNFTablesBackend(log)
+	switch {
+	case cfg.Mode == ModeObserve:
+		backend, mode = NewMemoryBackend(log), ModeObserve
+	case cfg.Backend == "iptables":
+		backend, err = NewIPTablesBackend(log, cfg.EnableIPv6)
+	case cfg.Backend == "nftables":
+		backend, err = NewNFTablesBackend(log, cfg.EnableIPv6)
+	case cfg.Backend == "memory":
+		backend = NewMemoryBackend(log)
 	default:
 		return nil, fmt.Errorf("unsupported firewall backend: %s", cfg.Backend)
 	}
 	
+	if errors.Is(err, ErrInsufficientPrivileges) && cfg.Mode == ModeAuto {
+		log.Warnf("Firewall rules will be recorded but not applied: %v", err)
+		backend, mode, err = NewMemoryBackend(log), ModeObserve, nil
+	}
+	
 	if err != nil {
 		return nil, fmt.Errorf("failed to create firewall backend: %w", err)
 	}
 	
-	return &Manager{
-		config:   cfg,
-		log:      log,
-		backend:  backend,
-		rules:    make(map[string]*Rule),
-		stopChan: make(chan struct{}),
-	}, nil
+	m := &Manager{
+		config:     cfg,
+		log:        log,
+		backend:    backend,
+		mode:       mode,
+		newID:      idgen.Random(),
+		rules:      make(map[string]*Rule),
+		fromConfig: make(map[string]bool),
+		events:     events.NewBus[Event]("firewall", log),
+		expiryWake: make(chan struct{}, 1),
+	}
+	if cfg.DropLog.Enabled {
+		m.drops = newDropRing(cfg.DropLog.BufferSize)
+	}
+	return m, nil
+}
+
+// SetAuditLog sets the audit log recording rule changes. It must be called
+// before Start.
+func (m *Manager) SetAuditLog(auditLog *audit.Logger) {
+	m.audit = auditLog
 }
 
-// Start starts the firewall manager
+// SetMetrics sets the recorder used for rule metrics. It must be called
+// before Start.
+func (m *Manager) SetMetrics(metrics MetricsRecorder) {
+	m.metrics = metrics
+}
+
+// SetIDGenerator replaces the generator of rule IDs, e.g. with
+// idgen.Sequence for deterministic IDs in tests. It must be called before
+// rules are added.
+func (m *Manager) SetIDGenerator(newID idgen.Generator) {
+	m.newID = newID
+}
+
+// Start starts the firewall manager. Its loops run until ctx is cancelled
+// or Stop is called; the manager can be started again after Stop.
 func (m *Manager) Start(ctx context.Context) error {
 	m.mu.Lock()
 	if m.running {
@@ -80,27 +230,59 @@ func (m *Manager) Start(ctx context.Context) error {
 		return fmt.Errorf("firewall manager is already running")
 	}
 	m.running = true
+	ctx, cancel := context.WithCancel(ctx)
+	m.cancel = cancel
 	m.mu.Unlock()
 	
+	fail := func(err error) error {
+		cancel()
+		m.mu.Lock()
+		m.running = false
+		m.mu.Unlock()
+		return err
+	}
+	
 	m.log.Info("Starting firewall manager...")
 	
 	// Set default policies
 	if err := m.setDefaultPolicies(); err != nil {
-		return fmt.Errorf("failed to set default policies: %w", err)
+		return fail(fmt.Errorf("failed to set default policies: %w", err))
 	}
 	
 	// Load initial rules from config
 	if err := m.loadConfigRules(); err != nil {
-		return fmt.Errorf("failed to load config rules: %w", err)
+		return fail(fmt.Errorf("failed to load config rules: %w", err))
+	}
+	
+	if m.config.WatchRulesDir {
+		if err := m.watchRulesDir(ctx, m.config.RulesDir); err != nil {
+			return fail(err)
+		}
+	}
+	
+	// The drop log is diagnostics: the firewall runs without it
+	if m.drops != nil {
+		if err := m.watchDrops(ctx); err != nil {
+			m.log.Warnf("Dropped packets will not be logged: %v", err)
+		}
 	}
 	
 	// Start sync loop
-	go m.syncLoop(ctx)
+	m.loops.Add(2)
+	go func() {
+		defer m.loops.Done()
+		m.syncLoop(ctx)
+	}()
+	go func() {
+		defer m.loops.Done()
+		m.expiryLoop(ctx)
+	}()
 	
 	return nil
 }
 
-// Stop stops the firewall manager
+// Stop stops the firewall manager, cancelling its loops and waiting for
+// them to return
 func (m *Manager) Stop() error {
 	m.mu.Lock()
 	if !m.running {
@@ -108,9 +290,11 @@ func (m *Manager) Stop() error {
 		return fmt.Errorf("firewall manager is not running")
 	}
 	m.running = false
+	cancel := m.cancel
 	m.mu.Unlock()
 	
-	close(m.stopChan)
+	cancel()
+	m.loops.Wait()
 	m.log.Info("Firewall manager stopped")
 	
 	return nil
@@ -118,44 +302,166 @@ func (m *Manager) Stop() error {
 
 // AddRule adds a new firewall rule
 func (m *Manager) AddRule(rule *Rule) error {
+	return m.AddRuleContext(context.Background(), rule)
+}
+
+// AddRuleContext adds a new firewall rule, logging the request ID from ctx
+func (m *Manager) AddRuleContext(ctx context.Context, rule *Rule) error {
+	ctx, span := tracing.Start(ctx, "firewall.AddRule", trace.WithAttributes(
+		attribute.String("firewall.chain", rule.Chain),
+		attribute.String("firewall.action", rule.Action),
+	))
+	defer span.End()
+	
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	
+	if _, exists := m.rules[rule.ID]; exists {
+		return tracing.Fail(span, fmt.Errorf("%w: %s", ErrRuleExists, rule.ID))
+	}
+	
+	return tracing.Fail(span, m.addRuleLocked(ctx, rule))
+}
+
+// addRuleLocked validates and applies a rule. Callers must hold m.mu.
+func (m *Manager) addRuleLocked(ctx context.Context, rule *Rule) error {
+	if err := m.validateRuleLocked(rule); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRule, err)
+	}
+	
 	if rule.ID == "" {
-		rule.ID = generateRuleID()
+		rule.ID = m.generateRuleID()
 	}
 	rule.CreatedAt = time.Now()
+	setExpiry(rule, rule.CreatedAt)
 	
-	if err := m.backend.AddRule(rule); err != nil {
-		return fmt.Errorf("failed to add rule: %w", err)
+	if rule.Enabled {
+		if err := m.applyRuleLocked(rule); err != nil {
+			return fmt.Errorf("failed to add rule: %w", err)
+		}
 	}
 	
-	m.rules[rule.ID] = rule
-	m.log.Infof("Added firewall rule: %s", rule.ID)
-	
+	m.trackRuleLocked(ctx, rule)
 	return nil
 }
 
+// trackRuleLocked records a rule applied to the backend. Callers must hold
+// m.mu.
+func (m *Manager) trackRuleLocked(ctx context.Context, rule *Rule) {
+	m.rules[rule.ID] = rule
+	if rule.Enabled {
+		logging.Entry(ctx, m.log).Infof("Added firewall rule: %s", rule.ID)
+	} else {
+		logging.Entry(ctx, m.log).Infof("Added disabled firewall rule: %s", rule.ID)
+	}
+	for _, conflict := range m.conflictsOfLocked(rule) {
+		logging.Entry(ctx, m.log).Warnf("Firewall rule conflict: %s", conflict.Message)
+	}
+	m.audit.Record(ctx, audit.ActionRuleAdd, rule)
+	m.publish(EventRuleAdded, rule)
+	m.recordAddLocked(rule)
+}
+
+// recordAddLocked updates the rule metrics and, for expiring rules, wakes the
+// expiry loop. Callers must hold m.mu.
+func (m *Manager) recordAddLocked(rule *Rule) {
+	if m.metrics != nil {
+		m.metrics.RecordFirewallRuleAdd()
+		m.metrics.SetFirewallRulesTotal(float64(len(m.rules)))
+	}
+	if !rule.ExpiresAt.IsZero() {
+		m.wakeExpiry()
+	}
+}
+
 // DeleteRule deletes a firewall rule
 func (m *Manager) DeleteRule(ruleID string) error {
+	return m.DeleteRuleContext(context.Background(), ruleID)
+}
+
+// DeleteRuleContext deletes a firewall rule, logging the request ID from ctx
+func (m *Manager) DeleteRuleContext(ctx context.Context, ruleID string) error {
+	ctx, span := tracing.Start(ctx, "firewall.DeleteRule",
+		trace.WithAttributes(attribute.String("firewall.rule_id", ruleID)))
+	defer span.End()
+	
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	
 	rule, exists := m.rules[ruleID]
 	if !exists {
-		return fmt.Errorf("rule not found: %s", ruleID)
+		return tracing.Fail(span, fmt.Errorf("%w: %s", ErrRuleNotFound, ruleID))
 	}
 	
-	if err := m.backend.DeleteRule(rule); err != nil {
-		return fmt.Errorf("failed to delete rule: %w", err)
+	return tracing.Fail(span, m.deleteRuleLocked(ctx, rule))
+}
+
+// deleteRuleLocked removes a rule from the backend. Callers must hold m.mu.
+func (m *Manager) deleteRuleLocked(ctx context.Context, rule *Rule) error {
+	if rule.Enabled {
+		if err := m.backend.DeleteRule(rule); err != nil {
+			return fmt.Errorf("failed to delete rule: %w", err)
+		}
 	}
 	
-	delete(m.rules, ruleID)
-	m.log.Infof("Deleted firewall rule: %s", ruleID)
-	
+	m.forgetRuleLocked(ctx, rule)
 	return nil
 }
 
+// forgetRuleLocked drops a rule already removed from the backend. Callers
+// must hold m.mu.
+func (m *Manager) forgetRuleLocked(ctx context.Context, rule *Rule) {
+	delete(m.rules, rule.ID)
+	delete(m.fromConfig, rule.ID)
+	logging.Entry(ctx, m.log).Infof("Deleted firewall rule: %s", rule.ID)
+	m.audit.Record(ctx, audit.ActionRuleDelete, rule)
+	m.publish(EventRuleDeleted, rule)
+	if m.metrics != nil {
+		m.metrics.RecordFirewallRuleDelete()
+		m.metrics.SetFirewallRulesTotal(float64(len(m.rules)))
+	}
+}
+
+// SetRuleEnabled enables or disables a rule. Disabling removes the rule
+// from the backend but keeps its ID and priority; enabling applies it again
+// at its priority position. Setting the current state again does nothing.
+func (m *Manager) SetRuleEnabled(ctx context.Context, ruleID string, enabled bool) (*Rule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	rule, exists := m.rules[ruleID]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrRuleNotFound, ruleID)
+	}
+	if rule.Enabled == enabled {
+		return rule, nil
+	}
+	
+	if enabled {
+		rule.Enabled = true
+		if err := m.applyRuleLocked(rule); err != nil {
+			rule.Enabled = false
+			return nil, fmt.Errorf("failed to enable rule: %w", err)
+		}
+		logging.Entry(ctx, m.log).Infof("Enabled firewall rule: %s", rule.ID)
+		for _, conflict := range m.conflictsOfLocked(rule) {
+			logging.Entry(ctx, m.log).Warnf("Firewall rule conflict: %s", conflict.Message)
+		}
+		m.audit.Record(ctx, audit.ActionRuleEnable, rule)
+		m.publish(EventRuleEnabled, rule)
+		return rule, nil
+	}
+	
+	if err := m.backend.DeleteRule(rule); err != nil {
+		return nil, fmt.Errorf("failed to disable rule: %w", err)
+	}
+	rule.Enabled = false
+	logging.Entry(ctx, m.log).Infof("Disabled firewall rule: %s", rule.ID)
+	m.audit.Record(ctx, audit.ActionRuleDisable, rule)
+	m.publish(EventRuleDisabled, rule)
+	return rule, nil
+}
+
 // ListRules returns all firewall rules
 func (m *Manager) ListRules() []*Rule {
 	m.mu.RLock()
@@ -169,6 +475,72 @@ func (m *Manager) ListRules() []*Rule {
 	return rules
 }
 
+// RuntimeRules returns the rules added at runtime, leaving out those loaded
+// from the configuration and rule files, ordered by priority
+func (m *Manager) RuntimeRules() []*Rule {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	rules := make([]*Rule, 0, len(m.rules))
+	for id, rule := range m.rules {
+		if !m.fromConfig[id] {
+			rules = append(rules, rule)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool { return ruleLess(rules[i], rules[j]) })
+	
+	return rules
+}
+
+// RuleFilter selects and pages rules for ListRulesFiltered. Empty fields
+// match everything; a zero Limit returns all remaining rules.
+type RuleFilter struct {
+	Chain  string
+	Action string
+	Group  string
+	Limit  int
+	Offset int
+}
+
+// ListRulesFiltered returns the rules matching filter, ordered by creation
+// time, along with the total number of matches before paging
+func (m *Manager) ListRulesFiltered(filter RuleFilter) ([]*Rule, int) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	matched := make([]*Rule, 0, len(m.rules))
+	for _, rule := range m.rules {
+		if filter.Chain != "" && rule.Chain != filter.Chain {
+			continue
+		}
+		if filter.Action != "" && rule.Action != filter.Action {
+			continue
+		}
+		if filter.Group != "" && rule.Group != filter.Group {
+			continue
+		}
+		matched = append(matched, rule)
+	}
+	
+	sort.Slice(matched, func(i, j int) bool {
+		if !matched[i].CreatedAt.Equal(matched[j].CreatedAt) {
+			return matched[i].CreatedAt.Before(matched[j].CreatedAt)
+		}
+		return matched[i].ID < matched[j].ID
+	})
+	
+	total := len(matched)
+	if filter.Offset >= total {
+		return []*Rule{}, total
+	}
+	matched = matched[filter.Offset:]
+	if filter.Limit > 0 && filter.Limit < len(matched) {
+		matched = matched[:filter.Limit]
+	}
+	
+	return matched, total
+}
+
 // GetRule returns a specific rule by ID
 func (m *Manager) GetRule(ruleID string) (*Rule, error) {
 	m.mu.RLock()
@@ -176,7 +548,7 @@ func (m *Manager) GetRule(ruleID string) (*Rule, error) {
 	
 	rule, exists := m.rules[ruleID]
 	if !exists {
-		return nil, fmt.Errorf("rule not found: %s", ruleID)
+		return nil, fmt.Errorf("%w: %s", ErrRuleNotFound, ruleID)
 	}
 	
 	return rule, nil
@@ -184,91 +556,291 @@ func (m *Manager) GetRule(ruleID string) (*Rule, error) {
 
 // Flush removes all firewall rules
 func (m *Manager) Flush() error {
+	return m.FlushContext(context.Background())
+}
+
+// FlushContext removes all firewall rules, recording the caller from ctx in
+// the audit log
+func (m *Manager) FlushContext(ctx context.Context) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	
+	if err := m.flushLocked(ctx); err != nil {
+		return err
+	}
+	m.audit.Record(ctx, audit.ActionRulesFlush, nil)
+	
+	return nil
+}
+
+// ReloadRules flushes all rules, including those added through the API, and
+// reapplies the rules from the configuration and the rules directory. It
+// returns the number of rules in effect afterwards.
+func (m *Manager) ReloadRules() (int, error) {
+	return m.ReloadRulesContext(context.Background())
+}
+
+// ReloadRulesContext is ReloadRules, recording the caller from ctx in the
+// audit log
+func (m *Manager) ReloadRulesContext(ctx context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	// Read the rule files first so that a broken file does not leave the
+	// host without rules
+	rules, err := m.desiredRules(m.config)
+	if err != nil {
+		return len(m.rules), err
+	}
+	
+	if err := m.flushLocked(ctx); err != nil {
+		return len(m.rules), err
+	}
+	
+	m.addConfigRulesLocked(rules)
+	logging.Entry(ctx, m.log).Infof("Reapplied %d firewall rules from config", len(m.rules))
+	m.audit.Record(ctx, audit.ActionRulesReload, nil)
+	
+	return len(m.rules), nil
+}
+
+// flushLocked removes all rules from the backend and the rule set, then
+// reapplies the default policies, which the backend flush may have reset.
+// Callers must hold m.mu.
+func (m *Manager) flushLocked(ctx context.Context) error {
 	if err := m.backend.Flush(); err != nil {
 		return fmt.Errorf("failed to flush rules: %w", err)
 	}
 	
 	m.rules = make(map[string]*Rule)
-	m.log.Info("Flushed all firewall rules")
+	m.fromConfig = make(map[string]bool)
+	if m.metrics != nil {
+		m.metrics.SetFirewallRulesTotal(0)
+	}
+	logging.Entry(ctx, m.log).Info("Flushed all firewall rules")
+	m.publish(EventRulesFlushed, nil)
+	
+	if err := m.setDefaultPolicies(); err != nil {
+		return fmt.Errorf("failed to restore default policies after flush: %w", err)
+	}
 	
 	return nil
 }
 
-// setDefaultPolicies sets the default firewall policies
-func (m *Manager) setDefaultPolicies() error {
-	chains := []string{"INPUT", "FORWARD", "OUTPUT"}
-	policy := "ACCEPT"
+// Backend returns the firewall backend, e.g. to inspect a *MemoryBackend in
+// tests
+func (m *Manager) Backend() Backend {
+	return m.backend
+}
+
+// Mode returns ModeEnforce if rules are applied to the kernel, or
+// ModeObserve if they are only recorded
+func (m *Manager) Mode() string {
+	return m.mode
+}
+
+// Healthy returns an error if the manager is not running, the backend is
+// unreachable or the last rule sync failed
+func (m *Manager) Healthy() error {
+	m.mu.RLock()
+	running, syncErr := m.running, m.syncErr
+	m.mu.RUnlock()
+	
+	if !running {
+		return fmt.Errorf("firewall manager is not running")
+	}
 	
-	if m.config.DefaultPolicy == "deny" {
-		policy = "DROP"
+	if checker, ok := m.backend.(interface{ Healthy() error }); ok {
+		if err := checker.Healthy(); err != nil {
+			return fmt.Errorf("firewall backend unhealthy: %w", err)
+		}
 	}
 	
-	for _, chain := range chains {
+	if syncErr != nil {
+		return fmt.Errorf("last rule sync failed: %w", syncErr)
+	}
+	
+	return nil
+}
+
+// setDefaultPolicies sets the default firewall policies
+func (m *Manager) setDefaultPolicies() error {
+	return m.setPolicies(m.config)
+}
+
+// setPolicies sets the default policy of every chain from cfg
+func (m *Manager) setPolicies(cfg config.FirewallConfig) error {
+	applied := make([]string, 0, len(config.PolicyChains))
+	for _, chain := range config.PolicyChains {
+		policy := cfg.ChainPolicy(chain)
 		if err := m.backend.SetDefaultPolicy(chain, policy); err != nil {
 			return fmt.Errorf("failed to set default policy for %s: %w", chain, err)
 		}
+		applied = append(applied, chain+"="+policy)
 	}
 	
-	m.log.Infof("Set default policy to %s", policy)
+	m.log.Infof("Set default policies: %s", strings.Join(applied, ", "))
 	return nil
 }
 
-// loadConfigRules loads rules from configuration
+// loadConfigRules loads rules from configuration and the rules directory
 func (m *Manager) loadConfigRules() error {
-	for _, cfgRule := range m.config.Rules {
-		rule := &Rule{
-			Chain:    cfgRule.Chain,
-			Protocol: cfgRule.Protocol,
-			Source:   cfgRule.Source,
-			Dest:     cfgRule.Dest,
-			SPort:    cfgRule.SPort,
-			DPort:    cfgRule.DPort,
-			Action:   cfgRule.Action,
-			Comment:  cfgRule.Comment,
-		}
-		
-		if err := m.AddRule(rule); err != nil {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	rules, err := m.desiredRules(m.config)
+	if err != nil {
+		return err
+	}
+	
+	m.addConfigRulesLocked(rules)
+	return nil
+}
+
+// addConfigRulesLocked applies rules from the configuration, logging the
+// ones that fail. Callers must hold m.mu.
+func (m *Manager) addConfigRulesLocked(rules []*Rule) {
+	for _, rule := range rules {
+		if err := m.addRuleLocked(context.Background(), rule); err != nil {
 			m.log.Errorf("Failed to add config rule: %v", err)
+			continue
+		}
+		m.fromConfig[rule.ID] = true
+	}
+}
+
+// Reload applies a new firewall configuration in place. Rules loaded from
+// the previous configuration that are no longer present are deleted and new
+// ones are added in one transaction (see ReplaceRules), while unchanged
+// rules and rules added through the API are left alone. Rule files are
+// reread from the rules directory; if they cannot be read, nothing is
+// changed. Default policies are applied after the rules, and the new
+// configuration is kept only once both succeed. Changing the backend
+// requires a restart.
+func (m *Manager) Reload(cfg config.FirewallConfig) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	if cfg.Backend != m.config.Backend {
+		return fmt.Errorf("changing firewall backend from %s to %s requires a restart", m.config.Backend, cfg.Backend)
+	}
+	
+	desired, err := m.desiredRules(cfg)
+	if err != nil {
+		return err
+	}
+	
+	added, removed, err := m.replaceRulesLocked(context.Background(), desired)
+	if err != nil {
+		return err
+	}
+	
+	if policiesChanged(m.config, cfg) {
+		if err := m.setPolicies(cfg); err != nil {
+			// Put back the policies of the chains set before the failure
+			return errors.Join(err, m.setDefaultPolicies())
 		}
 	}
 	
+	m.config = cfg
+	m.log.Infof("Reloaded firewall config: %d rules added, %d removed", added, removed)
+	
 	return nil
 }
 
+// policiesChanged reports whether the default policy of any chain differs
+// between two configurations
+func policiesChanged(old, new config.FirewallConfig) bool {
+	for _, chain := range config.PolicyChains {
+		if old.ChainPolicy(chain) != new.ChainPolicy(chain) {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleFromConfig converts a configured rule to a Rule
+func ruleFromConfig(cfgRule config.FirewallRule) *Rule {
+	return &Rule{
+		Table:      cfgRule.Table,
+		Chain:      cfgRule.Chain,
+		Protocol:   cfgRule.Protocol,
+		Source:     cfgRule.Source,
+		Dest:       cfgRule.Dest,
+		SPort:      cfgRule.SPort,
+		DPort:      cfgRule.DPort,
+		Action:     cfgRule.Action,
+		Comment:    cfgRule.Comment,
+		LogPrefix:  cfgRule.LogPrefix,
+		RateLimit:  cfgRule.RateLimit,
+		RateBurst:  cfgRule.RateBurst,
+		PerSource:  cfgRule.PerSource,
+		RejectWith: cfgRule.RejectWith,
+		Priority:   cfgRule.Priority,
+		ICMPType:   cfgRule.ICMPType,
+		Mark:       cfgRule.Mark,
+		SetMark:    cfgRule.SetMark,
+		Group:      cfgRule.Group,
+		Family:     cfgRule.Family,
+		Enabled:    cfgRule.Enabled == nil || *cfgRule.Enabled,
+	}
+}
+
 // syncLoop periodically syncs firewall rules
 func (m *Manager) syncLoop(ctx context.Context) {
-	ticker := time.NewTicker(m.config.SyncInterval)
+	interval := m.config.SyncInterval
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 	
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-m.stopChan:
-			return
 		case <-ticker.C:
-			if err := m.sync(); err != nil {
+			err := m.sync()
+			if err != nil {
 				m.log.Errorf("Failed to sync firewall rules: %v", err)
 			}
+			m.mu.Lock()
+			m.syncErr = err
+			next := m.config.SyncInterval
+			m.mu.Unlock()
+			
+			// Pick up interval changes from Reload
+			if next != interval {
+				interval = next
+				ticker.Reset(interval)
+			}
 		}
 	}
 }
 
-// sync synchronizes firewall rules with the backend
+// sync synchronizes firewall rules with the backend. Ordered backends are
+// also checked for rules that drifted out of priority order. Backends that
+// cannot list their rules are not synced: every rule would look missing and
+// be added again on each sync.
 func (m *Manager) sync() error {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	
+	if ordered, ok := m.backend.(OrderedBackend); ok {
+		return m.syncOrderLocked(ordered)
+	}
+	
 	backendRules, err := m.backend.ListRules()
+	if errors.Is(err, ErrListUnsupported) {
+		m.log.Debugf("Skipping firewall sync: %v", err)
+		return nil
+	}
 	if err != nil {
 		return fmt.Errorf("failed to list backend rules: %w", err)
 	}
 	
 	// Check for missing rules and add them
 	for _, rule := range m.rules {
+		if !rule.Enabled {
+			continue
+		}
 		found := false
 		for _, backendRule := range backendRules {
 			if rulesEqual(rule, backendRule) {
@@ -288,87 +860,261 @@ func (m *Manager) sync() error {
 	return nil
 }
 
+// Validate checks the rule fields before the rule reaches a backend. All
+// problems are reported together in a *config.ValidationError.
+func (r *Rule) Validate() error {
+	var problems []string
+	addf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	check := func(field string, err error) {
+		if err != nil {
+			addf("%s: %v", field, err)
+		}
+	}
+	checkErr := func(err error) {
+		if err != nil {
+			addf("%v", err)
+		}
+	}
+	
+	if r.Chain == "" {
+		addf("chain is required")
+	}
+	checkErr(config.ValidateProtocol(r.Protocol))
+	checkErr(config.ValidateAction(r.Action))
+	checkErr(config.ValidateTable(r.Table, r.Chain, r.Action))
+	if r.Source != "" {
+		check("source", config.ValidateAddress(r.Source))
+	}
+	if r.Dest != "" {
+		check("dest", config.ValidateAddress(r.Dest))
+	}
+	if r.SPort != "" || r.DPort != "" {
+		if !config.PortProtocols[r.Protocol] {
+			addf("ports require protocol tcp, udp or sctp")
+		}
+		if r.SPort != "" {
+			check("sport", config.ValidatePort(r.SPort))
+		}
+		if r.DPort != "" {
+			check("dport", config.ValidatePort(r.DPort))
+		}
+	}
+	
+	if r.ICMPType != "" {
+		_, _, err := config.ParseICMPType(r.ICMPType, r.Protocol)
+		checkErr(err)
+	}
+	if r.Group != "" {
+		checkErr(config.ValidateGroup(r.Group))
+	}
+	_, err := config.RuleFamily(r.Family, r.Protocol, r.RejectWith, r.Source, r.Dest)
+	checkErr(err)
+	
+	if r.TTL < 0 {
+		addf("rule TTL must not be negative")
+	}
+	if !r.ExpiresAt.IsZero() && !r.ExpiresAt.After(time.Now()) {
+		addf("rule expiry %s is in the past", r.ExpiresAt.Format(time.RFC3339))
+	}
+	
+	if r.RateLimit != "" {
+		checkErr(config.ValidateRateLimit(r.RateLimit))
+	}
+	if r.RateBurst < 0 {
+		addf("rate burst must not be negative")
+	}
+	
+	if r.RejectWith != "" {
+		if r.Action != ActionReject {
+			addf("reject type requires action %s", ActionReject)
+		} else {
+			checkErr(config.ValidateRejectWith(r.RejectWith, r.Protocol))
+		}
+	}
+	
+	if r.Mark != "" {
+		check("mark", config.ValidateMark(r.Mark))
+	}
+	check("set mark", config.ValidateSetMark(r.SetMark, r.Action))
+	
+	if len(problems) > 0 {
+		return &config.ValidationError{Problems: problems}
+	}
+	return nil
+}
+
 // rulesEqual checks if two rules are equal
 func rulesEqual(r1, r2 *Rule) bool {
-	return r1.Chain == r2.Chain &&
+	return r1.table() == r2.table() &&
+		r1.Chain == r2.Chain &&
 		r1.Protocol == r2.Protocol &&
 		r1.Source == r2.Source &&
 		r1.Dest == r2.Dest &&
 		r1.SPort == r2.SPort &&
 		r1.DPort == r2.DPort &&
-		r1.Action == r2.Action
+		r1.Action == r2.Action &&
+		r1.LogPrefix == r2.LogPrefix &&
+		r1.RateLimit == r2.RateLimit &&
+		r1.RateBurst == r2.RateBurst &&
+		r1.PerSource == r2.PerSource &&
+		r1.RejectWith == r2.RejectWith &&
+		r1.ICMPType == r2.ICMPType &&
+		r1.Mark == r2.Mark &&
+		r1.SetMark == r2.SetMark &&
+		r1.family() == r2.family()
 }
 
 // generateRuleID generates a unique rule ID
-func generateRuleID() string {
-	return fmt.Sprintf("rule-%d", time.Now().UnixNano())
+func (m *Manager) generateRuleID() string {
+	return "rule-" + m.newID()
 }
 
-// IPTablesBackend implements the Backend interface using iptables
+// IPTablesBackend implements the Backend interface using iptables, and
+// ip6tables for the rules that apply to IPv6
 type IPTablesBackend struct {
-	ipt *iptables.IPTables
-	log *logrus.Logger
+	ipt  *iptables.IPTables
+	ip6t *iptables.IPTables // nil unless firewall.enable_ipv6 is set
+	log  *logrus.Logger
 }
 
-// NewIPTablesBackend creates a new iptables backend
-func NewIPTablesBackend(log *logrus.Logger) (*IPTablesBackend, error) {
-	ipt, err := iptables.New()
+// NewIPTablesBackend creates a new iptables backend, also managing
+// ip6tables when enableIPv6 is set
+func NewIPTablesBackend(log *logrus.Logger, enableIPv6 bool) (*IPTablesBackend, error) {
+	// iptables.New succeeds without privileges; newFamilyHandle probes the
+	// filter table to report a missing CAP_NET_ADMIN now
+	ipt, err := newFamilyHandle(iptables.ProtocolIPv4)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize iptables: %w", err)
 	}
 	
-	return &IPTablesBackend{
+	b := &IPTablesBackend{
 		ipt: ipt,
 		log: log,
-	}, nil
+	}
+	
+	if enableIPv6 {
+		if b.ip6t, err = newFamilyHandle(iptables.ProtocolIPv6); err != nil {
+			return nil, fmt.Errorf("failed to initialize ip6tables: %w", err)
+		}
+	}
+	
+	return b, nil
 }
 
-// AddRule adds a rule using iptables
+// AddRule adds a rule using iptables, to each family the rule applies to.
+// If a family fails, the rule is removed from the families it was added to.
 func (b *IPTablesBackend) AddRule(rule *Rule) error {
+	handles := b.handles(rule)
+	for i, h := range handles {
+		if err := b.addRule(h, rule); err != nil {
+			for _, added := range handles[:i] {
+				if err := b.deleteRule(added, rule); err != nil {
+					b.log.Errorf("Failed to roll back %s rule %s: %v", added.family, rule.ID, err)
+				}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// addRule adds a rule to the tables of one family
+func (b *IPTablesBackend) addRule(h familyHandle, rule *Rule) error {
+	// The companion LOG rule is appended first so it sits immediately
+	// before the main rule in the chain
+	if rule.logsBeforeAction() {
+		if err := h.ipt.AppendUnique(rule.table(), rule.Chain, b.buildLogSpec(rule)...); err != nil {
+			return fmt.Errorf("failed to add %s iptables log rule: %w", h.family, err)
+		}
+	}
+	
 	ruleSpec := b.buildRuleSpec(rule)
 	
-	if err := b.ipt.AppendUnique("filter", rule.Chain, ruleSpec...); err != nil {
-		return fmt.Errorf("failed to add iptables rule: %w", err)
+	if err := h.ipt.AppendUnique(rule.table(), rule.Chain, ruleSpec...); err != nil {
+		// Don't leave the LOG rule behind without its rule
+		if rule.logsBeforeAction() {
+			if delErr := h.ipt.DeleteIfExists(rule.table(), rule.Chain, b.buildLogSpec(rule)...); delErr != nil {
+				b.log.Errorf("Failed to remove %s iptables log rule of %s: %v", h.family, rule.ID, delErr)
+			}
+		}
+		return fmt.Errorf("failed to add %s iptables rule: %w", h.family, err)
 	}
 	
 	return nil
 }
 
-// DeleteRule deletes a rule using iptables
+// DeleteRule deletes a rule using iptables, from each family the rule
+// applies to
 func (b *IPTablesBackend) DeleteRule(rule *Rule) error {
+	for _, h := range b.handles(rule) {
+		if err := b.deleteRule(h, rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteRule deletes a rule from the tables of one family
+func (b *IPTablesBackend) deleteRule(h familyHandle, rule *Rule) error {
 	ruleSpec := b.buildRuleSpec(rule)
 	
-	if err := b.ipt.Delete("filter", rule.Chain, ruleSpec...); err != nil {
-		return fmt.Errorf("failed to delete iptables rule: %w", err)
+	if err := h.ipt.Delete(rule.table(), rule.Chain, ruleSpec...); err != nil {
+		return fmt.Errorf("failed to delete %s iptables rule: %w", h.family, err)
+	}
+	
+	if rule.logsBeforeAction() {
+		if err := h.ipt.Delete(rule.table(), rule.Chain, b.buildLogSpec(rule)...); err != nil {
+			return fmt.Errorf("failed to delete %s iptables log rule: %w", h.family, err)
+		}
 	}
 	
 	return nil
 }
 
-// ListRules lists all rules using iptables
+// ListRules is not supported: the iptables backend is synced through
+// InOrder, which checks the chains for the managed rules
 func (b *IPTablesBackend) ListRules() ([]*Rule, error) {
-	// This is a simplified implementation
-	// In production, you'd parse iptables-save output
-	return []*Rule{}, nil
+	return nil, fmt.Errorf("iptables backend: %w", ErrListUnsupported)
 }
 
 // Flush flushes all rules using iptables
 func (b *IPTablesBackend) Flush() error {
-	chains := []string{"INPUT", "FORWARD", "OUTPUT"}
+	chains := []tableChain{
+		{TableFilter, "INPUT"}, {TableFilter, "FORWARD"}, {TableFilter, "OUTPUT"},
+		{TableRaw, "PREROUTING"}, {TableRaw, "OUTPUT"},
+		{TableMangle, "PREROUTING"}, {TableMangle, "INPUT"}, {TableMangle, "FORWARD"},
+		{TableMangle, "OUTPUT"}, {TableMangle, "POSTROUTING"},
+	}
 	
-	for _, chain := range chains {
-		if err := b.ipt.ClearChain("filter", chain); err != nil {
-			return fmt.Errorf("failed to clear chain %s: %w", chain, err)
+	for _, h := range b.allHandles() {
+		for _, c := range chains {
+			if err := h.ipt.ClearChain(c.table, c.chain); err != nil {
+				return fmt.Errorf("failed to clear %s chain %s: %w", h.family, c, err)
+			}
 		}
 	}
 	
 	return nil
 }
 
-// SetDefaultPolicy sets the default policy for a chain
+// Healthy checks that iptables, and ip6tables if enabled, can be queried
+func (b *IPTablesBackend) Healthy() error {
+	for _, h := range b.allHandles() {
+		if _, err := h.ipt.ListChains("filter"); err != nil {
+			return fmt.Errorf("failed to list %s iptables chains: %w", h.family, err)
+		}
+	}
+	return nil
+}
+
+// SetDefaultPolicy sets the default policy for a chain of every family
 func (b *IPTablesBackend) SetDefaultPolicy(chain, policy string) error {
-	if err := b.ipt.ChangePolicy("filter", chain, policy); err != nil {
-		return fmt.Errorf("failed to set policy: %w", err)
+	for _, h := range b.allHandles() {
+		if err := h.ipt.ChangePolicy("filter", chain, policy); err != nil {
+			return fmt.Errorf("failed to set %s policy: %w", h.family, err)
+		}
 	}
 	
 	return nil
@@ -376,6 +1122,45 @@ func (b *IPTablesBackend) SetDefaultPolicy(chain, policy string) error {
 
 // buildRuleSpec builds an iptables rule specification
 func (b *IPTablesBackend) buildRuleSpec(rule *Rule) []string {
+	spec := b.buildMatchSpec(rule)
+	
+	if rule.Comment != "" {
+		spec = append(spec, "-m", "comment", "--comment", rule.Comment)
+	}
+	
+	switch rule.Action {
+	case ActionNotrack:
+		// The NOTRACK target is deprecated in favor of CT --notrack
+		spec = append(spec, "-j", "CT", "--notrack")
+	case ActionSetMark:
+		// --set-mark value/mask is listed as --set-xmark, which zeroes the
+		// mask bits and XORs in the value, the same for values within the
+		// mask
+		value, mask, _ := config.ParseMark(rule.SetMark)
+		spec = append(spec, "-j", "MARK", "--set-xmark", fmt.Sprintf("0x%x/0x%x", value&mask, mask))
+	default:
+		spec = append(spec, "-j", rule.Action)
+	}
+	
+	if rule.Action == ActionLog && rule.LogPrefix != "" {
+		spec = append(spec, "--log-prefix", rule.LogPrefix)
+	}
+	
+	if rule.Action == ActionReject && rule.RejectWith != "" {
+		spec = append(spec, "--reject-with", rule.RejectWith)
+	}
+	
+	return spec
+}
+
+// buildLogSpec builds the companion LOG rule specification for a rule
+func (b *IPTablesBackend) buildLogSpec(rule *Rule) []string {
+	spec := b.buildMatchSpec(rule)
+	return append(spec, "-j", ActionLog, "--log-prefix", rule.LogPrefix)
+}
+
+// buildMatchSpec builds the packet match part of an iptables rule specification
+func (b *IPTablesBackend) buildMatchSpec(rule *Rule) []string {
 	spec := []string{}
 	
 	if rule.Protocol != "" {
@@ -390,6 +1175,10 @@ func (b *IPTablesBackend) buildRuleSpec(rule *Rule) []string {
 		spec = append(spec, "-d", rule.Dest)
 	}
 	
+	if rule.Protocol == "sctp" && (rule.SPort != "" || rule.DPort != "") {
+		spec = append(spec, "-m", "sctp")
+	}
+	
 	if rule.SPort != "" {
 		spec = append(spec, "--sport", rule.SPort)
 	}
@@ -398,59 +1187,67 @@ func (b *IPTablesBackend) buildRuleSpec(rule *Rule) []string {
 		spec = append(spec, "--dport", rule.DPort)
 	}
 	
-	if rule.Comment != "" {
-		spec = append(spec, "-m", "comment", "--comment", rule.Comment)
+	if rule.ICMPType != "" {
+		spec = append(spec, buildICMPSpec(rule)...)
 	}
 	
-	spec = append(spec, "-j", rule.Action)
+	if rule.Mark != "" {
+		spec = append(spec, "-m", "mark", "--mark", listedMark(rule.Mark))
+	}
+	
+	if rule.RateLimit != "" {
+		spec = append(spec, b.buildLimitSpec(rule)...)
+	}
 	
 	return spec
 }
 
-// NFTablesBackend implements the Backend interface using nftables
-type NFTablesBackend struct {
-	log *logrus.Logger
-}
-
-// NewNFTablesBackend creates a new nftables backend
-func NewNFTablesBackend(log *logrus.Logger) (*NFTablesBackend, error) {
-	// This is a placeholder implementation
-	// In production, you'd use github.com/google/nftables
-	return &NFTablesBackend{
-		log: log,
-	}, nil
-}
-
-// AddRule adds a rule using nftables
-func (b *NFTablesBackend) AddRule(rule *Rule) error {
-	// Placeholder implementation
-	b.log.Infof("Adding nftables rule: %+v", rule)
-	return nil
-}
-
-// DeleteRule deletes a rule using nftables
-func (b *NFTablesBackend) DeleteRule(rule *Rule) error {
-	// Placeholder implementation
-	b.log.Infof("Deleting nftables rule: %+v", rule)
-	return nil
+// buildICMPSpec builds the icmp type match. Types are given by number, the
+// way iptables lists them, so that listed rules can be matched.
+func buildICMPSpec(rule *Rule) []string {
+	number, code, _ := config.ParseICMPType(rule.ICMPType, rule.Protocol)
+	icmpType := strconv.Itoa(number)
+	if code >= 0 {
+		icmpType += "/" + strconv.Itoa(code)
+	}
+	
+	if rule.Protocol == "icmpv6" {
+		return []string{"-m", "icmp6", "--icmpv6-type", icmpType}
+	}
+	return []string{"-m", "icmp", "--icmp-type", icmpType}
 }
 
-// ListRules lists all rules using nftables
-func (b *NFTablesBackend) ListRules() ([]*Rule, error) {
-	// Placeholder implementation
-	return []*Rule{}, nil
+// listedMark formats a mark match in hex the way iptables lists it, e.g.
+// 0x100 or 0x100/0xff00, so that listed rules can be matched
+func listedMark(mark string) string {
+	value, mask, _ := config.ParseMark(mark)
+	if mask == 0xffffffff {
+		return fmt.Sprintf("0x%x", value)
+	}
+	return fmt.Sprintf("0x%x/0x%x", value, mask)
 }
 
-// Flush flushes all rules using nftables
-func (b *NFTablesBackend) Flush() error {
-	// Placeholder implementation
-	b.log.Info("Flushing nftables rules")
-	return nil
+// buildLimitSpec builds the rate limit match, using hashlimit for per-source limits
+func (b *IPTablesBackend) buildLimitSpec(rule *Rule) []string {
+	if rule.PerSource {
+		spec := []string{"-m", "hashlimit", "--hashlimit-upto", rule.RateLimit}
+		if rule.RateBurst > 0 {
+			spec = append(spec, "--hashlimit-burst", strconv.Itoa(rule.RateBurst))
+		}
+		return append(spec, "--hashlimit-mode", "srcip", "--hashlimit-name", limitName(rule))
+	}
+	
+	spec := []string{"-m", "limit", "--limit", rule.RateLimit}
+	if rule.RateBurst > 0 {
+		spec = append(spec, "--limit-burst", strconv.Itoa(rule.RateBurst))
+	}
+	return spec
 }
 
-// SetDefaultPolicy sets the default policy for a chain
-func (b *NFTablesBackend) SetDefaultPolicy(chain, policy string) error {
-	// Placeholder implementation
-	b.log.Infof("Setting nftables default policy: %s -> %s", chain, policy)
-	return nil
+// limitName derives a stable hashlimit/meter name for a rule. The kernel caps
+// hashlimit names at 15 characters, so the rule ID is hashed.
+func limitName(rule *Rule) string {
+	h := fnv.New32a()
+	h.Write([]byte(rule.ID))
+	return fmt.Sprintf("hbf-%08x", h.Sum32())
 }
@@ -0,0 +1,106 @@
+package firewall
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourusername/hbf-agent/internal/config"
+	"go.uber.org/goleak"
+)
+
+func TestManagerStartStopCycles(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	
+	m, err := NewManager(config.FirewallConfig{
+		Backend:       "memory",
+		DefaultPolicy: "accept",
+		SyncInterval:  10 * time.Millisecond,
+		WatchRulesDir: true,
+		RulesDir:      t.TempDir(),
+	}, log)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	
+	for i := 0; i < 5; i++ {
+		if err := m.Start(context.Background()); err != nil {
+			t.Fatalf("Start %d: %v", i, err)
+		}
+		if err := m.AddRule(&Rule{Chain: "INPUT", Protocol: "tcp", DPort: "22", Action: "ACCEPT", Enabled: true, TTL: time.Hour}); err != nil {
+			t.Fatalf("AddRule %d: %v", i, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+		if err := m.Stop(); err != nil {
+			t.Fatalf("Stop %d: %v", i, err)
+		}
+	}
+	
+	if err := m.Stop(); err == nil {
+		t.Error("Stop of a stopped manager succeeded")
+	}
+}
+
+func TestManagerStopsWithStartContext(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	
+	m, err := NewManager(config.FirewallConfig{Backend: "memory", DefaultPolicy: "accept", SyncInterval: time.Second}, log)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := m.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	cancel()
+	
+	// Stop waits for the loops the cancelled context already ended
+	if err := m.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+}
+
+func TestReloadKeepsPoliciesWhenRulesFail(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	
+	cfg := config.FirewallConfig{Backend: "memory", DefaultPolicy: "accept", SyncInterval: time.Second}
+	m, err := NewManager(cfg, log)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	backend := m.Backend().(*MemoryBackend)
+	
+	next := cfg
+	next.DefaultPolicy = "drop"
+	next.Rules = []config.FirewallRule{{Chain: "INPUT", Protocol: "tcp", DPort: "22", Action: "BOGUS"}}
+	if err := m.Reload(next); err == nil {
+		t.Fatal("Reload with an invalid rule succeeded")
+	}
+	if policy := backend.Policy("INPUT"); policy != "" {
+		t.Errorf("INPUT policy = %q after a failed reload, want it unset", policy)
+	}
+	if m.config.DefaultPolicy != "accept" {
+		t.Errorf("default policy = %q after a failed reload, want accept", m.config.DefaultPolicy)
+	}
+	
+	next.Rules[0].Action = "ACCEPT"
+	if err := m.Reload(next); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if policy := backend.Policy("INPUT"); policy != "DROP" {
+		t.Errorf("INPUT policy = %q, want DROP", policy)
+	}
+	if m.config.DefaultPolicy != "drop" {
+		t.Errorf("default policy = %q, want drop", m.config.DefaultPolicy)
+	}
+}
@@ -0,0 +1,170 @@
+package firewall
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// MemoryBackend keeps rules and default policies in memory without touching
+// the kernel. It backs the "memory" backend for tests and development and
+// the observe mode.
+type MemoryBackend struct {
+	log      *logrus.Logger
+	rules    map[string]*Rule
+	chains   map[tableChain][]string // rule IDs per chain, in chain order
+	policies map[string]string
+	added    int
+	deleted  int
+	mu       sync.Mutex
+}
+
+// NewMemoryBackend creates an empty in-memory backend
+func NewMemoryBackend(log *logrus.Logger) *MemoryBackend {
+	return &MemoryBackend{
+		log:      log,
+		rules:    make(map[string]*Rule),
+		chains:   make(map[tableChain][]string),
+		policies: make(map[string]string),
+	}
+}
+
+// AddRule records a rule
+func (b *MemoryBackend) AddRule(rule *Rule) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	
+	b.log.Debugf("Recording rule %s without applying it", rule.ID)
+	if _, exists := b.rules[rule.ID]; !exists {
+		b.chains[chainOf(rule)] = append(b.chains[chainOf(rule)], rule.ID)
+	}
+	b.rules[rule.ID] = rule
+	b.added++
+	return nil
+}
+
+// InsertRule records a rule directly after the preceding rules of its
+// chain, moving it there if it is already recorded
+func (b *MemoryBackend) InsertRule(rule *Rule, preceding []*Rule) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	
+	b.log.Debugf("Recording rule %s at position %d without applying it", rule.ID, len(preceding)+1)
+	ids := b.removeLocked(rule)
+	position := min(len(preceding), len(ids))
+	b.chains[chainOf(rule)] = append(ids[:position], append([]string{rule.ID}, ids[position:]...)...)
+	b.rules[rule.ID] = rule
+	b.added++
+	return nil
+}
+
+// InOrder reports whether rules are recorded in chain in the given order
+func (b *MemoryBackend) InOrder(chain tableChain, rules []*Rule) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	
+	ids := b.chains[chain]
+	next := 0
+	for _, rule := range rules {
+		for next < len(ids) && ids[next] != rule.ID {
+			next++
+		}
+		if next == len(ids) {
+			return false, nil
+		}
+		next++
+	}
+	return true, nil
+}
+
+// removeLocked drops a rule from its chain order and returns the remaining
+// IDs. Callers must hold b.mu.
+func (b *MemoryBackend) removeLocked(rule *Rule) []string {
+	ids := b.chains[chainOf(rule)]
+	for i, id := range ids {
+		if id == rule.ID {
+			ids = append(ids[:i], ids[i+1:]...)
+			break
+		}
+	}
+	b.chains[chainOf(rule)] = ids
+	return ids
+}
+
+// DeleteRule forgets a rule
+func (b *MemoryBackend) DeleteRule(rule *Rule) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	
+	b.log.Debugf("Forgetting rule %s without applying it", rule.ID)
+	b.removeLocked(rule)
+	delete(b.rules, rule.ID)
+	b.deleted++
+	return nil
+}
+
+// ListRules returns the recorded rules in chain order, with chains ordered
+// by table and name
+func (b *MemoryBackend) ListRules() ([]*Rule, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	
+	chains := make([]tableChain, 0, len(b.chains))
+	for chain := range b.chains {
+		chains = append(chains, chain)
+	}
+	sort.Slice(chains, func(i, j int) bool { return chains[i].String() < chains[j].String() })
+	
+	rules := make([]*Rule, 0, len(b.rules))
+	for _, chain := range chains {
+		for _, id := range b.chains[chain] {
+			rules = append(rules, b.rules[id])
+		}
+	}
+	return rules, nil
+}
+
+// Flush forgets all recorded rules
+func (b *MemoryBackend) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	
+	b.rules = make(map[string]*Rule)
+	b.chains = make(map[tableChain][]string)
+	return nil
+}
+
+// SetDefaultPolicy records a chain policy
+func (b *MemoryBackend) SetDefaultPolicy(chain, policy string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	
+	b.log.Debugf("Recording %s policy %s without applying it", chain, policy)
+	b.policies[chain] = policy
+	return nil
+}
+
+// HasRule reports whether a rule with the given ID is recorded
+func (b *MemoryBackend) HasRule(ruleID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	
+	_, exists := b.rules[ruleID]
+	return exists
+}
+
+// Policy returns the recorded default policy of a chain, or "" if none was
+// set
+func (b *MemoryBackend) Policy(chain string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.policies[chain]
+}
+
+// Counts returns how many rules have been added and deleted in total
+func (b *MemoryBackend) Counts() (added, deleted int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.added, b.deleted
+}
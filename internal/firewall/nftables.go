@@ -0,0 +1,669 @@
+package firewall
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourusername/hbf-agent/internal/config"
+)
+
+// nftTable is the inet table holding the managed rules, so that they never
+// mix with rules of other tools
+const nftTable = "hbf"
+
+// nftTagPrefix starts the comment of every managed rule. The tag carries the
+// rule ID, to find the rule's handle again, and a hash of its match and
+// verdict, to re-associate rules left by a previous run of the agent.
+const nftTagPrefix = "hbf:"
+
+// nftMaxComment is the longest comment nftables accepts
+const nftMaxComment = 128
+
+// nftBaseChain is a base chain of nftTable and the hook it is attached to
+type nftBaseChain struct {
+	name string
+	hook string
+}
+
+// nftBaseChains maps the chains of rules to the base chains of nftTable
+var nftBaseChains = map[tableChain]nftBaseChain{
+	{TableFilter, "INPUT"}:   {"input", "type filter hook input priority filter"},
+	{TableFilter, "FORWARD"}: {"forward", "type filter hook forward priority filter"},
+	{TableFilter, "OUTPUT"}:  {"output", "type filter hook output priority filter"},
+	{TableRaw, "PREROUTING"}: {"raw_prerouting", "type filter hook prerouting priority raw"},
+	{TableRaw, "OUTPUT"}:     {"raw_output", "type filter hook output priority raw"},
+	// Locally generated packets are routed again when a route chain
+	// changes their mark
+	{TableMangle, "PREROUTING"}:  {"mangle_prerouting", "type filter hook prerouting priority mangle"},
+	{TableMangle, "INPUT"}:       {"mangle_input", "type filter hook input priority mangle"},
+	{TableMangle, "FORWARD"}:     {"mangle_forward", "type filter hook forward priority mangle"},
+	{TableMangle, "OUTPUT"}:      {"mangle_output", "type route hook output priority mangle"},
+	{TableMangle, "POSTROUTING"}: {"mangle_postrouting", "type filter hook postrouting priority mangle"},
+}
+
+// nftHandlePattern finds the handle nft --echo --handle reports for a rule
+var nftHandlePattern = regexp.MustCompile(`# handle (\d+)`)
+
+// nftRuleHandle locates a rule in the kernel
+type nftRuleHandle struct {
+	chain  string
+	handle uint64
+	hash   string
+}
+
+// NFTablesBackend implements the Backend interface with the nft command in
+// its own inet table. The kernel assigns each rule a handle, which is the
+// only exact way to delete it; handles are tracked by rule ID and read back
+// from the kernel, where every rule is tagged with its ID in its comment.
+// The inet table sees both families; rules restricted to one match it with
+// meta nfproto. Rules are kept in priority order by placing each one after
+// the handle of the rule before it.
+type NFTablesBackend struct {
+	log *logrus.Logger
+	// enableIPv6 is unset to restrict rules of both families to IPv4
+	enableIPv6 bool
+	// run executes nft with args, feeding it input on stdin
+	run func(input string, args ...string) ([]byte, error)
+	
+	mu      sync.Mutex
+	rules   map[string]*Rule
+	handles map[string]nftRuleHandle
+	// orphans are tagged rules of a previous run, by hash, waiting to be
+	// re-associated with the rule that adds the same match again
+	orphans map[string][]nftRuleHandle
+}
+
+// NewNFTablesBackend creates the managed table and its base chains and
+// reads back the rules already in it. Unless enableIPv6 is set, rules apply
+// to IPv4 only.
+func NewNFTablesBackend(log *logrus.Logger, enableIPv6 bool) (*NFTablesBackend, error) {
+	if _, err := exec.LookPath("nft"); err != nil {
+		return nil, fmt.Errorf("failed to initialize nftables: %w", err)
+	}
+	
+	b := &NFTablesBackend{
+		log:        log,
+		enableIPv6: enableIPv6,
+		run:        runNFT,
+		rules:   make(map[string]*Rule),
+		handles: make(map[string]nftRuleHandle),
+		orphans: make(map[string][]nftRuleHandle),
+	}
+	
+	if _, err := b.run(nftSetupScript()); err != nil {
+		if isPermissionError(err) {
+			return nil, fmt.Errorf("%w: %v", ErrInsufficientPrivileges, err)
+		}
+		return nil, fmt.Errorf("failed to initialize nftables: %w", err)
+	}
+	
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, err := b.refreshLocked(); err != nil {
+		return nil, fmt.Errorf("failed to initialize nftables: %w", err)
+	}
+	if n := b.orphanCountLocked(); n > 0 {
+		log.Infof("Found %d nftables rules of a previous run; they are kept for re-association", n)
+	}
+	
+	return b, nil
+}
+
+// runNFT executes the nft command
+func runNFT(input string, args ...string) ([]byte, error) {
+	if input != "" {
+		args = append([]string{"-f", "-"}, args...)
+	}
+	cmd := exec.Command("nft", args...)
+	if input != "" {
+		cmd.Stdin = strings.NewReader(input)
+	}
+	
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("nft %s failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return out, nil
+}
+
+// nftSetupScript declares the managed table and its base chains. Declaring
+// existing chains keeps their rules and policies.
+func nftSetupScript() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "table inet %s {\n", nftTable)
+	for _, chain := range sortedChains(nftChainSet()) {
+		base := nftBaseChains[chain]
+		fmt.Fprintf(&buf, "\tchain %s { %s; }\n", base.name, base.hook)
+	}
+	buf.WriteString("}\n")
+	return buf.String()
+}
+
+// nftChainSet returns the chains that have a base chain
+func nftChainSet() map[tableChain]bool {
+	chains := make(map[tableChain]bool, len(nftBaseChains))
+	for chain := range nftBaseChains {
+		chains[chain] = true
+	}
+	return chains
+}
+
+// nftChainName returns the nftables chain of a rule chain
+func nftChainName(chain tableChain) string {
+	if base, ok := nftBaseChains[chain]; ok {
+		return base.name
+	}
+	if chain.table == TableFilter {
+		return strings.ToLower(chain.chain)
+	}
+	return chain.table + "_" + strings.ToLower(chain.chain)
+}
+
+// ruleChain maps an nftables chain back to the chain of a rule
+func ruleChain(name string) tableChain {
+	for chain, base := range nftBaseChains {
+		if base.name == name {
+			return chain
+		}
+	}
+	return tableChain{table: TableFilter, chain: strings.ToUpper(name)}
+}
+
+// AddRule adds a rule and records its handle. Adding a rule again is
+// idempotent: an unchanged rule is left alone and a changed one replaced. A
+// rule of a previous run with the same match is taken over instead of
+// adding a duplicate.
+func (b *NFTablesBackend) AddRule(rule *Rule) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	
+	chain := nftChainName(chainOf(rule))
+	hash := nftHash(chain, b.buildMatchExpr(rule))
+	expr := b.buildRuleExpr(rule)
+	
+	if current, ok := b.handles[rule.ID]; ok {
+		if current.hash == hash {
+			b.rules[rule.ID] = rule
+			return nil
+		}
+		if err := b.deleteHandleLocked(rule.ID, current); err != nil {
+			return err
+		}
+	}
+	
+	if orphans := b.orphans[hash]; len(orphans) > 0 {
+		orphan := orphans[0]
+		if _, err := b.run("", "replace", "rule", "inet", nftTable, orphan.chain,
+			"handle", strconv.FormatUint(orphan.handle, 10), expr); err == nil {
+			b.orphans[hash] = orphans[1:]
+			b.track(rule, orphan)
+			b.log.Infof("Re-associated nftables rule %s with handle %d", rule.ID, orphan.handle)
+			return nil
+		}
+		// The orphan is gone; forget it and add the rule anew
+		b.orphans[hash] = orphans[1:]
+	}
+	
+	handle, err := b.createRule("add", chain, 0, expr)
+	if err != nil {
+		return fmt.Errorf("failed to add nftables rule: %w", err)
+	}
+	
+	b.track(rule, nftRuleHandle{chain: chain, handle: handle, hash: hash})
+	b.log.Debugf("Added nftables rule %s with handle %d: %s", rule.ID, handle, expr)
+	return nil
+}
+
+// InsertRule adds a rule directly after the last preceding managed rule of
+// its chain, or at the top of the chain if none precedes it. The existing
+// copy of the rule, and any rule of a previous run with the same match, is
+// deleted first so that the rule moves to its position.
+func (b *NFTablesBackend) InsertRule(rule *Rule, preceding []*Rule) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	
+	chain := nftChainName(chainOf(rule))
+	hash := nftHash(chain, b.buildMatchExpr(rule))
+	expr := b.buildRuleExpr(rule)
+	
+	if current, ok := b.handles[rule.ID]; ok {
+		if err := b.deleteHandleLocked(rule.ID, current); err != nil {
+			return err
+		}
+	}
+	for len(b.orphans[hash]) > 0 {
+		orphan := b.orphans[hash][0]
+		_, err := b.run("", "delete", "rule", "inet", nftTable, orphan.chain, "handle", strconv.FormatUint(orphan.handle, 10))
+		if err != nil && !nftNotFound(err) {
+			return fmt.Errorf("failed to delete nftables rule of a previous run: %w", err)
+		}
+		b.orphans[hash] = b.orphans[hash][1:]
+	}
+	
+	// Handles are stable, so the rule is placed relative to the last
+	// preceding rule the kernel has
+	verb, after := "insert", uint64(0)
+	for i := len(preceding) - 1; i >= 0; i-- {
+		if handle, ok := b.handles[preceding[i].ID]; ok && handle.chain == chain {
+			verb, after = "add", handle.handle
+			break
+		}
+	}
+	
+	handle, err := b.createRule(verb, chain, after, expr)
+	if err != nil {
+		return fmt.Errorf("failed to insert nftables rule: %w", err)
+	}
+	
+	b.track(rule, nftRuleHandle{chain: chain, handle: handle, hash: hash})
+	b.log.Debugf("Inserted nftables rule %s with handle %d after handle %d: %s", rule.ID, handle, after, expr)
+	return nil
+}
+
+// createRule runs nft add or insert for a rule of chain, positioned
+// relative to the rule with handle position unless it is 0, and returns the
+// handle the kernel assigned. Callers must hold b.mu.
+func (b *NFTablesBackend) createRule(verb, chain string, position uint64, expr string) (uint64, error) {
+	args := []string{"--echo", "--handle", verb, "rule", "inet", nftTable, chain}
+	if position != 0 {
+		args = append(args, "position", strconv.FormatUint(position, 10))
+	}
+	
+	out, err := b.run("", append(args, expr)...)
+	if err != nil {
+		return 0, err
+	}
+	
+	match := nftHandlePattern.FindSubmatch(out)
+	if match == nil {
+		return 0, fmt.Errorf("no handle in nft output %q", strings.TrimSpace(string(out)))
+	}
+	handle, _ := strconv.ParseUint(string(match[1]), 10, 64)
+	return handle, nil
+}
+
+// InOrder checks that the tagged rules of chain carry the IDs of rules in
+// order. Unmanaged rules in between are ignored.
+func (b *NFTablesBackend) InOrder(chain tableChain, rules []*Rule) (bool, error) {
+	out, err := b.run("", "-j", "list", "chain", "inet", nftTable, nftChainName(chain))
+	if err != nil {
+		if nftNotFound(err) {
+			return len(rules) == 0, nil
+		}
+		return false, fmt.Errorf("failed to list nftables chain %s: %w", chain, err)
+	}
+	
+	var listing nftListing
+	if err := json.Unmarshal(out, &listing); err != nil {
+		return false, fmt.Errorf("failed to parse nft output: %w", err)
+	}
+	
+	var ids []string
+	for _, item := range listing.Nftables {
+		if item.Rule == nil {
+			continue
+		}
+		if id, _, _, ok := parseNFTTag(item.Rule.Comment); ok {
+			ids = append(ids, id)
+		}
+	}
+	
+	next := 0
+	for _, rule := range rules {
+		for next < len(ids) && ids[next] != rule.ID {
+			next++
+		}
+		if next == len(ids) {
+			return false, nil
+		}
+		next++
+	}
+	return true, nil
+}
+
+// track records the handle of a rule. Callers must hold b.mu.
+func (b *NFTablesBackend) track(rule *Rule, handle nftRuleHandle) {
+	b.rules[rule.ID] = rule
+	b.handles[rule.ID] = handle
+}
+
+// DeleteRule deletes a rule by its handle. Deleting a rule the kernel no
+// longer has succeeds, so deletion is idempotent.
+func (b *NFTablesBackend) DeleteRule(rule *Rule) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	
+	handle, ok := b.handles[rule.ID]
+	if !ok {
+		// The handle may have been assigned before a restart
+		if _, err := b.refreshLocked(); err != nil {
+			return fmt.Errorf("failed to delete nftables rule: %w", err)
+		}
+		if handle, ok = b.handles[rule.ID]; !ok {
+			delete(b.rules, rule.ID)
+			b.log.Debugf("nftables rule %s is already gone", rule.ID)
+			return nil
+		}
+	}
+	
+	return b.deleteHandleLocked(rule.ID, handle)
+}
+
+// deleteHandleLocked deletes the kernel rule of a handle. Callers must hold
+// b.mu.
+func (b *NFTablesBackend) deleteHandleLocked(ruleID string, handle nftRuleHandle) error {
+	_, err := b.run("", "delete", "rule", "inet", nftTable, handle.chain, "handle", strconv.FormatUint(handle.handle, 10))
+	if err != nil && !nftNotFound(err) {
+		return fmt.Errorf("failed to delete nftables rule: %w", err)
+	}
+	
+	delete(b.rules, ruleID)
+	delete(b.handles, ruleID)
+	return nil
+}
+
+// nftNotFound reports whether nft failed because the object does not exist
+func nftNotFound(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "No such file or directory") || strings.Contains(msg, "does not exist")
+}
+
+// ListRules reads the managed rules back from the kernel, refreshing their
+// handles. Rules added by this agent are returned as added; rules of a
+// previous run only carry their ID, chain and comment.
+func (b *NFTablesBackend) ListRules() ([]*Rule, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	
+	return b.refreshLocked()
+}
+
+// nftListing is the part of nft -j output the backend reads
+type nftListing struct {
+	Nftables []struct {
+		Rule *struct {
+			Chain   string `json:"chain"`
+			Handle  uint64 `json:"handle"`
+			Comment string `json:"comment"`
+		} `json:"rule"`
+	} `json:"nftables"`
+}
+
+// refreshLocked lists the tagged rules of the managed table and rebuilds
+// the handles. Callers must hold b.mu.
+func (b *NFTablesBackend) refreshLocked() ([]*Rule, error) {
+	out, err := b.run("", "-j", "list", "table", "inet", nftTable)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nftables rules: %w", err)
+	}
+	
+	var listing nftListing
+	if err := json.Unmarshal(out, &listing); err != nil {
+		return nil, fmt.Errorf("failed to parse nft output: %w", err)
+	}
+	
+	handles := make(map[string]nftRuleHandle)
+	orphans := make(map[string][]nftRuleHandle)
+	rules := []*Rule{}
+	
+	for _, item := range listing.Nftables {
+		if item.Rule == nil {
+			continue
+		}
+		id, hash, comment, ok := parseNFTTag(item.Rule.Comment)
+		if !ok {
+			continue
+		}
+		
+		handle := nftRuleHandle{chain: item.Rule.Chain, handle: item.Rule.Handle, hash: hash}
+		rule, known := b.rules[id]
+		if !known {
+			orphans[hash] = append(orphans[hash], handle)
+			chain := ruleChain(item.Rule.Chain)
+			rules = append(rules, &Rule{ID: id, Table: chain.table, Chain: chain.chain, Comment: comment})
+			continue
+		}
+		
+		handles[id] = handle
+		rules = append(rules, rule)
+	}
+	
+	b.handles = handles
+	b.orphans = orphans
+	return rules, nil
+}
+
+// orphanCountLocked returns the number of rules of a previous run. Callers
+// must hold b.mu.
+func (b *NFTablesBackend) orphanCountLocked() int {
+	n := 0
+	for _, handles := range b.orphans {
+		n += len(handles)
+	}
+	return n
+}
+
+// Flush removes all rules from the managed table
+func (b *NFTablesBackend) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	
+	if _, err := b.run("", "flush", "table", "inet", nftTable); err != nil {
+		return fmt.Errorf("failed to flush nftables rules: %w", err)
+	}
+	
+	b.rules = make(map[string]*Rule)
+	b.handles = make(map[string]nftRuleHandle)
+	b.orphans = make(map[string][]nftRuleHandle)
+	return nil
+}
+
+// SetDefaultPolicy sets the policy of a filter base chain
+func (b *NFTablesBackend) SetDefaultPolicy(chain, policy string) error {
+	base, ok := nftBaseChains[tableChain{TableFilter, chain}]
+	if !ok {
+		return fmt.Errorf("failed to set policy: no nftables base chain for %s", chain)
+	}
+	
+	spec := fmt.Sprintf("{ %s; policy %s; }", base.hook, strings.ToLower(policy))
+	if _, err := b.run("", "add", "chain", "inet", nftTable, base.name, spec); err != nil {
+		return fmt.Errorf("failed to set policy: %w", err)
+	}
+	return nil
+}
+
+// Healthy checks that the managed table can be listed
+func (b *NFTablesBackend) Healthy() error {
+	if _, err := b.run("", "list", "table", "inet", nftTable); err != nil {
+		return fmt.Errorf("failed to list nftables table: %w", err)
+	}
+	return nil
+}
+
+// nftHash identifies the match and verdict of a rule, as built by
+// buildMatchExpr, in its chain
+func nftHash(chain, match string) string {
+	h := fnv.New32a()
+	h.Write([]byte(chain + " " + match))
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+// nftComment returns the comment of a rule in the kernel: its tag followed
+// by the rule's own comment, cut to the length nftables accepts
+func nftComment(rule *Rule, hash string) string {
+	comment := nftTagPrefix + rule.ID + ":" + hash
+	if rule.Comment != "" {
+		comment += " " + rule.Comment
+	}
+	if len(comment) > nftMaxComment {
+		comment = comment[:nftMaxComment]
+	}
+	return comment
+}
+
+// parseNFTTag splits the comment of a managed rule into the rule ID, the
+// hash and the rule's own comment
+func parseNFTTag(comment string) (id, hash, rest string, ok bool) {
+	if !strings.HasPrefix(comment, nftTagPrefix) {
+		return "", "", "", false
+	}
+	
+	tag, rest, _ := strings.Cut(strings.TrimPrefix(comment, nftTagPrefix), " ")
+	sep := strings.LastIndex(tag, ":")
+	if sep <= 0 {
+		return "", "", "", false
+	}
+	return tag[:sep], tag[sep+1:], rest, true
+}
+
+// buildRuleExpr builds an nftables rule expression, tagged with the rule ID
+// in its comment. Unlike iptables, the log statement is part of the same
+// rule, so no companion rule is needed.
+func (b *NFTablesBackend) buildRuleExpr(rule *Rule) string {
+	chain := nftChainName(chainOf(rule))
+	match := b.buildMatchExpr(rule)
+	return match + " comment " + strconv.Quote(nftComment(rule, nftHash(chain, match)))
+}
+
+// buildMatchExpr builds the matches and statements of an nftables rule,
+// without its comment
+func (b *NFTablesBackend) buildMatchExpr(rule *Rule) string {
+	expr := []string{}
+	
+	family := rule.family()
+	if family == config.FamilyBoth && !b.enableIPv6 {
+		family = config.FamilyIPv4
+	}
+	// Address matches imply their family
+	if family != config.FamilyBoth && rule.Source == "" && rule.Dest == "" {
+		expr = append(expr, "meta nfproto", family)
+	}
+	
+	if rule.Source != "" {
+		expr = append(expr, nftAddressKey(rule.Source)+" saddr", rule.Source)
+	}
+	
+	if rule.Dest != "" {
+		expr = append(expr, nftAddressKey(rule.Dest)+" daddr", rule.Dest)
+	}
+	
+	if rule.Protocol != "" {
+		if rule.SPort != "" {
+			expr = append(expr, rule.Protocol, "sport", rule.SPort)
+		}
+		if rule.DPort != "" {
+			expr = append(expr, rule.Protocol, "dport", rule.DPort)
+		}
+		if rule.ICMPType != "" {
+			expr = append(expr, nftICMPMatch(rule))
+		} else if rule.SPort == "" && rule.DPort == "" {
+			expr = append(expr, "meta l4proto", rule.Protocol)
+		}
+	}
+	
+	if rule.Mark != "" {
+		expr = append(expr, nftMarkMatch(rule.Mark))
+	}
+	
+	if rule.RateLimit != "" {
+		limit := "limit rate " + rule.RateLimit
+		if rule.RateBurst > 0 {
+			limit += fmt.Sprintf(" burst %d packets", rule.RateBurst)
+		}
+		if rule.PerSource {
+			key := "ip"
+			if family == config.FamilyIPv6 {
+				key = "ip6"
+			}
+			limit = fmt.Sprintf("meter %s { %s saddr %s }", limitName(rule), key, limit)
+		}
+		expr = append(expr, limit)
+	}
+	
+	if rule.LogPrefix != "" {
+		expr = append(expr, "log prefix", strconv.Quote(rule.LogPrefix))
+	} else if rule.Action == ActionLog {
+		expr = append(expr, "log")
+	}
+	
+	if rule.Action != ActionLog {
+		expr = append(expr, nftVerdict(rule))
+	}
+	
+	return strings.Join(expr, " ")
+}
+
+// nftAddressKey returns the nftables protocol matching the family of an
+// address, ip or ip6
+func nftAddressKey(addr string) string {
+	if config.AddressFamily(addr) == config.FamilyIPv6 {
+		return "ip6"
+	}
+	return "ip"
+}
+
+// nftICMPMatch builds the icmp type match of a rule, e.g.
+// "icmp type 3 icmp code 4"
+func nftICMPMatch(rule *Rule) string {
+	number, code, _ := config.ParseICMPType(rule.ICMPType, rule.Protocol)
+	match := fmt.Sprintf("%s type %d", rule.Protocol, number)
+	if code >= 0 {
+		match += fmt.Sprintf(" %s code %d", rule.Protocol, code)
+	}
+	return match
+}
+
+// nftMarkMatch builds the fwmark match of a rule, e.g. "meta mark 0x100"
+// or "meta mark and 0xff00 == 0x100"
+func nftMarkMatch(mark string) string {
+	value, mask, _ := config.ParseMark(mark)
+	if mask == 0xffffffff {
+		return fmt.Sprintf("meta mark 0x%x", value)
+	}
+	return fmt.Sprintf("meta mark and 0x%x == 0x%x", mask, value&mask)
+}
+
+// nftSetMark builds the statement of a SETMARK rule. A masked mark keeps
+// the bits outside the mask: "meta mark set meta mark and ~mask or value".
+func nftSetMark(mark string) string {
+	value, mask, _ := config.ParseMark(mark)
+	if mask == 0xffffffff {
+		return fmt.Sprintf("meta mark set 0x%x", value)
+	}
+	return fmt.Sprintf("meta mark set meta mark and 0x%x or 0x%x", ^mask, value&mask)
+}
+
+// nftRejectTypes maps iptables reject types to nftables reject expressions
+var nftRejectTypes = map[string]string{
+	"icmp-net-unreachable":   "icmp type net-unreachable",
+	"icmp-host-unreachable":  "icmp type host-unreachable",
+	"icmp-port-unreachable":  "icmp type port-unreachable",
+	"icmp-proto-unreachable": "icmp type prot-unreachable",
+	"icmp-net-prohibited":    "icmp type net-prohibited",
+	"icmp-host-prohibited":   "icmp type host-prohibited",
+	"icmp-admin-prohibited":  "icmp type admin-prohibited",
+	"icmp6-no-route":         "icmpv6 type no-route",
+	"icmp6-adm-prohibited":   "icmpv6 type admin-prohibited",
+	"icmp6-addr-unreachable": "icmpv6 type addr-unreachable",
+	"icmp6-port-unreachable": "icmpv6 type port-unreachable",
+	"tcp-reset":              "tcp reset",
+}
+
+// nftVerdict maps an iptables-style target to an nftables verdict
+func nftVerdict(rule *Rule) string {
+	if rule.Action == ActionReject && rule.RejectWith != "" {
+		return "reject with " + nftRejectTypes[rule.RejectWith]
+	}
+	if rule.Action == ActionSetMark {
+		return nftSetMark(rule.SetMark)
+	}
+	return strings.ToLower(rule.Action)
+}
@@ -0,0 +1,142 @@
+package firewall
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourusername/hbf-agent/internal/config"
+)
+
+// fakeNFT keeps the rules of the managed table in chain order and answers
+// the nft commands of NFTablesBackend
+type fakeNFT struct {
+	next   uint64
+	chains map[string][]fakeNFTRule
+}
+
+type fakeNFTRule struct {
+	handle  uint64
+	comment string
+}
+
+func (f *fakeNFT) run(input string, args ...string) ([]byte, error) {
+	cmd := strings.Join(args, " ")
+	switch {
+	case strings.HasPrefix(cmd, "--echo --handle "):
+		// --echo --handle add|insert rule inet hbf <chain> [position <handle>] <expr>
+		verb, chain, expr := args[2], args[6], args[len(args)-1]
+		rules := f.chains[chain]
+		at := 0
+		if verb == "add" {
+			at = len(rules)
+		}
+		if args[7] == "position" {
+			position, _ := strconv.ParseUint(args[8], 10, 64)
+			at = -1
+			for i, rule := range rules {
+				if rule.handle == position {
+					at = i
+				}
+			}
+			if at < 0 {
+				return nil, fmt.Errorf("nft %s failed: No such file or directory", cmd)
+			}
+			if verb == "add" {
+				at++
+			}
+		}
+		
+		_, quoted, _ := strings.Cut(expr, " comment ")
+		comment, err := strconv.Unquote(quoted)
+		if err != nil {
+			return nil, fmt.Errorf("nft %s failed: bad comment: %v", cmd, err)
+		}
+		f.next++
+		rule := fakeNFTRule{handle: f.next, comment: comment}
+		f.chains[chain] = append(rules[:at], append([]fakeNFTRule{rule}, rules[at:]...)...)
+		return []byte(fmt.Sprintf("%s rule inet hbf %s %s # handle %d\n", verb, chain, expr, f.next)), nil
+		
+	case strings.HasPrefix(cmd, "delete rule "):
+		chain := args[4]
+		handle, _ := strconv.ParseUint(args[6], 10, 64)
+		for i, rule := range f.chains[chain] {
+			if rule.handle == handle {
+				f.chains[chain] = append(f.chains[chain][:i], f.chains[chain][i+1:]...)
+				return nil, nil
+			}
+		}
+		return nil, fmt.Errorf("nft %s failed: No such file or directory", cmd)
+		
+	case strings.HasPrefix(cmd, "-j list chain "):
+		chain := args[5]
+		items := []map[string]any{}
+		for _, rule := range f.chains[chain] {
+			items = append(items, map[string]any{
+				"rule": map[string]any{"chain": chain, "handle": rule.handle, "comment": rule.comment},
+			})
+		}
+		return json.Marshal(map[string]any{"nftables": items})
+	}
+	return nil, fmt.Errorf("nft %s: unexpected command", cmd)
+}
+
+// ids returns the rule IDs of a chain in kernel order
+func (f *fakeNFT) ids(chain string) []string {
+	var ids []string
+	for _, rule := range f.chains[chain] {
+		id, _, _, _ := parseNFTTag(rule.comment)
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func TestNFTablesKeepsPriorityOrder(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	
+	m, err := NewManager(config.FirewallConfig{Backend: "memory", DefaultPolicy: "accept", SyncInterval: time.Second}, log)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	nft := &fakeNFT{chains: make(map[string][]fakeNFTRule)}
+	m.backend = &NFTablesBackend{
+		log:     log,
+		run:     nft.run,
+		rules:   make(map[string]*Rule),
+		handles: make(map[string]nftRuleHandle),
+		orphans: make(map[string][]nftRuleHandle),
+	}
+	
+	for _, rule := range []*Rule{
+		{ID: "ssh", Chain: "INPUT", Protocol: "tcp", DPort: "22", Action: "ACCEPT", Priority: 20, Enabled: true},
+		{ID: "deny", Chain: "INPUT", Source: "10.0.0.1", Action: "DROP", Priority: 10, Enabled: true},
+		{ID: "web", Chain: "INPUT", Protocol: "tcp", DPort: "80", Action: "ACCEPT", Priority: 30, Enabled: true},
+		{ID: "first", Chain: "INPUT", Protocol: "udp", DPort: "53", Action: "ACCEPT", Priority: -5, Enabled: true},
+		{ID: "late", Chain: "INPUT", Protocol: "tcp", DPort: "443", Action: "ACCEPT", Priority: 20, Enabled: true},
+	} {
+		if err := m.AddRule(rule); err != nil {
+			t.Fatalf("AddRule %s: %v", rule.ID, err)
+		}
+	}
+	
+	want := "first deny ssh late web"
+	if got := strings.Join(nft.ids("input"), " "); got != want {
+		t.Fatalf("input chain = %s, want %s", got, want)
+	}
+	
+	// A rule moved out of place is put back by the sync
+	rules := nft.chains["input"]
+	rules[0], rules[4] = rules[4], rules[0]
+	if err := m.sync(); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if got := strings.Join(nft.ids("input"), " "); got != want {
+		t.Errorf("input chain after sync = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,33 @@
+package firewall
+
+import (
+	"errors"
+	"os"
+	"strings"
+)
+
+// Firewall modes
+const (
+	// ModeEnforce applies rules to the kernel
+	ModeEnforce = "enforce"
+	// ModeObserve records rule changes without applying them
+	ModeObserve = "observe"
+	// ModeAuto enforces when privileged and observes otherwise
+	ModeAuto = "auto"
+)
+
+// ErrInsufficientPrivileges is returned when the firewall backend cannot be
+// used because the agent lacks root or CAP_NET_ADMIN
+var ErrInsufficientPrivileges = errors.New("insufficient privileges for the firewall backend (requires root or CAP_NET_ADMIN)")
+
+// isPermissionError reports whether err comes from a missing privilege
+func isPermissionError(err error) bool {
+	if errors.Is(err, os.ErrPermission) {
+		return true
+	}
+	
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "permission denied") ||
+		strings.Contains(msg, "you must be root") ||
+		strings.Contains(msg, "operation not permitted")
+}
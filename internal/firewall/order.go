@@ -0,0 +1,305 @@
+package firewall
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// OrderedBackend is implemented by backends that place each rule at its
+// position in the chain, so that the kernel evaluates rules in priority
+// order. Managed rules are kept at the head of their chain.
+type OrderedBackend interface {
+	// InsertRule places rule directly after the preceding managed rules of
+	// its chain, moving it there if it already exists
+	InsertRule(rule *Rule, preceding []*Rule) error
+	// InOrder reports whether rules, given in priority order, are all
+	// present in chain in that order
+	InOrder(chain tableChain, rules []*Rule) (bool, error)
+}
+
+// tableChain identifies a chain within its table
+type tableChain struct {
+	table string
+	chain string
+}
+
+// String returns the chain as table/chain
+func (c tableChain) String() string {
+	return c.table + "/" + c.chain
+}
+
+// sortedChains returns the chains of a set ordered by table and name
+func sortedChains(chains map[tableChain]bool) []tableChain {
+	sorted := make([]tableChain, 0, len(chains))
+	for chain := range chains {
+		sorted = append(sorted, chain)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].String() < sorted[j].String() })
+	return sorted
+}
+
+// chainOf returns the chain a rule is in
+func chainOf(rule *Rule) tableChain {
+	return tableChain{table: rule.table(), chain: rule.Chain}
+}
+
+// ruleLess orders rules by priority, then by creation time and ID so that
+// rules of equal priority keep the order they were added in
+func ruleLess(a, b *Rule) bool {
+	if a.Priority != b.Priority {
+		return a.Priority < b.Priority
+	}
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID < b.ID
+}
+
+// chainRulesLocked returns the enabled rules of a chain in priority order.
+// Callers must hold m.mu.
+func (m *Manager) chainRulesLocked(chain tableChain) []*Rule {
+	var rules []*Rule
+	for _, rule := range m.rules {
+		if rule.Enabled && chainOf(rule) == chain {
+			rules = append(rules, rule)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool { return ruleLess(rules[i], rules[j]) })
+	return rules
+}
+
+// applyRuleLocked adds a rule to the backend, at its priority position when
+// the backend supports ordering. Callers must hold m.mu.
+func (m *Manager) applyRuleLocked(rule *Rule) error {
+	ordered, ok := m.backend.(OrderedBackend)
+	if !ok {
+		return m.backend.AddRule(rule)
+	}
+	
+	var preceding []*Rule
+	for _, other := range m.chainRulesLocked(chainOf(rule)) {
+		if other.ID != rule.ID && ruleLess(other, rule) {
+			preceding = append(preceding, other)
+		}
+	}
+	return ordered.InsertRule(rule, preceding)
+}
+
+// syncOrderLocked checks every chain for missing or misordered rules and
+// reinserts the chain's rules in priority order where the kernel has
+// drifted. Callers must hold m.mu.
+func (m *Manager) syncOrderLocked(ordered OrderedBackend) error {
+	chains := make(map[tableChain]bool)
+	for _, rule := range m.rules {
+		chains[chainOf(rule)] = true
+	}
+	
+	for chain := range chains {
+		if err := m.reorderChainLocked(ordered, chain); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reorderChainLocked reinserts the rules of a chain in priority order if
+// they are not already in order. Callers must hold m.mu.
+func (m *Manager) reorderChainLocked(ordered OrderedBackend, chain tableChain) error {
+	rules := m.chainRulesLocked(chain)
+	
+	inOrder, err := ordered.InOrder(chain, rules)
+	if err != nil {
+		return err
+	}
+	if inOrder {
+		return nil
+	}
+	
+	m.log.Warnf("Rules in chain %s are missing or out of order, reinserting %d rules", chain, len(rules))
+	for i, rule := range rules {
+		if err := ordered.InsertRule(rule, rules[:i]); err != nil {
+			m.log.Errorf("Failed to reinsert rule %s: %v", rule.ID, err)
+		}
+	}
+	return nil
+}
+
+// InsertRule inserts a rule after the preceding managed rules, counting
+// their companion LOG rules. An existing copy of the rule is removed first so
+// that the rule moves to its position. Each family the rule applies to only
+// counts the preceding rules of that family.
+func (b *IPTablesBackend) InsertRule(rule *Rule, preceding []*Rule) error {
+	for _, h := range b.handles(rule) {
+		position := 1
+		for _, other := range preceding {
+			if other.appliesTo(h.family) {
+				position += len(b.kernelSpecs(other))
+			}
+		}
+		
+		for _, spec := range b.kernelSpecs(rule) {
+			exists, err := h.ipt.Exists(rule.table(), rule.Chain, spec...)
+			if err != nil {
+				return fmt.Errorf("failed to check %s iptables rule: %w", h.family, err)
+			}
+			if exists {
+				if err := h.ipt.Delete(rule.table(), rule.Chain, spec...); err != nil {
+					return fmt.Errorf("failed to move %s iptables rule: %w", h.family, err)
+				}
+			}
+			
+			if err := h.ipt.Insert(rule.table(), rule.Chain, position, spec...); err != nil {
+				return fmt.Errorf("failed to insert %s iptables rule: %w", h.family, err)
+			}
+			position++
+		}
+	}
+	
+	return nil
+}
+
+// InOrder checks that the kernel rules of rules appear in chain in order, in
+// the tables of every family. Unmanaged rules in between are ignored.
+func (b *IPTablesBackend) InOrder(chain tableChain, rules []*Rule) (bool, error) {
+	for _, h := range b.allHandles() {
+		inOrder, err := b.inOrder(h, chain, rules)
+		if err != nil || !inOrder {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// inOrder checks the order of the rules of one family
+func (b *IPTablesBackend) inOrder(h familyHandle, chain tableChain, rules []*Rule) (bool, error) {
+	lines, err := h.ipt.List(chain.table, chain.chain)
+	if err != nil {
+		return false, fmt.Errorf("failed to list %s chain %s: %w", h.family, chain, err)
+	}
+	
+	next := 0
+	for _, rule := range rules {
+		if !rule.appliesTo(h.family) {
+			continue
+		}
+		for _, spec := range b.kernelSpecs(rule) {
+			found := false
+			for next < len(lines) {
+				line := lines[next]
+				next++
+				if specMatches(line, spec) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false, nil
+			}
+		}
+	}
+	
+	return true, nil
+}
+
+// kernelSpecs returns the iptables rules emitted for a rule: the companion
+// LOG rule, if any, followed by the rule itself
+func (b *IPTablesBackend) kernelSpecs(rule *Rule) [][]string {
+	if rule.logsBeforeAction() {
+		return [][]string{b.buildLogSpec(rule), b.buildRuleSpec(rule)}
+	}
+	return [][]string{b.buildRuleSpec(rule)}
+}
+
+// specMatches reports whether an iptables -S line carries every argument of
+// spec. iptables adds implicit matches such as "-m tcp" and prefix lengths
+// to addresses when listing, so a plain comparison would not match.
+func specMatches(line string, spec []string) bool {
+	fields := listFields(line)
+	present := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		present[field] = true
+	}
+	
+	for _, arg := range spec {
+		if present[arg] {
+			continue
+		}
+		if listed, ok := listedAddress(arg); ok && present[listed] {
+			continue
+		}
+		if present[listedRate(arg)] {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// listedAddress returns an address argument the way iptables lists it:
+// single addresses with a /32 or /128 prefix length and networks in
+// canonical form, e.g. 2001:DB8::1 as 2001:db8::1/128
+func listedAddress(arg string) (string, bool) {
+	if ip := net.ParseIP(arg); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			return ip4.String() + "/32", true
+		}
+		return ip.String() + "/128", true
+	}
+	if _, network, err := net.ParseCIDR(arg); err == nil {
+		return network.String(), true
+	}
+	return "", false
+}
+
+// listedRate abbreviates the unit of a rate limit the way iptables lists it,
+// e.g. 10/second as 10/sec. Other arguments are returned unchanged.
+func listedRate(arg string) string {
+	count, unit, ok := strings.Cut(arg, "/")
+	if !ok {
+		return arg
+	}
+	switch unit {
+	case "second":
+		return count + "/sec"
+	case "minute":
+		return count + "/min"
+	}
+	return arg
+}
+
+// listFields splits an iptables -S line into arguments, honouring double
+// quotes around comments and log prefixes
+func listFields(line string) []string {
+	var (
+		fields  []string
+		current strings.Builder
+		quoted  bool
+		escaped bool
+	)
+	
+	for _, r := range line {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quoted:
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case r == ' ' && !quoted:
+			if current.Len() > 0 {
+				fields = append(fields, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		fields = append(fields, current.String())
+	}
+	
+	return fields
+}
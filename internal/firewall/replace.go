@@ -0,0 +1,430 @@
+package firewall
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yourusername/hbf-agent/internal/logging"
+	"github.com/yourusername/hbf-agent/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrReplaceUnsupported is returned by a ReplaceBackend that cannot apply a
+// replacement in one transaction on this host, e.g. without iptables-restore
+var ErrReplaceUnsupported = errors.New("atomic rule replacement not supported")
+
+// ReplaceBackend is implemented by backends that can delete and add rules in
+// a single transaction, so that no packet ever sees the rule set half
+// replaced. ordered holds, for every chain rules are added to, the enabled
+// rules of the chain after the replacement in priority order, for backends
+// that place rules at their position.
+type ReplaceBackend interface {
+	ReplaceRules(remove, add []*Rule, ordered map[tableChain][]*Rule) error
+}
+
+// ReplaceRules makes desired the rules managed by the configuration, as
+// Reload does: configuration rules not in desired are deleted, rules of
+// desired not yet applied are added, and unchanged rules and rules added
+// through the API are left alone. The deletions and additions are applied
+// in one backend transaction, so a large change never leaves traffic
+// unprotected or dropped in between. Backends that cannot replace rules
+// atomically get the changes one rule at a time.
+func (m *Manager) ReplaceRules(desired []*Rule) error {
+	return m.ReplaceRulesContext(context.Background(), desired)
+}
+
+// ReplaceRulesContext is ReplaceRules, logging the request ID from ctx
+func (m *Manager) ReplaceRulesContext(ctx context.Context, desired []*Rule) error {
+	ctx, span := tracing.Start(ctx, "firewall.ReplaceRules",
+		trace.WithAttributes(attribute.Int("firewall.rules", len(desired))))
+	defer span.End()
+	
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	added, removed, err := m.replaceRulesLocked(ctx, desired)
+	if err == nil {
+		logging.Entry(ctx, m.log).Infof("Replaced firewall rules: %d added, %d removed", added, removed)
+	}
+	return tracing.Fail(span, err)
+}
+
+// replaceRulesLocked replaces the configuration rules with desired and
+// returns the number of rules added and removed. An invalid rule, or a
+// failed transaction, leaves the rules unchanged. Callers must hold m.mu.
+func (m *Manager) replaceRulesLocked(ctx context.Context, desired []*Rule) (int, int, error) {
+	for i, rule := range desired {
+		if err := m.validateRuleLocked(rule); err != nil {
+			return 0, 0, fmt.Errorf("%w: rule %d: %v", ErrInvalidRule, i, err)
+		}
+	}
+	
+	remove, add := m.configDiffLocked(desired)
+	if len(remove) == 0 && len(add) == 0 {
+		return 0, 0, nil
+	}
+	
+	removing := make(map[string]bool, len(remove))
+	for _, rule := range remove {
+		removing[rule.ID] = true
+	}
+	for _, rule := range add {
+		if _, exists := m.rules[rule.ID]; exists && !removing[rule.ID] {
+			return 0, 0, fmt.Errorf("%w: %s", ErrRuleExists, rule.ID)
+		}
+	}
+	
+	replacer, ok := m.backend.(ReplaceBackend)
+	if ok {
+		err := m.replaceAtomicLocked(ctx, replacer, remove, add)
+		if err == nil {
+			return len(add), len(remove), nil
+		}
+		if !errors.Is(err, ErrReplaceUnsupported) {
+			return 0, 0, fmt.Errorf("failed to replace rules: %w", err)
+		}
+		logging.Entry(ctx, m.log).Warnf("Falling back to applying rules one at a time: %v", err)
+	}
+	
+	var errs []error
+	removed, added := 0, 0
+	for _, rule := range remove {
+		if err := m.deleteRuleLocked(ctx, rule); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		removed++
+	}
+	for _, rule := range add {
+		if err := m.addRuleLocked(ctx, rule); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		m.fromConfig[rule.ID] = true
+		added++
+	}
+	
+	return added, removed, errors.Join(errs...)
+}
+
+// configDiffLocked returns the configuration rules missing from desired,
+// to remove, and the rules of desired not applied yet, to add. Callers must
+// hold m.mu.
+func (m *Manager) configDiffLocked(desired []*Rule) (remove, add []*Rule) {
+	ids := make([]string, 0, len(m.fromConfig))
+	for id := range m.fromConfig {
+		if _, exists := m.rules[id]; !exists {
+			delete(m.fromConfig, id)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	
+	kept := make(map[int]bool)
+	for _, id := range ids {
+		rule := m.rules[id]
+		match := -1
+		for i, want := range desired {
+			if !kept[i] && sameConfigRule(rule, want) {
+				match = i
+				break
+			}
+		}
+		
+		if match >= 0 {
+			kept[match] = true
+			continue
+		}
+		remove = append(remove, rule)
+	}
+	
+	for i, rule := range desired {
+		if !kept[i] {
+			add = append(add, rule)
+		}
+	}
+	return remove, add
+}
+
+// sameConfigRule reports whether an applied rule is the configured rule
+// want, so that a reload leaves it in place
+func sameConfigRule(rule, want *Rule) bool {
+	return rulesEqual(rule, want) &&
+		rule.Comment == want.Comment &&
+		rule.Priority == want.Priority &&
+		rule.Enabled == want.Enabled &&
+		rule.Group == want.Group &&
+		rule.Family == want.Family
+}
+
+// replaceAtomicLocked applies a replacement in one backend transaction and
+// records it. Callers must hold m.mu.
+func (m *Manager) replaceAtomicLocked(ctx context.Context, replacer ReplaceBackend, remove, add []*Rule) error {
+	now := time.Now()
+	for _, rule := range add {
+		if rule.ID == "" {
+			rule.ID = m.generateRuleID()
+		}
+		rule.CreatedAt = now
+		setExpiry(rule, now)
+	}
+	
+	removing := make(map[string]bool, len(remove))
+	var removeEnabled []*Rule
+	for _, rule := range remove {
+		removing[rule.ID] = true
+		if rule.Enabled {
+			removeEnabled = append(removeEnabled, rule)
+		}
+	}
+	
+	// The chains rules are added to, as they will be after the replacement
+	ordered := make(map[tableChain][]*Rule)
+	var addEnabled []*Rule
+	for _, rule := range add {
+		if !rule.Enabled {
+			continue
+		}
+		addEnabled = append(addEnabled, rule)
+		chain := chainOf(rule)
+		if _, seen := ordered[chain]; seen {
+			continue
+		}
+		for _, other := range m.chainRulesLocked(chain) {
+			if !removing[other.ID] {
+				ordered[chain] = append(ordered[chain], other)
+			}
+		}
+		for _, other := range add {
+			if other.Enabled && chainOf(other) == chain {
+				ordered[chain] = append(ordered[chain], other)
+			}
+		}
+		rules := ordered[chain]
+		sort.Slice(rules, func(i, j int) bool { return ruleLess(rules[i], rules[j]) })
+	}
+	
+	if err := replacer.ReplaceRules(removeEnabled, addEnabled, ordered); err != nil {
+		return err
+	}
+	
+	for _, rule := range remove {
+		m.forgetRuleLocked(ctx, rule)
+	}
+	for _, rule := range add {
+		m.trackRuleLocked(ctx, rule)
+		m.fromConfig[rule.ID] = true
+	}
+	return nil
+}
+
+// ReplaceRules deletes and adds rules with one iptables-restore transaction
+// per family. Added rules are inserted at their priority positions, counted
+// like InsertRule does. Each family is committed on its own, IPv4 first, so
+// the transactions of all families are tested before any is committed, and
+// if a family still fails to commit, the tables changed so far are restored
+// as they were.
+func (b *IPTablesBackend) ReplaceRules(remove, add []*Rule, ordered map[tableChain][]*Rule) error {
+	handles := b.allHandles()
+	for _, h := range handles {
+		if _, err := exec.LookPath(h.restoreCommand()); err != nil {
+			return fmt.Errorf("%w: %v", ErrReplaceUnsupported, err)
+		}
+		if _, err := exec.LookPath(h.saveCommand()); err != nil {
+			return fmt.Errorf("%w: %v", ErrReplaceUnsupported, err)
+		}
+	}
+	
+	scripts := make([]restoreScript, len(handles))
+	for i, h := range handles {
+		script, err := b.replaceScript(h, remove, add, ordered)
+		if err != nil {
+			return err
+		}
+		if len(script.tables) > 0 {
+			if err := restore(h, script.tables, script.lines, "--test"); err != nil {
+				return err
+			}
+		}
+		scripts[i] = script
+	}
+	
+	var committed []savedTables
+	for i, h := range handles {
+		script := scripts[i]
+		if len(script.tables) == 0 {
+			continue
+		}
+		saved, err := saveTables(h, script.tables)
+		if err != nil {
+			return errors.Join(err, restoreSaved(committed))
+		}
+		// iptables-restore commits table by table, so a failed family may
+		// have committed some of its tables too
+		committed = append(committed, saved)
+		if err := restore(h, script.tables, script.lines); err != nil {
+			return errors.Join(err, restoreSaved(committed))
+		}
+	}
+	return nil
+}
+
+// restoreScript is the iptables-restore input of one family, by table
+type restoreScript struct {
+	tables []string
+	lines  map[string][]string
+}
+
+// replaceScript returns the iptables-restore input replacing the rules of
+// one family
+func (b *IPTablesBackend) replaceScript(h familyHandle, remove, add []*Rule, ordered map[tableChain][]*Rule) (restoreScript, error) {
+	var tables []string
+	lines := make(map[string][]string)
+	emit := func(table, line string) {
+		if _, seen := lines[table]; !seen {
+			tables = append(tables, table)
+		}
+		lines[table] = append(lines[table], line)
+	}
+	
+	// Each kernel rule is deleted at most once; deleting a rule the kernel
+	// does not have would fail the whole transaction
+	deleted := make(map[string]bool)
+	deleteSpec := func(rule *Rule, spec []string) error {
+		line := fmt.Sprintf("-D %s %s", rule.Chain, restoreArgs(spec))
+		if deleted[rule.table()+" "+line] {
+			return nil
+		}
+		exists, err := h.ipt.Exists(rule.table(), rule.Chain, spec...)
+		if err != nil {
+			return fmt.Errorf("failed to check %s iptables rule: %w", h.family, err)
+		}
+		if exists {
+			deleted[rule.table()+" "+line] = true
+			emit(rule.table(), line)
+		}
+		return nil
+	}
+	
+	for _, rule := range remove {
+		if !rule.appliesTo(h.family) {
+			continue
+		}
+		for _, spec := range b.kernelSpecs(rule) {
+			if err := deleteSpec(rule, spec); err != nil {
+				return restoreScript{}, err
+			}
+		}
+	}
+	
+	adding := make(map[string]bool, len(add))
+	for _, rule := range add {
+		adding[rule.ID] = true
+	}
+	
+	chains := make(map[tableChain]bool, len(ordered))
+	for chain := range ordered {
+		chains[chain] = true
+	}
+	for _, chain := range sortedChains(chains) {
+		position := 1
+		for _, rule := range ordered[chain] {
+			if !rule.appliesTo(h.family) {
+				continue
+			}
+			specs := b.kernelSpecs(rule)
+			if !adding[rule.ID] {
+				position += len(specs)
+				continue
+			}
+			for _, spec := range specs {
+				// Move an existing copy to the position
+				if err := deleteSpec(rule, spec); err != nil {
+					return restoreScript{}, err
+				}
+				emit(chain.table, fmt.Sprintf("-I %s %d %s", chain.chain, position, restoreArgs(spec)))
+				position++
+			}
+		}
+	}
+	
+	return restoreScript{tables: tables, lines: lines}, nil
+}
+
+// ReplaceRules deletes and adds rules in one nft transaction. Rules of a
+// previous run with the same match are taken over in place, as AddRule
+// does.
+func (b *NFTablesBackend) ReplaceRules(remove, add []*Rule, ordered map[tableChain][]*Rule) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	
+	// Read the handles back first: a handle gone from the kernel would fail
+	// the transaction
+	if _, err := b.refreshLocked(); err != nil {
+		return fmt.Errorf("failed to replace nftables rules: %w", err)
+	}
+	
+	var script strings.Builder
+	deleted := make(map[string]bool)
+	deleteHandle := func(ruleID string) {
+		if handle, ok := b.handles[ruleID]; ok && !deleted[ruleID] {
+			deleted[ruleID] = true
+			fmt.Fprintf(&script, "delete rule inet %s %s handle %d\n", nftTable, handle.chain, handle.handle)
+		}
+	}
+	
+	for _, rule := range remove {
+		deleteHandle(rule.ID)
+	}
+	
+	taken := make(map[string]int)
+	for _, rule := range add {
+		chain := nftChainName(chainOf(rule))
+		hash := nftHash(chain, b.buildMatchExpr(rule))
+		expr := b.buildRuleExpr(rule)
+		
+		if current, ok := b.handles[rule.ID]; ok && !deleted[rule.ID] {
+			if current.hash == hash {
+				continue
+			}
+			deleteHandle(rule.ID)
+		}
+		
+		if orphans := b.orphans[hash]; taken[hash] < len(orphans) {
+			orphan := orphans[taken[hash]]
+			taken[hash]++
+			fmt.Fprintf(&script, "replace rule inet %s %s handle %d %s\n", nftTable, orphan.chain, orphan.handle, expr)
+			continue
+		}
+		fmt.Fprintf(&script, "add rule inet %s %s %s\n", nftTable, chain, expr)
+	}
+	
+	if script.Len() > 0 {
+		if _, err := b.run(script.String()); err != nil {
+			return fmt.Errorf("failed to replace nftables rules: %w", err)
+		}
+	}
+	
+	for _, rule := range remove {
+		delete(b.rules, rule.ID)
+		delete(b.handles, rule.ID)
+	}
+	for _, rule := range add {
+		b.rules[rule.ID] = rule
+	}
+	
+	// The transaction is committed; handles not read back here are looked up
+	// again when their rule is deleted
+	if _, err := b.refreshLocked(); err != nil {
+		b.log.Warnf("Failed to read back nftables handles: %v", err)
+	}
+	b.log.Debugf("Replaced nftables rules: %d removed, %d added", len(remove), len(add))
+	return nil
+}
@@ -0,0 +1,148 @@
+package firewall
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/yourusername/hbf-agent/internal/config"
+)
+
+// rulesDirDebounce coalesces the burst of events an editor or a ConfigMap
+// update produces into one reload
+const rulesDirDebounce = 500 * time.Millisecond
+
+// configuredRule is a rule from the configuration and where it came from
+type configuredRule struct {
+	rule   *Rule
+	origin string // "firewall.rules" or the rule file path
+}
+
+// desiredRules returns the ICMPv6 neighbor discovery rules, if enabled,
+// then the inline rules followed by the rules of every file in the rules
+// directory. Exact duplicates are dropped and rules that match the same
+// traffic with a different action are reported.
+func (m *Manager) desiredRules(cfg config.FirewallConfig) ([]*Rule, error) {
+	var configured []configuredRule
+	for _, rule := range icmpv6NDRules(cfg) {
+		configured = append(configured, configuredRule{rule: rule, origin: "firewall.allow_icmpv6_nd"})
+	}
+	for _, cfgRule := range cfg.Rules {
+		configured = append(configured, configuredRule{rule: ruleFromConfig(cfgRule), origin: "firewall.rules"})
+	}
+	
+	if cfg.RulesDir != "" {
+		files, err := config.LoadRulesDir(cfg.RulesDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			for _, cfgRule := range file.Rules {
+				configured = append(configured, configuredRule{rule: ruleFromConfig(cfgRule), origin: file.Path})
+			}
+		}
+	}
+	
+	rules := make([]*Rule, 0, len(configured))
+	kept := make([]configuredRule, 0, len(configured))
+	
+	for _, c := range configured {
+		duplicate := false
+		for _, other := range kept {
+			if rulesEqual(c.rule, other.rule) {
+				m.log.Warnf("Ignoring duplicate %s rule in %s, already defined in %s",
+					c.rule.Chain, c.origin, other.origin)
+				duplicate = true
+				break
+			}
+			if sameMatch(c.rule, other.rule) && c.rule.Action != other.rule.Action {
+				m.log.Warnf("Conflicting %s rules: %s in %s and %s in %s match the same traffic",
+					c.rule.Chain, c.rule.Action, c.origin, other.rule.Action, other.origin)
+			}
+		}
+		if duplicate {
+			continue
+		}
+		
+		kept = append(kept, c)
+		rules = append(rules, c.rule)
+	}
+	
+	return rules, nil
+}
+
+// sameMatch reports whether two rules match the same packets
+func sameMatch(r1, r2 *Rule) bool {
+	return r1.table() == r2.table() &&
+		r1.Chain == r2.Chain &&
+		r1.Protocol == r2.Protocol &&
+		r1.Source == r2.Source &&
+		r1.Dest == r2.Dest &&
+		r1.SPort == r2.SPort &&
+		r1.DPort == r2.DPort
+}
+
+// watchRulesDir reloads the configured rules whenever a rule file in the
+// rules directory changes, until ctx is cancelled
+func (m *Manager) watchRulesDir(ctx context.Context, dir string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create rules directory watcher: %w", err)
+	}
+	
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch %s: %w", dir, err)
+	}
+	
+	m.loops.Add(1)
+	go func() {
+		defer m.loops.Done()
+		defer watcher.Close()
+		
+		var debounce *time.Timer
+		for {
+			select {
+			case <-ctx.Done():
+				if debounce != nil {
+					debounce.Stop()
+				}
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !config.IsRulesFile(filepath.Base(event.Name)) {
+					continue
+				}
+				if debounce == nil {
+					debounce = time.AfterFunc(rulesDirDebounce, m.reloadRulesDir)
+				} else {
+					debounce.Reset(rulesDirDebounce)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				m.log.Errorf("Rules directory watcher error: %v", err)
+			}
+		}
+	}()
+	
+	return nil
+}
+
+// reloadRulesDir reapplies the current configuration, picking up changed
+// rule files
+func (m *Manager) reloadRulesDir() {
+	m.mu.RLock()
+	cfg := m.config
+	m.mu.RUnlock()
+	
+	m.log.Infof("Rule files in %s changed, reloading", cfg.RulesDir)
+	if err := m.Reload(cfg); err != nil {
+		m.log.Errorf("Failed to reload rules directory: %v", err)
+	}
+}
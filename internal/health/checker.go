@@ -2,37 +2,113 @@ package health
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"io"
+	"math/rand"
 	"net"
 	"net/http"
+	"os"
+	"os/exec"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"github.com/yourusername/hbf-agent/internal/config"
+)
+
+var (
+	// ErrCheckNotFound is returned for operations on unknown checks
+	ErrCheckNotFound = errors.New("check not found")
+	// ErrCheckExists is returned when adding a check whose ID is taken
+	ErrCheckExists = errors.New("check already exists")
+	// ErrInvalidCheck wraps check validation failures
+	ErrInvalidCheck = errors.New("invalid check")
 )
 
 // Checker performs health checks on services
 type Checker struct {
+	config   config.HealthConfig
 	log      *logrus.Logger
 	checks   map[string]*Check
+	metrics  MetricsRecorder
+	sem      chan struct{} // limits concurrent checks; nil when unlimited
+	active   int32
 	mu       sync.RWMutex
-	stopChan chan struct{}
+	// ctx is the context the check loops run with, derived from the
+	// context of Start and cancelled by Stop
+	ctx      context.Context
+	cancel   context.CancelFunc
+	loops    sync.WaitGroup // the running check loops
 	running  bool
+	
+	// passed and failed count the check runs since the last log summary
+	passed int
+	failed int
+	
+	// transport is shared by http checks without TLS settings of their own
+	transport *http.Transport
+}
+
+// MetricsRecorder records health check metrics
+type MetricsRecorder interface {
+	RecordHealthCheck(checkID, status string, duration float64)
+	SetChecksInFlight(n int)
+	RecordCheckSkipped(checkID string)
+	SetCheckState(checkID, status string, consecutiveFailures int)
+	DeleteCheckState(checkID string)
 }
 
 // Check represents a health check
 type Check struct {
-	ID       string
-	Type     string // http, tcp, grpc
-	Target   string
-	Interval time.Duration
-	Timeout  time.Duration
-	Status   CheckStatus
+	ID        string
+	Type      string // http, tcp, udp, grpc, exec, ttl
+	Target    string
+	Payload   string   // udp: datagram sent to the target
+	Args      []string // exec: command and arguments, run without a shell
+	Interval  time.Duration
+	Timeout   time.Duration
+	Status    CheckStatus
 	LastCheck time.Time
-	Failures int
-	callback func(status CheckStatus)
+	Failures  int
+	Output    string // exec: output of the last run; ttl: output of the last update
+	
+	// LastHeartbeat is when a ttl check was last updated. The check turns
+	// critical when no update arrives within Interval.
+	LastHeartbeat time.Time
+	
+	// TLS settings for https targets of http checks
+	TLSSkipVerify bool
+	CAFile        string
+	CertFile      string // client certificate for mTLS-protected endpoints
+	KeyFile       string
+	
+	callback     func(status CheckStatus)
+	history      resultRing
+	inFlight     bool
+	client       *http.Client  // http: reused across runs
+	ownTransport bool          // http: client has a transport for this check's TLS settings
+	beat         chan struct{} // ttl: wakes the check loop on updates
 }
 
+// maxCheckOutput caps the captured output of exec checks
+const maxCheckOutput = 4096
+
+const (
+	// httpCheckIdleConnsPerHost is how many keep-alive connections http
+	// checks keep open to each target
+	httpCheckIdleConnsPerHost = 2
+	// httpCheckConnsPerHost caps the connections http checks open to each
+	// target
+	httpCheckConnsPerHost = 4
+	// maxDrainedBody is how much of a response body an http check reads so
+	// that the connection can be reused; larger bodies close it
+	maxDrainedBody = 64 << 10
+)
+
 // CheckStatus represents the status of a health check
 type CheckStatus string
 
@@ -43,37 +119,61 @@ const (
 )
 
 // NewChecker creates a new health checker
-func NewChecker(log *logrus.Logger) *Checker {
-	return &Checker{
+func NewChecker(cfg config.HealthConfig, log *logrus.Logger) *Checker {
+	c := &Checker{
+		config:   cfg,
 		log:      log,
 		checks:   make(map[string]*Check),
-		stopChan: make(chan struct{}),
 	}
+	
+	if cfg.MaxConcurrentChecks > 0 {
+		c.sem = make(chan struct{}, cfg.MaxConcurrentChecks)
+	}
+	c.transport = newHTTPCheckTransport(nil)
+	
+	return c
+}
+
+// SetMetrics sets the recorder used for health check metrics
+func (c *Checker) SetMetrics(metrics MetricsRecorder) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.metrics = metrics
 }
 
-// Start starts the health checker
+// Start starts the health checker. The check loops run until ctx is
+// cancelled or Stop is called; the checker can be started again after Stop.
 func (c *Checker) Start(ctx context.Context) error {
 	c.mu.Lock()
+	defer c.mu.Unlock()
+	
 	if c.running {
-		c.mu.Unlock()
 		return fmt.Errorf("health checker is already running")
 	}
 	c.running = true
-	c.mu.Unlock()
+	c.ctx, c.cancel = context.WithCancel(ctx)
 	
 	c.log.Info("Starting health checker...")
 	
 	// Start check loops for all registered checks
-	c.mu.RLock()
 	for _, check := range c.checks {
-		go c.checkLoop(ctx, check)
+		c.startLoopLocked(check)
+	}
+	
+	if interval := c.config.LogSummaryInterval; interval > 0 {
+		ctx := c.ctx
+		c.loops.Add(1)
+		go func() {
+			defer c.loops.Done()
+			c.summaryLoop(ctx, interval)
+		}()
 	}
-	c.mu.RUnlock()
 	
 	return nil
 }
 
-// Stop stops the health checker
+// Stop stops the health checker, cancelling the check loops and waiting for
+// them to return. Checks already probing finish in the background.
 func (c *Checker) Stop() error {
 	c.mu.Lock()
 	if !c.running {
@@ -81,21 +181,52 @@ func (c *Checker) Stop() error {
 		return fmt.Errorf("health checker is not running")
 	}
 	c.running = false
+	cancel := c.cancel
 	c.mu.Unlock()
 	
-	close(c.stopChan)
+	cancel()
+	c.loops.Wait()
 	c.log.Info("Health checker stopped")
 	
 	return nil
 }
 
+// startLoopLocked starts the check loop of a check. Callers must hold c.mu
+// and the checker must be running.
+func (c *Checker) startLoopLocked(check *Check) {
+	ctx := c.ctx
+	c.loops.Add(1)
+	go func() {
+		defer c.loops.Done()
+		c.checkLoop(ctx, check)
+	}()
+}
+
+// Healthy returns an error if the health checker is not running
+func (c *Checker) Healthy() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	
+	if !c.running {
+		return fmt.Errorf("health checker is not running")
+	}
+	
+	return nil
+}
+
 // AddCheck adds a new health check
 func (c *Checker) AddCheck(check *Check) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	
+	if err := validateCheck(check); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCheck, err)
+	}
+	
 	if check.ID == "" {
 		check.ID = generateCheckID()
+	} else if _, exists := c.checks[check.ID]; exists {
+		return fmt.Errorf("%w: %s", ErrCheckExists, check.ID)
 	}
 	
 	if check.Interval == 0 {
@@ -106,15 +237,29 @@ func (c *Checker) AddCheck(check *Check) error {
 		check.Timeout = 5 * time.Second
 	}
 	
+	if check.Type == "http" {
+		if err := c.setHTTPClient(check); err != nil {
+			return err
+		}
+	}
+	
+	if check.Type == "ttl" {
+		check.beat = make(chan struct{}, 1)
+		check.LastHeartbeat = time.Now()
+	}
+	
 	check.Status = StatusPassing
 	check.LastCheck = time.Now()
 	
 	c.checks[check.ID] = check
+	if c.metrics != nil {
+		c.metrics.SetCheckState(check.ID, string(check.Status), 0)
+	}
 	c.log.Infof("Added health check: %s (%s)", check.ID, check.Type)
 	
 	// Start check loop if checker is running
 	if c.running {
-		go c.checkLoop(context.Background(), check)
+		c.startLoopLocked(check)
 	}
 	
 	return nil
@@ -125,11 +270,18 @@ func (c *Checker) RemoveCheck(checkID string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	
-	if _, exists := c.checks[checkID]; !exists {
-		return fmt.Errorf("check not found: %s", checkID)
+	check, exists := c.checks[checkID]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrCheckNotFound, checkID)
 	}
 	
+	if check.ownTransport {
+		check.client.CloseIdleConnections()
+	}
 	delete(c.checks, checkID)
+	if c.metrics != nil {
+		c.metrics.DeleteCheckState(checkID)
+	}
 	c.log.Infof("Removed health check: %s", checkID)
 	
 	return nil
@@ -142,7 +294,7 @@ func (c *Checker) GetCheck(checkID string) (*Check, error) {
 	
 	check, exists := c.checks[checkID]
 	if !exists {
-		return nil, fmt.Errorf("check not found: %s", checkID)
+		return nil, fmt.Errorf("%w: %s", ErrCheckNotFound, checkID)
 	}
 	
 	return check, nil
@@ -161,27 +313,228 @@ func (c *Checker) ListChecks() []*Check {
 	return checks
 }
 
-// checkLoop runs the health check loop for a specific check
+// checkLoop runs the health check loop for a specific check. The first run
+// is delayed by a random jitter of up to one interval so that checks
+// registered together don't all probe at the same moment.
 func (c *Checker) checkLoop(ctx context.Context, check *Check) {
+	if check.Type == "ttl" {
+		c.ttlLoop(ctx, check)
+		return
+	}
+	
+	jitter := time.Duration(rand.Int63n(int64(check.Interval)))
+	
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(jitter):
+	}
+	
 	ticker := time.NewTicker(check.Interval)
 	defer ticker.Stop()
 	
 	for {
+		c.mu.RLock()
+		current := c.checks[check.ID]
+		c.mu.RUnlock()
+		
+		// Stop once the check has been removed or replaced
+		if current != check {
+			return
+		}
+		c.schedule(ctx, check)
+		
 		select {
 		case <-ctx.Done():
 			return
-		case <-c.stopChan:
-			return
 		case <-ticker.C:
-			c.performCheck(check)
 		}
 	}
 }
 
+// ttlLoop waits for updates of a ttl check instead of probing it. The check
+// turns critical when no update arrives within the interval.
+func (c *Checker) ttlLoop(ctx context.Context, check *Check) {
+	timer := time.NewTimer(check.Interval)
+	defer timer.Stop()
+	
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-check.beat:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+		case <-timer.C:
+			c.mu.RLock()
+			current := c.checks[check.ID]
+			c.mu.RUnlock()
+			
+			// Stop once the check has been removed or replaced
+			if current != check {
+				return
+			}
+			c.expireTTL(check)
+		}
+		timer.Reset(check.Interval)
+	}
+}
+
+// expireTTL marks a ttl check critical if it has not been updated within its
+// interval
+func (c *Checker) expireTTL(check *Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	
+	check.LastCheck = time.Now()
+	if time.Since(check.LastHeartbeat) < check.Interval || check.Status == StatusCritical {
+		return
+	}
+	
+	check.Status = StatusCritical
+	check.Output = fmt.Sprintf("TTL expired: no update for %s", check.Interval)
+	c.failed++
+	c.log.Errorf("Health check %s missed its heartbeat, TTL of %s expired", check.ID, check.Interval)
+	c.reportLocked(check, 0, check.Output)
+}
+
+// Pass marks a ttl check passing and restarts its TTL
+func (c *Checker) Pass(checkID string) error {
+	return c.UpdateTTL(checkID, StatusPassing, "")
+}
+
+// Fail marks a ttl check critical and restarts its TTL
+func (c *Checker) Fail(checkID string) error {
+	return c.UpdateTTL(checkID, StatusCritical, "")
+}
+
+// UpdateTTL records a heartbeat of a ttl check with the status reported by
+// the service
+func (c *Checker) UpdateTTL(checkID string, status CheckStatus, output string) error {
+	switch status {
+	case StatusPassing, StatusWarning, StatusCritical:
+	default:
+		return fmt.Errorf("invalid check status: %s", status)
+	}
+	
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	
+	check, exists := c.checks[checkID]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrCheckNotFound, checkID)
+	}
+	if check.Type != "ttl" {
+		return fmt.Errorf("check %s is not a ttl check", checkID)
+	}
+	
+	now := time.Now()
+	previous := check.Status
+	check.LastHeartbeat = now
+	check.LastCheck = now
+	check.Status = status
+	check.Output = output
+	if status == StatusPassing {
+		check.Failures = 0
+	} else {
+		check.Failures++
+	}
+	c.logResultLocked(check, previous, output)
+	c.reportLocked(check, 0, output)
+	
+	// Restart the TTL; a pending wake-up already does
+	select {
+	case check.beat <- struct{}{}:
+	default:
+	}
+	
+	return nil
+}
+
+// reportLocked records the status of a check in its history and the
+// metrics and notifies its callback. Callers must hold c.mu.
+func (c *Checker) reportLocked(check *Check, duration time.Duration, output string) {
+	check.history.add(CheckResult{
+		Status:    check.Status,
+		Output:    output,
+		Duration:  duration.String(),
+		Timestamp: check.LastCheck,
+	})
+	
+	if c.metrics != nil {
+		c.metrics.RecordHealthCheck(check.ID, string(check.Status), duration.Seconds())
+		c.metrics.SetCheckState(check.ID, string(check.Status), check.Failures)
+	}
+	
+	// Call callback if set
+	if check.callback != nil {
+		go check.callback(check.Status)
+	}
+}
+
+// schedule runs a check in the background, waiting for a slot when the
+// concurrency limit is reached. The run is skipped if the previous one has
+// not finished yet.
+func (c *Checker) schedule(ctx context.Context, check *Check) {
+	c.mu.Lock()
+	if check.inFlight {
+		rec := c.metrics
+		c.mu.Unlock()
+		
+		c.log.Debugf("Skipping health check %s: previous run still in progress", check.ID)
+		if rec != nil {
+			rec.RecordCheckSkipped(check.ID)
+		}
+		return
+	}
+	check.inFlight = true
+	c.mu.Unlock()
+	
+	go func() {
+		defer func() {
+			c.mu.Lock()
+			check.inFlight = false
+			c.mu.Unlock()
+		}()
+		
+		if c.sem != nil {
+			select {
+			case c.sem <- struct{}{}:
+				defer func() { <-c.sem }()
+			case <-ctx.Done():
+				return
+			}
+		}
+		
+		c.trackInFlight(1)
+		defer c.trackInFlight(-1)
+		
+		c.performCheck(check)
+	}()
+}
+
+// trackInFlight adjusts the number of running checks
+func (c *Checker) trackInFlight(delta int32) {
+	n := atomic.AddInt32(&c.active, delta)
+	
+	c.mu.RLock()
+	rec := c.metrics
+	c.mu.RUnlock()
+	
+	if rec != nil {
+		rec.SetChecksInFlight(int(n))
+	}
+}
+
 // performCheck performs a single health check
 func (c *Checker) performCheck(check *Check) {
+	start := time.Now()
 	c.mu.Lock()
-	check.LastCheck = time.Now()
+	check.LastCheck = start
 	c.mu.Unlock()
 	
 	var err error
@@ -191,8 +544,12 @@ func (c *Checker) performCheck(check *Check) {
 		err = c.checkHTTP(check)
 	case "tcp":
 		err = c.checkTCP(check)
+	case "udp":
+		err = c.checkUDP(check)
 	case "grpc":
 		err = c.checkGRPC(check)
+	case "exec":
+		err = c.checkExec(check)
 	default:
 		c.log.Errorf("Unknown check type: %s", check.Type)
 		return
@@ -201,37 +558,42 @@ func (c *Checker) performCheck(check *Check) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	
+	output := ""
+	previous := check.Status
 	if err != nil {
+		output = err.Error()
 		check.Failures++
 		if check.Failures >= 3 {
 			check.Status = StatusCritical
 		} else {
 			check.Status = StatusWarning
 		}
-		c.log.Warnf("Health check failed: %s - %v", check.ID, err)
 	} else {
 		check.Failures = 0
 		check.Status = StatusPassing
-		c.log.Debugf("Health check passed: %s", check.ID)
 	}
+	c.logResultLocked(check, previous, output)
 	
-	// Call callback if set
-	if check.callback != nil {
-		go check.callback(check.Status)
-	}
+	c.reportLocked(check, time.Since(start), output)
 }
 
 // checkHTTP performs an HTTP health check
 func (c *Checker) checkHTTP(check *Check) error {
-	client := &http.Client{
-		Timeout: check.Timeout,
+	ctx, cancel := context.WithTimeout(context.Background(), check.Timeout)
+	defer cancel()
+	
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, check.Target, nil)
+	if err != nil {
+		return fmt.Errorf("HTTP check failed: %w", err)
 	}
 	
-	resp, err := client.Get(check.Target)
+	resp, err := check.client.Do(req)
 	if err != nil {
 		return fmt.Errorf("HTTP check failed: %w", err)
 	}
 	defer resp.Body.Close()
+	// Read the body so that the connection goes back to the pool
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainedBody))
 	
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		return fmt.Errorf("HTTP check returned status %d", resp.StatusCode)
@@ -240,6 +602,65 @@ func (c *Checker) checkHTTP(check *Check) error {
 	return nil
 }
 
+// setHTTPClient sets the client of an http check. Checks without TLS
+// settings share the checker's transport, so connections to a target are
+// kept alive and reused across runs and checks; checks with TLS settings
+// get a transport of their own. The check timeout is applied per request.
+func (c *Checker) setHTTPClient(check *Check) error {
+	if !check.TLSSkipVerify && check.CAFile == "" && check.CertFile == "" && check.KeyFile == "" {
+		check.client = &http.Client{Transport: c.transport}
+		return nil
+	}
+	
+	tlsConfig, err := httpCheckTLSConfig(check)
+	if err != nil {
+		return err
+	}
+	check.client = &http.Client{Transport: newHTTPCheckTransport(tlsConfig)}
+	check.ownTransport = true
+	return nil
+}
+
+// newHTTPCheckTransport returns a keep-alive transport with a bounded
+// connection pool per target
+func newHTTPCheckTransport(tlsConfig *tls.Config) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.MaxIdleConnsPerHost = httpCheckIdleConnsPerHost
+	transport.MaxConnsPerHost = httpCheckConnsPerHost
+	return transport
+}
+
+// httpCheckTLSConfig builds the TLS settings of an http check
+func httpCheckTLSConfig(check *Check) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: check.TLSSkipVerify,
+	}
+	
+	if check.CAFile != "" {
+		caCert, err := os.ReadFile(check.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", check.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	
+	if check.CertFile != "" || check.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(check.CertFile, check.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	
+	return tlsConfig, nil
+}
+
 // checkTCP performs a TCP health check
 func (c *Checker) checkTCP(check *Check) error {
 	conn, err := net.DialTimeout("tcp", check.Target, check.Timeout)
@@ -251,6 +672,59 @@ func (c *Checker) checkTCP(check *Check) error {
 	return nil
 }
 
+// checkUDP sends the check payload to the target and waits for any response
+// within the timeout. An ICMP port unreachable reply surfaces as a read
+// error and fails the check.
+func (c *Checker) checkUDP(check *Check) error {
+	conn, err := net.DialTimeout("udp", check.Target, check.Timeout)
+	if err != nil {
+		return fmt.Errorf("UDP check failed: %w", err)
+	}
+	defer conn.Close()
+	
+	if err := conn.SetDeadline(time.Now().Add(check.Timeout)); err != nil {
+		return fmt.Errorf("UDP check failed: %w", err)
+	}
+	
+	if _, err := conn.Write([]byte(check.Payload)); err != nil {
+		return fmt.Errorf("UDP check failed to send: %w", err)
+	}
+	
+	buf := make([]byte, 1500)
+	if _, err := conn.Read(buf); err != nil {
+		return fmt.Errorf("UDP check got no response: %w", err)
+	}
+	
+	return nil
+}
+
+// checkExec runs the check command and passes on a zero exit code. The
+// command is executed directly from Args, never through a shell.
+func (c *Checker) checkExec(check *Check) error {
+	ctx, cancel := context.WithTimeout(context.Background(), check.Timeout)
+	defer cancel()
+	
+	cmd := exec.CommandContext(ctx, check.Args[0], check.Args[1:]...)
+	output, err := cmd.CombinedOutput()
+	if len(output) > maxCheckOutput {
+		output = output[:maxCheckOutput]
+	}
+	
+	c.mu.Lock()
+	check.Output = string(output)
+	c.mu.Unlock()
+	
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("exec check timed out after %s", check.Timeout)
+	}
+	
+	if err != nil {
+		return fmt.Errorf("exec check failed: %w: %s", err, output)
+	}
+	
+	return nil
+}
+
 // checkGRPC performs a gRPC health check
 func (c *Checker) checkGRPC(check *Check) error {
 	// Placeholder for gRPC health check
@@ -259,6 +733,29 @@ func (c *Checker) checkGRPC(check *Check) error {
 	return nil
 }
 
+// validateCheck checks that a check has what its type needs
+func validateCheck(check *Check) error {
+	switch check.Type {
+	case "http", "tcp", "udp", "grpc":
+		if check.Target == "" {
+			return fmt.Errorf("%s check requires a target", check.Type)
+		}
+	case "exec":
+		if len(check.Args) == 0 || check.Args[0] == "" {
+			return fmt.Errorf("exec check requires a command in args")
+		}
+	case "ttl":
+	default:
+		return fmt.Errorf("unknown check type: %s", check.Type)
+	}
+	
+	if check.Interval < 0 || check.Timeout < 0 {
+		return fmt.Errorf("check interval and timeout must not be negative")
+	}
+	
+	return nil
+}
+
 // generateCheckID generates a unique check ID
 func generateCheckID() string {
 	return fmt.Sprintf("check-%d", time.Now().UnixNano())
@@ -271,7 +768,7 @@ func (c *Checker) SetCallback(checkID string, callback func(status CheckStatus))
 	
 	check, exists := c.checks[checkID]
 	if !exists {
-		return fmt.Errorf("check not found: %s", checkID)
+		return fmt.Errorf("%w: %s", ErrCheckNotFound, checkID)
 	}
 	
 	check.callback = callback
@@ -0,0 +1,120 @@
+package health
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourusername/hbf-agent/internal/config"
+	"go.uber.org/goleak"
+)
+
+// closedAddr returns a local address nothing listens on
+func closedAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	return addr
+}
+
+func TestCheckerStartStopCycles(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	
+	c := NewChecker(config.HealthConfig{
+		MaxConcurrentChecks: 2,
+		LogSummaryInterval:  10 * time.Millisecond,
+	}, log)
+	
+	target := closedAddr(t)
+	if err := c.AddCheck(&Check{ID: "tcp", Type: "tcp", Target: target, Interval: 5 * time.Millisecond, Timeout: time.Second}); err != nil {
+		t.Fatalf("AddCheck: %v", err)
+	}
+	if err := c.AddCheck(&Check{ID: "ttl", Type: "ttl", Interval: 5 * time.Millisecond}); err != nil {
+		t.Fatalf("AddCheck: %v", err)
+	}
+	
+	for i := 0; i < 5; i++ {
+		if err := c.Start(context.Background()); err != nil {
+			t.Fatalf("Start %d: %v", i, err)
+		}
+		
+		// Checks added and removed while running start and stop their loops
+		if err := c.AddCheck(&Check{ID: "added", Type: "tcp", Target: target, Interval: 5 * time.Millisecond}); err != nil {
+			t.Fatalf("AddCheck %d: %v", i, err)
+		}
+		if err := c.Pass("ttl"); err != nil {
+			t.Fatalf("Pass %d: %v", i, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+		if err := c.RemoveCheck("added"); err != nil {
+			t.Fatalf("RemoveCheck %d: %v", i, err)
+		}
+		
+		if err := c.Stop(); err != nil {
+			t.Fatalf("Stop %d: %v", i, err)
+		}
+	}
+	
+	if err := c.Stop(); err == nil {
+		t.Error("Stop of a stopped checker succeeded")
+	}
+}
+
+// benchmarkHTTPCheck measures http check runs against a local server, with
+// the client of each run returned by client
+func benchmarkHTTPCheck(b *testing.B, client func(c *Checker, check *Check) *http.Client) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	c := NewChecker(config.HealthConfig{}, log)
+	check := &Check{ID: "web", Type: "http", Target: server.URL, Timeout: time.Second}
+	if err := c.AddCheck(check); err != nil {
+		b.Fatalf("AddCheck: %v", err)
+	}
+	
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		check.client = client(c, check)
+		if err := c.checkHTTP(check); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkHTTPCheckSharedTransport probes through the checker's
+// keep-alive transport, as checks without TLS settings do
+func BenchmarkHTTPCheckSharedTransport(b *testing.B) {
+	benchmarkHTTPCheck(b, func(c *Checker, check *Check) *http.Client {
+		return check.client
+	})
+}
+
+// BenchmarkHTTPCheckFreshClient probes with a new client and transport per
+// run, opening a connection every time
+func BenchmarkHTTPCheckFreshClient(b *testing.B) {
+	var previous *http.Transport
+	benchmarkHTTPCheck(b, func(c *Checker, check *Check) *http.Client {
+		if previous != nil {
+			previous.CloseIdleConnections()
+		}
+		previous = newHTTPCheckTransport(nil)
+		return &http.Client{Transport: previous}
+	})
+}
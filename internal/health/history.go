@@ -0,0 +1,61 @@
+package health
+
+import (
+	"fmt"
+	"time"
+)
+
+// checkHistorySize is how many results each check keeps
+const checkHistorySize = 20
+
+// CheckResult is the outcome of one run or update of a check
+type CheckResult struct {
+	Status    CheckStatus `json:"status"`
+	Output    string      `json:"output,omitempty"` // error or output of the run
+	Duration  string      `json:"duration"`
+	Timestamp time.Time   `json:"timestamp"`
+}
+
+// resultRing keeps the latest checkHistorySize results of a check
+type resultRing struct {
+	results [checkHistorySize]CheckResult
+	next    int
+	count   int
+}
+
+// add records a result, overwriting the oldest once the ring is full
+func (r *resultRing) add(result CheckResult) {
+	r.results[r.next] = result
+	r.next = (r.next + 1) % checkHistorySize
+	if r.count < checkHistorySize {
+		r.count++
+	}
+}
+
+// latest returns up to limit results, newest first; limit 0 returns all
+func (r *resultRing) latest(limit int) []CheckResult {
+	n := r.count
+	if limit > 0 && limit < n {
+		n = limit
+	}
+	
+	results := make([]CheckResult, n)
+	for i := range results {
+		results[i] = r.results[(r.next-1-i+checkHistorySize)%checkHistorySize]
+	}
+	return results
+}
+
+// History returns the latest results of a check, newest first. At most
+// checkHistorySize results are kept; limit 0 returns all of them.
+func (c *Checker) History(checkID string, limit int) ([]CheckResult, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	
+	check, exists := c.checks[checkID]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrCheckNotFound, checkID)
+	}
+	
+	return check.history.latest(limit), nil
+}
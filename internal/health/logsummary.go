@@ -0,0 +1,91 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxSummaryChecks caps the checks named in a summary
+const maxSummaryChecks = 10
+
+// logResultLocked logs the result of a check run. Status changes are always
+// logged: becoming critical as an error, failing as a warning and passing
+// again as info. Ongoing failures keep logging a warning per run, while
+// repeated passing results are only counted for the periodic summary,
+// unless health.log_summary_interval is 0 and each result is logged.
+// Callers must hold c.mu.
+func (c *Checker) logResultLocked(check *Check, previous CheckStatus, output string) {
+	if output == "" {
+		output = string(check.Status)
+	}
+	if check.Status == StatusPassing {
+		c.passed++
+	} else {
+		c.failed++
+	}
+	
+	switch {
+	case check.Status == StatusPassing && previous == StatusPassing && c.config.LogSummaryInterval > 0:
+		// Counted for the summary
+	case check.Status == StatusCritical && previous != StatusCritical:
+		c.log.Errorf("Health check %s is critical: %s", check.ID, output)
+	case check.Status == StatusPassing && previous != StatusPassing:
+		c.log.Infof("Health check %s is passing again", check.ID)
+	case check.Status != StatusPassing:
+		c.log.Warnf("Health check failed: %s - %s", check.ID, output)
+	default:
+		c.log.Debugf("Health check passed: %s", check.ID)
+	}
+}
+
+// summaryLoop logs a summary of the check runs every interval
+func (c *Checker) summaryLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.logSummary(interval)
+		}
+	}
+}
+
+// logSummary logs how many check runs passed and failed since the last
+// summary and which checks are not passing. Nothing is logged when no check
+// ran.
+func (c *Checker) logSummary(interval time.Duration) {
+	c.mu.Lock()
+	passed, failed := c.passed, c.failed
+	c.passed, c.failed = 0, 0
+	total := len(c.checks)
+	var failing []string
+	for id, check := range c.checks {
+		if check.Status != StatusPassing {
+			failing = append(failing, id)
+		}
+	}
+	c.mu.Unlock()
+	
+	if passed+failed == 0 {
+		return
+	}
+	
+	summary := fmt.Sprintf("Health checks in the last %s: %d runs passed, %d failed", interval, passed, failed)
+	if len(failing) == 0 {
+		c.log.Infof("%s; all %d checks passing", summary, total)
+		return
+	}
+	
+	sort.Strings(failing)
+	names := strings.Join(failing[:min(len(failing), maxSummaryChecks)], ", ")
+	if len(failing) > maxSummaryChecks {
+		names += fmt.Sprintf(" and %d more", len(failing)-maxSummaryChecks)
+	}
+	c.log.Warnf("%s; %d of %d checks not passing: %s", summary, len(failing), total, names)
+}
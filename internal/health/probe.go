@@ -0,0 +1,67 @@
+package health
+
+import (
+	"fmt"
+	"time"
+)
+
+// ProbeResult is the outcome of an on-demand probe
+type ProbeResult struct {
+	Type    string      `json:"type"`
+	Target  string      `json:"target"`
+	Status  CheckStatus `json:"status"` // passing or critical
+	Output  string      `json:"output,omitempty"` // error of a failed probe
+	Latency string      `json:"latency"`
+}
+
+// Probe runs a check once and reports the result, without adding the check
+// to the checker: no check state, history, metrics or callbacks change.
+// Only checks probing a target over the network (http, tcp, udp, grpc) can
+// be probed. The timeout defaults to that of added checks.
+func (c *Checker) Probe(check *Check) (*ProbeResult, error) {
+	switch check.Type {
+	case "http", "tcp", "udp", "grpc":
+	default:
+		return nil, fmt.Errorf("%w: cannot probe %s checks", ErrInvalidCheck, check.Type)
+	}
+	if err := validateCheck(check); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCheck, err)
+	}
+	
+	if check.Timeout == 0 {
+		check.Timeout = 5 * time.Second
+	}
+	if check.Type == "http" {
+		if err := c.setHTTPClient(check); err != nil {
+			return nil, err
+		}
+		if check.ownTransport {
+			defer check.client.CloseIdleConnections()
+		}
+	}
+	
+	start := time.Now()
+	var err error
+	switch check.Type {
+	case "http":
+		err = c.checkHTTP(check)
+	case "tcp":
+		err = c.checkTCP(check)
+	case "udp":
+		err = c.checkUDP(check)
+	case "grpc":
+		err = c.checkGRPC(check)
+	}
+	
+	result := &ProbeResult{
+		Type:    check.Type,
+		Target:  check.Target,
+		Status:  StatusPassing,
+		Latency: time.Since(start).String(),
+	}
+	if err != nil {
+		result.Status = StatusCritical
+		result.Output = err.Error()
+	}
+	return result, nil
+}
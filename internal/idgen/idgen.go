@@ -0,0 +1,35 @@
+package idgen
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+)
+
+// Generator returns a new unique ID on each call. Generators must be safe
+// for concurrent use.
+type Generator func() string
+
+// Random returns a generator of random (version 4) UUIDs
+func Random() Generator {
+	return func() string {
+		var b [16]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			// crypto/rand does not fail on supported platforms
+			panic(fmt.Sprintf("failed to read random bytes: %v", err))
+		}
+		b[6] = b[6]&0x0f | 0x40
+		b[8] = b[8]&0x3f | 0x80
+		return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+	}
+}
+
+// Sequence returns a generator of the deterministic IDs "1", "2", "3", ...
+// for tests
+func Sequence() Generator {
+	var n uint64
+	return func() string {
+		return strconv.FormatUint(atomic.AddUint64(&n, 1), 10)
+	}
+}
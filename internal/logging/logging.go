@@ -0,0 +1,130 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourusername/hbf-agent/internal/config"
+)
+
+// New creates a logger configured from LogConfig
+func New(cfg config.LogConfig) (*logrus.Logger, error) {
+	log := logrus.New()
+	if err := Configure(log, cfg); err != nil {
+		return nil, err
+	}
+	return log, nil
+}
+
+// Configure applies LogConfig to an existing logger. It can be called again
+// on a running agent to change level, format or output in place.
+//
+//   - level:  debug, info, warn, error (anything logrus.ParseLevel accepts)
+//   - format: text or json
+//   - output: stdout, stderr or a file path, opened for appending
+func Configure(log *logrus.Logger, cfg config.LogConfig) error {
+	level := logrus.InfoLevel
+	if cfg.Level != "" {
+		parsed, err := logrus.ParseLevel(cfg.Level)
+		if err != nil {
+			return fmt.Errorf("invalid log level: %w", err)
+		}
+		level = parsed
+	}
+	
+	var formatter logrus.Formatter
+	switch strings.ToLower(cfg.Format) {
+	case "", "text":
+		formatter = &logrus.TextFormatter{
+			FullTimestamp:   true,
+			TimestampFormat: time.RFC3339,
+		}
+	case "json":
+		formatter = &logrus.JSONFormatter{
+			TimestampFormat: time.RFC3339Nano,
+		}
+	default:
+		return fmt.Errorf("invalid log format: %s", cfg.Format)
+	}
+	
+	output, err := openOutput(cfg.Output)
+	if err != nil {
+		return err
+	}
+	
+	log.SetLevel(level)
+	log.SetFormatter(formatter)
+	setOutput(log, output)
+	
+	return nil
+}
+
+// WithFields adds fields to every entry logged through log, so that
+// messages from all components carry e.g. the node ID
+func WithFields(log *logrus.Logger, fields logrus.Fields) {
+	log.AddHook(&fieldsHook{fields: fields})
+}
+
+// fieldsHook adds a fixed set of fields to every entry
+type fieldsHook struct {
+	fields logrus.Fields
+}
+
+func (h *fieldsHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *fieldsHook) Fire(entry *logrus.Entry) error {
+	for key, value := range h.fields {
+		if _, exists := entry.Data[key]; !exists {
+			entry.Data[key] = value
+		}
+	}
+	return nil
+}
+
+// openOutput resolves the output setting to a writer
+func openOutput(output string) (io.Writer, error) {
+	switch output {
+	case "", "stdout":
+		return os.Stdout, nil
+	case "stderr":
+		return os.Stderr, nil
+	default:
+		file, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open log file %s: %w", output, err)
+		}
+		return file, nil
+	}
+}
+
+// files tracks the log file currently used by each logger so that it can be
+// closed when the output changes
+var (
+	files   = make(map[*logrus.Logger]*os.File)
+	filesMu sync.Mutex
+)
+
+// setOutput switches the logger output, closing a previously opened file
+func setOutput(log *logrus.Logger, output io.Writer) {
+	filesMu.Lock()
+	defer filesMu.Unlock()
+	
+	log.SetOutput(output)
+	
+	previous := files[log]
+	delete(files, log)
+	if file, ok := output.(*os.File); ok && file != os.Stdout && file != os.Stderr {
+		files[log] = file
+	}
+	
+	if previous != nil && previous != output {
+		previous.Close()
+	}
+}
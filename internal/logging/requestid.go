@@ -0,0 +1,43 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RequestIDHeader is the HTTP header carrying the request ID
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// NewRequestID generates a random request ID
+func NewRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// WithRequestID returns a copy of ctx carrying the request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID carried by ctx, if any
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Entry returns a log entry carrying the request ID from ctx, so that
+// actions can be traced back to the API request that caused them
+func Entry(ctx context.Context, log *logrus.Logger) *logrus.Entry {
+	if id := RequestID(ctx); id != "" {
+		return log.WithField("request_id", id)
+	}
+	return logrus.NewEntry(log)
+}
@@ -3,8 +3,10 @@ package metrics
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -21,8 +23,21 @@ type Manager struct {
 	metrics  *Metrics
 	mu       sync.RWMutex
 	running  bool
+	ctx      context.Context    // the start context; the server is closed once it is done
+	cancel   context.CancelFunc // cancels ctx on Stop
+	watcher  sync.WaitGroup     // the goroutine watching ctx
+	served   chan struct{}      // closed when the Serve goroutine of server returns
+	
+	// serveErr has its own lock: the Serve goroutine cannot take mu while
+	// closeLocked waits for it
+	errMu    sync.Mutex
+	serveErr error
 }
 
+// requestDurationBuckets are the histogram buckets shared by the service and
+// API request duration metrics
+var requestDurationBuckets = prometheus.DefBuckets
+
 // Metrics contains all Prometheus metrics
 type Metrics struct {
 	// Firewall metrics
@@ -35,6 +50,18 @@ type Metrics struct {
 	ServiceHealthStatus   *prometheus.GaugeVec
 	ServiceRequests       *prometheus.CounterVec
 	ServiceRequestDuration *prometheus.HistogramVec
+	ServiceCallAttempts   *prometheus.CounterVec
+	ServiceTimeouts       *prometheus.CounterVec
+	LBSelections          *prometheus.CounterVec
+	LBNoHealthy           *prometheus.CounterVec
+	LBSplitSelections     *prometheus.CounterVec
+	DiscoveryConnected    prometheus.Gauge
+	DiscoveryBackendUp    *prometheus.GaugeVec
+	DiscoveryErrors       *prometheus.CounterVec
+	DiscoveryTimeouts     *prometheus.CounterVec
+	DiscoveryCacheServed  *prometheus.CounterVec
+	DiscoverySyncDuration prometheus.Histogram
+	DiscoverySyncsSkipped prometheus.Counter
 	
 	// Traffic metrics
 	TrafficBytesTotal     *prometheus.CounterVec
@@ -44,10 +71,21 @@ type Metrics struct {
 	// Health check metrics
 	HealthChecksTotal     *prometheus.CounterVec
 	HealthCheckDuration   *prometheus.HistogramVec
+	HealthChecksInFlight  prometheus.Gauge
+	HealthChecksSkipped   *prometheus.CounterVec
+	HealthCheckStatus     *prometheus.GaugeVec
+	HealthCheckFailures   *prometheus.GaugeVec
+	
+	// API server metrics
+	APIRequests           *prometheus.CounterVec
+	APIRequestDuration    *prometheus.HistogramVec
+	APIRequestsInFlight   *prometheus.GaugeVec
+	TLSCertExpiry         *prometheus.GaugeVec
 	
 	// Agent metrics
 	AgentUptime           prometheus.Counter
 	AgentErrors           *prometheus.CounterVec
+	AgentLeader           prometheus.Gauge
 }
 
 // NewManager creates a new metrics manager
@@ -77,7 +115,7 @@ func NewManager(cfg config.MonitoringConfig, log *logrus.Logger) *Manager {
 		ServiceHealthStatus: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "hbf_service_health_status",
-				Help: "Service health status (1=healthy, 0=unhealthy)",
+				Help: "Service health status (1=healthy, 0=unhealthy, 2=maintenance, 3=warning)",
 			},
 			[]string{"service_name", "service_id"},
 		),
@@ -86,17 +124,94 @@ func NewManager(cfg config.MonitoringConfig, log *logrus.Logger) *Manager {
 				Name: "hbf_service_requests_total",
 				Help: "Total number of service requests",
 			},
-			[]string{"service_name", "method", "status"},
+			[]string{"service_name", "method", "status", "strategy"},
 		),
 		ServiceRequestDuration: prometheus.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Name:    "hbf_service_request_duration_seconds",
 				Help:    "Service request duration in seconds",
-				Buckets: prometheus.DefBuckets,
+				Buckets: requestDurationBuckets,
+			},
+			[]string{"service_name", "method", "strategy"},
+		),
+		ServiceCallAttempts: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "hbf_service_call_attempts_total",
+				Help: "Total number of service call attempts made by CallWithRetry",
+			},
+			[]string{"service_name", "outcome"},
+		),
+		ServiceTimeouts: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "hbf_service_timeouts_total",
+				Help: "Total number of proxied connections aborted by the service request timeout",
+			},
+			[]string{"service_name"},
+		),
+		LBSelections: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "hbf_lb_selections_total",
+				Help: "Total number of instances selected by the load balancer",
+			},
+			[]string{"service_name", "service_id", "strategy"},
+		),
+		LBNoHealthy: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "hbf_lb_no_healthy_total",
+				Help: "Total number of selections that failed because no instance was eligible",
 			},
-			[]string{"service_name", "method"},
+			[]string{"service_name"},
+		),
+		LBSplitSelections: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "hbf_lb_split_selections_total",
+				Help: "Total number of selections of a traffic split, by the bucket they hit",
+			},
+			[]string{"service_name", "bucket"},
 		),
 		
+		DiscoveryConnected: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "hbf_discovery_connected",
+			Help: "Whether the last sync with the discovery backend succeeded (1) or not (0)",
+		}),
+		DiscoveryBackendUp: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "hbf_discovery_backend_up",
+				Help: "Whether the last ping of the discovery backend succeeded (1) or not (0)",
+			},
+			[]string{"backend"},
+		),
+		DiscoveryErrors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "hbf_discovery_errors_total",
+				Help: "Total number of failed discovery backend operations",
+			},
+			[]string{"backend", "operation"}, // ping, register, deregister, discover
+		),
+		DiscoveryTimeouts: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "hbf_discovery_timeouts_total",
+				Help: "Total number of discovery backend operations that timed out",
+			},
+			[]string{"backend", "operation"},
+		),
+		DiscoveryCacheServed: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "hbf_discovery_served_from_cache_total",
+				Help: "Total number of discoveries served from the cache because the backend failed",
+			},
+			[]string{"service_name"},
+		),
+		DiscoverySyncDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "hbf_discovery_sync_duration_seconds",
+			Help:    "Duration of discovery syncs in seconds",
+			Buckets: prometheus.DefBuckets,
+		}),
+		DiscoverySyncsSkipped: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "hbf_discovery_syncs_skipped_total",
+			Help: "Total number of discovery syncs skipped because the previous sync was still running",
+		}),
+		
 		// Traffic metrics
 		TrafficBytesTotal: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
@@ -130,6 +245,62 @@ func NewManager(cfg config.MonitoringConfig, log *logrus.Logger) *Manager {
 			},
 			[]string{"check_id"},
 		),
+		HealthChecksInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "hbf_health_checks_in_flight",
+			Help: "Number of health checks currently running",
+		}),
+		HealthChecksSkipped: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "hbf_health_checks_skipped_total",
+				Help: "Total number of health check runs skipped because the previous run had not finished",
+			},
+			[]string{"check_id"},
+		),
+		HealthCheckStatus: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "hbf_health_check_status",
+				Help: "Current status of a health check (0 passing, 1 warning, 2 critical)",
+			},
+			[]string{"check_id"},
+		),
+		HealthCheckFailures: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "hbf_health_check_consecutive_failures",
+				Help: "Number of consecutive failed runs of a health check",
+			},
+			[]string{"check_id"},
+		),
+		
+		// API server metrics
+		APIRequests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "hbf_api_requests_total",
+				Help: "Total number of API requests",
+			},
+			[]string{"method", "path", "status"},
+		),
+		APIRequestDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "hbf_api_request_duration_seconds",
+				Help:    "API request duration in seconds",
+				Buckets: requestDurationBuckets,
+			},
+			[]string{"method", "path", "status"},
+		),
+		APIRequestsInFlight: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "hbf_api_requests_in_flight",
+				Help: "Number of API requests currently being served",
+			},
+			[]string{"method", "path"},
+		),
+		TLSCertExpiry: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "hbf_tls_cert_expiry_timestamp_seconds",
+				Help: "Expiry of the API server certificate and of the earliest expiring client CA certificate, as a Unix timestamp",
+			},
+			[]string{"cert"},
+		),
 		
 		// Agent metrics
 		AgentUptime: prometheus.NewCounter(prometheus.CounterOpts{
@@ -143,6 +314,10 @@ func NewManager(cfg config.MonitoringConfig, log *logrus.Logger) *Manager {
 			},
 			[]string{"component", "error_type"},
 		),
+		AgentLeader: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "hbf_agent_leader",
+			Help: "Whether this agent is the cluster leader (1) or not (0)",
+		}),
 	}
 	
 	// Register all metrics
@@ -154,13 +329,34 @@ func NewManager(cfg config.MonitoringConfig, log *logrus.Logger) *Manager {
 		metrics.ServiceHealthStatus,
 		metrics.ServiceRequests,
 		metrics.ServiceRequestDuration,
+		metrics.ServiceCallAttempts,
+		metrics.ServiceTimeouts,
+		metrics.LBSelections,
+		metrics.LBNoHealthy,
+		metrics.LBSplitSelections,
+		metrics.DiscoveryConnected,
+		metrics.DiscoveryBackendUp,
+		metrics.DiscoveryErrors,
+		metrics.DiscoveryTimeouts,
+		metrics.DiscoveryCacheServed,
+		metrics.DiscoverySyncDuration,
+		metrics.DiscoverySyncsSkipped,
 		metrics.TrafficBytesTotal,
 		metrics.ConnectionsActive,
 		metrics.ConnectionsTotal,
 		metrics.HealthChecksTotal,
 		metrics.HealthCheckDuration,
+		metrics.HealthChecksInFlight,
+		metrics.HealthChecksSkipped,
+		metrics.HealthCheckStatus,
+		metrics.HealthCheckFailures,
+		metrics.APIRequests,
+		metrics.APIRequestDuration,
+		metrics.APIRequestsInFlight,
+		metrics.TLSCertExpiry,
 		metrics.AgentUptime,
 		metrics.AgentErrors,
+		metrics.AgentLeader,
 	)
 	
 	return &Manager{
@@ -171,60 +367,176 @@ func NewManager(cfg config.MonitoringConfig, log *logrus.Logger) *Manager {
 	}
 }
 
-// Start starts the metrics manager
+// Start starts the metrics manager. The metrics server runs until ctx is
+// cancelled or Stop is called; the manager can be started again after Stop.
 func (m *Manager) Start(ctx context.Context) error {
 	m.mu.Lock()
+	defer m.mu.Unlock()
+	
 	if m.running {
-		m.mu.Unlock()
 		return fmt.Errorf("metrics manager is already running")
 	}
-	m.running = true
-	m.mu.Unlock()
 	
 	if !m.config.Enabled {
 		m.log.Info("Metrics collection is disabled")
+	} else {
+		m.log.Info("Starting metrics manager...")
+		if err := m.serveLocked(); err != nil {
+			return err
+		}
+	}
+	
+	m.ctx, m.cancel = context.WithCancel(ctx)
+	m.running = true
+	m.watcher.Add(1)
+	go m.watch(m.ctx)
+	
+	return nil
+}
+
+// watch closes the metrics server once the start context is done
+func (m *Manager) watch(ctx context.Context) {
+	defer m.watcher.Done()
+	<-ctx.Done()
+	
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.closeLocked(); err != nil {
+		m.log.Errorf("Failed to stop metrics server: %v", err)
+	}
+}
+
+// Stop stops the metrics manager, closing the metrics server and waiting
+// for it to return
+func (m *Manager) Stop() error {
+	m.mu.Lock()
+	if !m.running {
+		m.mu.Unlock()
+		return fmt.Errorf("metrics manager is not running")
+	}
+	m.running = false
+	cancel := m.cancel
+	err := m.closeLocked()
+	m.mu.Unlock()
+	
+	cancel()
+	m.watcher.Wait()
+	if err != nil {
+		return err
+	}
+	
+	m.log.Info("Metrics manager stopped")
+	return nil
+}
+
+// Reload applies a new monitoring configuration. Enabling, disabling or
+// moving the metrics endpoint restarts the metrics server; collected
+// metrics are kept.
+func (m *Manager) Reload(cfg config.MonitoringConfig) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	restart := cfg.Enabled != m.config.Enabled ||
+		cfg.MetricsPort != m.config.MetricsPort ||
+		cfg.MetricsPath != m.config.MetricsPath
+	m.config = cfg
+	
+	// Once the start context is done the server stays closed
+	if !m.running || !restart || m.ctx.Err() != nil {
 		return nil
 	}
 	
-	m.log.Info("Starting metrics manager...")
+	if err := m.closeLocked(); err != nil {
+		return err
+	}
+	
+	if !cfg.Enabled {
+		m.log.Info("Metrics collection disabled")
+		return nil
+	}
 	
-	// Create HTTP server for metrics
+	return m.serveLocked()
+}
+
+// Handler returns the Prometheus exposition handler for the metrics
+// registry, for mounting on other servers
+func (m *Manager) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// serveLocked binds the metrics listener and serves it in the background.
+// Binding happens before returning so that a port conflict is reported to
+// the caller. Callers must hold m.mu.
+func (m *Manager) serveLocked() error {
 	mux := http.NewServeMux()
-	mux.Handle(m.config.MetricsPath, promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+	mux.Handle(m.config.MetricsPath, m.Handler())
 	
-	m.server = &http.Server{
+	server := &http.Server{
 		Addr:    fmt.Sprintf(":%d", m.config.MetricsPort),
 		Handler: mux,
 	}
 	
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", server.Addr, err)
+	}
+	
+	served := make(chan struct{})
+	m.server = server
+	m.served = served
+	m.errMu.Lock()
+	m.serveErr = nil
+	m.errMu.Unlock()
+	m.log.Infof("Metrics server listening on %s%s", server.Addr, m.config.MetricsPath)
+	
 	// Start server in goroutine
 	go func() {
-		m.log.Infof("Metrics server listening on :%d%s", m.config.MetricsPort, m.config.MetricsPath)
-		if err := m.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		defer close(served)
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
 			m.log.Errorf("Metrics server error: %v", err)
+			m.errMu.Lock()
+			m.serveErr = err
+			m.errMu.Unlock()
 		}
 	}()
 	
 	return nil
 }
 
-// Stop stops the metrics manager
-func (m *Manager) Stop() error {
-	m.mu.Lock()
+// closeLocked shuts the metrics server down and waits for its Serve
+// goroutine to return. Callers must hold m.mu.
+func (m *Manager) closeLocked() error {
+	if m.server == nil {
+		return nil
+	}
+	
+	server, served := m.server, m.served
+	m.server, m.served = nil, nil
+	err := server.Close()
+	<-served
+	if err != nil {
+		return fmt.Errorf("failed to stop metrics server: %w", err)
+	}
+	
+	return nil
+}
+
+// Healthy returns an error if the manager is not running or the metrics
+// server failed
+func (m *Manager) Healthy() error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
 	if !m.running {
-		m.mu.Unlock()
 		return fmt.Errorf("metrics manager is not running")
 	}
-	m.running = false
-	m.mu.Unlock()
 	
-	if m.server != nil {
-		if err := m.server.Close(); err != nil {
-			return fmt.Errorf("failed to stop metrics server: %w", err)
-		}
+	m.errMu.Lock()
+	defer m.errMu.Unlock()
+	if m.serveErr != nil {
+		return fmt.Errorf("metrics server failed: %w", m.serveErr)
 	}
 	
-	m.log.Info("Metrics manager stopped")
 	return nil
 }
 
@@ -255,19 +567,104 @@ func (m *Manager) SetServicesRegistered(count float64) {
 	m.metrics.ServicesRegistered.Set(count)
 }
 
-// SetServiceHealthStatus sets the health status of a service
-func (m *Manager) SetServiceHealthStatus(serviceName, serviceID string, healthy bool) {
+// SetServiceHealthStatus sets the health status of a service instance:
+// 1 for "healthy", 2 for "maintenance" and 0 otherwise
+func (m *Manager) SetServiceHealthStatus(serviceName, serviceID, status string) {
 	value := 0.0
-	if healthy {
+	switch status {
+	case "healthy":
 		value = 1.0
+	case "maintenance":
+		value = 2.0
+	case "warning":
+		value = 3.0
 	}
 	m.metrics.ServiceHealthStatus.WithLabelValues(serviceName, serviceID).Set(value)
 }
 
-// RecordServiceRequest records a service request
-func (m *Manager) RecordServiceRequest(serviceName, method, status string, duration float64) {
-	m.metrics.ServiceRequests.WithLabelValues(serviceName, method, status).Inc()
-	m.metrics.ServiceRequestDuration.WithLabelValues(serviceName, method).Observe(duration)
+// DeleteServiceHealthStatus removes the health status of a deregistered
+// service instance
+func (m *Manager) DeleteServiceHealthStatus(serviceName, serviceID string) {
+	m.metrics.ServiceHealthStatus.DeleteLabelValues(serviceName, serviceID)
+}
+
+// RecordServiceRequest records a service request served through the load
+// balancing strategy named strategy
+func (m *Manager) RecordServiceRequest(serviceName, method, status, strategy string, duration float64) {
+	m.metrics.ServiceRequests.WithLabelValues(serviceName, method, status, strategy).Inc()
+	m.metrics.ServiceRequestDuration.WithLabelValues(serviceName, method, strategy).Observe(duration)
+}
+
+// RecordCallAttempt records a service call attempt and its outcome
+func (m *Manager) RecordCallAttempt(serviceName, outcome string) {
+	m.metrics.ServiceCallAttempts.WithLabelValues(serviceName, outcome).Inc()
+}
+
+// RecordServiceTimeout records a proxied connection aborted by the request
+// timeout
+func (m *Manager) RecordServiceTimeout(serviceName string) {
+	m.metrics.ServiceTimeouts.WithLabelValues(serviceName).Inc()
+}
+
+// RecordLBSelection records an instance selected by the load balancer
+func (m *Manager) RecordLBSelection(serviceName, serviceID, strategy string) {
+	m.metrics.LBSelections.WithLabelValues(serviceName, serviceID, strategy).Inc()
+}
+
+// RecordLBNoHealthy records a selection that found no eligible instance
+func (m *Manager) RecordLBNoHealthy(serviceName string) {
+	m.metrics.LBNoHealthy.WithLabelValues(serviceName).Inc()
+}
+
+// RecordLBSplitSelection records a selection of a traffic split hitting a
+// bucket
+func (m *Manager) RecordLBSplitSelection(serviceName, bucket string) {
+	m.metrics.LBSplitSelections.WithLabelValues(serviceName, bucket).Inc()
+}
+
+// SetDiscoveryConnected records whether the discovery backend is reachable
+func (m *Manager) SetDiscoveryConnected(connected bool) {
+	if connected {
+		m.metrics.DiscoveryConnected.Set(1)
+		return
+	}
+	m.metrics.DiscoveryConnected.Set(0)
+}
+
+// SetDiscoveryBackendUp records the result of a discovery backend ping
+func (m *Manager) SetDiscoveryBackendUp(backend string, up bool) {
+	if up {
+		m.metrics.DiscoveryBackendUp.WithLabelValues(backend).Set(1)
+		return
+	}
+	m.metrics.DiscoveryBackendUp.WithLabelValues(backend).Set(0)
+}
+
+// RecordDiscoveryError records a failed discovery backend operation
+func (m *Manager) RecordDiscoveryError(backend, operation string) {
+	m.metrics.DiscoveryErrors.WithLabelValues(backend, operation).Inc()
+}
+
+// RecordDiscoveryTimeout records a discovery backend operation that timed
+// out
+func (m *Manager) RecordDiscoveryTimeout(backend, operation string) {
+	m.metrics.DiscoveryTimeouts.WithLabelValues(backend, operation).Inc()
+}
+
+// RecordDiscoveryCacheServed records a discovery served from the cache
+func (m *Manager) RecordDiscoveryCacheServed(serviceName string) {
+	m.metrics.DiscoveryCacheServed.WithLabelValues(serviceName).Inc()
+}
+
+// RecordDiscoverySync records the duration of a discovery sync
+func (m *Manager) RecordDiscoverySync(duration float64) {
+	m.metrics.DiscoverySyncDuration.Observe(duration)
+}
+
+// RecordDiscoverySyncSkipped records a discovery sync skipped because the
+// previous one was still running
+func (m *Manager) RecordDiscoverySyncSkipped() {
+	m.metrics.DiscoverySyncsSkipped.Inc()
 }
 
 // RecordTrafficBytes records traffic bytes
@@ -291,11 +688,68 @@ func (m *Manager) RecordHealthCheck(checkID, status string, duration float64) {
 	m.metrics.HealthCheckDuration.WithLabelValues(checkID).Observe(duration)
 }
 
+// SetChecksInFlight records the number of health checks currently running
+func (m *Manager) SetChecksInFlight(n int) {
+	m.metrics.HealthChecksInFlight.Set(float64(n))
+}
+
+// RecordCheckSkipped records a health check run skipped because the
+// previous run had not finished
+func (m *Manager) RecordCheckSkipped(checkID string) {
+	m.metrics.HealthChecksSkipped.WithLabelValues(checkID).Inc()
+}
+
+// SetCheckState records the status of a health check, 0 for "passing", 1
+// for "warning" and 2 otherwise, and its consecutive failures
+func (m *Manager) SetCheckState(checkID, status string, consecutiveFailures int) {
+	value := 2.0
+	switch status {
+	case "passing":
+		value = 0.0
+	case "warning":
+		value = 1.0
+	}
+	m.metrics.HealthCheckStatus.WithLabelValues(checkID).Set(value)
+	m.metrics.HealthCheckFailures.WithLabelValues(checkID).Set(float64(consecutiveFailures))
+}
+
+// DeleteCheckState removes the state of a removed health check
+func (m *Manager) DeleteCheckState(checkID string) {
+	m.metrics.HealthCheckStatus.DeleteLabelValues(checkID)
+	m.metrics.HealthCheckFailures.DeleteLabelValues(checkID)
+}
+
+// RecordAPIRequest records a completed API request
+func (m *Manager) RecordAPIRequest(method, path, status string, duration float64) {
+	m.metrics.APIRequests.WithLabelValues(method, path, status).Inc()
+	m.metrics.APIRequestDuration.WithLabelValues(method, path, status).Observe(duration)
+}
+
+// AddAPIRequestsInFlight adjusts the number of API requests being served
+func (m *Manager) AddAPIRequestsInFlight(method, path string, delta float64) {
+	m.metrics.APIRequestsInFlight.WithLabelValues(method, path).Add(delta)
+}
+
+// SetCertExpiry records when a TLS certificate ("server" or "client_ca")
+// expires
+func (m *Manager) SetCertExpiry(name string, notAfter time.Time) {
+	m.metrics.TLSCertExpiry.WithLabelValues(name).Set(float64(notAfter.Unix()))
+}
+
 // RecordError records an error
 func (m *Manager) RecordError(component, errorType string) {
 	m.metrics.AgentErrors.WithLabelValues(component, errorType).Inc()
 }
 
+// SetLeader records whether this agent is the cluster leader
+func (m *Manager) SetLeader(leader bool) {
+	if leader {
+		m.metrics.AgentLeader.Set(1)
+		return
+	}
+	m.metrics.AgentLeader.Set(0)
+}
+
 // IncrementUptime increments the agent uptime
 func (m *Manager) IncrementUptime() {
 	m.metrics.AgentUptime.Inc()
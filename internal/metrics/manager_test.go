@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourusername/hbf-agent/internal/config"
+	"go.uber.org/goleak"
+)
+
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func newTestManager(t *testing.T) (*Manager, string) {
+	t.Helper()
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	
+	port := freePort(t)
+	m := NewManager(config.MonitoringConfig{Enabled: true, MetricsPort: port, MetricsPath: "/metrics"}, log)
+	return m, fmt.Sprintf("http://127.0.0.1:%d/metrics", port)
+}
+
+// scrape fetches the metrics endpoint over a keep-alive connection, which
+// closing the server must also close
+func scrape(t *testing.T, client *http.Client, url string) {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s = %d, want %d", url, resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestManagerStartStopCycles(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	
+	m, url := newTestManager(t)
+	client := &http.Client{Transport: &http.Transport{}}
+	defer client.CloseIdleConnections()
+	
+	for i := 0; i < 5; i++ {
+		if err := m.Start(context.Background()); err != nil {
+			t.Fatalf("Start %d: %v", i, err)
+		}
+		scrape(t, client, url)
+		if err := m.Stop(); err != nil {
+			t.Fatalf("Stop %d: %v", i, err)
+		}
+		client.CloseIdleConnections()
+	}
+	
+	if err := m.Stop(); err == nil {
+		t.Error("Stop of a stopped manager succeeded")
+	}
+}
+
+func TestManagerStopsWithStartContext(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	
+	m, url := newTestManager(t)
+	client := &http.Client{Transport: &http.Transport{}}
+	defer client.CloseIdleConnections()
+	
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := m.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	scrape(t, client, url)
+	cancel()
+	
+	// The server closes without Stop
+	deadline := time.Now().Add(time.Second)
+	for {
+		client.CloseIdleConnections()
+		resp, err := client.Get(url)
+		if err != nil {
+			break
+		}
+		resp.Body.Close()
+		if time.Now().After(deadline) {
+			t.Fatalf("GET %s still succeeds after the start context was cancelled", url)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	
+	// A reload that moves the endpoint must not serve it again
+	moved := freePort(t)
+	if err := m.Reload(config.MonitoringConfig{Enabled: true, MetricsPort: moved, MetricsPath: "/metrics"}); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if resp, err := client.Get(fmt.Sprintf("http://127.0.0.1:%d/metrics", moved)); err == nil {
+		resp.Body.Close()
+		t.Error("Reload served the metrics endpoint after the start context was cancelled")
+	}
+	
+	if err := m.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+// Package secrets resolves secret references in the configuration, such as
+// "vault://secret/data/hbf-agent#cert", so that certificates and tokens need
+// not be stored in the configuration file.
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/yourusername/hbf-agent/internal/config"
+)
+
+// ErrUnknownScheme is returned for references whose scheme has no provider
+var ErrUnknownScheme = errors.New("no secret provider for scheme")
+
+// SecretProvider fetches secrets of one reference scheme. path is the part
+// of the reference after "<scheme>://" and field the part after "#", empty
+// when the reference has none.
+type SecretProvider interface {
+	Fetch(ctx context.Context, path, field string) ([]byte, error)
+}
+
+// Resolver resolves secret references with the provider registered for
+// their scheme. The file and env providers are always available; vault is
+// added when security.vault is configured.
+type Resolver struct {
+	providers map[string]SecretProvider
+}
+
+// NewResolver creates a resolver with the file and env providers and, if an
+// address is configured (or VAULT_ADDR is set), the vault provider
+func NewResolver(cfg config.VaultConfig) *Resolver {
+	r := &Resolver{providers: make(map[string]SecretProvider)}
+	r.Register("file", FileProvider{})
+	r.Register("env", EnvProvider{})
+	if vault := NewVaultProvider(cfg); vault != nil {
+		r.Register("vault", vault)
+	}
+	return r
+}
+
+// Register adds or replaces the provider of a scheme
+func (r *Resolver) Register(scheme string, provider SecretProvider) {
+	r.providers[scheme] = provider
+}
+
+// IsRef reports whether s is a secret reference of the form
+// "<scheme>://<path>[#field]" rather than a literal value or plain path
+func IsRef(s string) bool {
+	scheme, _, ok := strings.Cut(s, "://")
+	return ok && scheme != "" && !strings.ContainsAny(scheme, "/.")
+}
+
+// Resolve returns the secret a reference points to. Values that are not
+// references are read as file paths, so existing cert_file settings keep
+// working.
+func (r *Resolver) Resolve(ctx context.Context, ref string) ([]byte, error) {
+	if !IsRef(ref) {
+		return r.fetch(ctx, "file", ref, "")
+	}
+	
+	scheme, rest, _ := strings.Cut(ref, "://")
+	path, field, _ := strings.Cut(rest, "#")
+	return r.fetch(ctx, scheme, path, field)
+}
+
+func (r *Resolver) fetch(ctx context.Context, scheme, path, field string) ([]byte, error) {
+	provider, ok := r.providers[scheme]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownScheme, scheme)
+	}
+	
+	secret, err := provider.Fetch(ctx, path, field)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s secret %s: %w", scheme, path, err)
+	}
+	return secret, nil
+}
+
+// ResolveTokens expands the references in a token list. Entries that are
+// not references are literal tokens; a reference may hold several tokens
+// separated by commas or newlines.
+func (r *Resolver) ResolveTokens(ctx context.Context, tokens []string) ([]string, error) {
+	resolved := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		if !IsRef(token) {
+			resolved = append(resolved, token)
+			continue
+		}
+		
+		secret, err := r.Resolve(ctx, token)
+		if err != nil {
+			return nil, err
+		}
+		
+		n := len(resolved)
+		for _, value := range strings.FieldsFunc(string(secret), func(c rune) bool { return c == ',' || c == '\n' }) {
+			if value = strings.TrimSpace(value); value != "" {
+				resolved = append(resolved, value)
+			}
+		}
+		if len(resolved) == n {
+			return nil, fmt.Errorf("secret %s holds no tokens", token)
+		}
+	}
+	return resolved, nil
+}
+
+// ResolveConfig replaces the token references in security.auth.tokens and
+// security.roles with the tokens they hold. Certificate references are
+// resolved when the TLS configuration is built. Any reference that cannot
+// be resolved fails the whole configuration, so the agent never runs with a
+// partial token list.
+func (r *Resolver) ResolveConfig(ctx context.Context, cfg *config.Config) error {
+	tokens, err := r.ResolveTokens(ctx, cfg.Security.Auth.Tokens)
+	if err != nil {
+		return fmt.Errorf("security.auth.tokens: %w", err)
+	}
+	cfg.Security.Auth.Tokens = tokens
+	
+	for i := range cfg.Security.Roles {
+		role := &cfg.Security.Roles[i]
+		tokens, err := r.ResolveTokens(ctx, role.Tokens)
+		if err != nil {
+			return fmt.Errorf("security.roles[%d].tokens: %w", i, err)
+		}
+		role.Tokens = tokens
+	}
+	
+	return nil
+}
+
+// FileProvider reads secrets from files: "file:///etc/hbf-agent/token"
+type FileProvider struct{}
+
+func (FileProvider) Fetch(ctx context.Context, path, field string) ([]byte, error) {
+	if field != "" {
+		return nil, fmt.Errorf("file secrets have no fields")
+	}
+	return os.ReadFile(path)
+}
+
+// EnvProvider reads secrets from environment variables: "env://HBF_TOKEN"
+type EnvProvider struct{}
+
+func (EnvProvider) Fetch(ctx context.Context, name, field string) ([]byte, error) {
+	if field != "" {
+		return nil, fmt.Errorf("environment secrets have no fields")
+	}
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, fmt.Errorf("environment variable %s is not set", name)
+	}
+	return []byte(value), nil
+}
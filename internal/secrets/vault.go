@@ -0,0 +1,113 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/yourusername/hbf-agent/internal/config"
+)
+
+// VaultProvider reads secrets from HashiCorp Vault over its HTTP API:
+// "vault://secret/data/hbf-agent#cert" reads the "cert" field of the secret
+// at secret/data/hbf-agent. Both KV v1 and KV v2 mounts are supported; for
+// KV v2 the path includes "data/" as in the Vault API.
+type VaultProvider struct {
+	address   string
+	token     string
+	namespace string
+	client    *http.Client
+}
+
+// NewVaultProvider creates a Vault provider, falling back to VAULT_ADDR,
+// VAULT_TOKEN and VAULT_NAMESPACE for unset settings. It returns nil when no
+// Vault address is configured.
+func NewVaultProvider(cfg config.VaultConfig) *VaultProvider {
+	address := cfg.Address
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	if address == "" {
+		return nil
+	}
+	
+	token := cfg.Token
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	namespace := cfg.Namespace
+	if namespace == "" {
+		namespace = os.Getenv("VAULT_NAMESPACE")
+	}
+	
+	return &VaultProvider{
+		address:   strings.TrimSuffix(address, "/"),
+		token:     token,
+		namespace: namespace,
+		client:    &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// vaultResponse is the part of a Vault read response the provider uses
+type vaultResponse struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []string               `json:"errors"`
+}
+
+// Fetch reads a field of a Vault secret. The field is required, since a
+// secret is a map of values.
+func (v *VaultProvider) Fetch(ctx context.Context, path, field string) ([]byte, error) {
+	if field == "" {
+		return nil, fmt.Errorf("vault secrets need a field, e.g. vault://%s#value", path)
+	}
+	
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.address+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if v.token != "" {
+		req.Header.Set("X-Vault-Token", v.token)
+	}
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+	
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from vault: %w", err)
+	}
+	defer resp.Body.Close()
+	
+	var body vaultResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil && resp.StatusCode == http.StatusOK {
+		return nil, fmt.Errorf("failed to decode vault response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if len(body.Errors) > 0 {
+			return nil, fmt.Errorf("vault returned %s: %s", resp.Status, strings.Join(body.Errors, "; "))
+		}
+		return nil, fmt.Errorf("vault returned %s", resp.Status)
+	}
+	
+	data := body.Data
+	// KV v2 nests the secret under data.data next to data.metadata
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, versioned := data["metadata"]; versioned {
+			data = nested
+		}
+	}
+	
+	value, ok := data[field]
+	if !ok {
+		return nil, fmt.Errorf("field %s not found", field)
+	}
+	s, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("field %s is not a string", field)
+	}
+	return []byte(s), nil
+}
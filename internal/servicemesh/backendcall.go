@@ -0,0 +1,105 @@
+package servicemesh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	consul "github.com/hashicorp/consul/api"
+	"github.com/yourusername/hbf-agent/internal/config"
+)
+
+// ErrDiscoveryTimeout is returned when a discovery backend call does not
+// finish within discovery.timeout
+var ErrDiscoveryTimeout = errors.New("discovery backend timed out")
+
+// backendCall runs a discovery backend call with a deadline of
+// discovery.timeout per attempt. Transient failures (timeouts, refused
+// connections, server errors) are retried up to discovery.retries times,
+// backing off exponentially from discovery.retry_backoff, so a slow or
+// flapping backend can neither hang the caller nor fail it on a blip.
+func backendCall(ctx context.Context, cfg config.DiscoveryConfig, fn func(ctx context.Context) error) error {
+	backoff := config.RetryConfig{BaseBackoff: cfg.RetryBackoff, MaxBackoff: cfg.MaxBackoff}
+	
+	var err error
+	for attempt := 0; attempt <= cfg.Retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(retryBackoff(backoff, attempt)):
+			}
+		}
+		
+		err = backendAttempt(ctx, cfg.Timeout, fn)
+		if err == nil || ctx.Err() != nil || !transientError(err) {
+			return err
+		}
+	}
+	
+	return err
+}
+
+// backendAttempt runs one attempt of a backend call within timeout
+func backendAttempt(ctx context.Context, timeout time.Duration, fn func(ctx context.Context) error) error {
+	if timeout <= 0 {
+		return fn(ctx)
+	}
+	
+	attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	
+	err := fn(attemptCtx)
+	if err != nil && ctx.Err() == nil && (attemptCtx.Err() != nil || timeoutError(err)) {
+		return fmt.Errorf("%w after %s: %w", ErrDiscoveryTimeout, timeout, err)
+	}
+	return err
+}
+
+// transientError reports whether a failed backend call may succeed when
+// retried
+func transientError(err error) bool {
+	if timeoutError(err) {
+		return true
+	}
+	
+	var statusErr consul.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code >= http.StatusInternalServerError || statusErr.Code == http.StatusTooManyRequests
+	}
+	
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTemporary
+	}
+	
+	var opErr *net.OpError
+	return errors.As(err, &opErr)
+}
+
+// timeoutError reports whether err is a timeout of a backend call
+func timeoutError(err error) bool {
+	if errors.Is(err, ErrDiscoveryTimeout) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// recordDiscoveryFailure records a failed discovery backend operation,
+// counting timeouts apart from other errors
+func recordDiscoveryFailure(metrics MetricsRecorder, backend, operation string, err error) {
+	if metrics == nil || err == nil {
+		return
+	}
+	
+	if timeoutError(err) {
+		metrics.RecordDiscoveryTimeout(backend, operation)
+		return
+	}
+	metrics.RecordDiscoveryError(backend, operation)
+}
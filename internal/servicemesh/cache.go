@@ -0,0 +1,68 @@
+package servicemesh
+
+import (
+	"sync"
+	"time"
+)
+
+// discoveryCache keeps the last successful discovery result of each service
+// so that traffic keeps flowing on the last-known instances while the
+// discovery backend is failing
+type discoveryCache struct {
+	maxAge  time.Duration
+	entries map[string]cacheEntry
+	mu      sync.Mutex
+}
+
+// cacheEntry is a discovery result and when it was fetched
+type cacheEntry struct {
+	services  []*Service
+	fetchedAt time.Time
+}
+
+// newDiscoveryCache creates a cache serving results up to maxAge old; a
+// zero maxAge disables it
+func newDiscoveryCache(maxAge time.Duration) *discoveryCache {
+	return &discoveryCache{
+		maxAge:  maxAge,
+		entries: make(map[string]cacheEntry),
+	}
+}
+
+// store replaces the cached result of a service with fresh data
+func (c *discoveryCache) store(serviceName string, services []*Service) {
+	if c.maxAge <= 0 {
+		return
+	}
+	
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[serviceName] = cacheEntry{services: services, fetchedAt: time.Now()}
+}
+
+// lookup returns copies of the cached instances of a service marked as
+// stale, and how old they are. Entries older than the max age are dropped.
+func (c *discoveryCache) lookup(serviceName string) ([]*Service, time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	
+	entry, ok := c.entries[serviceName]
+	if !ok {
+		return nil, 0, false
+	}
+	
+	age := time.Since(entry.fetchedAt)
+	if age > c.maxAge {
+		delete(c.entries, serviceName)
+		return nil, 0, false
+	}
+	
+	// Backends may hand out shared instances, so the flag goes on copies
+	services := make([]*Service, 0, len(entry.services))
+	for _, service := range entry.services {
+		stale := *service
+		stale.Stale = true
+		services = append(services, &stale)
+	}
+	return services, age, true
+}
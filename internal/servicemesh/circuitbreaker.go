@@ -0,0 +1,129 @@
+package servicemesh
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourusername/hbf-agent/internal/config"
+)
+
+// CircuitState represents the state of an instance circuit breaker
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"
+	CircuitOpen     CircuitState = "open"
+	CircuitHalfOpen CircuitState = "half_open"
+)
+
+// circuitBreaker keeps a circuit per service instance. A circuit opens after
+// Threshold consecutive failures, stays open for Timeout, and then lets
+// trial requests through; HalfOpenRequests successful trials close it again
+// while any failed trial reopens it.
+type circuitBreaker struct {
+	config   config.CircuitBreakerConfig
+	log      *logrus.Logger
+	circuits map[string]*circuit
+	mu       sync.Mutex
+}
+
+type circuit struct {
+	state     CircuitState
+	failures  int
+	successes int
+	openedAt  time.Time
+}
+
+func newCircuitBreaker(cfg config.CircuitBreakerConfig, log *logrus.Logger) *circuitBreaker {
+	return &circuitBreaker{
+		config:   cfg,
+		log:      log,
+		circuits: make(map[string]*circuit),
+	}
+}
+
+// allows reports whether new requests may be sent to an instance
+func (cb *circuitBreaker) allows(serviceID string) bool {
+	return cb.state(serviceID) != CircuitOpen
+}
+
+// state returns the current circuit state of an instance
+func (cb *circuitBreaker) state(serviceID string) CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	
+	if !cb.config.Enabled {
+		return CircuitClosed
+	}
+	
+	c, exists := cb.circuits[serviceID]
+	if !exists {
+		return CircuitClosed
+	}
+	
+	if c.state == CircuitOpen && time.Since(c.openedAt) >= cb.config.Timeout {
+		c.state = CircuitHalfOpen
+		c.successes = 0
+	}
+	
+	return c.state
+}
+
+// record records the outcome of a request to an instance
+func (cb *circuitBreaker) record(serviceID string, ok bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	
+	if !cb.config.Enabled {
+		return
+	}
+	
+	c, exists := cb.circuits[serviceID]
+	if !exists {
+		if ok {
+			return
+		}
+		c = &circuit{state: CircuitClosed}
+		cb.circuits[serviceID] = c
+	}
+	
+	if ok {
+		c.failures = 0
+		if c.state == CircuitHalfOpen {
+			c.successes++
+			if c.successes >= cb.config.HalfOpenRequests {
+				cb.log.Infof("Circuit closed for instance %s", serviceID)
+				delete(cb.circuits, serviceID)
+			}
+		}
+		return
+	}
+	
+	c.failures++
+	if c.state == CircuitHalfOpen || c.failures >= cb.config.Threshold {
+		if c.state != CircuitOpen {
+			cb.log.Warnf("Circuit opened for instance %s after %d failures", serviceID, c.failures)
+		}
+		c.state = CircuitOpen
+		c.openedAt = time.Now()
+	}
+}
+
+// setConfig replaces the breaker settings. Disabling the breaker closes all
+// circuits.
+func (cb *circuitBreaker) setConfig(cfg config.CircuitBreakerConfig) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.config = cfg
+	if !cfg.Enabled {
+		cb.circuits = make(map[string]*circuit)
+	}
+}
+
+// forget drops the circuit of an instance
+func (cb *circuitBreaker) forget(serviceID string) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	delete(cb.circuits, serviceID)
+}
@@ -0,0 +1,216 @@
+package servicemesh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourusername/hbf-agent/internal/config"
+)
+
+// CompositeDiscovery bridges several discovery backends: discoveries and
+// watches fan out to all of them and their instances are merged, while
+// registrations go to the primary backend only. An instance listed by
+// several backends (same name, address and port) is reported once, as the
+// first backend in configuration order lists it, the primary first.
+type CompositeDiscovery struct {
+	log      *logrus.Logger
+	names    []string
+	backends []Discovery // the primary first
+}
+
+// NewCompositeDiscovery creates the backend of DiscoveryConfig.Backend as
+// the primary and one backend per entry of DiscoveryConfig.Backends
+func NewCompositeDiscovery(cfg config.DiscoveryConfig, log *logrus.Logger) (*CompositeDiscovery, error) {
+	d := &CompositeDiscovery{log: log}
+	
+	configs := []config.DiscoveryConfig{cfg}
+	for i := range cfg.Backends {
+		configs = append(configs, cfg.BackendConfig(i))
+	}
+	
+	for _, backendCfg := range configs {
+		backend, err := newDiscoveryBackend(backendCfg, log)
+		if err != nil {
+			d.Close()
+			return nil, fmt.Errorf("discovery backend %s: %w", backendCfg.Backend, err)
+		}
+		d.names = append(d.names, backendCfg.Backend)
+		d.backends = append(d.backends, backend)
+	}
+	
+	return d, nil
+}
+
+// Primary returns the backend that receives registrations
+func (d *CompositeDiscovery) Primary() Discovery {
+	return d.backends[0]
+}
+
+func (d *CompositeDiscovery) Register(service *Service) error {
+	return d.Primary().Register(service)
+}
+
+func (d *CompositeDiscovery) Deregister(serviceID string) error {
+	return d.Primary().Deregister(serviceID)
+}
+
+// Discover merges the instances of a service from all backends. A failing
+// backend is skipped with a warning so that the others keep serving; the
+// call only fails if every backend fails.
+func (d *CompositeDiscovery) Discover(serviceName string) ([]*Service, error) {
+	results := make([][]*Service, 0, len(d.backends))
+	var errs []error
+	
+	for i, backend := range d.backends {
+		services, err := backend.Discover(serviceName)
+		if err != nil {
+			d.log.Warnf("Discovery backend %s failed to discover %s: %v", d.names[i], serviceName, err)
+			errs = append(errs, fmt.Errorf("%s: %w", d.names[i], err))
+			continue
+		}
+		results = append(results, services)
+	}
+	
+	if len(results) == 0 {
+		return nil, errors.Join(errs...)
+	}
+	return mergeInstances(results...), nil
+}
+
+// compositeUpdate is an update of one backend's watch; closed is set when
+// the watch ended
+type compositeUpdate struct {
+	index    int
+	services []*Service
+	closed   bool
+}
+
+// Watch watches a service on every backend and sends the merged instances
+// whenever one of them changes, once each backend has reported. If any
+// backend cannot be watched the whole watch is unsupported and the service
+// is polled. The channel is closed when any backend's watch ends or ctx is
+// done.
+func (d *CompositeDiscovery) Watch(ctx context.Context, serviceName string) (<-chan []*Service, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	
+	watches := make([]<-chan []*Service, len(d.backends))
+	for i, backend := range d.backends {
+		updates, err := backend.Watch(ctx, serviceName)
+		if err != nil {
+			cancel()
+			if errors.Is(err, ErrWatchUnsupported) {
+				return nil, fmt.Errorf("%w: %s", ErrWatchUnsupported, d.names[i])
+			}
+			return nil, fmt.Errorf("%s: %w", d.names[i], err)
+		}
+		watches[i] = updates
+	}
+	
+	merged := make(chan compositeUpdate)
+	for i, updates := range watches {
+		go func(i int, updates <-chan []*Service) {
+			for services := range updates {
+				select {
+				case merged <- compositeUpdate{index: i, services: services}:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case merged <- compositeUpdate{index: i, closed: true}:
+			case <-ctx.Done():
+			}
+		}(i, updates)
+	}
+	
+	ch := make(chan []*Service)
+	go func() {
+		defer close(ch)
+		defer cancel()
+		
+		latest := make([][]*Service, len(d.backends))
+		reported := make([]bool, len(d.backends))
+		pending := len(d.backends)
+		
+		for {
+			var update compositeUpdate
+			select {
+			case <-ctx.Done():
+				return
+			case update = <-merged:
+			}
+			
+			if update.closed {
+				return
+			}
+			latest[update.index] = update.services
+			if !reported[update.index] {
+				reported[update.index] = true
+				pending--
+			}
+			if pending > 0 {
+				continue
+			}
+			
+			select {
+			case ch <- mergeInstances(latest...):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	
+	return ch, nil
+}
+
+// Ping checks every backend. Only an unreachable primary fails the ping,
+// since registrations depend on it; an unreachable secondary is logged and
+// its instances are missing from discoveries until it recovers.
+func (d *CompositeDiscovery) Ping(ctx context.Context) error {
+	for i, backend := range d.backends {
+		err := backend.Ping(ctx)
+		switch {
+		case err != nil && i == 0:
+			return err
+		case err != nil:
+			d.log.Warnf("Discovery backend %s is unreachable: %v", d.names[i], err)
+		}
+	}
+	return nil
+}
+
+// Close releases the resources of the backends that hold any
+func (d *CompositeDiscovery) Close() error {
+	var errs []error
+	for _, backend := range d.backends {
+		if closer, ok := backend.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// mergeInstances concatenates instance lists, keeping the first instance of
+// each name, address and port
+func mergeInstances(lists ...[]*Service) []*Service {
+	seen := make(map[string]bool)
+	merged := make([]*Service, 0)
+	
+	for _, services := range lists {
+		for _, service := range services {
+			key := service.Name + "/" + service.Address + ":" + strconv.Itoa(service.Port)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			merged = append(merged, service)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,163 @@
+package servicemesh
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// connReapInterval is how often the connection counts of instances gone
+// from discovery are dropped
+const connReapInterval = time.Minute
+
+// Connection is an in-flight request or connection to an instance. It
+// counts towards the instance's active connections, for the
+// connection-aware strategies and for draining, until it is closed.
+type Connection struct {
+	Service *Service
+	
+	manager  *Manager
+	balancer LoadBalancer
+	closed   atomic.Bool
+}
+
+// Close ends the connection, releasing its count. Closing again does
+// nothing.
+func (c *Connection) Close() error {
+	if c.closed.Swap(true) {
+		return nil
+	}
+	
+	c.manager.active.release(c.Service.ID)
+	if counter, ok := c.balancer.(connectionCounter); ok {
+		counter.ReleaseConnection(c.Service.ID)
+	}
+	return nil
+}
+
+// Connect selects an instance of a service like SelectServiceContext and
+// opens a Connection to it. Close the connection when the request ends.
+func (m *Manager) Connect(ctx context.Context, serviceName string) (*Connection, error) {
+	service, err := m.SelectServiceContext(ctx, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	return m.Acquire(service), nil
+}
+
+// Acquire opens a Connection to an instance chosen by a selection. The
+// count goes to the load balancer the service currently uses, and Close
+// releases it there even if the strategy changes meanwhile.
+func (m *Manager) Acquire(service *Service) *Connection {
+	conn := &Connection{
+		Service:  service,
+		manager:  m,
+		balancer: m.currentBalancer(service.Name),
+	}
+	
+	m.active.acquire(service)
+	if counter, ok := conn.balancer.(connectionCounter); ok {
+		counter.AcquireConnection(service.ID)
+	}
+	return conn
+}
+
+// currentBalancer returns the load balancer a service currently uses,
+// without resolving its strategy again
+func (m *Manager) currentBalancer(serviceName string) LoadBalancer {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	if sb, exists := m.balancers[serviceName]; exists {
+		return sb.balancer
+	}
+	return m.loadBalance
+}
+
+// currentStrategy returns the name of the strategy a service currently
+// uses, one of config.Strategies, without resolving it again
+func (m *Manager) currentStrategy(serviceName string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	if sb, exists := m.balancers[serviceName]; exists {
+		return sb.strategy
+	}
+	return m.config.LoadBalance.Strategy
+}
+
+// reapLoop periodically drops the connection counts of instances gone from
+// discovery
+func (m *Manager) reapLoop(ctx context.Context) {
+	ticker := time.NewTicker(connReapInterval)
+	defer ticker.Stop()
+	
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.reapConnections(ctx)
+		}
+	}
+}
+
+// reapConnections drops the counts of instances that no longer appear in
+// the discovery of their service, so that connections never closed, or
+// counts left behind by instances that went away, do not skew selection
+// when an instance with the same ID returns. Locally registered instances
+// are kept for draining, and nothing is dropped for a service whose
+// discovery fails. It returns the number of instances dropped.
+func (m *Manager) reapConnections(ctx context.Context) int {
+	reaped := 0
+	for name, ids := range m.active.byService() {
+		services, err := m.DiscoverServiceContext(ctx, name)
+		if err != nil {
+			continue
+		}
+		
+		live := make(map[string]bool, len(services))
+		for _, service := range services {
+			live[service.ID] = true
+		}
+		
+		for _, id := range ids {
+			if live[id] || m.isLocal(id) {
+				continue
+			}
+			m.forgetConnections(id)
+			reaped++
+			m.log.Debugf("Dropped connection count of %s instance %s, gone from discovery", name, id)
+		}
+	}
+	return reaped
+}
+
+// isLocal reports whether an instance is registered on this agent
+func (m *Manager) isLocal(serviceID string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	_, exists := m.services[serviceID]
+	return exists
+}
+
+// forgetConnections drops the connection count of an instance from the
+// manager and from every load balancer
+func (m *Manager) forgetConnections(serviceID string) {
+	m.active.forget(serviceID)
+	
+	m.mu.RLock()
+	balancers := make([]LoadBalancer, 0, len(m.balancers)+1)
+	balancers = append(balancers, m.loadBalance)
+	for _, sb := range m.balancers {
+		balancers = append(balancers, sb.balancer)
+	}
+	m.mu.RUnlock()
+	
+	for _, balancer := range balancers {
+		if counter, ok := balancer.(connectionCounter); ok {
+			counter.ForgetConnection(serviceID)
+		}
+	}
+}
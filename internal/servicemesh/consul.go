@@ -0,0 +1,302 @@
+package servicemesh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	consul "github.com/hashicorp/consul/api"
+	"github.com/sirupsen/logrus"
+	"github.com/yourusername/hbf-agent/internal/config"
+)
+
+// consulWatchWait is how long a blocking query of a watch waits for changes
+// before Consul answers with the unchanged result
+const consulWatchWait = 5 * time.Minute
+
+// ConsulDiscovery implements Discovery using Consul
+type ConsulDiscovery struct {
+	config config.DiscoveryConfig
+	log    *logrus.Logger
+	client *consul.Client
+	// watchClient has no request timeout so blocking queries can wait for
+	// changes
+	watchClient *consul.Client
+}
+
+// NewConsulDiscovery creates a new Consul discovery backend
+func NewConsulDiscovery(cfg config.DiscoveryConfig, log *logrus.Logger) (*ConsulDiscovery, error) {
+	clientCfg := consul.DefaultConfig()
+	clientCfg.Address = cfg.Address
+	clientCfg.Datacenter = cfg.Datacenter
+	if cfg.Timeout > 0 {
+		clientCfg.HttpClient = &http.Client{Timeout: cfg.Timeout}
+	}
+	
+	client, err := consul.NewClient(clientCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Consul client: %w", err)
+	}
+	
+	watchCfg := consul.DefaultConfig()
+	watchCfg.Address = cfg.Address
+	watchCfg.Datacenter = cfg.Datacenter
+	watchClient, err := consul.NewClient(watchCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Consul client: %w", err)
+	}
+	
+	return &ConsulDiscovery{
+		config:      cfg,
+		log:         log,
+		client:      client,
+		watchClient: watchClient,
+	}, nil
+}
+
+func (d *ConsulDiscovery) Register(service *Service) error {
+	d.log.Debugf("Registering service with Consul: %s", service.Name)
+	
+	registration := &consul.AgentServiceRegistration{
+		ID:      service.ID,
+		Name:    service.Name,
+		Address: service.Address,
+		Port:    service.Port,
+		Tags:    service.Tags,
+		Meta:    service.Meta,
+	}
+	
+	err := backendCall(context.Background(), d.config, func(ctx context.Context) error {
+		return d.client.Agent().ServiceRegisterOpts(registration, consul.ServiceRegisterOpts{}.WithContext(ctx))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to register service with Consul: %w", err)
+	}
+	
+	return nil
+}
+
+func (d *ConsulDiscovery) Deregister(serviceID string) error {
+	d.log.Infof("Deregistering service from Consul: %s", serviceID)
+	
+	err := backendCall(context.Background(), d.config, func(ctx context.Context) error {
+		return d.client.Agent().ServiceDeregisterOpts(serviceID, (&consul.QueryOptions{}).WithContext(ctx))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to deregister service from Consul: %w", err)
+	}
+	
+	return nil
+}
+
+// Ping checks that the Consul agent answers and its cluster has a leader
+func (d *ConsulDiscovery) Ping(ctx context.Context) error {
+	leader, err := d.client.Status().LeaderWithQueryOptions((&consul.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to reach Consul: %w", err)
+	}
+	if leader == "" {
+		return fmt.Errorf("Consul cluster has no leader")
+	}
+	return nil
+}
+
+// Discover queries the Consul health endpoint for instances of a service in
+// the configured datacenter. Like registrations, queries are bounded by
+// discovery.timeout and retried on transient failures.
+func (d *ConsulDiscovery) Discover(serviceName string) ([]*Service, error) {
+	d.log.Debugf("Discovering service from Consul: %s (dc %s)", serviceName, d.config.Datacenter)
+	
+	var entries []*consul.ServiceEntry
+	err := backendCall(context.Background(), d.config, func(ctx context.Context) error {
+		var err error
+		entries, _, err = d.client.Health().Service(serviceName, "", false, (&consul.QueryOptions{
+			Datacenter: d.config.Datacenter,
+		}).WithContext(ctx))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Consul: %w", err)
+	}
+	
+	services := make([]*Service, 0, len(entries))
+	for _, entry := range entries {
+		services = append(services, consulEntryToService(entry))
+	}
+	
+	return services, nil
+}
+
+// DiscoverRemote queries the instances of a service in every datacenter
+// but the configured one. Datacenters that fail are skipped; an error is
+// returned along with the instances of the others.
+func (d *ConsulDiscovery) DiscoverRemote(serviceName string) ([]*Service, error) {
+	var datacenters []string
+	err := backendCall(context.Background(), d.config, func(ctx context.Context) error {
+		var err error
+		datacenters, err = d.client.Catalog().Datacenters()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Consul datacenters: %w", err)
+	}
+	
+	var services []*Service
+	var errs []error
+	for _, dc := range datacenters {
+		if dc == d.config.Datacenter {
+			continue
+		}
+		
+		var entries []*consul.ServiceEntry
+		err := backendCall(context.Background(), d.config, func(ctx context.Context) error {
+			var err error
+			entries, _, err = d.client.Health().Service(serviceName, "", false, (&consul.QueryOptions{
+				Datacenter: dc,
+			}).WithContext(ctx))
+			return err
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to query Consul datacenter %s: %w", dc, err))
+			continue
+		}
+		for _, entry := range entries {
+			services = append(services, consulEntryToService(entry))
+		}
+	}
+	
+	return services, errors.Join(errs...)
+}
+
+// Watch follows the instances of a service with Consul blocking queries. The
+// current instances are sent first, then every change; failed queries are
+// retried after the discovery interval. The channel is closed when ctx is
+// done.
+func (d *ConsulDiscovery) Watch(ctx context.Context, serviceName string) (<-chan []*Service, error) {
+	ch := make(chan []*Service)
+	
+	go func() {
+		defer close(ch)
+		
+		var index uint64
+		for {
+			opts := (&consul.QueryOptions{
+				Datacenter: d.config.Datacenter,
+				WaitIndex:  index,
+				WaitTime:   consulWatchWait,
+			}).WithContext(ctx)
+			
+			entries, meta, err := d.watchClient.Health().Service(serviceName, "", false, opts)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				d.log.Warnf("Consul watch of service %s failed: %v", serviceName, err)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(d.config.Interval):
+				}
+				continue
+			}
+			
+			// Blocking queries return when they time out too; only
+			// a new index is a change
+			if index != 0 && meta.LastIndex == index {
+				continue
+			}
+			// The index going backwards means the Consul state was
+			// reset, so start over
+			if meta.LastIndex < index {
+				index = 0
+			} else {
+				index = meta.LastIndex
+			}
+			
+			services := make([]*Service, 0, len(entries))
+			for _, entry := range entries {
+				services = append(services, consulEntryToService(entry))
+			}
+			
+			select {
+			case ch <- services:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	
+	return ch, nil
+}
+
+// Campaign acquires a Consul lock on key backed by a session with the given
+// TTL. It blocks until the lock is held or ctx is done. The returned channel
+// is closed when the lock is lost; cancelling ctx releases it.
+func (d *ConsulDiscovery) Campaign(ctx context.Context, key, value string, ttl time.Duration) (<-chan struct{}, error) {
+	lock, err := d.client.LockOpts(&consul.LockOptions{
+		Key:         key,
+		Value:       []byte(value),
+		SessionName: "hbf-agent-leader",
+		SessionTTL:  ttl.String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Consul lock: %w", err)
+	}
+	
+	lost, err := lock.Lock(ctx.Done())
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire Consul lock %s: %w", key, err)
+	}
+	if lost == nil {
+		return nil, ctx.Err()
+	}
+	
+	go func() {
+		select {
+		case <-ctx.Done():
+			if err := lock.Unlock(); err != nil && err != consul.ErrLockNotHeld {
+				d.log.Warnf("Failed to release Consul lock %s: %v", key, err)
+			}
+		case <-lost:
+		}
+	}()
+	
+	return lost, nil
+}
+
+// consulEntryToService converts a Consul health entry to a Service
+func consulEntryToService(entry *consul.ServiceEntry) *Service {
+	meta := make(map[string]string, len(entry.Service.Meta)+1)
+	for k, v := range entry.Service.Meta {
+		meta[k] = v
+	}
+	if _, ok := meta["datacenter"]; !ok && entry.Node != nil {
+		meta["datacenter"] = entry.Node.Datacenter
+	}
+	
+	address := entry.Service.Address
+	if address == "" && entry.Node != nil {
+		address = entry.Node.Address
+	}
+	
+	status := StatusUnhealthy
+	switch entry.Checks.AggregatedStatus() {
+	case consul.HealthPassing:
+		status = StatusHealthy
+	case consul.HealthWarning:
+		status = StatusWarning
+	}
+	
+	return &Service{
+		ID:       entry.Service.ID,
+		Name:     entry.Service.Service,
+		Address:  address,
+		Port:     entry.Service.Port,
+		Tags:     entry.Service.Tags,
+		Meta:     meta,
+		Status:   status,
+		LastSeen: time.Now(),
+	}
+}
@@ -0,0 +1,60 @@
+package servicemesh
+
+import (
+	"math/rand"
+)
+
+// Warning policies, see load_balance.warning_policy. Under "exclude"
+// warning instances receive no traffic.
+const (
+	warningFallback = "fallback"
+	warningWeighted = "weighted"
+)
+
+// filterHealth returns the instances of a service that may receive traffic
+// by health: the healthy ones not in maintenance, plus warning instances as
+// the warning policy allows. Under the weighted policy each warning instance
+// takes part in a selection with probability warning_weight, so it receives
+// that share of the traffic of a healthy instance with any strategy.
+func (m *Manager) filterHealth(serviceName string, services []*Service) []*Service {
+	lb := m.settings().LoadBalance
+	
+	healthy := make([]*Service, 0, len(services))
+	var degraded []*Service
+	for _, service := range services {
+		if m.inMaintenance(service) {
+			continue
+		}
+		switch {
+		case service.Eligible():
+			healthy = append(healthy, service)
+		case service.Degraded():
+			degraded = append(degraded, service)
+		}
+	}
+	
+	switch lb.WarningPolicy {
+	case warningFallback:
+		if len(healthy) == 0 && len(degraded) > 0 {
+			m.log.Debugf("No healthy instances of %s, falling back to %d warning instances", serviceName, len(degraded))
+			return degraded
+		}
+	case warningWeighted:
+		if len(healthy) == 0 {
+			return degraded
+		}
+		for _, service := range degraded {
+			if rand.Float64() < lb.WarningWeight {
+				healthy = append(healthy, service)
+			}
+		}
+	}
+	
+	return healthy
+}
+
+// selectable reports whether a candidate passed to a selection may be
+// chosen: healthy instances, and warning instances the warning policy let in
+func selectable(service *Service) bool {
+	return service.Eligible() || service.Degraded()
+}
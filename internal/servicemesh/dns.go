@@ -0,0 +1,109 @@
+package servicemesh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourusername/hbf-agent/internal/config"
+)
+
+// DNSDiscovery implements Discovery using DNS SRV records.
+//
+// A service name is looked up as an SRV name as given, e.g.
+// "_http._tcp.web.example.com" or "web.service.consul". Each record becomes
+// an instance whose "weight" and "priority" meta come from the record, so
+// the weighted strategies follow standard SRV semantics. DNS is read-only:
+// registrations are kept locally only.
+type DNSDiscovery struct {
+	config   config.DiscoveryConfig
+	log      *logrus.Logger
+	resolver *net.Resolver
+}
+
+// NewDNSDiscovery creates a DNS discovery backend. DiscoveryConfig.Address
+// selects the DNS server (host or host:port); empty uses the system
+// resolver.
+func NewDNSDiscovery(cfg config.DiscoveryConfig, log *logrus.Logger) (*DNSDiscovery, error) {
+	resolver := net.DefaultResolver
+	if cfg.Address != "" {
+		server := cfg.Address
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+		
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, network, server)
+			},
+		}
+	}
+	
+	return &DNSDiscovery{config: cfg, log: log, resolver: resolver}, nil
+}
+
+func (d *DNSDiscovery) Register(service *Service) error { return nil }
+func (d *DNSDiscovery) Deregister(serviceID string) error { return nil }
+
+// Discover resolves the SRV records of a service
+func (d *DNSDiscovery) Discover(serviceName string) ([]*Service, error) {
+	d.log.Debugf("Discovering service from DNS: %s", serviceName)
+	
+	var records []*net.SRV
+	err := backendCall(context.Background(), d.config, func(ctx context.Context) error {
+		var err error
+		_, records, err = d.resolver.LookupSRV(ctx, "", "", serviceName)
+		return err
+	})
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return []*Service{}, nil
+		}
+		return nil, fmt.Errorf("failed to resolve SRV records of %s: %w", serviceName, err)
+	}
+	
+	now := time.Now()
+	services := make([]*Service, 0, len(records))
+	for _, record := range records {
+		target := strings.TrimSuffix(record.Target, ".")
+		services = append(services, &Service{
+			ID:      fmt.Sprintf("%s-%s-%d", serviceName, target, record.Port),
+			Name:    serviceName,
+			Address: target,
+			Port:    int(record.Port),
+			Meta: map[string]string{
+				WeightMetaKey:   strconv.Itoa(int(record.Weight)),
+				PriorityMetaKey: strconv.Itoa(int(record.Priority)),
+			},
+			Status:   StatusHealthy,
+			LastSeen: now,
+		})
+	}
+	
+	return services, nil
+}
+
+func (d *DNSDiscovery) Watch(ctx context.Context, serviceName string) (<-chan []*Service, error) {
+	return nil, ErrWatchUnsupported
+}
+
+// Ping checks that the DNS server answers by looking up the root name
+// servers. An answer without records still shows the server is reachable.
+func (d *DNSDiscovery) Ping(ctx context.Context) error {
+	_, err := d.resolver.LookupNS(ctx, ".")
+	if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("DNS server is unreachable: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package servicemesh
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/yourusername/hbf-agent/internal/logging"
+)
+
+// drainPollInterval is how often a draining instance's connections are
+// checked
+const drainPollInterval = 100 * time.Millisecond
+
+// connectionCounter is implemented by load balancers that count active
+// connections
+type connectionCounter interface {
+	AcquireConnection(serviceID string)
+	ReleaseConnection(serviceID string)
+	ForgetConnection(serviceID string)
+}
+
+// latencyObserver is implemented by load balancers that select by observed
+// latency
+type latencyObserver interface {
+	ObserveLatency(serviceID string, latency time.Duration)
+}
+
+// activeConns counts the in-flight requests and connections per instance,
+// remembering the service of each counted instance for the reaper
+type activeConns struct {
+	counts map[string]int64
+	names  map[string]string
+	mu     sync.Mutex
+}
+
+func newActiveConns() *activeConns {
+	return &activeConns{counts: make(map[string]int64), names: make(map[string]string)}
+}
+
+func (a *activeConns) acquire(service *Service) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	
+	a.counts[service.ID]++
+	a.names[service.ID] = service.Name
+}
+
+func (a *activeConns) release(serviceID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	
+	a.counts[serviceID]--
+	if a.counts[serviceID] <= 0 {
+		delete(a.counts, serviceID)
+		delete(a.names, serviceID)
+	}
+}
+
+func (a *activeConns) forget(serviceID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	
+	delete(a.counts, serviceID)
+	delete(a.names, serviceID)
+}
+
+// byService returns the counted instance IDs grouped by service name
+func (a *activeConns) byService() map[string][]string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	
+	ids := make(map[string][]string)
+	for id, name := range a.names {
+		ids[name] = append(ids[name], id)
+	}
+	return ids
+}
+
+func (a *activeConns) get(serviceID string) int64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.counts[serviceID]
+}
+
+// ReportLatency records how long a request to an instance took, for
+// latency-aware load balancing
+func (m *Manager) ReportLatency(service *Service, latency time.Duration) {
+	if observer, ok := m.currentBalancer(service.Name).(latencyObserver); ok {
+		observer.ObserveLatency(service.ID, latency)
+	}
+}
+
+// ActiveConnections returns the number of in-flight requests and
+// connections to an instance
+func (m *Manager) ActiveConnections(serviceID string) int64 {
+	return m.active.get(serviceID)
+}
+
+// SetDraining marks an instance as draining: it receives no new requests,
+// but stays registered so in-flight requests can complete
+func (m *Manager) SetDraining(serviceID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	service, exists := m.services[serviceID]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrServiceNotFound, serviceID)
+	}
+	
+	m.setDrainingLocked(service)
+	return nil
+}
+
+// setDrainingLocked marks a service as draining. Callers must hold m.mu.
+func (m *Manager) setDrainingLocked(service *Service) {
+	if service.Status == StatusDraining {
+		return
+	}
+	
+	service.Status = StatusDraining
+	m.log.Infof("Draining service: %s (%s)", service.Name, service.ID)
+	m.publish(EventStatusChanged, service)
+}
+
+// removeLocked deregisters a service, draining it first if
+// service_mesh.drain_timeout is set. Callers must hold m.mu.
+func (m *Manager) removeLocked(ctx context.Context, service *Service) error {
+	timeout := m.config.DrainTimeout
+	if timeout <= 0 || m.active.get(service.ID) == 0 {
+		return m.deregisterLocked(ctx, service)
+	}
+	
+	alreadyDraining := service.Status == StatusDraining
+	m.setDrainingLocked(service)
+	if !alreadyDraining {
+		m.persistLocked()
+		go m.drain(context.WithoutCancel(ctx), service, timeout)
+	}
+	
+	return nil
+}
+
+// drain waits for a draining instance to become idle, then deregisters it
+func (m *Manager) drain(ctx context.Context, service *Service, timeout time.Duration) {
+	if !m.waitIdle(ctx, service.ID, timeout) {
+		return
+	}
+	
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	// The service may have been re-registered or removed meanwhile
+	if current, exists := m.services[service.ID]; !exists || current != service || current.Status != StatusDraining {
+		return
+	}
+	
+	if err := m.deregisterLocked(ctx, service); err != nil {
+		logging.Entry(ctx, m.log).Errorf("Failed to deregister drained service %s: %v", service.ID, err)
+	}
+}
+
+// waitIdle waits until an instance has no active connections or the
+// timeout expires. It returns false if the manager stops meanwhile.
+func (m *Manager) waitIdle(ctx context.Context, serviceID string, timeout time.Duration) bool {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	
+	m.mu.RLock()
+	stopped := m.stopChan
+	m.mu.RUnlock()
+	
+	for m.active.get(serviceID) > 0 {
+		select {
+		case <-stopped:
+			return false
+		case <-deadline.C:
+			logging.Entry(ctx, m.log).Warnf("Drain timeout for service %s with %d active connections",
+				serviceID, m.active.get(serviceID))
+			return true
+		case <-ticker.C:
+		}
+	}
+	
+	return true
+}
@@ -0,0 +1,42 @@
+package servicemesh
+
+import (
+	"time"
+)
+
+// EventType represents the kind of a service event
+type EventType string
+
+const (
+	EventRegistered    EventType = "registered"
+	EventDeregistered  EventType = "deregistered"
+	EventStatusChanged EventType = "status_changed"
+)
+
+// Event describes a change to a registered service
+type Event struct {
+	Type      EventType `json:"type"`
+	Service   Service   `json:"service"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// publish publishes an event carrying a snapshot of the service
+func (m *Manager) publish(eventType EventType, service *Service) {
+	m.events.Publish(Event{
+		Type:      eventType,
+		Service:   *service,
+		Timestamp: time.Now(),
+	})
+}
+
+// Subscribe returns a channel receiving service events. Events are dropped
+// for subscribers that fall behind, so the manager never blocks. Call
+// Unsubscribe when done to release the channel.
+func (m *Manager) Subscribe() <-chan Event {
+	return m.events.Subscribe()
+}
+
+// Unsubscribe stops delivery to a channel returned by Subscribe and closes it
+func (m *Manager) Unsubscribe(ch <-chan Event) {
+	m.events.Unsubscribe(ch)
+}
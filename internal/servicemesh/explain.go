@@ -0,0 +1,175 @@
+package servicemesh
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SelectionExplanation describes how a selection was made: every discovered
+// instance with the state the selection saw, and why the selected one won.
+// Selected is nil when no instance was eligible; Reason then says why.
+type SelectionExplanation struct {
+	Service    string                 `json:"service"`
+	Strategy   string                 `json:"strategy"`
+	// Bucket is the traffic split bucket the selection hit, if the service
+	// has a split
+	Bucket     string                 `json:"bucket,omitempty"`
+	Selected   *Service               `json:"selected"`
+	Reason     string                 `json:"reason"`
+	Candidates []CandidateExplanation `json:"candidates"`
+	Timestamp  time.Time              `json:"timestamp"`
+}
+
+// CandidateExplanation is the state of one instance in a selection.
+// Excluded says why an instance was not eligible.
+type CandidateExplanation struct {
+	ID                string        `json:"id"`
+	Address           string        `json:"address"`
+	Port              int           `json:"port"`
+	Status            ServiceStatus `json:"status"`
+	Maintenance       bool          `json:"maintenance"`
+	Weight            int           `json:"weight"`
+	Priority          int           `json:"priority"`
+	ActiveConnections int64         `json:"active_connections"`
+	Circuit           CircuitState  `json:"circuit"`
+	Ejected           bool          `json:"ejected"`
+	Eligible          bool          `json:"eligible"`
+	Excluded          string        `json:"excluded,omitempty"`
+	Selected          bool          `json:"selected"`
+}
+
+// ExplainSelection selects an instance like SelectServiceFilteredContext
+// and explains the selection. It is a real selection: it advances the load
+// balancer like any other.
+func (m *Manager) ExplainSelection(ctx context.Context, serviceName string, tags []string, metaMatch map[string]string) (*SelectionExplanation, error) {
+	if err := m.waitReady(ctx); err != nil {
+		return nil, err
+	}
+	
+	services, err := m.DiscoverServiceContext(ctx, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	
+	explanation := &SelectionExplanation{
+		Service:    serviceName,
+		Candidates: make([]CandidateExplanation, 0, len(services)),
+		Timestamp:  time.Now(),
+	}
+	
+	excluded := make(map[string]string)
+	candidates, err := m.eligible(serviceName, services, excluded)
+	if err == nil {
+		candidates, err = matchFilter(serviceName, candidates, tags, metaMatch, excluded)
+	}
+	
+	var selected *Service
+	split := m.serviceSplit(serviceName)
+	if err == nil && len(split) > 0 {
+		_, explanation.Strategy = m.resolveBalancer(serviceName, candidates)
+		selected, explanation.Bucket, err = m.selectSplit(serviceName, candidates, split)
+	} else if err == nil {
+		var balancer LoadBalancer
+		balancer, explanation.Strategy = m.resolveBalancer(serviceName, candidates)
+		selected, err = balancer.Select(candidates)
+	} else {
+		_, explanation.Strategy = m.resolveBalancer(serviceName, services)
+	}
+	
+	if err != nil {
+		explanation.Reason = err.Error()
+	} else {
+		explanation.Selected = selected
+		explanation.Reason = selectionReason(explanation.Strategy, selected, candidates)
+		if explanation.Bucket != "" {
+			// selectSplit recorded the selection already
+			explanation.Reason = fmt.Sprintf("split bucket %s, then %s", explanation.Bucket, explanation.Reason)
+		} else {
+			m.recordSelection(serviceName, selected.ID, explanation.Strategy)
+		}
+	}
+	
+	for _, service := range services {
+		_, dropped := excluded[service.ID]
+		explanation.Candidates = append(explanation.Candidates, CandidateExplanation{
+			ID:                service.ID,
+			Address:           service.Address,
+			Port:              service.Port,
+			Status:            service.Status,
+			Maintenance:       m.inMaintenance(service),
+			Weight:            serviceWeight(service),
+			Priority:          servicePriority(service),
+			ActiveConnections: m.active.get(service.ID),
+			Circuit:           m.breakers.state(service.ID),
+			Ejected:           m.outliers.ejected(service.ID),
+			Eligible:          err == nil && !dropped,
+			Excluded:          excluded[service.ID],
+			Selected:          selected != nil && selected.ID == service.ID,
+		})
+	}
+	
+	return explanation, nil
+}
+
+// healthExclusion says why filterHealth dropped an instance
+func (m *Manager) healthExclusion(service *Service) string {
+	switch {
+	case m.inMaintenance(service):
+		return "in maintenance"
+	case service.Degraded():
+		return fmt.Sprintf("status %s, not admitted by warning policy %s", service.Status, m.settings().LoadBalance.WarningPolicy)
+	default:
+		return fmt.Sprintf("status %s", service.Status)
+	}
+}
+
+// recordExcluded records the reason for each instance of before missing
+// from after. It does nothing if excluded is nil, so that plain selections
+// pay nothing for explanations.
+func recordExcluded(excluded map[string]string, before, after []*Service, reason func(*Service) string) {
+	if excluded == nil {
+		return
+	}
+	
+	kept := make(map[string]bool, len(after))
+	for _, service := range after {
+		kept[service.ID] = true
+	}
+	for _, service := range before {
+		if !kept[service.ID] {
+			excluded[service.ID] = reason(service)
+		}
+	}
+}
+
+// selectionReason describes why a strategy picked selected among candidates
+func selectionReason(strategy string, selected *Service, candidates []*Service) string {
+	n := len(candidates)
+	switch strategy {
+	case "least_conn":
+		return fmt.Sprintf("fewest active connections among %d eligible instances", n)
+	case "p2c":
+		return fmt.Sprintf("fewer active connections of two instances drawn at random among %d eligible instances", n)
+	case "least_time":
+		return fmt.Sprintf("lowest average latency among %d eligible instances", n)
+	case "random":
+		return fmt.Sprintf("drawn at random among %d eligible instances", n)
+	case "weighted", "smooth_weighted_round_robin":
+		tier := priorityTier(candidates)
+		total := 0
+		for _, service := range tier {
+			total += serviceWeight(service)
+		}
+		return fmt.Sprintf("weight %d of %d in priority tier %d (%d of %d eligible instances)",
+			serviceWeight(selected), total, servicePriority(selected), len(tier), n)
+	case "topology":
+		zone := serviceZone(selected)
+		if zone == "" {
+			zone = "none"
+		}
+		return fmt.Sprintf("zone %s chosen by topology among %d eligible instances", zone, n)
+	default:
+		return fmt.Sprintf("next in round-robin order among %d eligible instances", n)
+	}
+}
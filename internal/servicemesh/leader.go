@@ -0,0 +1,158 @@
+package servicemesh
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourusername/hbf-agent/internal/config"
+)
+
+// LeaderElector is implemented by discovery backends that can elect a
+// cluster leader
+type LeaderElector interface {
+	// Campaign blocks until leadership of key is acquired or ctx is done.
+	// The returned channel is closed when leadership is lost; cancelling
+	// ctx resigns.
+	Campaign(ctx context.Context, key, value string, ttl time.Duration) (<-chan struct{}, error)
+}
+
+// LeaderElection keeps campaigning for leadership through the discovery
+// backend and tracks whether this agent currently holds it
+type LeaderElection struct {
+	config    config.LeaderElectionConfig
+	log       *logrus.Logger
+	elector   LeaderElector
+	id        string
+	leader    bool
+	callbacks []func(leader bool)
+	cancel    context.CancelFunc
+	done      chan struct{}
+	mu        sync.RWMutex
+}
+
+// NewLeaderElection creates a leader election for the agent identified by
+// id. The discovery backend, or the primary of a CompositeDiscovery, must
+// implement LeaderElector.
+func NewLeaderElection(cfg config.LeaderElectionConfig, discovery Discovery, id string, log *logrus.Logger) (*LeaderElection, error) {
+	if composite, ok := discovery.(*CompositeDiscovery); ok {
+		discovery = composite.Primary()
+	}
+	elector, ok := discovery.(LeaderElector)
+	if !ok {
+		return nil, fmt.Errorf("discovery backend %T does not support leader election", discovery)
+	}
+	
+	return &LeaderElection{
+		config:  cfg,
+		log:     log,
+		elector: elector,
+		id:      id,
+	}, nil
+}
+
+// OnChange registers a callback invoked with the new role whenever
+// leadership is acquired or lost. Callbacks must not block.
+func (e *LeaderElection) OnChange(fn func(leader bool)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.callbacks = append(e.callbacks, fn)
+}
+
+// IsLeader reports whether this agent currently holds leadership
+func (e *LeaderElection) IsLeader() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.leader
+}
+
+// Start starts campaigning in the background
+func (e *LeaderElection) Start(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	
+	if e.cancel != nil {
+		return fmt.Errorf("leader election is already running")
+	}
+	
+	ctx, cancel := context.WithCancel(ctx)
+	e.cancel = cancel
+	e.done = make(chan struct{})
+	
+	e.log.Infof("Campaigning for leadership of %s as %s", e.config.Key, e.id)
+	go e.run(ctx, e.done)
+	
+	return nil
+}
+
+// Stop resigns leadership and stops campaigning
+func (e *LeaderElection) Stop() error {
+	e.mu.Lock()
+	cancel, done := e.cancel, e.done
+	e.cancel = nil
+	e.mu.Unlock()
+	
+	if cancel == nil {
+		return fmt.Errorf("leader election is not running")
+	}
+	
+	cancel()
+	<-done
+	return nil
+}
+
+func (e *LeaderElection) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	
+	for {
+		lost, err := e.elector.Campaign(ctx, e.config.Key, e.id, e.config.TTL)
+		if ctx.Err() != nil {
+			e.setLeader(false)
+			return
+		}
+		
+		if err != nil {
+			e.log.Errorf("Leader election failed, retrying: %v", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(e.config.TTL / 2):
+			}
+			continue
+		}
+		
+		e.setLeader(true)
+		
+		select {
+		case <-lost:
+			e.setLeader(false)
+		case <-ctx.Done():
+			e.setLeader(false)
+			return
+		}
+	}
+}
+
+// setLeader records the current role and notifies callbacks on change
+func (e *LeaderElection) setLeader(leader bool) {
+	e.mu.Lock()
+	if e.leader == leader {
+		e.mu.Unlock()
+		return
+	}
+	e.leader = leader
+	callbacks := append([]func(bool){}, e.callbacks...)
+	e.mu.Unlock()
+	
+	if leader {
+		e.log.Infof("Acquired leadership of %s", e.config.Key)
+	} else {
+		e.log.Warnf("Lost leadership of %s", e.config.Key)
+	}
+	
+	for _, fn := range callbacks {
+		fn(leader)
+	}
+}
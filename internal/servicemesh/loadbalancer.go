@@ -3,23 +3,74 @@ package servicemesh
 import (
 	"fmt"
 	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/sirupsen/logrus"
+	"github.com/yourusername/hbf-agent/internal/config"
 )
 
+// StrategyMetaKey is the service meta key selecting a load balancing
+// strategy for one service
+const StrategyMetaKey = "lb_strategy"
+
+const (
+	// WeightMetaKey is the service meta key holding the instance weight for
+	// the weighted strategies
+	WeightMetaKey = "weight"
+	// PriorityMetaKey is the service meta key holding the instance priority
+	// tier. As with SRV records, lower values are preferred.
+	PriorityMetaKey = "priority"
+)
+
+// serviceBalancer is a load balancer created for one service
+type serviceBalancer struct {
+	strategy string
+	balancer LoadBalancer
+}
+
 // RoundRobinLoadBalancer implements round-robin load balancing
 type RoundRobinLoadBalancer struct {
 	counter uint64
 	log     *logrus.Logger
 }
 
+// connTracker counts active connections per instance for the
+// connection-aware strategies. Selecting does not count: connections are
+// counted from Manager.Acquire until their Connection is closed, so
+// selections that never become connections (e.g. API lookups) do not skew
+// the counts.
+type connTracker struct {
+	connections map[string]int64
+	mu          sync.Mutex
+}
+
 // LeastConnLoadBalancer implements least-connection load balancing
 type LeastConnLoadBalancer struct {
-	connections map[string]int64
-	mu          sync.RWMutex
-	log         *logrus.Logger
+	*connTracker
+	log *logrus.Logger
+}
+
+// P2CLoadBalancer implements power-of-two-choices load balancing: it picks
+// two random instances and uses the one with fewer active connections
+type P2CLoadBalancer struct {
+	*connTracker
+	log *logrus.Logger
+}
+
+// LeastTimeLoadBalancer picks the instance with the lowest
+// exponentially-weighted moving average of observed latencies, breaking
+// ties by active connections. Instances without observations are tried
+// first.
+type LeastTimeLoadBalancer struct {
+	*connTracker
+	latency map[string]float64 // EWMA in seconds
+	decay   float64            // weight of the previous average
+	log     *logrus.Logger
 }
 
 // RandomLoadBalancer implements random load balancing
@@ -27,25 +78,74 @@ type RandomLoadBalancer struct {
 	log *logrus.Logger
 }
 
-// WeightedLoadBalancer implements weighted load balancing
+// WeightedLoadBalancer implements weighted random load balancing within the
+// most preferred priority tier
 type WeightedLoadBalancer struct {
 	log *logrus.Logger
 }
 
-// NewLoadBalancer creates a new load balancer based on strategy
-func NewLoadBalancer(strategy string, log *logrus.Logger) LoadBalancer {
+// SmoothWeightedLoadBalancer implements nginx-style smooth weighted round
+// robin, which interleaves selections evenly instead of clustering them.
+// Like WeightedLoadBalancer it only selects from the most preferred
+// priority tier.
+type SmoothWeightedLoadBalancer struct {
+	current map[string]int
+	key     string
+	mu      sync.Mutex
+	log     *logrus.Logger
+}
+
+// TopologyLoadBalancer keeps a fraction of requests in the local zone and
+// spills the rest to other zones, using smooth weighted round robin within
+// each zone. When one side has no instances, all requests go to the other.
+type TopologyLoadBalancer struct {
+	zone        string
+	localWeight float64
+	local       *SmoothWeightedLoadBalancer
+	remote      *SmoothWeightedLoadBalancer
+	log         *logrus.Logger
+}
+
+// NewLoadBalancer creates a new load balancer based on strategy. cfg
+// supplies strategy-specific settings.
+func NewLoadBalancer(strategy string, cfg config.LoadBalanceConfig, log *logrus.Logger) LoadBalancer {
 	switch strategy {
 	case "round_robin":
 		return &RoundRobinLoadBalancer{log: log}
 	case "least_conn":
 		return &LeastConnLoadBalancer{
-			connections: make(map[string]int64),
+			connTracker: newConnTracker(),
+			log:         log,
+		}
+	case "p2c":
+		return &P2CLoadBalancer{
+			connTracker: newConnTracker(),
+			log:         log,
+		}
+	case "least_time":
+		return &LeastTimeLoadBalancer{
+			connTracker: newConnTracker(),
+			latency:     make(map[string]float64),
+			decay:       cfg.EWMADecay,
 			log:         log,
 		}
 	case "random":
 		return &RandomLoadBalancer{log: log}
 	case "weighted":
 		return &WeightedLoadBalancer{log: log}
+	case "smooth_weighted_round_robin":
+		return &SmoothWeightedLoadBalancer{
+			current: make(map[string]int),
+			log:     log,
+		}
+	case "topology":
+		return &TopologyLoadBalancer{
+			zone:        cfg.Zone,
+			localWeight: cfg.LocalWeight,
+			local:       &SmoothWeightedLoadBalancer{current: make(map[string]int), log: log},
+			remote:      &SmoothWeightedLoadBalancer{current: make(map[string]int), log: log},
+			log:         log,
+		}
 	default:
 		return &RoundRobinLoadBalancer{log: log}
 	}
@@ -73,8 +173,8 @@ func (lb *LeastConnLoadBalancer) Select(services []*Service) (*Service, error) {
 		return nil, fmt.Errorf("no services available")
 	}
 	
-	lb.mu.RLock()
-	defer lb.mu.RUnlock()
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
 	
 	var selected *Service
 	minConn := int64(-1)
@@ -87,28 +187,115 @@ func (lb *LeastConnLoadBalancer) Select(services []*Service) (*Service, error) {
 		}
 	}
 	
-	if selected != nil {
-		lb.mu.RUnlock()
-		lb.mu.Lock()
-		lb.connections[selected.ID]++
-		lb.mu.Unlock()
-		lb.mu.RLock()
+	return selected, nil
+}
+
+func (lb *LeastConnLoadBalancer) UpdateStrategy(strategy string) error {
+	return fmt.Errorf("cannot change strategy on existing load balancer")
+}
+
+// P2CLoadBalancer implementation
+
+func (lb *P2CLoadBalancer) Select(services []*Service) (*Service, error) {
+	if len(services) == 0 {
+		return nil, fmt.Errorf("no services available")
+	}
+	
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	
+	selected := services[0]
+	if len(services) > 1 {
+		// Two distinct random indexes
+		i := rand.Intn(len(services))
+		j := rand.Intn(len(services) - 1)
+		if j >= i {
+			j++
+		}
+		
+		selected = services[i]
+		if lb.connections[services[j].ID] < lb.connections[selected.ID] {
+			selected = services[j]
+		}
+	}
+	
+	return selected, nil
+}
+
+func (lb *P2CLoadBalancer) UpdateStrategy(strategy string) error {
+	return fmt.Errorf("cannot change strategy on existing load balancer")
+}
+
+// LeastTimeLoadBalancer implementation
+
+func (lb *LeastTimeLoadBalancer) Select(services []*Service) (*Service, error) {
+	if len(services) == 0 {
+		return nil, fmt.Errorf("no services available")
+	}
+	
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	
+	selected := services[0]
+	for _, service := range services[1:] {
+		latency, best := lb.latency[service.ID], lb.latency[selected.ID]
+		if latency < best || (latency == best && lb.connections[service.ID] < lb.connections[selected.ID]) {
+			selected = service
+		}
 	}
 	
 	return selected, nil
 }
 
-func (lb *LeastConnLoadBalancer) UpdateStrategy(strategy string) error {
+func (lb *LeastTimeLoadBalancer) UpdateStrategy(strategy string) error {
 	return fmt.Errorf("cannot change strategy on existing load balancer")
 }
 
-func (lb *LeastConnLoadBalancer) ReleaseConnection(serviceID string) {
+// ObserveLatency folds a latency observation into the instance's average
+func (lb *LeastTimeLoadBalancer) ObserveLatency(serviceID string, latency time.Duration) {
 	lb.mu.Lock()
 	defer lb.mu.Unlock()
 	
-	if count, exists := lb.connections[serviceID]; exists && count > 0 {
-		lb.connections[serviceID]--
+	sample := latency.Seconds()
+	if avg, exists := lb.latency[serviceID]; exists {
+		sample = lb.decay*avg + (1-lb.decay)*sample
 	}
+	lb.latency[serviceID] = sample
+}
+
+// connTracker implementation
+
+func newConnTracker() *connTracker {
+	return &connTracker{connections: make(map[string]int64)}
+}
+
+// AcquireConnection records that a connection to the instance has started
+func (t *connTracker) AcquireConnection(serviceID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	
+	t.connections[serviceID]++
+}
+
+// ReleaseConnection records that a connection to the instance has ended
+func (t *connTracker) ReleaseConnection(serviceID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	
+	if count, exists := t.connections[serviceID]; exists && count > 1 {
+		t.connections[serviceID]--
+	} else {
+		delete(t.connections, serviceID)
+	}
+}
+
+// ForgetConnection drops the count of an instance, e.g. one that is gone
+// from discovery
+func (t *connTracker) ForgetConnection(serviceID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	
+	delete(t.connections, serviceID)
 }
 
 // RandomLoadBalancer implementation
@@ -133,12 +320,158 @@ func (lb *WeightedLoadBalancer) Select(services []*Service) (*Service, error) {
 		return nil, fmt.Errorf("no services available")
 	}
 	
-	// For now, treat all services with equal weight
-	// In production, you'd read weights from service metadata
-	index := rand.Intn(len(services))
-	return services[index], nil
+	services = priorityTier(services)
+	
+	total := 0
+	for _, service := range services {
+		total += serviceWeight(service)
+	}
+	
+	pick := rand.Intn(total)
+	for _, service := range services {
+		pick -= serviceWeight(service)
+		if pick < 0 {
+			return service, nil
+		}
+	}
+	return services[len(services)-1], nil
 }
 
 func (lb *WeightedLoadBalancer) UpdateStrategy(strategy string) error {
 	return fmt.Errorf("cannot change strategy on existing load balancer")
 }
+
+// SmoothWeightedLoadBalancer implementation
+
+func (lb *SmoothWeightedLoadBalancer) Select(services []*Service) (*Service, error) {
+	if len(services) == 0 {
+		return nil, fmt.Errorf("no services available")
+	}
+	
+	services = priorityTier(services)
+	
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	
+	// Start over whenever the instance set or a weight changes
+	if key := instanceSetKey(services); key != lb.key {
+		lb.key = key
+		lb.current = make(map[string]int, len(services))
+	}
+	
+	var selected *Service
+	total := 0
+	
+	for _, service := range services {
+		weight := serviceWeight(service)
+		total += weight
+		lb.current[service.ID] += weight
+		if selected == nil || lb.current[service.ID] > lb.current[selected.ID] {
+			selected = service
+		}
+	}
+	
+	lb.current[selected.ID] -= total
+	return selected, nil
+}
+
+func (lb *SmoothWeightedLoadBalancer) UpdateStrategy(strategy string) error {
+	return fmt.Errorf("cannot change strategy on existing load balancer")
+}
+
+// TopologyLoadBalancer implementation
+
+func (lb *TopologyLoadBalancer) Select(services []*Service) (*Service, error) {
+	if len(services) == 0 {
+		return nil, fmt.Errorf("no services available")
+	}
+	
+	var local, remote []*Service
+	for _, service := range services {
+		if serviceZone(service) == "" || serviceZone(service) == lb.zone {
+			local = append(local, service)
+		} else {
+			remote = append(remote, service)
+		}
+	}
+	
+	switch {
+	case len(local) == 0:
+		return lb.remote.Select(remote)
+	case len(remote) == 0:
+		return lb.local.Select(local)
+	case rand.Float64() < lb.localWeight:
+		return lb.local.Select(local)
+	default:
+		return lb.remote.Select(remote)
+	}
+}
+
+func (lb *TopologyLoadBalancer) UpdateStrategy(strategy string) error {
+	return fmt.Errorf("cannot change strategy on existing load balancer")
+}
+
+// serviceZone returns the zone of an instance from Meta["zone"], falling
+// back to Meta["datacenter"]
+func serviceZone(service *Service) string {
+	if zone := service.Meta["zone"]; zone != "" {
+		return zone
+	}
+	return service.Meta["datacenter"]
+}
+
+// serviceWeight returns the weight from Meta["weight"]. Missing, invalid and
+// non-positive weights count as 1.
+func serviceWeight(service *Service) int {
+	weight, err := strconv.Atoi(service.Meta[WeightMetaKey])
+	if err != nil || weight < 1 {
+		return 1
+	}
+	return weight
+}
+
+// servicePriority returns the priority tier from Meta["priority"]. Missing
+// and invalid priorities count as 0, the most preferred tier.
+func servicePriority(service *Service) int {
+	priority, err := strconv.Atoi(service.Meta[PriorityMetaKey])
+	if err != nil || priority < 0 {
+		return 0
+	}
+	return priority
+}
+
+// priorityTier returns the instances of the most preferred priority tier.
+// The instances passed to the balancers are the healthy ones, so lower
+// tiers are only used once every instance of the higher tiers is gone.
+func priorityTier(services []*Service) []*Service {
+	best := servicePriority(services[0])
+	mixed := false
+	for _, service := range services[1:] {
+		if priority := servicePriority(service); priority != best {
+			mixed = true
+			best = min(best, priority)
+		}
+	}
+	if !mixed {
+		return services
+	}
+	
+	tier := make([]*Service, 0, len(services))
+	for _, service := range services {
+		if servicePriority(service) == best {
+			tier = append(tier, service)
+		}
+	}
+	return tier
+}
+
+// instanceSetKey identifies a set of instances and their weights,
+// regardless of order
+func instanceSetKey(services []*Service) string {
+	entries := make([]string, len(services))
+	for i, service := range services {
+		entries[i] = fmt.Sprintf("%s=%d", service.ID, serviceWeight(service))
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
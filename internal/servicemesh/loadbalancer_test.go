@@ -0,0 +1,155 @@
+package servicemesh
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourusername/hbf-agent/internal/config"
+)
+
+// weighted returns a healthy instance with the given weight
+func weighted(id string, weight int) *Service {
+	return &Service{
+		ID:     id,
+		Name:   "web",
+		Status: StatusHealthy,
+		Meta:   map[string]string{WeightMetaKey: strconv.Itoa(weight)},
+	}
+}
+
+// selections returns the IDs of n selections, comma-separated
+func selections(t *testing.T, lb LoadBalancer, services []*Service, n int) string {
+	t.Helper()
+	ids := make([]string, n)
+	for i := range ids {
+		service, err := lb.Select(services)
+		if err != nil {
+			t.Fatalf("Select: %v", err)
+		}
+		ids[i] = service.ID
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestSmoothWeightedSequence(t *testing.T) {
+	tests := []struct {
+		name     string
+		services []*Service
+		want     string
+	}{
+		{
+			name:     "5/1/1 interleaves",
+			services: []*Service{weighted("a", 5), weighted("b", 1), weighted("c", 1)},
+			want:     "a,a,b,a,c,a,a,a,a,b,a,c,a,a",
+		},
+		{
+			name:     "equal weights rotate",
+			services: []*Service{weighted("a", 1), weighted("b", 1), weighted("c", 1)},
+			want:     "a,b,c,a,b,c",
+		},
+		{
+			name:     "3/2",
+			services: []*Service{weighted("a", 3), weighted("b", 2)},
+			want:     "a,b,a,b,a,a,b,a,b,a",
+		},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lb := NewLoadBalancer("smooth_weighted_round_robin", config.LoadBalanceConfig{}, logrus.New())
+			n := len(strings.Split(tt.want, ","))
+			if got := selections(t, lb, tt.services, n); got != tt.want {
+				t.Errorf("selections = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSmoothWeightedResetsOnInstanceSetChange(t *testing.T) {
+	lb := NewLoadBalancer("smooth_weighted_round_robin", config.LoadBalanceConfig{}, logrus.New())
+	a, b, c := weighted("a", 5), weighted("b", 1), weighted("c", 1)
+	
+	if got := selections(t, lb, []*Service{a, b, c}, 2); got != "a,a" {
+		t.Fatalf("selections = %s, want a,a", got)
+	}
+	
+	// Carried over, the current weights (a=-4, b=2) would select b next
+	if got := selections(t, lb, []*Service{a, b}, 6); got != "a,a,a,b,a,a" {
+		t.Errorf("after removing c: selections = %s, want a,a,a,b,a,a", got)
+	}
+	
+	// A weight change starts over too
+	b = weighted("b", 5)
+	if got := selections(t, lb, []*Service{a, b}, 4); got != "a,b,a,b" {
+		t.Errorf("after reweighting b: selections = %s, want a,b,a,b", got)
+	}
+}
+
+// benchmarkSelect measures selecting from a pool of 1000 instances with
+// active connections spread over them
+func benchmarkSelect(b *testing.B, strategy string) {
+	lb := NewLoadBalancer(strategy, config.LoadBalanceConfig{}, logrus.New())
+	counter := lb.(connectionCounter)
+	
+	services := make([]*Service, 1000)
+	for i := range services {
+		services[i] = weighted("web-"+strconv.Itoa(i), 1)
+		for j := 0; j < i%7; j++ {
+			counter.AcquireConnection(services[i].ID)
+		}
+	}
+	
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := lb.Select(services); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkP2C(b *testing.B) {
+	benchmarkSelect(b, "p2c")
+}
+
+func BenchmarkLeastConn(b *testing.B) {
+	benchmarkSelect(b, "least_conn")
+}
+
+func TestLeastTimePrefersFastInstances(t *testing.T) {
+	lb := NewLoadBalancer("least_time", config.LoadBalanceConfig{EWMADecay: 0.8}, logrus.New())
+	observer := lb.(latencyObserver)
+	
+	services := []*Service{weighted("slow", 1), weighted("medium", 1), weighted("fast", 1)}
+	base := map[string]time.Duration{
+		"slow":   200 * time.Millisecond,
+		"medium": 40 * time.Millisecond,
+		"fast":   20 * time.Millisecond,
+	}
+	
+	counts := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		service, err := lb.Select(services)
+		if err != nil {
+			t.Fatalf("Select: %v", err)
+		}
+		counts[service.ID]++
+		
+		// Latencies grow with the load an instance took, so that a loaded
+		// fast instance eventually loses to a less loaded slower one
+		latency := base[service.ID] + time.Duration(counts[service.ID]/10)*time.Millisecond
+		observer.ObserveLatency(service.ID, latency)
+	}
+	
+	// Instances without observations are tried first
+	for _, service := range services {
+		if counts[service.ID] == 0 {
+			t.Errorf("%s never selected: %v", service.ID, counts)
+		}
+	}
+	if !(counts["slow"] < counts["medium"] && counts["medium"] < counts["fast"]) {
+		t.Errorf("selections = %v, want fast > medium > slow", counts)
+	}
+}
@@ -0,0 +1,67 @@
+package servicemesh
+
+import (
+	"fmt"
+)
+
+// maintenanceStatus is the health status metric value of an instance in
+// maintenance, whatever its health
+const maintenanceStatus = "maintenance"
+
+// SetMaintenance puts an instance into or takes it out of maintenance. An
+// instance in maintenance stays registered with its metadata but receives
+// no traffic, regardless of its health, until maintenance is cleared.
+func (m *Manager) SetMaintenance(serviceID string, enabled bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	service, exists := m.services[serviceID]
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrServiceNotFound, serviceID)
+	}
+	
+	if service.Maintenance == enabled {
+		return nil
+	}
+	
+	service.Maintenance = enabled
+	if enabled {
+		m.log.Infof("Service %s (%s) entered maintenance", service.Name, service.ID)
+	} else {
+		m.log.Infof("Service %s (%s) left maintenance", service.Name, service.ID)
+	}
+	m.recordStatusLocked(service)
+	m.persistLocked()
+	m.publish(EventStatusChanged, service)
+	
+	return nil
+}
+
+// inMaintenance reports whether an instance, or the locally registered
+// instance with its ID, is in maintenance. Discovered instances are fresh
+// copies from the backend, so the local flag is authoritative.
+func (m *Manager) inMaintenance(service *Service) bool {
+	if service.Maintenance {
+		return true
+	}
+	
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	local, exists := m.services[service.ID]
+	return exists && local.Maintenance
+}
+
+// recordStatusLocked updates the health status metric of a registered
+// instance. Callers must hold m.mu.
+func (m *Manager) recordStatusLocked(service *Service) {
+	if m.metrics == nil {
+		return
+	}
+	
+	status := string(service.Status)
+	if service.Maintenance {
+		status = maintenanceStatus
+	}
+	m.metrics.SetServiceHealthStatus(service.Name, service.ID, status)
+}
@@ -2,12 +2,38 @@ package servicemesh
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"maps"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"github.com/yourusername/hbf-agent/internal/audit"
 	"github.com/yourusername/hbf-agent/internal/config"
+	"github.com/yourusername/hbf-agent/internal/events"
+	"github.com/yourusername/hbf-agent/internal/idgen"
+	"github.com/yourusername/hbf-agent/internal/logging"
+	"github.com/yourusername/hbf-agent/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	// ErrServiceNotFound is returned for operations on unknown services
+	ErrServiceNotFound = errors.New("service not found")
+	// ErrAlreadyRegistered is returned when registering a service whose ID
+	// is taken
+	ErrAlreadyRegistered = errors.New("service already registered")
+	// ErrInvalidService wraps service validation failures
+	ErrInvalidService = errors.New("invalid service")
 )
 
 // Manager manages service mesh functionality
@@ -16,10 +42,42 @@ type Manager struct {
 	log         *logrus.Logger
 	discovery   Discovery
 	loadBalance LoadBalancer
+	balancers   map[string]*serviceBalancer
 	services    map[string]*Service
+	proxy       *Proxy
+	metrics     MetricsRecorder
+	isLeader    func() bool
+	policy      RegistrationPolicy
+	outliers    *outlierDetector
+	breakers    *circuitBreaker
+	affinity    *affinityTable
+	cache       *discoveryCache
+	active      *activeConns
+	events      *events.Bus[Event]
+	audit       *audit.Logger
+	store       Store
+	newID       idgen.Generator
 	mu          sync.RWMutex
-	stopChan    chan struct{}
+	registering sync.Mutex // serializes registrations, see RegisterServiceContext
+	// stopChan is the Done channel of the context the loops run with, closed
+	// when the context of Start is cancelled or Stop is called. It is nil,
+	// never closing, until Start.
+	stopChan    <-chan struct{}
+	cancel      context.CancelFunc
+	loops       sync.WaitGroup // the loops started by Start
+	ready       chan struct{} // closed after the first successful sync
+	readyOnce   sync.Once
 	running     bool
+	syncErr     error
+	pingErr     error
+	failures    int // consecutive failed discovery syncs
+	syncing     atomic.Bool // a discovery sync is running
+	
+	// Discovery watches of the locally registered service names
+	watches          map[string]*serviceWatch
+	watching         bool // every name is watched; sync only as a keepalive
+	watchUnsupported bool
+	watchWake        chan struct{}
 }
 
 // Service represents a registered service
@@ -32,6 +90,12 @@ type Service struct {
 	Meta        map[string]string
 	HealthCheck *HealthCheck
 	Status      ServiceStatus
+	// Maintenance takes the instance out of rotation without deregistering
+	// it; see Manager.SetMaintenance
+	Maintenance bool
+	// Stale marks instances served from the discovery cache while the
+	// discovery backend is failing
+	Stale       bool
 	RegisteredAt time.Time
 	LastSeen    time.Time
 }
@@ -42,15 +106,90 @@ type ServiceStatus string
 const (
 	StatusHealthy   ServiceStatus = "healthy"
 	StatusUnhealthy ServiceStatus = "unhealthy"
+	// StatusWarning instances pass their checks with warnings; whether they
+	// receive traffic depends on load_balance.warning_policy
+	StatusWarning   ServiceStatus = "warning"
 	StatusUnknown   ServiceStatus = "unknown"
+	StatusDraining  ServiceStatus = "draining"
 )
 
+// Eligible reports whether the instance may receive traffic. Only
+// StatusHealthy instances are eligible; StatusUnhealthy and StatusUnknown
+// (registered but not yet checked) instances are not, and neither are
+// instances in maintenance.
+func (s *Service) Eligible() bool {
+	return s.Status == StatusHealthy && !s.Maintenance
+}
+
+// Degraded reports whether the instance warns but may still serve traffic
+// under load_balance.warning_policy
+func (s *Service) Degraded() bool {
+	return s.Status == StatusWarning && !s.Maintenance
+}
+
+// Matches reports whether the instance carries all of tags and all
+// key/value pairs of meta
+func (s *Service) Matches(tags []string, meta map[string]string) bool {
+	for _, tag := range tags {
+		if !hasTag(s, tag) {
+			return false
+		}
+	}
+	for key, value := range meta {
+		if s.Meta[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// DiscoverOptions controls how DiscoverServiceWithOptions filters instances
+type DiscoverOptions struct {
+	// HealthyOnly restricts the result to eligible instances
+	HealthyOnly bool
+}
+
 // HealthCheck represents a health check configuration
 type HealthCheck struct {
 	Type     string        // http, tcp, grpc
 	Endpoint string
 	Interval time.Duration
 	Timeout  time.Duration
+	// DeregisterCriticalServiceAfter deregisters the service once its
+	// check has been critical for this long; zero disables it
+	DeregisterCriticalServiceAfter time.Duration
+}
+
+// CheckTarget returns what the health check of an instance probes, resolving
+// the endpoint against the instance address: an http endpoint is a path on
+// the instance, other checks probe the instance port or the port given as
+// endpoint. Endpoints that are full URLs or host:port pairs are kept. It
+// returns false if the instance has no check of a type that probes a target.
+func (s *Service) CheckTarget() (string, bool) {
+	hc := s.HealthCheck
+	if hc == nil {
+		return "", false
+	}
+	
+	hostPort := net.JoinHostPort(s.Address, strconv.Itoa(s.Port))
+	switch hc.Type {
+	case "http":
+		if strings.Contains(hc.Endpoint, "://") {
+			return hc.Endpoint, true
+		}
+		return "http://" + hostPort + "/" + strings.TrimPrefix(hc.Endpoint, "/"), true
+	case "tcp", "udp", "grpc":
+		switch {
+		case hc.Endpoint == "":
+			return hostPort, true
+		case strings.Contains(hc.Endpoint, ":"):
+			return hc.Endpoint, true
+		default:
+			return net.JoinHostPort(s.Address, hc.Endpoint), true
+		}
+	default:
+		return "", false
+	}
 }
 
 // Discovery interface for service discovery
@@ -59,6 +198,15 @@ type Discovery interface {
 	Deregister(serviceID string) error
 	Discover(serviceName string) ([]*Service, error)
 	Watch(ctx context.Context, serviceName string) (<-chan []*Service, error)
+	// Ping checks that the backend is reachable
+	Ping(ctx context.Context) error
+}
+
+// RemoteDiscovery is implemented by discovery backends whose Discover only
+// returns the instances of the configured datacenter. DiscoverRemote returns
+// the instances in the other datacenters, for the prefer_local fallback.
+type RemoteDiscovery interface {
+	DiscoverRemote(serviceName string) ([]*Service, error)
 }
 
 // LoadBalancer interface for load balancing
@@ -76,19 +224,130 @@ func NewManager(cfg config.ServiceMeshConfig, log *logrus.Logger) (*Manager, err
 	}
 	
 	// Create load balancer
-	loadBalance := NewLoadBalancer(cfg.LoadBalance.Strategy, log)
+	loadBalance := NewLoadBalancer(cfg.LoadBalance.Strategy, cfg.LoadBalance, log)
 	
-	return &Manager{
+	m := &Manager{
 		config:      cfg,
 		log:         log,
 		discovery:   discovery,
 		loadBalance: loadBalance,
+		balancers:   make(map[string]*serviceBalancer),
 		services:    make(map[string]*Service),
-		stopChan:    make(chan struct{}),
-	}, nil
+		outliers:    newOutlierDetector(cfg.OutlierDetection, log),
+		breakers:    newCircuitBreaker(cfg.CircuitBreaker, log),
+		affinity:    newAffinityTable(cfg.LoadBalance.AffinityTTL),
+		cache:       newDiscoveryCache(cfg.Discovery.CacheMaxAge),
+		active:      newActiveConns(),
+		events:      events.NewBus[Event]("service", log),
+		ready:       make(chan struct{}),
+		newID:       idgen.Random(),
+		watches:     make(map[string]*serviceWatch),
+		watchWake:   make(chan struct{}, 1),
+	}
+	
+	if cfg.ProxyPort > 0 {
+		m.proxy = NewProxy(cfg, m, log)
+	}
+	
+	if cfg.StateFile != "" {
+		m.store = NewFileStore(cfg.StateFile)
+	}
+	
+	return m, nil
+}
+
+// SetMetrics sets the recorder used for data-plane metrics
+func (m *Manager) SetMetrics(metrics MetricsRecorder) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.metrics = metrics
+}
+
+// SetAuditLog sets the audit log recording service registrations. It must
+// be called before Start.
+func (m *Manager) SetAuditLog(auditLog *audit.Logger) {
+	m.audit = auditLog
+}
+
+// SetIDGenerator replaces the generator of service IDs, e.g. with
+// idgen.Sequence for deterministic IDs in tests. It must be called before
+// services are registered.
+func (m *Manager) SetIDGenerator(newID idgen.Generator) {
+	m.newID = newID
 }
 
-// Start starts the service mesh manager
+// metricsRecorder returns the configured metrics recorder, if any
+func (m *Manager) metricsRecorder() MetricsRecorder {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.metrics
+}
+
+// Reload applies a new configuration in place: load balancing strategy and
+// locality, retries, circuit breaker and outlier detection take effect for
+// subsequent requests. Discovery and listener settings cannot be changed
+// without a restart.
+func (m *Manager) Reload(cfg config.ServiceMeshConfig) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	if !reflect.DeepEqual(cfg.Discovery, m.config.Discovery) {
+		return fmt.Errorf("changing discovery settings requires a restart")
+	}
+	
+	if cfg.BindAddress != m.config.BindAddress || cfg.ProxyPort != m.config.ProxyPort || cfg.AdminPort != m.config.AdminPort {
+		return fmt.Errorf("changing proxy listener settings requires a restart")
+	}
+	
+	if cfg.StateFile != m.config.StateFile {
+		return fmt.Errorf("changing service_mesh.state_file requires a restart")
+	}
+	
+	// Strategy settings are baked into the balancers, so changing them
+	// rebuilds the balancers too
+	tuned := cfg.LoadBalance.EWMADecay != m.config.LoadBalance.EWMADecay ||
+		cfg.LoadBalance.Zone != m.config.LoadBalance.Zone ||
+		cfg.LoadBalance.LocalWeight != m.config.LoadBalance.LocalWeight
+	
+	if cfg.LoadBalance.Strategy != m.config.LoadBalance.Strategy || tuned {
+		m.loadBalance = NewLoadBalancer(cfg.LoadBalance.Strategy, cfg.LoadBalance, m.log)
+		m.log.Infof("Switched load balancing strategy from %s to %s",
+			m.config.LoadBalance.Strategy, cfg.LoadBalance.Strategy)
+	}
+	
+	if !maps.Equal(cfg.LoadBalance.Services, m.config.LoadBalance.Services) || tuned {
+		m.balancers = make(map[string]*serviceBalancer)
+	}
+	
+	m.breakers.setConfig(cfg.CircuitBreaker)
+	m.outliers.setConfig(cfg.OutlierDetection)
+	m.affinity.setTTL(cfg.LoadBalance.AffinityTTL)
+	m.config = cfg
+	
+	return nil
+}
+
+// SetLeaderCheck makes periodic discovery sync conditional on leadership, so
+// that only the leader writes to the shared discovery backend. Reads are not
+// affected.
+func (m *Manager) SetLeaderCheck(isLeader func() bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.isLeader = isLeader
+}
+
+// Discovery returns the discovery backend
+func (m *Manager) Discovery() Discovery {
+	return m.discovery
+}
+
+// Proxy returns the data-plane proxy, or nil if it is disabled
+func (m *Manager) Proxy() *Proxy {
+	return m.proxy
+}
+
+// Start starts the service mesh manager. Its loops run until ctx is
+// cancelled or Stop is called; the manager can be started again after Stop.
 func (m *Manager) Start(ctx context.Context) error {
 	m.mu.Lock()
 	if m.running {
@@ -96,17 +355,49 @@ func (m *Manager) Start(ctx context.Context) error {
 		return fmt.Errorf("service mesh manager is already running")
 	}
 	m.running = true
+	ctx, cancel := context.WithCancel(ctx)
+	m.cancel = cancel
+	m.stopChan = ctx.Done()
 	m.mu.Unlock()
 	
 	m.log.Info("Starting service mesh manager...")
 	
-	// Start discovery sync loop
-	go m.discoveryLoop(ctx)
+	// A failure to restore must not keep the agent down; new registrations
+	// replace the unreadable state
+	if err := m.restore(); err != nil {
+		m.log.Errorf("Failed to restore registered services: %v", err)
+	}
+	
+	// Start data-plane proxy
+	if m.proxy != nil {
+		if err := m.proxy.Start(ctx); err != nil {
+			cancel()
+			m.mu.Lock()
+			m.running = false
+			m.mu.Unlock()
+			return fmt.Errorf("failed to start proxy: %w", err)
+		}
+	}
+	
+	// Sync once before returning so that the manager is usually ready by the
+	// time Start returns; the loop keeps retrying if this pass fails
+	m.syncDiscovery()
+	m.reconcileWatches(ctx)
+	m.loops.Add(2)
+	go func() {
+		defer m.loops.Done()
+		m.discoveryLoop(ctx)
+	}()
+	go func() {
+		defer m.loops.Done()
+		m.reapLoop(ctx)
+	}()
 	
 	return nil
 }
 
-// Stop stops the service mesh manager
+// Stop stops the service mesh manager, cancelling its loops and waiting for
+// them to return before deregistering the local services
 func (m *Manager) Stop() error {
 	m.mu.Lock()
 	if !m.running {
@@ -114,8 +405,20 @@ func (m *Manager) Stop() error {
 		return fmt.Errorf("service mesh manager is not running")
 	}
 	m.running = false
+	m.stopWatchesLocked()
+	cancel := m.cancel
 	m.mu.Unlock()
 	
+	cancel()
+	m.loops.Wait()
+	
+	// Stop data-plane proxy
+	if m.proxy != nil {
+		if err := m.proxy.Stop(); err != nil {
+			m.log.Errorf("Failed to stop proxy: %v", err)
+		}
+	}
+	
 	// Deregister all services
 	m.mu.RLock()
 	for _, service := range m.services {
@@ -125,55 +428,219 @@ func (m *Manager) Stop() error {
 	}
 	m.mu.RUnlock()
 	
-	close(m.stopChan)
+	// Release discovery backend resources such as file watchers
+	if closer, ok := m.discovery.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			m.log.Errorf("Failed to close discovery backend: %v", err)
+		}
+	}
+	
 	m.log.Info("Service mesh manager stopped")
 	
 	return nil
 }
 
-// RegisterService registers a new service
+// RegisterService registers a new service. The service must pass
+// Service.Validate and the registration policy, if one is set.
 func (m *Manager) RegisterService(service *Service) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	return m.RegisterServiceContext(context.Background(), service)
+}
+
+// RegisterServiceContext registers a service, logging the request ID from ctx
+func (m *Manager) RegisterServiceContext(ctx context.Context, service *Service) error {
+	ctx, span := tracing.Start(ctx, "servicemesh.RegisterService",
+		trace.WithAttributes(attribute.String("service.name", service.Name)))
+	defer span.End()
 	
-	if service.ID == "" {
-		service.ID = generateServiceID(service.Name)
+	if err := service.Validate(); err != nil {
+		return tracing.Fail(span, fmt.Errorf("%w: %w", ErrInvalidService, err))
 	}
 	
-	service.RegisteredAt = time.Now()
+	// The backend call is made on a copy without holding m.mu, so that a
+	// slow backend does not block selections. Registrations are serialized
+	// instead, so that the instance found here is still current when the
+	// result is committed.
+	m.registering.Lock()
+	defer m.registering.Unlock()
+	
+	m.mu.RLock()
+	policy := m.policy
+	metrics := m.metrics
+	backend := m.config.Discovery.Backend
+	m.mu.RUnlock()
+	
+	if policy != nil {
+		if err := policy(service); err != nil {
+			return tracing.Fail(span, fmt.Errorf("%w: rejected by policy: %w", ErrInvalidService, err))
+		}
+	}
+	
+	m.mu.RLock()
+	existing, err := m.findRegisteredLocked(service)
+	if err != nil {
+		m.mu.RUnlock()
+		return tracing.Fail(span, err)
+	}
+	if existing != nil {
+		service.ID = existing.ID
+		service.RegisteredAt = existing.RegisteredAt
+		// A restart during maintenance must not return the instance to
+		// rotation
+		service.Maintenance = service.Maintenance || existing.Maintenance
+	} else {
+		if service.ID == "" {
+			service.ID = m.generateServiceID(service.Name)
+		}
+		service.RegisteredAt = time.Now()
+	}
+	m.mu.RUnlock()
 	service.LastSeen = time.Now()
 	service.Status = StatusUnknown
 	
-	if err := m.discovery.Register(service); err != nil {
-		return fmt.Errorf("failed to register service: %w", err)
+	snapshot := *service
+	_, discoverySpan := tracing.Start(ctx, "discovery.Register")
+	err = m.discovery.Register(&snapshot)
+	tracing.Fail(discoverySpan, err)
+	discoverySpan.End()
+	if err != nil {
+		recordDiscoveryFailure(metrics, backend, "register", err)
+		return tracing.Fail(span, fmt.Errorf("failed to register service: %w", err))
 	}
 	
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	// Maintenance may have been started while the backend was called
+	if current, ok := m.services[service.ID]; ok {
+		service.Maintenance = service.Maintenance || current.Maintenance
+	}
 	m.services[service.ID] = service
-	m.log.Infof("Registered service: %s (%s)", service.Name, service.ID)
+	m.recordStatusLocked(service)
+	m.persistLocked()
+	if existing != nil {
+		logging.Entry(ctx, m.log).Infof("Updated registered service: %s (%s)", service.Name, service.ID)
+	} else {
+		logging.Entry(ctx, m.log).Infof("Registered service: %s (%s)", service.Name, service.ID)
+	}
+	m.audit.Record(ctx, audit.ActionServiceRegister, service)
+	m.publish(EventRegistered, service)
+	m.wakeWatches()
 	
 	return nil
 }
 
 // DeregisterService deregisters a service
 func (m *Manager) DeregisterService(serviceID string) error {
+	return m.DeregisterServiceContext(context.Background(), serviceID)
+}
+
+// DeregisterServiceContext deregisters a service, logging the request ID
+// from ctx. An instance with active connections is drained first: it stops
+// receiving new requests and is removed once idle or after
+// service_mesh.drain_timeout.
+func (m *Manager) DeregisterServiceContext(ctx context.Context, serviceID string) error {
+	ctx, span := tracing.Start(ctx, "servicemesh.DeregisterService",
+		trace.WithAttributes(attribute.String("service.id", serviceID)))
+	defer span.End()
+	
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	
 	service, exists := m.services[serviceID]
 	if !exists {
-		return fmt.Errorf("service not found: %s", serviceID)
+		return tracing.Fail(span, fmt.Errorf("%w: %s", ErrServiceNotFound, serviceID))
+	}
+	
+	return tracing.Fail(span, m.removeLocked(ctx, service))
+}
+
+// DeregisterServiceByName deregisters every local instance of a service
+func (m *Manager) DeregisterServiceByName(name string) (int, error) {
+	return m.DeregisterServiceByNameContext(context.Background(), name)
+}
+
+// DeregisterServiceByNameContext deregisters every local instance of a
+// service and returns how many were removed or are draining. Instances that
+// fail to deregister are kept and reported together in the returned error.
+func (m *Manager) DeregisterServiceByNameContext(ctx context.Context, name string) (int, error) {
+	ctx, span := tracing.Start(ctx, "servicemesh.DeregisterServiceByName",
+		trace.WithAttributes(attribute.String("service.name", name)))
+	defer span.End()
+	
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	removed := 0
+	var errs []error
+	for _, service := range m.services {
+		if service.Name != name {
+			continue
+		}
+		if err := m.removeLocked(ctx, service); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", service.ID, err))
+			continue
+		}
+		removed++
 	}
 	
-	if err := m.discovery.Deregister(serviceID); err != nil {
+	span.SetAttributes(attribute.Int("service.removed", removed))
+	return removed, tracing.Fail(span, errors.Join(errs...))
+}
+
+// deregisterLocked removes a service from the discovery backend and the
+// local registry. Callers must hold m.mu.
+func (m *Manager) deregisterLocked(ctx context.Context, service *Service) error {
+	_, discoverySpan := tracing.Start(ctx, "discovery.Deregister")
+	err := m.discovery.Deregister(service.ID)
+	tracing.Fail(discoverySpan, err)
+	discoverySpan.End()
+	if err != nil {
+		recordDiscoveryFailure(m.metrics, m.config.Discovery.Backend, "deregister", err)
 		return fmt.Errorf("failed to deregister service: %w", err)
 	}
 	
-	delete(m.services, serviceID)
-	m.log.Infof("Deregistered service: %s (%s)", service.Name, serviceID)
+	delete(m.services, service.ID)
+	m.persistLocked()
+	if m.metrics != nil {
+		m.metrics.DeleteServiceHealthStatus(service.Name, service.ID)
+	}
+	m.outliers.forget(service.ID)
+	m.breakers.forget(service.ID)
+	logging.Entry(ctx, m.log).Infof("Deregistered service: %s (%s)", service.Name, service.ID)
+	m.audit.Record(ctx, audit.ActionServiceDeregister, service)
+	m.publish(EventDeregistered, service)
+	m.wakeWatches()
 	
 	return nil
 }
 
+// findRegisteredLocked returns the registered instance that a registration
+// repeats, if any. With idempotent registration, a registration repeats the
+// instance with its ID or, without an ID, the instance with its name,
+// address and port; otherwise re-using an ID fails. Callers must hold m.mu.
+func (m *Manager) findRegisteredLocked(service *Service) (*Service, error) {
+	if service.ID != "" {
+		existing, exists := m.services[service.ID]
+		if !exists {
+			return nil, nil
+		}
+		if !m.config.IdempotentRegistration {
+			return nil, fmt.Errorf("%w: %s", ErrAlreadyRegistered, service.ID)
+		}
+		return existing, nil
+	}
+	
+	if !m.config.IdempotentRegistration {
+		return nil, nil
+	}
+	for _, existing := range m.services {
+		if existing.Name == service.Name && existing.Address == service.Address && existing.Port == service.Port {
+			return existing, nil
+		}
+	}
+	return nil, nil
+}
+
 // GetService returns a service by ID
 func (m *Manager) GetService(serviceID string) (*Service, error) {
 	m.mu.RLock()
@@ -181,7 +648,7 @@ func (m *Manager) GetService(serviceID string) (*Service, error) {
 	
 	service, exists := m.services[serviceID]
 	if !exists {
-		return nil, fmt.Errorf("service not found: %s", serviceID)
+		return nil, fmt.Errorf("%w: %s", ErrServiceNotFound, serviceID)
 	}
 	
 	return service, nil
@@ -200,40 +667,417 @@ func (m *Manager) ListServices() []*Service {
 	return services
 }
 
-// DiscoverService discovers instances of a service
+// ServiceFilter selects and pages services for ListServicesFiltered. Empty
+// fields match everything; a zero Limit returns all remaining services.
+type ServiceFilter struct {
+	Status ServiceStatus
+	Tag    string
+	Limit  int
+	Offset int
+}
+
+// ListServicesFiltered returns the registered services matching filter,
+// ordered by name and ID, along with the total number of matches before
+// paging
+func (m *Manager) ListServicesFiltered(filter ServiceFilter) ([]*Service, int) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	matched := make([]*Service, 0, len(m.services))
+	for _, service := range m.services {
+		if filter.Status != "" && service.Status != filter.Status {
+			continue
+		}
+		if filter.Tag != "" && !hasTag(service, filter.Tag) {
+			continue
+		}
+		matched = append(matched, service)
+	}
+	
+	sort.Slice(matched, func(i, j int) bool {
+		if matched[i].Name != matched[j].Name {
+			return matched[i].Name < matched[j].Name
+		}
+		return matched[i].ID < matched[j].ID
+	})
+	
+	total := len(matched)
+	if filter.Offset >= total {
+		return []*Service{}, total
+	}
+	matched = matched[filter.Offset:]
+	if filter.Limit > 0 && filter.Limit < len(matched) {
+		matched = matched[:filter.Limit]
+	}
+	
+	return matched, total
+}
+
+// hasTag reports whether a service carries a tag
+func hasTag(service *Service, tag string) bool {
+	for _, t := range service.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// DiscoverService discovers all instances of a service as reported by the
+// discovery backend, regardless of their health
 func (m *Manager) DiscoverService(serviceName string) ([]*Service, error) {
+	return m.DiscoverServiceContext(context.Background(), serviceName)
+}
+
+// DiscoverServiceContext discovers service instances, tracing the discovery
+// backend call as a child of any span in ctx. While the backend is failing
+// the last successful result is served, with its instances marked Stale,
+// for up to discovery.cache_max_age.
+func (m *Manager) DiscoverServiceContext(ctx context.Context, serviceName string) ([]*Service, error) {
+	_, span := tracing.Start(ctx, "discovery.Discover",
+		trace.WithAttributes(attribute.String("service.name", serviceName)))
+	defer span.End()
+	
+	m.mu.RLock()
+	metrics := m.metrics
+	backend := m.config.Discovery.Backend
+	m.mu.RUnlock()
+	
 	services, err := m.discovery.Discover(serviceName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to discover service: %w", err)
+		recordDiscoveryFailure(metrics, backend, "discover", err)
+		cached, age, ok := m.cache.lookup(serviceName)
+		if !ok {
+			return nil, tracing.Fail(span, fmt.Errorf("failed to discover service: %w", err))
+		}
+		
+		m.log.Warnf("Discovery of %s failed, serving %d cached instances from %s ago: %v",
+			serviceName, len(cached), age.Round(time.Second), err)
+		if metrics != nil {
+			metrics.RecordDiscoveryCacheServed(serviceName)
+		}
+		span.SetAttributes(attribute.Int("service.instances", len(cached)), attribute.Bool("discovery.stale", true))
+		return cached, nil
 	}
 	
+	m.cache.store(serviceName, services)
+	span.SetAttributes(attribute.Int("service.instances", len(services)))
 	return services, nil
 }
 
+// DiscoverHealthy discovers the instances of a service that are eligible
+// for traffic (see Service.Eligible)
+func (m *Manager) DiscoverHealthy(serviceName string) ([]*Service, error) {
+	return m.DiscoverServiceWithOptions(serviceName, DiscoverOptions{HealthyOnly: true})
+}
+
+// DiscoverServiceWithOptions discovers instances of a service, filtered
+// according to opts
+func (m *Manager) DiscoverServiceWithOptions(serviceName string, opts DiscoverOptions) ([]*Service, error) {
+	services, err := m.DiscoverService(serviceName)
+	if err != nil {
+		return nil, err
+	}
+	
+	if !opts.HealthyOnly {
+		return services, nil
+	}
+	
+	healthy := make([]*Service, 0, len(services))
+	for _, service := range services {
+		if service.Eligible() && !m.inMaintenance(service) {
+			healthy = append(healthy, service)
+		}
+	}
+	
+	return healthy, nil
+}
+
 // SelectService selects a service instance using load balancing
 func (m *Manager) SelectService(serviceName string) (*Service, error) {
-	services, err := m.DiscoverService(serviceName)
+	return m.SelectServiceContext(context.Background(), serviceName)
+}
+
+// SelectServiceContext selects a service instance, tracing the selection as
+// a child of any span in ctx
+func (m *Manager) SelectServiceContext(ctx context.Context, serviceName string) (*Service, error) {
+	return m.SelectServiceFilteredContext(ctx, serviceName, nil, nil)
+}
+
+// SelectServiceFiltered selects a service instance among those carrying all
+// of tags and all key/value pairs of metaMatch, e.g. to route only to the v2
+// instances during a canary. Empty filters select among all instances.
+func (m *Manager) SelectServiceFiltered(serviceName string, tags []string, metaMatch map[string]string) (*Service, error) {
+	return m.SelectServiceFilteredContext(context.Background(), serviceName, tags, metaMatch)
+}
+
+// SelectServiceFilteredContext is SelectServiceFiltered, tracing the
+// selection as a child of any span in ctx
+func (m *Manager) SelectServiceFilteredContext(ctx context.Context, serviceName string, tags []string, metaMatch map[string]string) (*Service, error) {
+	ctx, span := tracing.Start(ctx, "servicemesh.SelectService",
+		trace.WithAttributes(attribute.String("service.name", serviceName)))
+	defer span.End()
+	
+	candidates, err := m.candidates(ctx, serviceName)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	
+	candidates, err = matchFilter(serviceName, candidates, tags, metaMatch, nil)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	
+	// Services with a configured traffic split select through it
+	if split := m.serviceSplit(serviceName); len(split) > 0 {
+		service, bucket, err := m.selectSplit(serviceName, candidates, split)
+		if err != nil {
+			return nil, tracing.Fail(span, err)
+		}
+		span.SetAttributes(attribute.String("service.id", service.ID), attribute.String("service.bucket", bucket))
+		return service, nil
+	}
+	
+	service, err := m.selectInstance(serviceName, candidates)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	
+	span.SetAttributes(attribute.String("service.id", service.ID))
+	return service, nil
+}
+
+// matchFilter returns the candidates carrying all of tags and all key/value
+// pairs of metaMatch. If excluded is not nil, it receives the reason each
+// dropped instance was dropped, by instance ID.
+func matchFilter(serviceName string, candidates []*Service, tags []string, metaMatch map[string]string, excluded map[string]string) ([]*Service, error) {
+	if len(tags) == 0 && len(metaMatch) == 0 {
+		return candidates, nil
+	}
+	
+	matching := make([]*Service, 0, len(candidates))
+	for _, service := range candidates {
+		if service.Matches(tags, metaMatch) {
+			matching = append(matching, service)
+		}
+	}
+	recordExcluded(excluded, candidates, matching, func(*Service) string { return "does not match the tag and meta filter" })
+	
+	if len(matching) == 0 {
+		return nil, fmt.Errorf("no available instances of service %s match the filter", serviceName)
+	}
+	return matching, nil
+}
+
+// balancerFor returns the load balancer for a service. The strategy is
+// taken from load_balance.services, then from the lb_strategy meta of the
+// instances, falling back to the global load balancer. Per-service balancers
+// are created on first use and replaced when the strategy changes.
+func (m *Manager) balancerFor(serviceName string, services []*Service) LoadBalancer {
+	balancer, _ := m.resolveBalancer(serviceName, services)
+	return balancer
+}
+
+// resolveBalancer is balancerFor, also returning the strategy in effect
+func (m *Manager) resolveBalancer(serviceName string, services []*Service) (LoadBalancer, string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	strategy := m.config.LoadBalance.Services[serviceName]
+	if strategy == "" {
+		for _, service := range services {
+			if s := service.Meta[StrategyMetaKey]; config.Strategies[s] {
+				strategy = s
+				break
+			}
+		}
+	}
+	
+	if strategy == "" {
+		delete(m.balancers, serviceName)
+		return m.loadBalance, m.config.LoadBalance.Strategy
+	}
+	
+	if sb, exists := m.balancers[serviceName]; exists && sb.strategy == strategy {
+		return sb.balancer, strategy
+	}
+	
+	sb := &serviceBalancer{strategy: strategy, balancer: NewLoadBalancer(strategy, m.config.LoadBalance, m.log)}
+	m.balancers[serviceName] = sb
+	return sb.balancer, strategy
+}
+
+// selectInstance picks one of the candidates with the service's load
+// balancer and records the selection
+func (m *Manager) selectInstance(serviceName string, candidates []*Service) (*Service, error) {
+	balancer, strategy := m.resolveBalancer(serviceName, candidates)
+	service, err := balancer.Select(candidates)
 	if err != nil {
 		return nil, err
 	}
 	
-	if len(services) == 0 {
-		return nil, fmt.Errorf("no instances found for service: %s", serviceName)
+	m.recordSelection(serviceName, service.ID, strategy)
+	return service, nil
+}
+
+// recordSelection counts a selected instance in the load balancing metrics
+func (m *Manager) recordSelection(serviceName, serviceID, strategy string) {
+	if rec := m.metricsRecorder(); rec != nil {
+		rec.RecordLBSelection(serviceName, serviceID, strategy)
+	}
+}
+
+// noEligible counts a selection without eligible instances and returns err
+func (m *Manager) noEligible(serviceName string, err error) error {
+	if rec := m.metricsRecorder(); rec != nil {
+		rec.RecordLBNoHealthy(serviceName)
+	}
+	return err
+}
+
+// settings returns a copy of the current configuration
+func (m *Manager) settings() config.ServiceMeshConfig {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.config
+}
+
+// candidates returns the instances of a service eligible for selection:
+// healthy (or, by the warning policy, warning) instances whose circuit is not
+// open and which are not ejected by outlier detection
+func (m *Manager) candidates(ctx context.Context, serviceName string) ([]*Service, error) {
+	if err := m.waitReady(ctx); err != nil {
+		return nil, err
 	}
 	
-	// Filter healthy services
-	healthyServices := make([]*Service, 0)
+	services, err := m.DiscoverServiceContext(ctx, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	
+	return m.eligible(serviceName, m.withRemote(serviceName, services), nil)
+}
+
+// withRemote adds the instances in other datacenters to the discovered
+// instances of a service when the locality policy is prefer_local, no local
+// instance is healthy and the backend discovers the local datacenter only
+func (m *Manager) withRemote(serviceName string, services []*Service) []*Service {
+	remote, ok := m.discovery.(RemoteDiscovery)
+	if !ok || m.settings().LoadBalance.Locality != "prefer_local" {
+		return services
+	}
 	for _, service := range services {
-		if service.Status == StatusHealthy {
-			healthyServices = append(healthyServices, service)
+		if service.Eligible() && !m.inMaintenance(service) {
+			return services
 		}
 	}
 	
+	others, err := remote.DiscoverRemote(serviceName)
+	if err != nil {
+		m.log.Warnf("Failed to discover %s in other datacenters: %v", serviceName, err)
+	}
+	if len(others) == 0 {
+		return services
+	}
+	m.log.Debugf("No healthy local instances of %s, adding %d from other datacenters", serviceName, len(others))
+	return append(services[:len(services):len(services)], others...)
+}
+
+// eligible narrows the discovered instances of a service down to the
+// candidates for selection. If excluded is not nil, it receives the reason
+// each dropped instance was dropped, by instance ID.
+func (m *Manager) eligible(serviceName string, services []*Service, excluded map[string]string) ([]*Service, error) {
+	if len(services) == 0 {
+		return nil, m.noEligible(serviceName, fmt.Errorf("no instances found for service: %s", serviceName))
+	}
+	
+	// Filter healthy services that are not in maintenance, adding warning
+	// instances as the warning policy allows
+	healthyServices := m.filterHealth(serviceName, services)
+	recordExcluded(excluded, services, healthyServices, m.healthExclusion)
+	if len(healthyServices) == 0 {
+		return nil, m.noEligible(serviceName, fmt.Errorf("no healthy instances found for service: %s", serviceName))
+	}
+	
+	local := m.filterLocality(healthyServices)
+	recordExcluded(excluded, healthyServices, local, func(*Service) string { return "not in the local datacenter" })
+	healthyServices = local
 	if len(healthyServices) == 0 {
-		return nil, fmt.Errorf("no healthy instances found for service: %s", serviceName)
+		return nil, m.noEligible(serviceName, fmt.Errorf("no healthy local instances found for service: %s", serviceName))
 	}
 	
-	return m.loadBalance.Select(healthyServices)
+	// Skip instances with an open circuit
+	available := make([]*Service, 0, len(healthyServices))
+	for _, service := range healthyServices {
+		if m.breakers.allows(service.ID) {
+			available = append(available, service)
+		}
+	}
+	recordExcluded(excluded, healthyServices, available, func(*Service) string { return "circuit open" })
+	
+	if len(available) == 0 {
+		return nil, m.noEligible(serviceName, fmt.Errorf("circuit open for all healthy instances of service: %s", serviceName))
+	}
+	
+	// Skip instances ejected by outlier detection, unless that would leave
+	// no candidates at all
+	candidates := make([]*Service, 0, len(available))
+	for _, service := range available {
+		if !m.outliers.ejected(service.ID) {
+			candidates = append(candidates, service)
+		}
+	}
+	
+	if len(candidates) == 0 {
+		m.log.Warnf("All healthy instances of %s are ejected, ignoring outlier detection", serviceName)
+		candidates = available
+	}
+	recordExcluded(excluded, available, candidates, func(*Service) string { return "ejected by outlier detection" })
+	
+	return candidates, nil
+}
+
+// filterLocality applies the locality policy to healthy instances. An
+// instance is local when its Meta["datacenter"] matches the agent's
+// datacenter; instances without a datacenter are treated as local.
+//
+//   - prefer_local: local instances, or all instances if none are local
+//   - local_only:   local instances only
+//   - any:          all instances
+func (m *Manager) filterLocality(services []*Service) []*Service {
+	cfg := m.settings()
+	policy := cfg.LoadBalance.Locality
+	if policy == "any" || policy == "" {
+		return services
+	}
+	
+	local := make([]*Service, 0, len(services))
+	for _, service := range services {
+		if isLocal(service, cfg.Discovery.Datacenter) {
+			local = append(local, service)
+		}
+	}
+	
+	if len(local) == 0 && policy == "prefer_local" {
+		return services
+	}
+	
+	return local
+}
+
+// isLocal reports whether an instance is in the given datacenter
+func isLocal(service *Service, datacenter string) bool {
+	dc, ok := service.Meta["datacenter"]
+	return !ok || dc == "" || dc == datacenter
+}
+
+// ReportResult reports the outcome of a request to a service instance for
+// passive outlier detection and the circuit breaker. Instances with too many
+// consecutive failures are ejected from SelectService for the configured
+// ejection time.
+func (m *Manager) ReportResult(serviceID string, ok bool) {
+	m.outliers.report(serviceID, ok)
+	m.breakers.record(serviceID, ok)
 }
 
 // UpdateServiceStatus updates the status of a service
@@ -243,104 +1087,344 @@ func (m *Manager) UpdateServiceStatus(serviceID string, status ServiceStatus) er
 	
 	service, exists := m.services[serviceID]
 	if !exists {
-		return fmt.Errorf("service not found: %s", serviceID)
+		return fmt.Errorf("%w: %s", ErrServiceNotFound, serviceID)
 	}
 	
-	service.Status = status
 	service.LastSeen = time.Now()
 	
+	// Health results must not return a draining instance to the pool
+	if service.Status == StatusDraining {
+		return nil
+	}
+	
+	previous := service.Status
+	service.Status = status
+	
 	m.log.Debugf("Updated service status: %s -> %s", serviceID, status)
 	
+	if previous != status {
+		m.recordStatusLocked(service)
+		m.publish(EventStatusChanged, service)
+	}
+	
 	return nil
 }
 
-// discoveryLoop periodically syncs with service discovery
+// discoveryLoop keeps the local services registered. Changes are learned
+// from discovery watches where the backend supports them, and the periodic
+// sync re-registers every service as a keepalive; without watches it polls
+// every interval, backing off while the backend is failing.
 func (m *Manager) discoveryLoop(ctx context.Context) {
-	ticker := time.NewTicker(m.config.Discovery.Interval)
-	defer ticker.Stop()
+	timer := time.NewTimer(m.nextSyncDelay())
+	defer timer.Stop()
 	
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-m.stopChan:
-			return
-		case <-ticker.C:
-			m.syncDiscovery()
+		case <-m.watchWake:
+			m.reconcileWatches(ctx)
+		case <-timer.C:
+			m.startSync()
+			timer.Reset(m.nextSyncDelay())
 		}
 	}
 }
 
-// syncDiscovery syncs local services with discovery backend
-func (m *Manager) syncDiscovery() {
+// startSync runs a discovery sync in the background, so that a slow backend
+// delays neither the next tick nor watch updates. A tick arriving while the
+// previous sync still runs is skipped rather than stacked. Watches are
+// reconciled after the sync, retrying the ones that failed.
+func (m *Manager) startSync() {
+	if !m.syncing.CompareAndSwap(false, true) {
+		m.log.Debug("Previous discovery sync still running, skipping this one")
+		if rec := m.metricsRecorder(); rec != nil {
+			rec.RecordDiscoverySyncSkipped()
+		}
+		return
+	}
+	
+	// The discovery loop holds its own count until it returns, so Stop
+	// waits for the sync as well
+	m.loops.Add(1)
+	go func() {
+		defer m.loops.Done()
+		defer m.syncing.Store(false)
+		
+		m.syncDiscovery()
+		m.wakeWatches()
+	}()
+}
+
+// nextSyncDelay returns the discovery interval, or the keepalive interval
+// while all services are watched, doubled for each consecutive failed sync
+// up to discovery.max_backoff
+func (m *Manager) nextSyncDelay() time.Duration {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	
+	delay := m.config.Discovery.Interval
+	if m.watching && m.failures == 0 && m.config.Discovery.KeepaliveInterval > 0 {
+		delay = m.config.Discovery.KeepaliveInterval
+	}
+	for i := 0; i < m.failures && delay < m.config.Discovery.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if max := m.config.Discovery.MaxBackoff; max > 0 && delay > max {
+		delay = max
+	}
+	return delay
+}
+
+// syncDiscovery pings the discovery backend and syncs local services with
+// it, re-registering up to discovery.sync_concurrency services at once.
+// Only the first failure of an outage is logged as an error; the sync is
+// skipped while the backend is unreachable and stops at the first failing
+// registration to spare the backend.
+func (m *Manager) syncDiscovery() {
+	start := time.Now()
+	pingErr := m.pingDiscovery()
+	
+	// Backend calls are made on copies without holding the lock, so that a
+	// slow backend delays the sync but not registrations and selections
+	m.mu.RLock()
+	leader := m.isLeader == nil || m.isLeader()
+	workers := m.config.Discovery.SyncConcurrency
+	backend := m.config.Discovery.Backend
+	services := make([]*Service, 0, len(m.services))
 	for _, service := range m.services {
-		// Re-register service to keep it alive
-		if err := m.discovery.Register(service); err != nil {
-			m.log.Errorf("Failed to sync service %s: %v", service.ID, err)
+		snapshot := *service
+		services = append(services, &snapshot)
+	}
+	m.mu.RUnlock()
+	
+	var syncErr, registerErr error
+	if pingErr != nil {
+		syncErr = fmt.Errorf("discovery backend is unreachable: %w", pingErr)
+	} else if leader {
+		// Re-register services to keep them alive
+		var failed *Service
+		if failed, registerErr = m.registerAll(services, workers); registerErr != nil {
+			syncErr = fmt.Errorf("failed to sync service %s: %w", failed.ID, registerErr)
 		}
 	}
+	
+	m.mu.Lock()
+	m.syncErr = syncErr
+	m.pingErr = pingErr
+	failures := m.failures
+	if syncErr != nil {
+		m.failures++
+	} else {
+		m.failures = 0
+	}
+	next := m.failures
+	metrics := m.metrics
+	m.mu.Unlock()
+	
+	switch {
+	case syncErr != nil && failures == 0:
+		m.log.Errorf("Discovery sync failed, backing off until the backend recovers: %v", syncErr)
+	case syncErr != nil:
+		m.log.Debugf("Discovery sync failed (%d consecutive failures): %v", next, syncErr)
+	case failures > 0:
+		m.log.Infof("Discovery backend recovered after %d failed syncs", failures)
+	}
+	
+	if metrics != nil {
+		metrics.SetDiscoveryConnected(syncErr == nil)
+		metrics.RecordDiscoverySync(time.Since(start).Seconds())
+	}
+	recordDiscoveryFailure(metrics, backend, "register", registerErr)
+	
+	if syncErr == nil {
+		m.readyOnce.Do(func() {
+			close(m.ready)
+			m.log.Info("Service mesh initial discovery sync complete")
+		})
+	}
 }
 
-// generateServiceID generates a unique service ID
-func generateServiceID(serviceName string) string {
-	return fmt.Sprintf("%s-%d", serviceName, time.Now().UnixNano())
+// registerAll registers services with up to workers backend calls at once.
+// No registration is started after the first failure, sparing a failing
+// backend; the service that failed is returned with its error.
+func (m *Manager) registerAll(services []*Service, workers int) (*Service, error) {
+	var (
+		wg      sync.WaitGroup
+		once    sync.Once
+		failed  *Service
+		failErr error
+	)
+	work := make(chan *Service)
+	stop := make(chan struct{})
+	
+	for i := 0; i < min(max(workers, 1), len(services)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for service := range work {
+				if err := m.discovery.Register(service); err != nil {
+					once.Do(func() {
+						failed, failErr = service, err
+						close(stop)
+					})
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, service := range services {
+		select {
+		case <-stop:
+			break feed
+		case work <- service:
+		}
+	}
+	close(work)
+	wg.Wait()
+	
+	return failed, failErr
 }
 
-// NewDiscovery creates a new discovery backend
-func NewDiscovery(cfg config.DiscoveryConfig, log *logrus.Logger) (Discovery, error) {
-	switch cfg.Backend {
-	case "consul":
-		return NewConsulDiscovery(cfg, log)
-	case "etcd":
-		return NewEtcdDiscovery(cfg, log)
-	case "dns":
-		return NewDNSDiscovery(cfg, log)
-	case "static":
-		return NewStaticDiscovery(cfg, log)
-	default:
-		return nil, fmt.Errorf("unsupported discovery backend: %s", cfg.Backend)
+// pingDiscovery checks that the discovery backend is reachable, within the
+// discovery timeout, and records the result
+func (m *Manager) pingDiscovery() error {
+	m.mu.RLock()
+	metrics := m.metrics
+	backend := m.config.Discovery.Backend
+	timeout := m.config.Discovery.Timeout
+	m.mu.RUnlock()
+	
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
 	}
+	
+	err := m.discovery.Ping(ctx)
+	
+	if metrics != nil {
+		metrics.SetDiscoveryBackendUp(backend, err == nil)
+	}
+	recordDiscoveryFailure(metrics, backend, "ping", err)
+	return err
 }
 
-// ConsulDiscovery implements Discovery using Consul
-type ConsulDiscovery struct {
-	config config.DiscoveryConfig
-	log    *logrus.Logger
+// DiscoveryStatus describes the connectivity to the discovery backend as
+// seen by the last syncs
+type DiscoveryStatus struct {
+	Backend             string `json:"backend"`
+	Reachable           bool   `json:"reachable"`
+	Connected           bool   `json:"connected"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	LastError           string `json:"last_error,omitempty"`
+}
+
+// DiscoveryStatus returns the connectivity to the discovery backend
+func (m *Manager) DiscoveryStatus() DiscoveryStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	status := DiscoveryStatus{
+		Backend:             m.config.Discovery.Backend,
+		Reachable:           m.pingErr == nil,
+		Connected:           m.syncErr == nil,
+		ConsecutiveFailures: m.failures,
+	}
+	if m.syncErr != nil {
+		status.LastError = m.syncErr.Error()
+	}
+	return status
 }
 
-// NewConsulDiscovery creates a new Consul discovery backend
-func NewConsulDiscovery(cfg config.DiscoveryConfig, log *logrus.Logger) (*ConsulDiscovery, error) {
-	return &ConsulDiscovery{
-		config: cfg,
-		log:    log,
-	}, nil
+// WaitReady blocks until the first discovery sync has succeeded or ctx is
+// done
+func (m *Manager) WaitReady(ctx context.Context) error {
+	select {
+	case <-m.ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
-func (d *ConsulDiscovery) Register(service *Service) error {
-	d.log.Infof("Registering service with Consul: %s", service.Name)
-	// Placeholder - implement Consul registration
+// waitReady waits up to service_mesh.ready_timeout for the first discovery
+// sync, so that selections during startup do not fail spuriously
+func (m *Manager) waitReady(ctx context.Context) error {
+	select {
+	case <-m.ready:
+		return nil
+	default:
+	}
+	
+	timeout := m.settings().ReadyTimeout
+	if timeout <= 0 {
+		return nil
+	}
+	
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	
+	if err := m.WaitReady(ctx); err != nil {
+		return fmt.Errorf("service mesh is not ready: %w", err)
+	}
 	return nil
 }
 
-func (d *ConsulDiscovery) Deregister(serviceID string) error {
-	d.log.Infof("Deregistering service from Consul: %s", serviceID)
-	// Placeholder - implement Consul deregistration
+// Healthy returns an error if the manager is not running or the last
+// discovery sync failed
+func (m *Manager) Healthy() error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	if !m.running {
+		return fmt.Errorf("service mesh manager is not running")
+	}
+	
+	if m.syncErr != nil {
+		return fmt.Errorf("last discovery sync failed: %w", m.syncErr)
+	}
+	
+	select {
+	case <-m.ready:
+	default:
+		return fmt.Errorf("initial discovery sync has not completed")
+	}
+	
 	return nil
 }
 
-func (d *ConsulDiscovery) Discover(serviceName string) ([]*Service, error) {
-	d.log.Infof("Discovering service from Consul: %s", serviceName)
-	// Placeholder - implement Consul discovery
-	return []*Service{}, nil
+// generateServiceID generates a unique service ID prefixed with the
+// service name
+func (m *Manager) generateServiceID(serviceName string) string {
+	return serviceName + "-" + m.newID()
 }
 
-func (d *ConsulDiscovery) Watch(ctx context.Context, serviceName string) (<-chan []*Service, error) {
-	ch := make(chan []*Service)
-	// Placeholder - implement Consul watch
-	return ch, nil
+// NewDiscovery creates the discovery backend, a CompositeDiscovery if
+// additional backends are configured
+func NewDiscovery(cfg config.DiscoveryConfig, log *logrus.Logger) (Discovery, error) {
+	if len(cfg.Backends) > 0 {
+		return NewCompositeDiscovery(cfg, log)
+	}
+	return newDiscoveryBackend(cfg, log)
+}
+
+// newDiscoveryBackend creates a single discovery backend
+func newDiscoveryBackend(cfg config.DiscoveryConfig, log *logrus.Logger) (Discovery, error) {
+	switch cfg.Backend {
+	case "consul":
+		return NewConsulDiscovery(cfg, log)
+	case "etcd":
+		return NewEtcdDiscovery(cfg, log)
+	case "dns":
+		return NewDNSDiscovery(cfg, log)
+	case "static":
+		return NewStaticDiscovery(cfg, log)
+	default:
+		return nil, fmt.Errorf("unsupported discovery backend: %s", cfg.Backend)
+	}
 }
 
 // EtcdDiscovery implements Discovery using etcd
@@ -357,69 +1441,6 @@ func (d *EtcdDiscovery) Register(service *Service) error { return nil }
 func (d *EtcdDiscovery) Deregister(serviceID string) error { return nil }
 func (d *EtcdDiscovery) Discover(serviceName string) ([]*Service, error) { return []*Service{}, nil }
 func (d *EtcdDiscovery) Watch(ctx context.Context, serviceName string) (<-chan []*Service, error) {
-	return make(chan []*Service), nil
-}
-
-// DNSDiscovery implements Discovery using DNS
-type DNSDiscovery struct {
-	config config.DiscoveryConfig
-	log    *logrus.Logger
-}
-
-func NewDNSDiscovery(cfg config.DiscoveryConfig, log *logrus.Logger) (*DNSDiscovery, error) {
-	return &DNSDiscovery{config: cfg, log: log}, nil
-}
-
-func (d *DNSDiscovery) Register(service *Service) error { return nil }
-func (d *DNSDiscovery) Deregister(serviceID string) error { return nil }
-func (d *DNSDiscovery) Discover(serviceName string) ([]*Service, error) { return []*Service{}, nil }
-func (d *DNSDiscovery) Watch(ctx context.Context, serviceName string) (<-chan []*Service, error) {
-	return make(chan []*Service), nil
-}
-
-// StaticDiscovery implements Discovery using static configuration
-type StaticDiscovery struct {
-	config   config.DiscoveryConfig
-	log      *logrus.Logger
-	services map[string][]*Service
-	mu       sync.RWMutex
-}
-
-func NewStaticDiscovery(cfg config.DiscoveryConfig, log *logrus.Logger) (*StaticDiscovery, error) {
-	return &StaticDiscovery{
-		config:   cfg,
-		log:      log,
-		services: make(map[string][]*Service),
-	}, nil
-}
-
-func (d *StaticDiscovery) Register(service *Service) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-	d.services[service.Name] = append(d.services[service.Name], service)
-	return nil
-}
-
-func (d *StaticDiscovery) Deregister(serviceID string) error {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-	for name, services := range d.services {
-		for i, service := range services {
-			if service.ID == serviceID {
-				d.services[name] = append(services[:i], services[i+1:]...)
-				return nil
-			}
-		}
-	}
-	return fmt.Errorf("service not found: %s", serviceID)
-}
-
-func (d *StaticDiscovery) Discover(serviceName string) ([]*Service, error) {
-	d.mu.RLock()
-	defer d.mu.RUnlock()
-	return d.services[serviceName], nil
-}
-
-func (d *StaticDiscovery) Watch(ctx context.Context, serviceName string) (<-chan []*Service, error) {
-	return make(chan []*Service), nil
+	return nil, ErrWatchUnsupported
 }
+func (d *EtcdDiscovery) Ping(ctx context.Context) error { return nil }
@@ -0,0 +1,189 @@
+package servicemesh
+
+import (
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourusername/hbf-agent/internal/config"
+	"github.com/yourusername/hbf-agent/internal/idgen"
+	"go.uber.org/goleak"
+)
+
+// freePort returns a local port nothing listens on
+func freePort(t *testing.T) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func TestManagerStartStopCycles(t *testing.T) {
+	defer goleak.VerifyNone(t)
+	
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	
+	m, err := NewManager(config.ServiceMeshConfig{
+		BindAddress: "127.0.0.1",
+		ProxyPort:   freePort(t),
+		AdminPort:   freePort(t),
+		Discovery: config.DiscoveryConfig{
+			Backend:           "static",
+			Timeout:           time.Second,
+			Interval:          10 * time.Millisecond,
+			MaxBackoff:        time.Second,
+			KeepaliveInterval: time.Second,
+			CacheMaxAge:       time.Minute,
+			SyncConcurrency:   2,
+		},
+		LoadBalance: config.LoadBalanceConfig{Strategy: "round_robin", Locality: "any", AffinityTTL: time.Minute},
+	}, log)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	
+	// Registered services are kept across restarts and re-registered by
+	// every discovery sync
+	if err := m.RegisterService(&Service{ID: "web-1", Name: "web", Address: "127.0.0.1", Port: 8080}); err != nil {
+		t.Fatalf("RegisterService: %v", err)
+	}
+	
+	for i := 0; i < 5; i++ {
+		if err := m.Start(context.Background()); err != nil {
+			t.Fatalf("Start %d: %v", i, err)
+		}
+		
+		// A proxied connection that finishes before Stop
+		conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(m.config.ProxyPort)))
+		if err != nil {
+			t.Fatalf("Dial %d: %v", i, err)
+		}
+		conn.Close()
+		
+		time.Sleep(20 * time.Millisecond)
+		if err := m.Stop(); err != nil {
+			t.Fatalf("Stop %d: %v", i, err)
+		}
+	}
+	
+	if err := m.Stop(); err == nil {
+		t.Error("Stop of a stopped manager succeeded")
+	}
+}
+
+// newTestManager returns a manager with static discovery and no proxy
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	
+	m, err := NewManager(config.ServiceMeshConfig{
+		Discovery:   config.DiscoveryConfig{Backend: "static", Timeout: time.Second},
+		LoadBalance: config.LoadBalanceConfig{Strategy: "round_robin", Locality: "any"},
+	}, log)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	return m
+}
+
+func TestRegisterServiceIDsAreUnique(t *testing.T) {
+	const n = 1000
+	
+	tests := []struct {
+		name  string
+		newID idgen.Generator
+	}{
+		{"random", idgen.Random()},
+		{"sequence", idgen.Sequence()},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t)
+			m.SetIDGenerator(tt.newID)
+			
+			services := make([]*Service, n)
+			var wg sync.WaitGroup
+			for i := range services {
+				services[i] = &Service{Name: "web", Address: "127.0.0.1", Port: 1000 + i}
+				wg.Add(1)
+				go func(service *Service) {
+					defer wg.Done()
+					if err := m.RegisterService(service); err != nil {
+						t.Errorf("RegisterService: %v", err)
+					}
+				}(services[i])
+			}
+			wg.Wait()
+			
+			seen := make(map[string]bool, n)
+			for _, service := range services {
+				if !strings.HasPrefix(service.ID, "web-") {
+					t.Errorf("ID %q is not prefixed with the service name", service.ID)
+				}
+				if seen[service.ID] {
+					t.Errorf("ID %s assigned twice", service.ID)
+				}
+				seen[service.ID] = true
+			}
+			if got := len(m.ListServices()); got != n {
+				t.Errorf("%d services registered, want %d", got, n)
+			}
+		})
+	}
+}
+
+// blockingDiscovery holds every registration until release is closed
+type blockingDiscovery struct {
+	Discovery
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (d *blockingDiscovery) Register(service *Service) error {
+	d.entered <- struct{}{}
+	<-d.release
+	return d.Discovery.Register(service)
+}
+
+func TestSlowRegistrationDoesNotBlockReads(t *testing.T) {
+	m := newTestManager(t)
+	discovery := &blockingDiscovery{Discovery: m.discovery, entered: make(chan struct{}), release: make(chan struct{})}
+	m.discovery = discovery
+	
+	registered := make(chan error, 1)
+	go func() {
+		registered <- m.RegisterService(&Service{ID: "web-1", Name: "web", Address: "127.0.0.1", Port: 8080})
+	}()
+	<-discovery.entered
+	
+	read := make(chan int, 1)
+	go func() { read <- len(m.ListServices()) }()
+	select {
+	case n := <-read:
+		if n != 0 {
+			t.Errorf("%d services listed before the backend registered any", n)
+		}
+	case <-time.After(time.Second):
+		t.Error("ListServices blocked on a registration in progress")
+	}
+	
+	close(discovery.release)
+	if err := <-registered; err != nil {
+		t.Fatalf("RegisterService: %v", err)
+	}
+	if _, err := m.GetService("web-1"); err != nil {
+		t.Errorf("GetService: %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package servicemesh
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourusername/hbf-agent/internal/config"
+)
+
+// outlierDetector passively ejects instances that report consecutive failures
+type outlierDetector struct {
+	config    config.OutlierDetectionConfig
+	log       *logrus.Logger
+	instances map[string]*outlierState
+	mu        sync.Mutex
+}
+
+// outlierState tracks the failure streak and ejection of one instance
+type outlierState struct {
+	failures     int
+	ejectedUntil time.Time
+	probing      bool // ejection expired; the next result decides
+}
+
+func newOutlierDetector(cfg config.OutlierDetectionConfig, log *logrus.Logger) *outlierDetector {
+	return &outlierDetector{
+		config:    cfg,
+		log:       log,
+		instances: make(map[string]*outlierState),
+	}
+}
+
+// report records the outcome of a request to an instance
+func (d *outlierDetector) report(serviceID string, ok bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	
+	if !d.config.Enabled {
+		return
+	}
+	
+	state, exists := d.instances[serviceID]
+	if ok {
+		if exists {
+			if state.probing {
+				d.log.Infof("Instance %s recovered, returning it to the pool", serviceID)
+			}
+			delete(d.instances, serviceID)
+		}
+		return
+	}
+	
+	if !exists {
+		state = &outlierState{}
+		d.instances[serviceID] = state
+	}
+	
+	state.failures++
+	
+	// A failed probe after an ejection re-ejects immediately
+	if state.probing || state.failures >= d.config.ConsecutiveFailures {
+		state.ejectedUntil = time.Now().Add(d.config.EjectionTime)
+		state.probing = false
+		d.log.Warnf("Ejecting instance %s for %s after %d consecutive failures",
+			serviceID, d.config.EjectionTime, state.failures)
+	}
+}
+
+// ejected reports whether an instance is currently ejected from the pool
+func (d *outlierDetector) ejected(serviceID string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	
+	if !d.config.Enabled {
+		return false
+	}
+	
+	state, exists := d.instances[serviceID]
+	if !exists || state.ejectedUntil.IsZero() {
+		return false
+	}
+	
+	if time.Now().Before(state.ejectedUntil) {
+		return true
+	}
+	
+	// Ejection window passed: let the instance take traffic again as a probe
+	state.ejectedUntil = time.Time{}
+	state.probing = true
+	return false
+}
+
+// setConfig replaces the detector settings. Disabling detection returns all
+// ejected instances to the pool.
+func (d *outlierDetector) setConfig(cfg config.OutlierDetectionConfig) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.config = cfg
+	if !cfg.Enabled {
+		d.instances = make(map[string]*outlierState)
+	}
+}
+
+// forget drops all state for an instance
+func (d *outlierDetector) forget(serviceID string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.instances, serviceID)
+}
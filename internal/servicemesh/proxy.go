@@ -0,0 +1,496 @@
+package servicemesh
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/yourusername/hbf-agent/internal/config"
+)
+
+// ServiceHeader lets plain HTTP clients name the target service explicitly
+// instead of relying on the Host header
+const ServiceHeader = "X-HBF-Service"
+
+// TimeoutMetaKey is the service meta key overriding the request timeout for
+// one service, as a duration such as "30s"
+const TimeoutMetaKey = "timeout"
+
+// MetricsRecorder records data-plane metrics. It is satisfied by metrics.Manager.
+type MetricsRecorder interface {
+	RecordServiceRequest(serviceName, method, status, strategy string, duration float64)
+	RecordTrafficBytes(direction string, bytes float64)
+	RecordCallAttempt(serviceName, outcome string)
+	RecordServiceTimeout(serviceName string)
+	RecordLBSelection(serviceName, serviceID, strategy string)
+	RecordLBNoHealthy(serviceName string)
+	RecordLBSplitSelection(serviceName, bucket string)
+	SetServiceHealthStatus(serviceName, serviceID, status string)
+	DeleteServiceHealthStatus(serviceName, serviceID string)
+	SetDiscoveryConnected(connected bool)
+	SetDiscoveryBackendUp(backend string, up bool)
+	RecordDiscoveryError(backend, operation string)
+	RecordDiscoveryTimeout(backend, operation string)
+	RecordDiscoveryCacheServed(serviceName string)
+	RecordDiscoverySync(duration float64)
+	RecordDiscoverySyncSkipped()
+}
+
+// stopDrainTimeout is how long Stop waits for proxied connections to finish
+// before closing them
+const stopDrainTimeout = 10 * time.Second
+
+// Proxy is a TCP proxy that routes connections to service instances
+type Proxy struct {
+	config   config.ServiceMeshConfig
+	log      *logrus.Logger
+	manager  *Manager
+	listener net.Listener
+	admin    *http.Server
+	
+	mu        sync.RWMutex
+	upstreams map[string]*UpstreamChoice
+	conns     map[net.Conn]struct{} // client and upstream connections
+	stopping  bool
+	wg        sync.WaitGroup
+}
+
+// UpstreamChoice describes the instance most recently chosen for a service
+type UpstreamChoice struct {
+	ServiceName       string    `json:"service_name"`
+	ServiceID         string    `json:"service_id"`
+	Address           string    `json:"address"`
+	ActiveConnections int64     `json:"active_connections"`
+	SelectedAt        time.Time `json:"selected_at"`
+}
+
+// NewProxy creates a new proxy for the given manager
+func NewProxy(cfg config.ServiceMeshConfig, manager *Manager, log *logrus.Logger) *Proxy {
+	return &Proxy{
+		config:    cfg,
+		log:       log,
+		manager:   manager,
+		upstreams: make(map[string]*UpstreamChoice),
+		conns:     make(map[net.Conn]struct{}),
+	}
+}
+
+// Start starts the proxy and admin listeners
+func (p *Proxy) Start(ctx context.Context) error {
+	addr := net.JoinHostPort(p.config.BindAddress, strconv.Itoa(p.config.ProxyPort))
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	p.listener = listener
+	
+	mux := http.NewServeMux()
+	mux.HandleFunc("/upstreams", p.handleUpstreams)
+	
+	p.admin = &http.Server{
+		Addr:    net.JoinHostPort(p.config.BindAddress, strconv.Itoa(p.config.AdminPort)),
+		Handler: mux,
+	}
+	
+	go func() {
+		p.log.Infof("Service mesh admin listening on %s", p.admin.Addr)
+		if err := p.admin.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			p.log.Errorf("Service mesh admin server error: %v", err)
+		}
+	}()
+	
+	p.log.Infof("Service mesh proxy listening on %s", addr)
+	go p.acceptLoop(ctx)
+	
+	return nil
+}
+
+// Stop stops accepting connections and waits for active ones to finish.
+// Connections still open after stopDrainTimeout, e.g. idle keep-alive
+// connections, are closed.
+func (p *Proxy) Stop() error {
+	var errs []error
+	
+	if p.listener != nil {
+		if err := p.listener.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close proxy listener: %w", err))
+		}
+	}
+	
+	if p.admin != nil {
+		if err := p.admin.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to stop admin server: %w", err))
+		}
+	}
+	
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	
+	select {
+	case <-done:
+	case <-time.After(stopDrainTimeout):
+		p.closeConns()
+		<-done
+	}
+	
+	return errors.Join(errs...)
+}
+
+// trackConn records an open connection so that Stop can close it. A
+// connection opened while Stop is closing them is closed right away.
+func (p *Proxy) trackConn(conn net.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	
+	if p.stopping {
+		conn.Close()
+		return
+	}
+	p.conns[conn] = struct{}{}
+}
+
+// untrackConn forgets a closed connection
+func (p *Proxy) untrackConn(conn net.Conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.conns, conn)
+}
+
+// closeConns closes the connections still open on Stop
+func (p *Proxy) closeConns() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	
+	p.stopping = true
+	if len(p.conns) > 0 {
+		p.log.Warnf("Closing %d proxy connections still open after %s", len(p.conns), stopDrainTimeout)
+	}
+	for conn := range p.conns {
+		conn.Close()
+	}
+}
+
+// Upstreams returns the current upstream choice for each proxied service
+func (p *Proxy) Upstreams() []*UpstreamChoice {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	
+	choices := make([]*UpstreamChoice, 0, len(p.upstreams))
+	for _, choice := range p.upstreams {
+		c := *choice
+		choices = append(choices, &c)
+	}
+	
+	return choices
+}
+
+// acceptLoop accepts proxy connections until the listener is closed
+func (p *Proxy) acceptLoop(ctx context.Context) {
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) || ctx.Err() != nil {
+				return
+			}
+			p.log.Errorf("Failed to accept proxy connection: %v", err)
+			continue
+		}
+		
+		p.wg.Add(1)
+		p.trackConn(conn)
+		go func() {
+			defer p.wg.Done()
+			defer p.untrackConn(conn)
+			p.handleConn(conn)
+		}()
+	}
+}
+
+// handleConn routes a single client connection to an upstream instance
+func (p *Proxy) handleConn(conn net.Conn) {
+	defer conn.Close()
+	
+	start := time.Now()
+	
+	serviceName, method, reader, err := p.readTarget(conn)
+	if err != nil {
+		p.log.Warnf("Failed to determine target service from %s: %v", conn.RemoteAddr(), err)
+		return
+	}
+	
+	status := "ok"
+	defer func() {
+		p.recordRequest(serviceName, method, status, time.Since(start))
+	}()
+	
+	upstreamConn, err := p.manager.Connect(context.Background(), serviceName)
+	if err != nil {
+		status = "no_upstream"
+		p.log.Warnf("No upstream for service %s: %v", serviceName, err)
+		return
+	}
+	defer upstreamConn.Close()
+	service := upstreamConn.Service
+	
+	upstreamAddr := net.JoinHostPort(service.Address, strconv.Itoa(service.Port))
+	p.trackUpstream(serviceName, service, upstreamAddr, 1)
+	defer p.trackUpstream(serviceName, service, upstreamAddr, -1)
+	
+	dialStart := time.Now()
+	upstream, err := net.DialTimeout("tcp", upstreamAddr, p.dialTimeout())
+	if err != nil {
+		p.manager.ReportResult(service.ID, false)
+		status = "upstream_error"
+		p.log.Warnf("Failed to connect to upstream %s for %s: %v", upstreamAddr, serviceName, err)
+		return
+	}
+	p.manager.ReportLatency(service, time.Since(dialStart))
+	defer upstream.Close()
+	p.trackConn(upstream)
+	defer p.untrackConn(upstream)
+	
+	timeout := p.requestTimeout(service)
+	if timeout > 0 {
+		if err := upstream.SetDeadline(time.Now().Add(timeout)); err != nil {
+			p.log.Warnf("Failed to set deadline on upstream %s: %v", upstreamAddr, err)
+		}
+	}
+	
+	inbound, outbound, err := p.pipe(conn, upstream, reader)
+	p.recordTraffic("inbound", inbound)
+	p.recordTraffic("outbound", outbound)
+	
+	// A timed out upstream counts as a failure for the circuit breaker and
+	// outlier detection
+	timedOut := errors.Is(err, os.ErrDeadlineExceeded)
+	p.manager.ReportResult(service.ID, !timedOut)
+	if timedOut {
+		status = "timeout"
+		p.log.Warnf("Upstream %s for %s exceeded the request timeout of %s", upstreamAddr, serviceName, timeout)
+		if rec := p.manager.metricsRecorder(); rec != nil {
+			rec.RecordServiceTimeout(serviceName)
+		}
+	}
+}
+
+// requestTimeout returns the request timeout of a service: its "timeout"
+// meta if set and valid, otherwise service_mesh.request_timeout
+func (p *Proxy) requestTimeout(service *Service) time.Duration {
+	if value, ok := service.Meta[TimeoutMetaKey]; ok {
+		timeout, err := time.ParseDuration(value)
+		if err == nil && timeout >= 0 {
+			return timeout
+		}
+		p.log.Warnf("Ignoring invalid %s meta %q of service %s", TimeoutMetaKey, value, service.ID)
+	}
+	return p.manager.settings().RequestTimeout
+}
+
+// readTarget determines the target service from the TLS SNI or, for plain
+// HTTP, from the X-HBF-Service or Host header. The returned reader replays
+// the bytes consumed while peeking.
+func (p *Proxy) readTarget(conn net.Conn) (string, string, io.Reader, error) {
+	if err := conn.SetReadDeadline(time.Now().Add(p.dialTimeout())); err != nil {
+		return "", "", nil, err
+	}
+	defer conn.SetReadDeadline(time.Time{})
+	
+	br := bufio.NewReader(conn)
+	first, err := br.Peek(1)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("failed to read from client: %w", err)
+	}
+	
+	var peeked bytes.Buffer
+	tee := io.TeeReader(br, &peeked)
+	
+	// 0x16 is the TLS handshake record type
+	if first[0] == 0x16 {
+		hello, err := readClientHello(tee)
+		if err != nil {
+			return "", "", nil, err
+		}
+		if hello.ServerName == "" {
+			return "", "", nil, fmt.Errorf("TLS client hello carries no SNI")
+		}
+		return hostOnly(hello.ServerName), "tls", io.MultiReader(&peeked, br), nil
+	}
+	
+	req, err := http.ReadRequest(bufio.NewReader(tee))
+	if err != nil {
+		return "", "", nil, fmt.Errorf("failed to parse HTTP request: %w", err)
+	}
+	
+	serviceName := req.Header.Get(ServiceHeader)
+	if serviceName == "" {
+		serviceName = hostOnly(req.Host)
+	}
+	if serviceName == "" {
+		return "", "", nil, fmt.Errorf("HTTP request names no service")
+	}
+	
+	// http.ReadRequest may buffer past the headers, so replay everything
+	// read from the connection so far
+	return serviceName, req.Method, io.MultiReader(&peeked, br), nil
+}
+
+// readClientHello parses a TLS ClientHello without completing the handshake
+func readClientHello(r io.Reader) (*tls.ClientHelloInfo, error) {
+	var hello *tls.ClientHelloInfo
+	
+	err := tls.Server(readOnlyConn{reader: r}, &tls.Config{
+		GetConfigForClient: func(info *tls.ClientHelloInfo) (*tls.Config, error) {
+			hello = info
+			return nil, errClientHelloRead
+		},
+	}).Handshake()
+	
+	if hello == nil {
+		return nil, fmt.Errorf("failed to read TLS client hello: %w", err)
+	}
+	
+	return hello, nil
+}
+
+var errClientHelloRead = errors.New("client hello read")
+
+// readOnlyConn is a net.Conn that only supports reading, used to parse a
+// ClientHello from a reader
+type readOnlyConn struct {
+	reader io.Reader
+}
+
+func (c readOnlyConn) Read(b []byte) (int, error)         { return c.reader.Read(b) }
+func (c readOnlyConn) Write(b []byte) (int, error)        { return 0, io.ErrClosedPipe }
+func (c readOnlyConn) Close() error                       { return nil }
+func (c readOnlyConn) LocalAddr() net.Addr                { return nil }
+func (c readOnlyConn) RemoteAddr() net.Addr               { return nil }
+func (c readOnlyConn) SetDeadline(t time.Time) error      { return nil }
+func (c readOnlyConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c readOnlyConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// pipe copies bytes in both directions until either side closes, returning
+// the bytes sent to the upstream, the bytes sent back to the client and the
+// first copy error. When the upstream deadline passes both connections are
+// closed so that neither direction keeps waiting.
+func (p *Proxy) pipe(client, upstream net.Conn, clientReader io.Reader) (int64, int64, error) {
+	var inbound, outbound int64
+	var inErr, outErr error
+	var wg sync.WaitGroup
+	wg.Add(2)
+	
+	abort := func(err error) {
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			client.Close()
+			upstream.Close()
+		}
+	}
+	
+	go func() {
+		defer wg.Done()
+		inbound, inErr = io.Copy(upstream, clientReader)
+		abort(inErr)
+		closeWrite(upstream)
+	}()
+	
+	go func() {
+		defer wg.Done()
+		outbound, outErr = io.Copy(client, upstream)
+		abort(outErr)
+		closeWrite(client)
+	}()
+	
+	wg.Wait()
+	return inbound, outbound, errors.Join(inErr, outErr)
+}
+
+// closeWrite half-closes a TCP connection so the peer sees EOF
+func closeWrite(conn net.Conn) {
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.CloseWrite()
+		return
+	}
+	conn.Close()
+}
+
+// trackUpstream records the chosen upstream and adjusts its connection count
+func (p *Proxy) trackUpstream(serviceName string, service *Service, addr string, delta int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	
+	choice, exists := p.upstreams[serviceName]
+	if !exists || choice.ServiceID != service.ID {
+		if delta < 0 {
+			return
+		}
+		choice = &UpstreamChoice{
+			ServiceName: serviceName,
+			ServiceID:   service.ID,
+			Address:     addr,
+		}
+		p.upstreams[serviceName] = choice
+	}
+	
+	if delta > 0 {
+		choice.SelectedAt = time.Now()
+	}
+	choice.ActiveConnections += delta
+}
+
+// dialTimeout returns the timeout for upstream dials and client preambles
+func (p *Proxy) dialTimeout() time.Duration {
+	if p.config.Discovery.Timeout > 0 {
+		return p.config.Discovery.Timeout
+	}
+	return 5 * time.Second
+}
+
+// recordRequest records a proxied request under the strategy the service
+// uses after its selection, so that request metrics follow strategy changes
+func (p *Proxy) recordRequest(serviceName, method, status string, duration time.Duration) {
+	if rec := p.manager.metricsRecorder(); rec != nil {
+		rec.RecordServiceRequest(serviceName, method, status, p.manager.currentStrategy(serviceName), duration.Seconds())
+	}
+}
+
+func (p *Proxy) recordTraffic(direction string, bytes int64) {
+	if rec := p.manager.metricsRecorder(); rec != nil {
+		rec.RecordTrafficBytes(direction, float64(bytes))
+	}
+}
+
+func (p *Proxy) handleUpstreams(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(p.Upstreams()); err != nil {
+		p.log.Errorf("Failed to encode upstreams: %v", err)
+	}
+}
+
+// hostOnly strips an optional port from a host string
+func hostOnly(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return strings.TrimSuffix(host, ".")
+}
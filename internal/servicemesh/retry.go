@@ -0,0 +1,115 @@
+package servicemesh
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/yourusername/hbf-agent/internal/config"
+)
+
+// CallWithRetry selects an instance of a service and runs fn against it. On
+// failure it retries against a different instance, backing off exponentially
+// with jitter, until fn succeeds, the configured attempts are exhausted or
+// ctx is cancelled. Each outcome is fed back through ReportResult so failing
+// instances trip their circuit breaker and are skipped on later attempts.
+func (m *Manager) CallWithRetry(ctx context.Context, serviceName string, fn func(*Service) error) error {
+	cfg := m.settings().Retry
+	maxAttempts := cfg.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	
+	tried := make(map[string]bool)
+	var lastErr error
+	
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("call to %s cancelled after %d attempts: %w", serviceName, attempt, ctx.Err())
+			case <-time.After(retryBackoff(cfg, attempt)):
+			}
+		}
+		
+		service, err := m.selectExcluding(ctx, serviceName, tried)
+		if err != nil {
+			m.recordCallAttempt(serviceName, "no_instance")
+			if lastErr != nil {
+				return fmt.Errorf("call to %s failed after %d attempts: %w", serviceName, attempt, lastErr)
+			}
+			return err
+		}
+		tried[service.ID] = true
+		
+		conn := m.Acquire(service)
+		start := time.Now()
+		err = fn(service)
+		m.ReportLatency(service, time.Since(start))
+		conn.Close()
+		
+		if err != nil {
+			lastErr = err
+			m.ReportResult(service.ID, false)
+			m.recordCallAttempt(serviceName, "failure")
+			m.log.Debugf("Call to %s (%s) failed on attempt %d: %v", serviceName, service.ID, attempt+1, err)
+			continue
+		}
+		
+		m.ReportResult(service.ID, true)
+		m.recordCallAttempt(serviceName, "success")
+		return nil
+	}
+	
+	return fmt.Errorf("call to %s failed after %d attempts: %w", serviceName, maxAttempts, lastErr)
+}
+
+// selectExcluding selects an instance, preferring ones not yet tried. Tried
+// instances are only reused once every candidate has been tried.
+func (m *Manager) selectExcluding(ctx context.Context, serviceName string, tried map[string]bool) (*Service, error) {
+	candidates, err := m.candidates(ctx, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	
+	untried := make([]*Service, 0, len(candidates))
+	for _, service := range candidates {
+		if !tried[service.ID] {
+			untried = append(untried, service)
+		}
+	}
+	
+	if len(untried) > 0 {
+		candidates = untried
+	}
+	
+	return m.selectInstance(serviceName, candidates)
+}
+
+// retryBackoff returns the delay before the given retry attempt: exponential
+// growth from the base backoff, capped at the maximum, with jitter in the
+// upper half of the interval
+func retryBackoff(cfg config.RetryConfig, attempt int) time.Duration {
+	backoff := cfg.BaseBackoff
+	for i := 1; i < attempt && backoff < cfg.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	
+	if cfg.MaxBackoff > 0 && backoff > cfg.MaxBackoff {
+		backoff = cfg.MaxBackoff
+	}
+	
+	if backoff <= 0 {
+		return 0
+	}
+	
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+func (m *Manager) recordCallAttempt(serviceName, outcome string) {
+	if rec := m.metricsRecorder(); rec != nil {
+		rec.RecordCallAttempt(serviceName, outcome)
+	}
+}
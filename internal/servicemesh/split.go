@@ -0,0 +1,117 @@
+package servicemesh
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/yourusername/hbf-agent/internal/config"
+	"github.com/yourusername/hbf-agent/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SelectWithSplit selects an instance of a service through a traffic split:
+// a bucket is picked by weight among the tag values in splits, e.g.
+// {"canary": 5, "stable": 95}, and an instance carrying that tag is then
+// chosen by the service's load balancer. Buckets without eligible instances
+// are skipped, their weight going to the others.
+func (m *Manager) SelectWithSplit(serviceName string, splits map[string]int) (*Service, error) {
+	return m.SelectWithSplitContext(context.Background(), serviceName, splits)
+}
+
+// SelectWithSplitContext is SelectWithSplit, tracing the selection as a
+// child of any span in ctx
+func (m *Manager) SelectWithSplitContext(ctx context.Context, serviceName string, splits map[string]int) (*Service, error) {
+	ctx, span := tracing.Start(ctx, "servicemesh.SelectService",
+		trace.WithAttributes(attribute.String("service.name", serviceName)))
+	defer span.End()
+	
+	if err := config.ValidateSplit(splits); err != nil {
+		return nil, tracing.Fail(span, fmt.Errorf("invalid split of service %s: %w", serviceName, err))
+	}
+	
+	candidates, err := m.candidates(ctx, serviceName)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	
+	service, bucket, err := m.selectSplit(serviceName, candidates, splits)
+	if err != nil {
+		return nil, tracing.Fail(span, err)
+	}
+	
+	span.SetAttributes(attribute.String("service.id", service.ID), attribute.String("service.bucket", bucket))
+	return service, nil
+}
+
+// serviceSplit returns the traffic split configured for a service in
+// load_balance.splits, or nil
+func (m *Manager) serviceSplit(serviceName string) map[string]int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.config.LoadBalance.Splits[serviceName]
+}
+
+// selectSplit picks a bucket of a split by weight among the buckets with
+// candidates, then an instance of the bucket, and counts the bucket hit
+func (m *Manager) selectSplit(serviceName string, candidates []*Service, splits map[string]int) (*Service, string, error) {
+	buckets := make([]string, 0, len(splits))
+	members := make(map[string][]*Service, len(splits))
+	total := 0
+	for tag, weight := range splits {
+		if weight <= 0 {
+			continue
+		}
+		for _, service := range candidates {
+			if hasTagFold(service, tag) {
+				members[tag] = append(members[tag], service)
+			}
+		}
+		if len(members[tag]) > 0 {
+			buckets = append(buckets, tag)
+			total += weight
+		}
+	}
+	
+	if total == 0 {
+		return nil, "", m.noEligible(serviceName,
+			fmt.Errorf("no available instances of service %s in any bucket of the split", serviceName))
+	}
+	
+	// Iterate in a fixed order so that a given draw always maps to the
+	// same bucket
+	sort.Strings(buckets)
+	bucket := buckets[len(buckets)-1]
+	draw := rand.Intn(total)
+	for _, tag := range buckets {
+		if draw < splits[tag] {
+			bucket = tag
+			break
+		}
+		draw -= splits[tag]
+	}
+	
+	service, err := m.selectInstance(serviceName, members[bucket])
+	if err != nil {
+		return nil, "", err
+	}
+	
+	if rec := m.metricsRecorder(); rec != nil {
+		rec.RecordLBSplitSelection(serviceName, bucket)
+	}
+	return service, bucket, nil
+}
+
+// hasTagFold reports whether the instance carries tag in any case. Split
+// buckets from the configuration are read lowercased.
+func hasTagFold(service *Service, tag string) bool {
+	for _, t := range service.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,240 @@
+package servicemesh
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+	"github.com/yourusername/hbf-agent/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// StaticDiscovery implements Discovery using static configuration.
+//
+// Instances are read from the services file at DiscoveryConfig.Address, a
+// YAML (or JSON) map of service name to instance list:
+//
+//	web:
+//	  - id: web-1
+//	    address: 10.0.0.1
+//	    port: 8080
+//	    tags: [v1]
+//
+// The file is watched and reloaded atomically when it changes. Services
+// registered through the API are kept separately and survive reloads.
+type StaticDiscovery struct {
+	config   config.DiscoveryConfig
+	log      *logrus.Logger
+	services map[string][]*Service
+	fromFile map[string][]*Service
+	watcher  *fsnotify.Watcher
+	mu       sync.RWMutex
+}
+
+// staticInstance is the services file representation of an instance
+type staticInstance struct {
+	ID      string            `yaml:"id"`
+	Address string            `yaml:"address"`
+	Port    int               `yaml:"port"`
+	Tags    []string          `yaml:"tags"`
+	Meta    map[string]string `yaml:"meta"`
+	Status  ServiceStatus     `yaml:"status"`
+}
+
+func NewStaticDiscovery(cfg config.DiscoveryConfig, log *logrus.Logger) (*StaticDiscovery, error) {
+	d := &StaticDiscovery{
+		config:   cfg,
+		log:      log,
+		services: make(map[string][]*Service),
+		fromFile: make(map[string][]*Service),
+	}
+	
+	if cfg.Address == "" {
+		return d, nil
+	}
+	
+	if err := d.reload(); err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		log.Warnf("Static services file %s does not exist yet", cfg.Address)
+	}
+	
+	if err := d.watch(); err != nil {
+		return nil, err
+	}
+	
+	return d, nil
+}
+
+// Register adds a service instance, replacing a registered instance with
+// the same ID, as discovery syncs re-register every local service
+func (d *StaticDiscovery) Register(service *Service) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	
+	for name, services := range d.services {
+		for i, existing := range services {
+			if existing.ID != service.ID {
+				continue
+			}
+			if name == service.Name {
+				services[i] = service
+				return nil
+			}
+			d.services[name] = append(services[:i], services[i+1:]...)
+			break
+		}
+	}
+	d.services[service.Name] = append(d.services[service.Name], service)
+	return nil
+}
+
+func (d *StaticDiscovery) Deregister(serviceID string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for name, services := range d.services {
+		for i, service := range services {
+			if service.ID == serviceID {
+				d.services[name] = append(services[:i], services[i+1:]...)
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrServiceNotFound, serviceID)
+}
+
+// Discover returns the file-defined and registered instances of a service.
+// The returned slice is a copy and may be modified by the caller.
+func (d *StaticDiscovery) Discover(serviceName string) ([]*Service, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	
+	fromFile := d.fromFile[serviceName]
+	registered := d.services[serviceName]
+	
+	services := make([]*Service, 0, len(fromFile)+len(registered))
+	services = append(services, fromFile...)
+	services = append(services, registered...)
+	return services, nil
+}
+
+func (d *StaticDiscovery) Watch(ctx context.Context, serviceName string) (<-chan []*Service, error) {
+	return nil, ErrWatchUnsupported
+}
+
+// Ping always succeeds: the instances live in memory, and a services file
+// that fails to reload keeps the previous set
+func (d *StaticDiscovery) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Close stops watching the services file
+func (d *StaticDiscovery) Close() error {
+	if d.watcher != nil {
+		return d.watcher.Close()
+	}
+	return nil
+}
+
+// reload reads the services file and swaps in its instances
+func (d *StaticDiscovery) reload() error {
+	data, err := os.ReadFile(d.config.Address)
+	if err != nil {
+		return err
+	}
+	
+	var file map[string][]staticInstance
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse static services file %s: %w", d.config.Address, err)
+	}
+	
+	now := time.Now()
+	fromFile := make(map[string][]*Service, len(file))
+	count := 0
+	
+	for name, instances := range file {
+		for i, inst := range instances {
+			if inst.Address == "" || inst.Port == 0 {
+				return fmt.Errorf("static service %s instance %d: address and port are required", name, i)
+			}
+			
+			id := inst.ID
+			if id == "" {
+				id = fmt.Sprintf("%s-%s-%d", name, inst.Address, inst.Port)
+			}
+			
+			// Static instances have no active health check, so they are
+			// considered healthy unless the file says otherwise
+			status := inst.Status
+			if status == "" {
+				status = StatusHealthy
+			}
+			
+			fromFile[name] = append(fromFile[name], &Service{
+				ID:           id,
+				Name:         name,
+				Address:      inst.Address,
+				Port:         inst.Port,
+				Tags:         inst.Tags,
+				Meta:         inst.Meta,
+				Status:       status,
+				RegisteredAt: now,
+				LastSeen:     now,
+			})
+			count++
+		}
+	}
+	
+	d.mu.Lock()
+	d.fromFile = fromFile
+	d.mu.Unlock()
+	
+	d.log.Infof("Loaded %d static instances of %d services from %s", count, len(fromFile), d.config.Address)
+	return nil
+}
+
+// watch reloads the services file whenever it changes. The parent directory
+// is watched so that files replaced by rename (editors, ConfigMaps) are seen.
+func (d *StaticDiscovery) watch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	
+	path := filepath.Clean(d.config.Address)
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch %s: %w", filepath.Dir(path), err)
+	}
+	d.watcher = watcher
+	
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != path || event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+					continue
+				}
+				if err := d.reload(); err != nil {
+					d.log.Errorf("Failed to reload static services, keeping previous set: %v", err)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				d.log.Errorf("Static services watcher error: %v", err)
+			}
+		}
+	}()
+	
+	return nil
+}
@@ -0,0 +1,125 @@
+package servicemesh
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// stickyStrategy labels selections made by client affinity rather than the
+// load balancer
+const stickyStrategy = "sticky"
+
+// affinityTable remembers which instance each client was sent to
+type affinityTable struct {
+	ttl       time.Duration
+	entries   map[string]affinityEntry
+	lastSweep time.Time
+	mu        sync.Mutex
+}
+
+// affinityEntry is one remembered assignment
+type affinityEntry struct {
+	serviceID string
+	expires   time.Time
+}
+
+func newAffinityTable(ttl time.Duration) *affinityTable {
+	return &affinityTable{
+		ttl:       ttl,
+		entries:   make(map[string]affinityEntry),
+		lastSweep: time.Now(),
+	}
+}
+
+// lookup returns the instance remembered for key, if it has not expired
+func (t *affinityTable) lookup(key string) (string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	
+	entry, exists := t.entries[key]
+	if !exists || time.Now().After(entry.expires) {
+		return "", false
+	}
+	return entry.serviceID, true
+}
+
+// store remembers serviceID for key for another TTL and drops expired
+// entries once per TTL
+func (t *affinityTable) store(key, serviceID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	
+	now := time.Now()
+	t.entries[key] = affinityEntry{serviceID: serviceID, expires: now.Add(t.ttl)}
+	
+	if now.Sub(t.lastSweep) < t.ttl {
+		return
+	}
+	t.lastSweep = now
+	for k, entry := range t.entries {
+		if now.After(entry.expires) {
+			delete(t.entries, k)
+		}
+	}
+}
+
+// setTTL changes the TTL for assignments stored from now on
+func (t *affinityTable) setTTL(ttl time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ttl = ttl
+}
+
+// SelectSticky selects an instance for a client, sending the same client
+// key to the same instance for as long as the assignment is used within the
+// affinity TTL. New clients are assigned by hashing the key; if the
+// remembered or hashed instance is no longer eligible, the load balancer
+// picks a new one. An empty client key selects through the load balancer.
+func (m *Manager) SelectSticky(services []*Service, clientKey string) (*Service, error) {
+	if len(services) == 0 {
+		return nil, fmt.Errorf("no services available")
+	}
+	
+	serviceName := services[0].Name
+	if clientKey == "" {
+		return m.selectInstance(serviceName, services)
+	}
+	
+	key := serviceName + "/" + clientKey
+	
+	if serviceID, ok := m.affinity.lookup(key); ok {
+		for _, service := range services {
+			if service.ID == serviceID && selectable(service) {
+				m.affinity.store(key, service.ID)
+				m.recordSelection(serviceName, service.ID, stickyStrategy)
+				return service, nil
+			}
+		}
+		m.log.Debugf("Sticky instance %s for %s is gone or unhealthy, reassigning", serviceID, key)
+	} else {
+		h := fnv.New32a()
+		h.Write([]byte(clientKey))
+		if service := services[h.Sum32()%uint32(len(services))]; selectable(service) {
+			m.affinity.store(key, service.ID)
+			m.recordSelection(serviceName, service.ID, stickyStrategy)
+			return service, nil
+		}
+	}
+	
+	eligible := make([]*Service, 0, len(services))
+	for _, service := range services {
+		if selectable(service) {
+			eligible = append(eligible, service)
+		}
+	}
+	
+	service, err := m.selectInstance(serviceName, eligible)
+	if err != nil {
+		return nil, err
+	}
+	
+	m.affinity.store(key, service.ID)
+	return service, nil
+}
@@ -0,0 +1,151 @@
+package servicemesh
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Store persists the locally registered services, so that they survive a
+// restart of the agent
+type Store interface {
+	Save(services []*Service) error
+	Load() ([]*Service, error)
+}
+
+// FileStore is a Store keeping the services in a JSON file. Saves replace
+// the file atomically, so a crash never leaves a partial file behind.
+type FileStore struct {
+	path string
+}
+
+// NewFileStore creates a store backed by the JSON file at path
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+// Save replaces the stored services
+func (s *FileStore) Save(services []*Service) error {
+	data, err := json.MarshalIndent(services, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode services: %w", err)
+	}
+	
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	
+	tmp, err := os.CreateTemp(dir, filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to replace state file: %w", err)
+	}
+	
+	return nil
+}
+
+// Load returns the stored services. A missing file holds no services.
+func (s *FileStore) Load() ([]*Service, error) {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+	
+	var services []*Service
+	if err := json.Unmarshal(data, &services); err != nil {
+		return nil, fmt.Errorf("failed to decode state file %s: %w", s.path, err)
+	}
+	return services, nil
+}
+
+// SetStore sets the store persisting the locally registered services,
+// replacing the one from service_mesh.state_file. It must be called before
+// Start.
+func (m *Manager) SetStore(store Store) {
+	m.store = store
+}
+
+// persistLocked saves the locally registered services. Draining instances
+// are left out: they are on their way out and must not come back after a
+// restart. Failures are logged rather than failing the registration, which
+// the backend already accepted. Callers must hold m.mu.
+func (m *Manager) persistLocked() {
+	if m.store == nil {
+		return
+	}
+	
+	services := make([]*Service, 0, len(m.services))
+	for _, service := range m.services {
+		if service.Status != StatusDraining {
+			services = append(services, service)
+		}
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].ID < services[j].ID })
+	
+	if err := m.store.Save(services); err != nil {
+		m.log.Errorf("Failed to persist registered services: %v", err)
+	}
+}
+
+// restore re-registers the services persisted by a previous run with the
+// discovery backend. Services the backend rejects are kept anyway, so that
+// the discovery sync retries them.
+func (m *Manager) restore() error {
+	if m.store == nil {
+		return nil
+	}
+	
+	services, err := m.store.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load persisted services: %w", err)
+	}
+	
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	restored := 0
+	for _, service := range services {
+		if service == nil || service.ID == "" {
+			continue
+		}
+		if _, exists := m.services[service.ID]; exists {
+			continue
+		}
+		
+		service.Status = StatusUnknown
+		service.Stale = false
+		if err := m.discovery.Register(service); err != nil {
+			m.log.Warnf("Failed to re-register persisted service %s, will retry on sync: %v", service.ID, err)
+		}
+		m.services[service.ID] = service
+		m.recordStatusLocked(service)
+		restored++
+	}
+	
+	if restored > 0 {
+		m.log.Infof("Restored %d persisted services", restored)
+	}
+	
+	return nil
+}
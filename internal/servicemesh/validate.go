@@ -0,0 +1,95 @@
+package servicemesh
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/yourusername/hbf-agent/internal/config"
+)
+
+// RegistrationPolicy enforces organization-specific rules on registrations,
+// e.g. required tags or meta keys, after Service.Validate passed. A non-nil
+// error rejects the registration with ErrInvalidService; a returned
+// *config.ValidationError has its problems reported one by one.
+type RegistrationPolicy func(service *Service) error
+
+// SetRegistrationPolicy sets the policy every registration must pass; nil
+// accepts any valid service
+func (m *Manager) SetRegistrationPolicy(policy RegistrationPolicy) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.policy = policy
+}
+
+// checkTypes lists the HealthCheck types of services
+var checkTypes = map[string]bool{
+	"http": true,
+	"tcp":  true,
+	"udp":  true,
+	"grpc": true,
+}
+
+// Validate checks the fields of a service before it is registered. All
+// problems are reported together in a *config.ValidationError, each
+// prefixed with its field.
+func (s *Service) Validate() error {
+	var problems []string
+	addf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	
+	if strings.TrimSpace(s.Name) == "" {
+		addf("Name: is required")
+	}
+	if s.Port < 1 || s.Port > 65535 {
+		addf("Port: %d is not between 1 and 65535", s.Port)
+	}
+	switch {
+	case s.Address == "":
+		addf("Address: is required")
+	case net.ParseIP(s.Address) == nil && !validHostname(s.Address):
+		addf("Address: %q is neither an IP address nor a hostname", s.Address)
+	}
+	
+	if strategy, ok := s.Meta[StrategyMetaKey]; ok {
+		if err := config.ValidateStrategy(strategy); err != nil {
+			addf("Meta.%s: %v", StrategyMetaKey, err)
+		}
+	}
+	
+	if hc := s.HealthCheck; hc != nil {
+		if hc.Type != "" && !checkTypes[hc.Type] {
+			addf("HealthCheck.Type: unknown type %q, expected http, tcp, udp or grpc", hc.Type)
+		}
+		if hc.Interval < 0 || hc.Timeout < 0 || hc.DeregisterCriticalServiceAfter < 0 {
+			addf("HealthCheck: Interval, Timeout and DeregisterCriticalServiceAfter must not be negative")
+		}
+	}
+	
+	if len(problems) > 0 {
+		return &config.ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// validHostname reports whether name is a DNS hostname: dot-separated
+// labels of letters, digits and inner hyphens, each at most 63 characters
+func validHostname(name string) bool {
+	name = strings.TrimSuffix(name, ".")
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,129 @@
+package servicemesh
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrWatchUnsupported is returned by Watch on discovery backends that can
+// only be polled
+var ErrWatchUnsupported = errors.New("discovery backend does not support watches")
+
+// serviceWatch is a running discovery watch of one service name
+type serviceWatch struct {
+	cancel context.CancelFunc
+}
+
+// wakeWatches makes the discovery loop start watches for newly registered
+// service names
+func (m *Manager) wakeWatches() {
+	select {
+	case m.watchWake <- struct{}{}:
+	default:
+	}
+}
+
+// reconcileWatches starts a discovery watch for every locally registered
+// service name and stops the watches of names no longer registered. While
+// all names are watched the periodic sync only serves as a keepalive; watch
+// errors fall back to polling.
+func (m *Manager) reconcileWatches(ctx context.Context) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	
+	names := make(map[string]bool)
+	for _, service := range m.services {
+		names[service.Name] = true
+	}
+	
+	for name, watch := range m.watches {
+		if !names[name] {
+			watch.cancel()
+			delete(m.watches, name)
+		}
+	}
+	
+	m.watching = !m.watchUnsupported
+	if m.watchUnsupported {
+		return
+	}
+	
+	for name := range names {
+		if _, exists := m.watches[name]; exists {
+			continue
+		}
+		
+		watchCtx, cancel := context.WithCancel(ctx)
+		updates, err := m.discovery.Watch(watchCtx, name)
+		if err != nil {
+			cancel()
+			m.watching = false
+			if errors.Is(err, ErrWatchUnsupported) {
+				m.watchUnsupported = true
+				m.log.Infof("Discovery backend %s cannot be watched, polling every %s",
+					m.config.Discovery.Backend, m.config.Discovery.Interval)
+				return
+			}
+			m.log.Warnf("Failed to watch service %s, polling until the watch can be restarted: %v", name, err)
+			continue
+		}
+		
+		watch := &serviceWatch{cancel: cancel}
+		m.watches[name] = watch
+		go m.consumeWatch(name, watch, updates)
+	}
+}
+
+// stopWatchesLocked cancels all discovery watches. Callers must hold m.mu.
+func (m *Manager) stopWatchesLocked() {
+	for name, watch := range m.watches {
+		watch.cancel()
+		delete(m.watches, name)
+	}
+	m.watching = false
+}
+
+// consumeWatch handles the updates of a watch until its channel closes. The
+// watch is then forgotten so that the next reconcile restarts it, with
+// polling covering the gap.
+func (m *Manager) consumeWatch(name string, watch *serviceWatch, updates <-chan []*Service) {
+	for instances := range updates {
+		m.handleWatchUpdate(name, instances)
+	}
+	
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.watches[name] == watch {
+		delete(m.watches, name)
+		m.watching = false
+		m.log.Debugf("Discovery watch of service %s ended", name)
+	}
+}
+
+// handleWatchUpdate re-registers local instances of a service that the
+// backend no longer lists, e.g. after the backend lost its state
+func (m *Manager) handleWatchUpdate(name string, instances []*Service) {
+	listed := make(map[string]bool, len(instances))
+	for _, instance := range instances {
+		listed[instance.ID] = true
+	}
+	
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	m.log.Debugf("Discovery reports %d instances of service %s", len(instances), name)
+	if m.isLeader != nil && !m.isLeader() {
+		return
+	}
+	
+	for _, service := range m.services {
+		if service.Name != name || listed[service.ID] {
+			continue
+		}
+		
+		m.log.Infof("Service %s is missing from discovery, re-registering", service.ID)
+		if err := m.discovery.Register(service); err != nil {
+			m.log.Warnf("Failed to re-register service %s: %v", service.ID, err)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// Package snapshot defines the exported state of an agent, used for backups
+// and for migrating rules and services to another node
+package snapshot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/yourusername/hbf-agent/internal/firewall"
+	"github.com/yourusername/hbf-agent/internal/health"
+	"github.com/yourusername/hbf-agent/internal/servicemesh"
+)
+
+// Version is the format version of snapshots written by this agent. It is
+// raised when a change would make older agents misread a snapshot; fields
+// added in a compatible way keep it, and unknown fields are ignored.
+const Version = 1
+
+var (
+	// ErrUnsupportedVersion is returned for snapshots of a format version
+	// this agent cannot read
+	ErrUnsupportedVersion = errors.New("unsupported snapshot version")
+	// ErrInvalidSnapshot is returned when items of a snapshot fail
+	// validation; nothing is restored
+	ErrInvalidSnapshot = errors.New("invalid snapshot")
+)
+
+// StateSnapshot is the state of an agent at one point in time
+type StateSnapshot struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	NodeID    string    `json:"node_id"`
+	// Config is the effective configuration with secrets redacted. It is
+	// informational: restore does not apply it.
+	Config json.RawMessage `json:"config,omitempty"`
+	// FirewallRules are the rules added at runtime; rules loaded from the
+	// configuration are part of Config instead
+	FirewallRules []*firewall.Rule       `json:"firewall_rules"`
+	Services      []*servicemesh.Service `json:"services"`
+	// HealthChecks is informational: checks derived from services come
+	// back with the services on restore
+	HealthChecks []*health.Check `json:"health_checks"`
+}
+
+// Decode reads a snapshot, checking that its version is supported
+func Decode(data []byte) (*StateSnapshot, error) {
+	var snap StateSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot: %w", err)
+	}
+	if err := snap.CheckVersion(); err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
+
+// CheckVersion returns ErrUnsupportedVersion unless the snapshot was
+// written in a format version this agent reads
+func (s *StateSnapshot) CheckVersion() error {
+	if s.Version < 1 || s.Version > Version {
+		return fmt.Errorf("%w: %d, expected at most %d", ErrUnsupportedVersion, s.Version, Version)
+	}
+	return nil
+}
+
+// Item kinds of a restore
+const (
+	KindRule    = "rule"
+	KindService = "service"
+)
+
+// Statuses of restored items
+const (
+	// StatusApplied items were added
+	StatusApplied = "applied"
+	// StatusSkipped items were already present or have expired
+	StatusSkipped = "skipped"
+	// StatusInvalid items failed validation
+	StatusInvalid = "invalid"
+	// StatusFailed items could not be applied, which rolled back the restore
+	StatusFailed = "failed"
+	// StatusRolledBack items were applied, then removed when a later item
+	// failed
+	StatusRolledBack = "rolled_back"
+	// StatusNotApplied items were not tried because the restore was aborted
+	StatusNotApplied = "not_applied"
+)
+
+// ItemResult is the outcome of restoring one rule or service
+type ItemResult struct {
+	Kind   string `json:"kind"`
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// RestoreResult reports the outcome of a restore per item. A restore is
+// all or nothing: Applied is false if any item was invalid or failed, and
+// then no item was left applied.
+type RestoreResult struct {
+	Applied bool         `json:"applied"`
+	Items   []ItemResult `json:"items"`
+}
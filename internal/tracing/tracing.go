@@ -0,0 +1,74 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yourusername/hbf-agent/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies spans created by the agent
+const instrumentationName = "github.com/yourusername/hbf-agent"
+
+// Setup installs the global tracer provider exporting spans over OTLP/gRPC
+// to MonitoringConfig.OTLPEndpoint. Without an endpoint the default no-op
+// provider stays in place, so instrumented code costs next to nothing. The
+// W3C trace context propagator is always installed so that incoming
+// traceparent headers are honored.
+//
+// The returned function flushes and shuts the exporter down.
+func Setup(ctx context.Context, cfg config.MonitoringConfig, nodeID string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	
+	if cfg.OTLPEndpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(cfg.OTLPEndpoint)}
+	if cfg.OTLPInsecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	
+	exporter, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+	
+	res := resource.NewSchemaless(
+		attribute.String("service.name", "hbf-agent"),
+		attribute.String("service.instance.id", nodeID),
+	)
+	
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	
+	return provider.Shutdown, nil
+}
+
+// Start starts a span as a child of any span in ctx
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, opts...)
+}
+
+// Fail marks the span as failed with err and returns err
+func Fail(span trace.Span, err error) error {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}